@@ -0,0 +1,3 @@
+ALTER TABLE users ADD COLUMN IF NOT EXISTS otp TEXT;
+
+DROP TABLE IF EXISTS otp_challenges;
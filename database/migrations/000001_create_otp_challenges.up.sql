@@ -0,0 +1,20 @@
+CREATE TABLE IF NOT EXISTS otp_challenges
+(
+    id           SERIAL PRIMARY KEY,
+    user_id      INTEGER     NOT NULL REFERENCES users (id),
+    purpose      TEXT        NOT NULL,
+    target       TEXT        NOT NULL,
+    otp_salt     TEXT        NOT NULL,
+    otp_hash     TEXT        NOT NULL,
+    attempts     INTEGER     NOT NULL DEFAULT 0,
+    max_attempts INTEGER     NOT NULL,
+    expires_at   TIMESTAMPTZ NOT NULL,
+    consumed     BOOLEAN     NOT NULL DEFAULT FALSE,
+    consumed_at  TIMESTAMPTZ,
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS otp_challenges_user_purpose_idx ON otp_challenges (user_id, purpose, created_at DESC);
+
+-- OTPs are no longer kept in plaintext on the users row
+ALTER TABLE users DROP COLUMN IF EXISTS otp;
@@ -0,0 +1,3 @@
+-- accounts can be registered with only a phone number
+ALTER TABLE users ALTER COLUMN email DROP NOT NULL;
+ALTER TABLE users ALTER COLUMN mobilenumber DROP NOT NULL;
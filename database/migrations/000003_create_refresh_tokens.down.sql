@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS refresh_tokens;
+
+ALTER TABLE sessions DROP COLUMN IF EXISTS revoked_at;
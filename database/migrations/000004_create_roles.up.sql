@@ -0,0 +1,66 @@
+CREATE TABLE IF NOT EXISTS roles
+(
+    id          SERIAL PRIMARY KEY,
+    name        TEXT NOT NULL UNIQUE,
+    description TEXT NOT NULL DEFAULT ''
+);
+
+CREATE TABLE IF NOT EXISTS permissions
+(
+    id          SERIAL PRIMARY KEY,
+    name        TEXT NOT NULL UNIQUE,
+    description TEXT NOT NULL DEFAULT ''
+);
+
+CREATE TABLE IF NOT EXISTS role_permissions
+(
+    role_id       INTEGER NOT NULL REFERENCES roles (id),
+    permission_id INTEGER NOT NULL REFERENCES permissions (id),
+    PRIMARY KEY (role_id, permission_id)
+);
+
+CREATE TABLE IF NOT EXISTS user_roles
+(
+    user_id    INTEGER     NOT NULL REFERENCES users (id),
+    role_id    INTEGER     NOT NULL REFERENCES roles (id),
+    created_by INTEGER,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (user_id, role_id)
+);
+
+INSERT INTO roles (name, description)
+VALUES ('customer', 'Shops on the app'),
+       ('admin', 'Runs the platform'),
+       ('vendor', 'Supplies and prices produce'),
+       ('rider', 'Delivers orders')
+ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO permissions (name, description)
+VALUES ('users:read', 'View customer accounts'),
+       ('users:write', 'Change customer accounts'),
+       ('roles:assign', 'Grant and revoke roles'),
+       ('catalog:write', 'Change products and prices'),
+       ('inventory:write', 'Change stock levels'),
+       ('deliveries:manage', 'Pick up and complete deliveries')
+ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO role_permissions (role_id, permission_id)
+SELECT roles.id, permissions.id
+FROM (VALUES ('admin', 'users:read'),
+             ('admin', 'users:write'),
+             ('admin', 'roles:assign'),
+             ('admin', 'catalog:write'),
+             ('admin', 'inventory:write'),
+             ('vendor', 'catalog:write'),
+             ('vendor', 'inventory:write'),
+             ('rider', 'deliveries:manage')) AS grants (role_name, permission_name)
+         JOIN roles ON roles.name = grants.role_name
+         JOIN permissions ON permissions.name = grants.permission_name
+ON CONFLICT DO NOTHING;
+
+-- every existing account is a customer
+INSERT INTO user_roles (user_id, role_id)
+SELECT users.id, roles.id
+FROM users
+         JOIN roles ON roles.name = 'customer'
+ON CONFLICT DO NOTHING;
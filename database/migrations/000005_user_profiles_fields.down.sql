@@ -0,0 +1,8 @@
+DROP INDEX IF EXISTS user_profiles_user_id_idx;
+
+ALTER TABLE user_profiles
+    DROP COLUMN IF EXISTS updated_at,
+    DROP COLUMN IF EXISTS default_address,
+    DROP COLUMN IF EXISTS dietary_preferences,
+    DROP COLUMN IF EXISTS preferred_language,
+    DROP COLUMN IF EXISTS avatar_url;
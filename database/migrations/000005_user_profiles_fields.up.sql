@@ -0,0 +1,10 @@
+ALTER TABLE user_profiles
+    ADD COLUMN IF NOT EXISTS avatar_url          TEXT,
+    ADD COLUMN IF NOT EXISTS preferred_language  TEXT   NOT NULL DEFAULT 'en',
+    ADD COLUMN IF NOT EXISTS dietary_preferences TEXT[] NOT NULL DEFAULT '{}',
+    ADD COLUMN IF NOT EXISTS default_address     JSONB,
+    ADD COLUMN IF NOT EXISTS updated_at          TIMESTAMPTZ;
+
+CREATE UNIQUE INDEX IF NOT EXISTS user_profiles_user_id_idx ON user_profiles (user_id);
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS updated_at TIMESTAMPTZ;
@@ -0,0 +1,9 @@
+DROP INDEX IF EXISTS users_pending_purge_idx;
+
+ALTER TABLE users
+    DROP COLUMN IF EXISTS anonymized_at,
+    DROP COLUMN IF EXISTS updated_by_admin,
+    DROP COLUMN IF EXISTS updated_by,
+    DROP COLUMN IF EXISTS deactivated_at,
+    DROP COLUMN IF EXISTS deleted_by_admin,
+    DROP COLUMN IF EXISTS archived_by;
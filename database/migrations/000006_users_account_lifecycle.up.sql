@@ -0,0 +1,9 @@
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS archived_by      INTEGER,
+    ADD COLUMN IF NOT EXISTS deleted_by_admin BOOLEAN NOT NULL DEFAULT FALSE,
+    ADD COLUMN IF NOT EXISTS deactivated_at   TIMESTAMPTZ,
+    ADD COLUMN IF NOT EXISTS updated_by       INTEGER,
+    ADD COLUMN IF NOT EXISTS updated_by_admin BOOLEAN NOT NULL DEFAULT FALSE,
+    ADD COLUMN IF NOT EXISTS anonymized_at    TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS users_pending_purge_idx ON users (archived_at) WHERE anonymized_at IS NULL;
@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS data_exports
+(
+    id                  SERIAL PRIMARY KEY,
+    user_id             INTEGER     NOT NULL REFERENCES users (id),
+    status              TEXT        NOT NULL DEFAULT 'queued',
+    file_path           TEXT,
+    download_token_hash TEXT UNIQUE,
+    expires_at          TIMESTAMPTZ,
+    downloaded_at       TIMESTAMPTZ,
+    error               TEXT,
+    created_at          TIMESTAMPTZ NOT NULL DEFAULT now(),
+    completed_at        TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS data_exports_status_idx ON data_exports (status, created_at);
+CREATE INDEX IF NOT EXISTS data_exports_user_idx ON data_exports (user_id);
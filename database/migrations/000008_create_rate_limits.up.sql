@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS rate_limit_hits
+(
+    key    TEXT        NOT NULL,
+    hit_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS rate_limit_hits_key_idx ON rate_limit_hits (key, hit_at);
+
+CREATE TABLE IF NOT EXISTS rate_limit_lockouts
+(
+    key               TEXT PRIMARY KEY,
+    locked_until      TIMESTAMPTZ NOT NULL,
+    violations        INTEGER     NOT NULL DEFAULT 0,
+    last_violation_at TIMESTAMPTZ NOT NULL
+);
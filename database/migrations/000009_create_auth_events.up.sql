@@ -0,0 +1,21 @@
+CREATE TABLE IF NOT EXISTS auth_events
+(
+    id         SERIAL PRIMARY KEY,
+    user_id    INTEGER REFERENCES users (id) ON DELETE SET NULL,
+    event_type TEXT        NOT NULL,
+    outcome    TEXT        NOT NULL,
+    reason     TEXT        NOT NULL DEFAULT '',
+    identifier TEXT        NOT NULL DEFAULT '',
+    ip         TEXT        NOT NULL DEFAULT '',
+    user_agent TEXT        NOT NULL DEFAULT '',
+    platform   TEXT        NOT NULL DEFAULT '',
+    model_name TEXT        NOT NULL DEFAULT '',
+    os_version TEXT        NOT NULL DEFAULT '',
+    device_id  TEXT        NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS auth_events_user_id_idx ON auth_events (user_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS auth_events_ip_idx ON auth_events (ip, created_at DESC);
+CREATE INDEX IF NOT EXISTS auth_events_device_id_idx ON auth_events (device_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS auth_events_created_at_idx ON auth_events (created_at DESC);
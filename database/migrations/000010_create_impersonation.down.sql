@@ -0,0 +1,10 @@
+DELETE FROM role_permissions
+WHERE permission_id IN (SELECT id FROM permissions WHERE name = 'users:impersonate');
+
+DELETE FROM permissions
+WHERE name = 'users:impersonate';
+
+DROP TABLE IF EXISTS impersonation_requests;
+
+ALTER TABLE sessions
+    DROP COLUMN IF EXISTS impersonated_by;
@@ -0,0 +1,29 @@
+ALTER TABLE sessions
+    ADD COLUMN IF NOT EXISTS impersonated_by INTEGER REFERENCES users (id);
+
+CREATE TABLE IF NOT EXISTS impersonation_requests
+(
+    id          SERIAL PRIMARY KEY,
+    session_id  TEXT        NOT NULL,
+    actor_id    INTEGER     NOT NULL REFERENCES users (id),
+    user_id     INTEGER     NOT NULL REFERENCES users (id),
+    method      TEXT        NOT NULL,
+    path        TEXT        NOT NULL,
+    status_code INTEGER     NOT NULL,
+    ip          TEXT        NOT NULL DEFAULT '',
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS impersonation_requests_actor_id_idx ON impersonation_requests (actor_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS impersonation_requests_user_id_idx ON impersonation_requests (user_id, created_at DESC);
+
+INSERT INTO permissions (name, description)
+VALUES ('users:impersonate', 'Act as another user for support')
+ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO role_permissions (role_id, permission_id)
+SELECT roles.id, permissions.id
+FROM roles
+         JOIN permissions ON permissions.name = 'users:impersonate'
+WHERE roles.name = 'admin'
+ON CONFLICT DO NOTHING;
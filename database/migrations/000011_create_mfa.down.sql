@@ -0,0 +1,5 @@
+ALTER TABLE sessions
+    DROP COLUMN IF EXISTS mfa_satisfied_at;
+
+DROP TABLE IF EXISTS mfa_recovery_codes;
+DROP TABLE IF EXISTS user_mfa;
@@ -0,0 +1,22 @@
+CREATE TABLE IF NOT EXISTS user_mfa
+(
+    user_id          INTEGER PRIMARY KEY REFERENCES users (id) ON DELETE CASCADE,
+    secret_encrypted TEXT        NOT NULL,
+    last_used_step   BIGINT      NOT NULL DEFAULT 0,
+    confirmed_at     TIMESTAMPTZ,
+    created_at       TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE TABLE IF NOT EXISTS mfa_recovery_codes
+(
+    id         SERIAL PRIMARY KEY,
+    user_id    INTEGER     NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    code_hash  TEXT        NOT NULL,
+    used_at    TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS mfa_recovery_codes_user_id_idx ON mfa_recovery_codes (user_id) WHERE used_at IS NULL;
+
+ALTER TABLE sessions
+    ADD COLUMN IF NOT EXISTS mfa_satisfied_at TIMESTAMPTZ;
@@ -0,0 +1,7 @@
+DROP TABLE IF EXISTS api_keys;
+
+DELETE FROM user_roles
+WHERE role_id IN (SELECT id FROM roles WHERE name = 'partner');
+
+DELETE FROM roles
+WHERE name = 'partner';
@@ -0,0 +1,22 @@
+INSERT INTO roles (name, description)
+VALUES ('partner', 'Business buyer calling the API with keys')
+ON CONFLICT (name) DO NOTHING;
+
+CREATE TABLE IF NOT EXISTS api_keys
+(
+    id                    SERIAL PRIMARY KEY,
+    user_id               INTEGER     NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    name                  TEXT        NOT NULL,
+    prefix                TEXT        NOT NULL UNIQUE,
+    key_hash              TEXT        NOT NULL,
+    scopes                TEXT[]      NOT NULL DEFAULT '{}',
+    rate_limit_per_minute INTEGER     NOT NULL,
+    rotated_from          INTEGER REFERENCES api_keys (id),
+    last_used_at          TIMESTAMPTZ,
+    expires_at            TIMESTAMPTZ,
+    revoked_at            TIMESTAMPTZ,
+    created_by            INTEGER REFERENCES users (id),
+    created_at            TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS api_keys_user_id_idx ON api_keys (user_id);
@@ -0,0 +1,26 @@
+CREATE TABLE IF NOT EXISTS oidc_login_states
+(
+    state_hash    TEXT PRIMARY KEY,
+    provider      TEXT        NOT NULL,
+    nonce         TEXT        NOT NULL,
+    code_verifier TEXT        NOT NULL,
+    platform      TEXT        NOT NULL DEFAULT '',
+    model_name    TEXT        NOT NULL DEFAULT '',
+    os_version    TEXT        NOT NULL DEFAULT '',
+    device_id     TEXT        NOT NULL DEFAULT '',
+    expires_at    TIMESTAMPTZ NOT NULL,
+    created_at    TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE TABLE IF NOT EXISTS user_identities
+(
+    id         SERIAL PRIMARY KEY,
+    user_id    INTEGER     NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    provider   TEXT        NOT NULL,
+    subject    TEXT        NOT NULL,
+    email      TEXT        NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    UNIQUE (provider, subject)
+);
+
+CREATE INDEX IF NOT EXISTS user_identities_user_id_idx ON user_identities (user_id);
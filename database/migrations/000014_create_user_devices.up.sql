@@ -0,0 +1,28 @@
+CREATE TABLE IF NOT EXISTS user_devices
+(
+    id            SERIAL PRIMARY KEY,
+    user_id       INTEGER     NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    device_key    TEXT        NOT NULL,
+    name          TEXT        NOT NULL DEFAULT '',
+    platform      TEXT        NOT NULL DEFAULT '',
+    model_name    TEXT        NOT NULL DEFAULT '',
+    os_version    TEXT        NOT NULL DEFAULT '',
+    device_id     TEXT        NOT NULL DEFAULT '',
+    last_ip       TEXT        NOT NULL DEFAULT '',
+    first_seen_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    last_seen_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+    UNIQUE (user_id, device_key)
+);
+
+CREATE TABLE IF NOT EXISTS device_alerts
+(
+    id         SERIAL PRIMARY KEY,
+    user_id    INTEGER     NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    session_id INTEGER     NOT NULL REFERENCES sessions (id) ON DELETE CASCADE,
+    device_key TEXT        NOT NULL,
+    token_hash TEXT        NOT NULL UNIQUE,
+    ip         TEXT        NOT NULL DEFAULT '',
+    expires_at TIMESTAMPTZ NOT NULL,
+    used_at    TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
@@ -0,0 +1,9 @@
+DELETE FROM role_permissions
+WHERE permission_id IN (SELECT id FROM permissions WHERE name = 'policies:write');
+
+DELETE FROM permissions
+WHERE name = 'policies:write';
+
+DROP TABLE IF EXISTS marketing_consents;
+DROP TABLE IF EXISTS user_consents;
+DROP TABLE IF EXISTS policy_documents;
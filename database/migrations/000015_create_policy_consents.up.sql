@@ -0,0 +1,51 @@
+CREATE TABLE IF NOT EXISTS policy_documents
+(
+    id           SERIAL PRIMARY KEY,
+    kind         TEXT        NOT NULL,
+    version      TEXT        NOT NULL,
+    title        TEXT        NOT NULL,
+    url          TEXT        NOT NULL DEFAULT '',
+    content      TEXT        NOT NULL DEFAULT '',
+    mandatory    BOOLEAN     NOT NULL DEFAULT TRUE,
+    published_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    created_by   INTEGER REFERENCES users (id),
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT now(),
+    UNIQUE (kind, version)
+);
+
+CREATE INDEX IF NOT EXISTS policy_documents_kind_idx ON policy_documents (kind, published_at DESC);
+
+CREATE TABLE IF NOT EXISTS user_consents
+(
+    id                 SERIAL PRIMARY KEY,
+    user_id            INTEGER     NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    policy_document_id INTEGER     NOT NULL REFERENCES policy_documents (id),
+    ip                 TEXT        NOT NULL DEFAULT '',
+    user_agent         TEXT        NOT NULL DEFAULT '',
+    accepted_at        TIMESTAMPTZ NOT NULL DEFAULT now(),
+    UNIQUE (user_id, policy_document_id)
+);
+
+-- every change is kept, the latest row is the user's current choice
+CREATE TABLE IF NOT EXISTS marketing_consents
+(
+    id         SERIAL PRIMARY KEY,
+    user_id    INTEGER     NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    opted_in   BOOLEAN     NOT NULL,
+    ip         TEXT        NOT NULL DEFAULT '',
+    user_agent TEXT        NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS marketing_consents_user_id_idx ON marketing_consents (user_id, created_at DESC);
+
+INSERT INTO permissions (name, description)
+VALUES ('policies:write', 'Publish terms of service and privacy policy versions')
+ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO role_permissions (role_id, permission_id)
+SELECT roles.id, permissions.id
+FROM roles
+         JOIN permissions ON permissions.name = 'policies:write'
+WHERE roles.name = 'admin'
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,59 @@
+-- base_quantity is how many base units (grams, pieces or bunches) one unit holds
+CREATE TABLE IF NOT EXISTS units
+(
+    id            SERIAL PRIMARY KEY,
+    code          TEXT        NOT NULL UNIQUE,
+    name          TEXT        NOT NULL,
+    base_unit     TEXT        NOT NULL,
+    base_quantity INTEGER     NOT NULL CHECK (base_quantity > 0),
+    created_at    TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+INSERT INTO units (code, name, base_unit, base_quantity)
+VALUES ('kg', '1 kg', 'g', 1000),
+       ('250g', '250 g', 'g', 250),
+       ('dozen', 'Dozen', 'piece', 12),
+       ('bunch', 'Bunch', 'bunch', 1),
+       ('piece', 'Piece', 'piece', 1)
+ON CONFLICT (code) DO NOTHING;
+
+CREATE TABLE IF NOT EXISTS categories
+(
+    id          SERIAL PRIMARY KEY,
+    parent_id   INTEGER REFERENCES categories (id),
+    name        TEXT        NOT NULL,
+    slug        TEXT        NOT NULL,
+    description TEXT        NOT NULL DEFAULT '',
+    image_url   TEXT        NOT NULL DEFAULT '',
+    sort_order  INTEGER     NOT NULL DEFAULT 0,
+    is_active   BOOLEAN     NOT NULL DEFAULT TRUE,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+    archived_at TIMESTAMPTZ
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS categories_slug_idx ON categories (slug) WHERE archived_at IS NULL;
+
+-- prices are in paise so they never go through floating point
+CREATE TABLE IF NOT EXISTS products
+(
+    id             SERIAL PRIMARY KEY,
+    category_id    INTEGER     NOT NULL REFERENCES categories (id),
+    unit_id        INTEGER     NOT NULL REFERENCES units (id),
+    name           TEXT        NOT NULL,
+    slug           TEXT        NOT NULL,
+    description    TEXT        NOT NULL DEFAULT '',
+    image_url      TEXT        NOT NULL DEFAULT '',
+    mrp_paise      BIGINT      NOT NULL CHECK (mrp_paise >= 0),
+    price_paise    BIGINT      NOT NULL CHECK (price_paise >= 0),
+    stock_quantity INTEGER     NOT NULL DEFAULT 0 CHECK (stock_quantity >= 0),
+    is_active      BOOLEAN     NOT NULL DEFAULT TRUE,
+    created_by     INTEGER REFERENCES users (id),
+    created_at     TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at     TIMESTAMPTZ NOT NULL DEFAULT now(),
+    archived_at    TIMESTAMPTZ,
+    CHECK (price_paise <= mrp_paise)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS products_slug_idx ON products (slug) WHERE archived_at IS NULL;
+CREATE INDEX IF NOT EXISTS products_category_id_idx ON products (category_id) WHERE archived_at IS NULL;
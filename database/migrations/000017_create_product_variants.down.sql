@@ -0,0 +1,26 @@
+ALTER TABLE products
+    ADD COLUMN IF NOT EXISTS unit_id        INTEGER REFERENCES units (id),
+    ADD COLUMN IF NOT EXISTS mrp_paise      BIGINT  NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS price_paise    BIGINT  NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS stock_quantity INTEGER NOT NULL DEFAULT 0;
+
+-- products keep the price and stock of their first pack
+UPDATE products
+SET unit_id        = first_variant.unit_id,
+    mrp_paise      = first_variant.mrp_paise,
+    price_paise    = first_variant.price_paise,
+    stock_quantity = first_variant.stock_quantity
+FROM (SELECT DISTINCT ON (product_id) product_id, unit_id, mrp_paise, price_paise, stock_quantity
+      FROM product_variants
+      ORDER BY product_id, archived_at IS NOT NULL, sort_order, id) first_variant
+WHERE first_variant.product_id = products.id;
+
+UPDATE products
+SET unit_id = (SELECT id FROM units WHERE code = 'piece')
+WHERE unit_id IS NULL;
+
+ALTER TABLE products
+    ALTER COLUMN unit_id SET NOT NULL,
+    ADD CHECK (price_paise <= mrp_paise);
+
+DROP TABLE IF EXISTS product_variants;
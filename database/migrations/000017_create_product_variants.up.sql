@@ -0,0 +1,36 @@
+-- pack_size counts units, a 500 g pack is 0.5 of the kg unit or 2 of the 250g unit
+CREATE TABLE IF NOT EXISTS product_variants
+(
+    id             SERIAL PRIMARY KEY,
+    product_id     INTEGER        NOT NULL REFERENCES products (id),
+    sku            TEXT           NOT NULL,
+    pack_size      NUMERIC(10, 3) NOT NULL DEFAULT 1 CHECK (pack_size > 0),
+    unit_id        INTEGER        NOT NULL REFERENCES units (id),
+    mrp_paise      BIGINT         NOT NULL CHECK (mrp_paise >= 0),
+    price_paise    BIGINT         NOT NULL CHECK (price_paise >= 0),
+    barcode        TEXT           NOT NULL DEFAULT '',
+    stock_quantity INTEGER        NOT NULL DEFAULT 0,
+    sort_order     INTEGER        NOT NULL DEFAULT 0,
+    is_active      BOOLEAN        NOT NULL DEFAULT TRUE,
+    created_at     TIMESTAMPTZ    NOT NULL DEFAULT now(),
+    updated_at     TIMESTAMPTZ    NOT NULL DEFAULT now(),
+    archived_at    TIMESTAMPTZ,
+    CONSTRAINT product_variants_price_check CHECK (price_paise <= mrp_paise),
+    CONSTRAINT product_variants_stock_check CHECK (stock_quantity >= 0)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS product_variants_sku_idx ON product_variants (sku) WHERE archived_at IS NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS product_variants_barcode_idx ON product_variants (barcode) WHERE archived_at IS NULL AND barcode <> '';
+CREATE INDEX IF NOT EXISTS product_variants_product_id_idx ON product_variants (product_id) WHERE archived_at IS NULL;
+
+-- every existing product becomes a single pack of its old unit
+INSERT INTO product_variants
+(product_id, sku, pack_size, unit_id, mrp_paise, price_paise, stock_quantity, created_at, updated_at, archived_at)
+SELECT id, 'SKU-' || id, 1, unit_id, mrp_paise, price_paise, stock_quantity, created_at, updated_at, archived_at
+FROM products;
+
+ALTER TABLE products
+    DROP COLUMN IF EXISTS unit_id,
+    DROP COLUMN IF EXISTS mrp_paise,
+    DROP COLUMN IF EXISTS price_paise,
+    DROP COLUMN IF EXISTS stock_quantity;
@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS products_name_fts_idx;
+DROP INDEX IF EXISTS products_name_trgm_idx;
+
+ALTER TABLE products
+    DROP COLUMN IF EXISTS popularity;
+
+DROP TABLE IF EXISTS product_aliases;
@@ -0,0 +1,21 @@
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+-- other names a product is searched by, such as "bhindi" and "भिंडी" for okra
+CREATE TABLE IF NOT EXISTS product_aliases
+(
+    id         SERIAL PRIMARY KEY,
+    product_id INTEGER     NOT NULL REFERENCES products (id) ON DELETE CASCADE,
+    name       TEXT        NOT NULL,
+    language   TEXT        NOT NULL DEFAULT 'en',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    UNIQUE (product_id, name)
+);
+
+-- popularity counts how often customers open the product, search ranks popular products higher
+ALTER TABLE products
+    ADD COLUMN IF NOT EXISTS popularity INTEGER NOT NULL DEFAULT 0;
+
+CREATE INDEX IF NOT EXISTS products_name_trgm_idx ON products USING GIN (lower(name) gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS products_name_fts_idx ON products USING GIN (to_tsvector('simple', name));
+CREATE INDEX IF NOT EXISTS product_aliases_name_trgm_idx ON product_aliases USING GIN (lower(name) gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS product_aliases_name_fts_idx ON product_aliases USING GIN (to_tsvector('simple', name));
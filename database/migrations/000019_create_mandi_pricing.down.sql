@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS price_history;
+DROP TABLE IF EXISTS pricing_rules;
+DROP TABLE IF EXISTS mandi_prices;
@@ -0,0 +1,50 @@
+-- wholesale rates as published by the mandi, modal_price_paise is quoted per unit_code
+CREATE TABLE IF NOT EXISTS mandi_prices
+(
+    id                SERIAL PRIMARY KEY,
+    price_date        DATE        NOT NULL,
+    commodity         TEXT        NOT NULL,
+    market            TEXT        NOT NULL DEFAULT '',
+    unit_code         TEXT        NOT NULL,
+    base_unit         TEXT        NOT NULL,
+    base_quantity     INTEGER     NOT NULL CHECK (base_quantity > 0),
+    modal_price_paise BIGINT      NOT NULL CHECK (modal_price_paise > 0),
+    imported_by       INTEGER REFERENCES users (id),
+    created_at        TIMESTAMPTZ NOT NULL DEFAULT now(),
+    UNIQUE (price_date, commodity, market)
+);
+
+CREATE INDEX IF NOT EXISTS mandi_prices_commodity_idx ON mandi_prices (commodity, price_date DESC);
+
+-- floor_paise and ceiling_paise are per one unit_id, for example per kg
+CREATE TABLE IF NOT EXISTS pricing_rules
+(
+    id             SERIAL PRIMARY KEY,
+    product_id     INTEGER       NOT NULL UNIQUE REFERENCES products (id) ON DELETE CASCADE,
+    commodity      TEXT          NOT NULL,
+    market         TEXT          NOT NULL DEFAULT '',
+    markup_percent NUMERIC(6, 2) NOT NULL CHECK (markup_percent >= 0),
+    rounding_paise INTEGER       NOT NULL DEFAULT 100 CHECK (rounding_paise > 0),
+    unit_id        INTEGER       NOT NULL REFERENCES units (id),
+    floor_paise    BIGINT CHECK (floor_paise >= 0),
+    ceiling_paise  BIGINT CHECK (ceiling_paise >= 0),
+    is_active      BOOLEAN       NOT NULL DEFAULT TRUE,
+    created_by     INTEGER REFERENCES users (id),
+    created_at     TIMESTAMPTZ   NOT NULL DEFAULT now(),
+    updated_at     TIMESTAMPTZ   NOT NULL DEFAULT now(),
+    CHECK (floor_paise IS NULL OR ceiling_paise IS NULL OR floor_paise <= ceiling_paise)
+);
+
+CREATE TABLE IF NOT EXISTS price_history
+(
+    id               SERIAL PRIMARY KEY,
+    variant_id       INTEGER     NOT NULL REFERENCES product_variants (id),
+    old_price_paise  BIGINT      NOT NULL,
+    new_price_paise  BIGINT      NOT NULL,
+    source           TEXT        NOT NULL,
+    mandi_price_date DATE,
+    changed_by       INTEGER REFERENCES users (id),
+    created_at       TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS price_history_variant_id_idx ON price_history (variant_id, created_at DESC);
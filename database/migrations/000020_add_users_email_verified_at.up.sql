@@ -0,0 +1,2 @@
+-- set once the owner of the email proved it, by an email OTP or a verified identity provider email
+ALTER TABLE users ADD COLUMN IF NOT EXISTS email_verified_at TIMESTAMPTZ;
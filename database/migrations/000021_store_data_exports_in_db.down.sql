@@ -0,0 +1,6 @@
+ALTER TABLE data_exports ADD COLUMN IF NOT EXISTS file_path TEXT;
+
+UPDATE data_exports SET status = 'expired' WHERE status = 'ready';
+
+ALTER TABLE data_exports DROP COLUMN IF EXISTS claimed_at;
+ALTER TABLE data_exports DROP COLUMN IF EXISTS archive;
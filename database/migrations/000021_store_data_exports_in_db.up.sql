@@ -0,0 +1,9 @@
+-- archives live in the database so any replica can serve the link, not only the one that built it
+ALTER TABLE data_exports ADD COLUMN archive BYTEA;
+ALTER TABLE data_exports ADD COLUMN claimed_at TIMESTAMPTZ;
+
+-- archives written to a replica's disk can not be served any more
+UPDATE data_exports SET status = 'expired' WHERE status = 'ready';
+UPDATE data_exports SET status = 'queued' WHERE status = 'processing';
+
+ALTER TABLE data_exports DROP COLUMN IF EXISTS file_path;
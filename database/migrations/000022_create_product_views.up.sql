@@ -0,0 +1,10 @@
+-- one row per customer, product and day, so reopening a product does not make it more popular
+CREATE TABLE IF NOT EXISTS product_views
+(
+    product_id INTEGER NOT NULL REFERENCES products (id) ON DELETE CASCADE,
+    user_id    INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    viewed_on  DATE    NOT NULL DEFAULT current_date,
+    PRIMARY KEY (product_id, user_id, viewed_on)
+);
+
+CREATE INDEX IF NOT EXISTS product_views_viewed_on_idx ON product_views (viewed_on);
@@ -0,0 +1 @@
+-- 000017 left the constraint with this same default name, there is nothing to undo
@@ -0,0 +1,4 @@
+-- named so a pack size of zero is reported as such instead of as a bad price
+ALTER TABLE product_variants
+    DROP CONSTRAINT IF EXISTS product_variants_pack_size_check,
+    ADD CONSTRAINT product_variants_pack_size_check CHECK (pack_size > 0);
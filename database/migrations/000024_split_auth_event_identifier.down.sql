@@ -0,0 +1,12 @@
+ALTER TABLE auth_events
+    ADD COLUMN IF NOT EXISTS identifier TEXT NOT NULL DEFAULT '';
+
+UPDATE auth_events
+SET identifier = email || phone;
+
+DROP INDEX IF EXISTS auth_events_email_idx;
+DROP INDEX IF EXISTS auth_events_phone_idx;
+
+ALTER TABLE auth_events
+    DROP COLUMN IF EXISTS email,
+    DROP COLUMN IF EXISTS phone;
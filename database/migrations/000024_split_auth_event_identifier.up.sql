@@ -0,0 +1,19 @@
+-- the email and E.164 phone an event was for, kept apart so either can be searched and purged exactly
+ALTER TABLE auth_events
+    ADD COLUMN IF NOT EXISTS email TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS phone TEXT NOT NULL DEFAULT '';
+
+-- identifier held an email, a phone number or both run together
+UPDATE auth_events
+SET phone = COALESCE(substring(identifier FROM '\+[0-9]{8,15}$'), '')
+WHERE identifier <> '';
+
+UPDATE auth_events
+SET email = lower(left(identifier, length(identifier) - length(phone)))
+WHERE identifier LIKE '%@%';
+
+ALTER TABLE auth_events
+    DROP COLUMN IF EXISTS identifier;
+
+CREATE INDEX IF NOT EXISTS auth_events_email_idx ON auth_events (email, created_at DESC) WHERE email <> '';
+CREATE INDEX IF NOT EXISTS auth_events_phone_idx ON auth_events (phone, created_at DESC) WHERE phone <> '';
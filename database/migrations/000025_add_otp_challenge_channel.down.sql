@@ -0,0 +1,2 @@
+ALTER TABLE otp_challenges
+    DROP COLUMN IF EXISTS channel;
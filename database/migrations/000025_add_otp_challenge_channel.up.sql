@@ -0,0 +1,7 @@
+-- how a challenge was delivered, a login only accepts a code sent to the channel it is logging in with
+ALTER TABLE otp_challenges
+    ADD COLUMN IF NOT EXISTS channel TEXT NOT NULL DEFAULT '';
+
+UPDATE otp_challenges
+SET channel = CASE WHEN target LIKE '%@%' THEN 'email' ELSE 'phone' END
+WHERE channel = '';
@@ -0,0 +1,32 @@
+package models
+
+import (
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/volatiletech/null"
+)
+
+type APIKey struct {
+	ID                 int            `json:"id" db:"id"`
+	UserID             int            `json:"userId" db:"user_id"`
+	Name               string         `json:"name" db:"name"`
+	Prefix             string         `json:"prefix" db:"prefix"`
+	KeyHash            string         `json:"-" db:"key_hash"`
+	Scopes             pq.StringArray `json:"scopes" db:"scopes"`
+	RateLimitPerMinute int            `json:"rateLimitPerMinute" db:"rate_limit_per_minute"`
+	RotatedFrom        null.Int       `json:"rotatedFrom" db:"rotated_from"`
+	LastUsedAt         null.Time      `json:"lastUsedAt" db:"last_used_at"`
+	ExpiresAt          null.Time      `json:"expiresAt" db:"expires_at"`
+	RevokedAt          null.Time      `json:"revokedAt" db:"revoked_at"`
+	CreatedBy          null.Int       `json:"createdBy" db:"created_by"`
+	CreatedAt          time.Time      `json:"createdAt" db:"created_at"`
+}
+
+type CreateAPIKeyRequest struct {
+	Name               string        `json:"name"`
+	Scopes             []APIKeyScope `json:"scopes"`
+	RateLimitPerMinute int           `json:"rateLimitPerMinute"`
+	UserID             int           `json:"-"`
+	CreatedBy          int           `json:"-"`
+}
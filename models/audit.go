@@ -0,0 +1,37 @@
+package models
+
+import (
+	"time"
+
+	"github.com/volatiletech/null"
+)
+
+// AuthEvent is one row of the authentication audit log. Email and Phone hold the lower cased email and
+// E.164 number a failed attempt was made for, so attempts against accounts can be found before a user id is known.
+type AuthEvent struct {
+	ID        int              `json:"id" db:"id"`
+	UserID    null.Int         `json:"userId" db:"user_id"`
+	EventType AuthEventType    `json:"eventType" db:"event_type"`
+	Outcome   AuthEventOutcome `json:"outcome" db:"outcome"`
+	Reason    string           `json:"reason" db:"reason"`
+	Email     string           `json:"email" db:"email"`
+	Phone     string           `json:"phone" db:"phone"`
+	IP        string           `json:"ip" db:"ip"`
+	UserAgent string           `json:"userAgent" db:"user_agent"`
+	CreateSessionRequest
+	CreatedAt time.Time `json:"createdAt" db:"created_at"`
+}
+
+type AuthEventFilter struct {
+	UserID    null.Int
+	EventType AuthEventType
+	Outcome   AuthEventOutcome
+	Email     string
+	Phone     string
+	IP        string
+	DeviceID  string
+	From      null.Time
+	To        null.Time
+	Limit     int
+	Offset    int
+}
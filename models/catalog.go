@@ -0,0 +1,182 @@
+package models
+
+import (
+	"time"
+
+	"github.com/volatiletech/null"
+)
+
+// Unit is how a product is sold, BaseQuantity counts BaseUnit (g, piece or bunch) in one unit.
+type Unit struct {
+	ID           int    `json:"id" db:"id"`
+	Code         string `json:"code" db:"code"`
+	Name         string `json:"name" db:"name"`
+	BaseUnit     string `json:"baseUnit" db:"base_unit"`
+	BaseQuantity int    `json:"baseQuantity" db:"base_quantity"`
+}
+
+type CreateUnitRequest struct {
+	Code         string `json:"code"`
+	Name         string `json:"name"`
+	BaseUnit     string `json:"baseUnit"`
+	BaseQuantity int    `json:"baseQuantity"`
+}
+
+type Category struct {
+	ID          int       `json:"id" db:"id"`
+	ParentID    null.Int  `json:"parentId" db:"parent_id"`
+	Name        string    `json:"name" db:"name"`
+	Slug        string    `json:"slug" db:"slug"`
+	Description string    `json:"description" db:"description"`
+	ImageURL    string    `json:"imageUrl" db:"image_url"`
+	SortOrder   int       `json:"sortOrder" db:"sort_order"`
+	IsActive    bool      `json:"isActive" db:"is_active"`
+	CreatedAt   time.Time `json:"createdAt" db:"created_at"`
+	UpdatedAt   time.Time `json:"updatedAt" db:"updated_at"`
+}
+
+type CreateCategoryRequest struct {
+	ParentID    null.Int  `json:"parentId"`
+	Name        string    `json:"name"`
+	Slug        string    `json:"slug"`
+	Description string    `json:"description"`
+	ImageURL    string    `json:"imageUrl"`
+	SortOrder   int       `json:"sortOrder"`
+	IsActive    null.Bool `json:"isActive"`
+}
+
+type UpdateCategoryRequest struct {
+	ParentID    null.Int    `json:"parentId"`
+	Name        null.String `json:"name"`
+	Slug        null.String `json:"slug"`
+	Description null.String `json:"description"`
+	ImageURL    null.String `json:"imageUrl"`
+	SortOrder   null.Int    `json:"sortOrder"`
+	IsActive    null.Bool   `json:"isActive"`
+}
+
+// Product is what customers browse, prices and stock live on its variants. PricePaise is the lowest
+// selling price of its active variants and InStock tells whether any of them can be ordered.
+type Product struct {
+	ID           int              `json:"id" db:"id"`
+	CategoryID   int              `json:"categoryId" db:"category_id"`
+	CategoryName string           `json:"categoryName" db:"category_name"`
+	CategorySlug string           `json:"categorySlug" db:"category_slug"`
+	Name         string           `json:"name" db:"name"`
+	Slug         string           `json:"slug" db:"slug"`
+	Description  string           `json:"description" db:"description"`
+	ImageURL     string           `json:"imageUrl" db:"image_url"`
+	PricePaise   int64            `json:"pricePaise" db:"price_paise"`
+	InStock      bool             `json:"inStock" db:"in_stock"`
+	IsActive     bool             `json:"isActive" db:"is_active"`
+	CreatedAt    time.Time        `json:"createdAt" db:"created_at"`
+	UpdatedAt    time.Time        `json:"updatedAt" db:"updated_at"`
+	Variants     []ProductVariant `json:"variants,omitempty" db:"-"`
+	Aliases      []ProductAlias   `json:"aliases,omitempty" db:"-"`
+}
+
+type CreateProductRequest struct {
+	CategoryID  int                    `json:"categoryId"`
+	Name        string                 `json:"name"`
+	Slug        string                 `json:"slug"`
+	Description string                 `json:"description"`
+	ImageURL    string                 `json:"imageUrl"`
+	IsActive    null.Bool              `json:"isActive"`
+	Variants    []CreateVariantRequest `json:"variants"`
+}
+
+type UpdateProductRequest struct {
+	CategoryID  null.Int    `json:"categoryId"`
+	Name        null.String `json:"name"`
+	Slug        null.String `json:"slug"`
+	Description null.String `json:"description"`
+	ImageURL    null.String `json:"imageUrl"`
+	IsActive    null.Bool   `json:"isActive"`
+}
+
+// ProductVariant is one pack of a product, such as 500 g of tomatoes, and is what carts, orders and
+// stock refer to. Prices are in paise, MRPPaise is the printed price and PricePaise what the customer pays.
+type ProductVariant struct {
+	ID            int       `json:"id" db:"id"`
+	ProductID     int       `json:"productId" db:"product_id"`
+	SKU           string    `json:"sku" db:"sku"`
+	PackSize      float64   `json:"packSize" db:"pack_size"`
+	UnitCode      string    `json:"unitCode" db:"unit_code"`
+	UnitName      string    `json:"unitName" db:"unit_name"`
+	MRPPaise      int64     `json:"mrpPaise" db:"mrp_paise"`
+	PricePaise    int64     `json:"pricePaise" db:"price_paise"`
+	Barcode       string    `json:"barcode" db:"barcode"`
+	StockQuantity int       `json:"stockQuantity" db:"stock_quantity"`
+	InStock       bool      `json:"inStock" db:"in_stock"`
+	SortOrder     int       `json:"sortOrder" db:"sort_order"`
+	IsActive      bool      `json:"isActive" db:"is_active"`
+	CreatedAt     time.Time `json:"createdAt" db:"created_at"`
+	UpdatedAt     time.Time `json:"updatedAt" db:"updated_at"`
+}
+
+type CreateVariantRequest struct {
+	SKU           string    `json:"sku"`
+	PackSize      float64   `json:"packSize"`
+	UnitCode      string    `json:"unitCode"`
+	MRPPaise      int64     `json:"mrpPaise"`
+	PricePaise    int64     `json:"pricePaise"`
+	Barcode       string    `json:"barcode"`
+	StockQuantity int       `json:"stockQuantity"`
+	SortOrder     int       `json:"sortOrder"`
+	IsActive      null.Bool `json:"isActive"`
+}
+
+type UpdateVariantRequest struct {
+	SKU           null.String  `json:"sku"`
+	PackSize      null.Float64 `json:"packSize"`
+	UnitCode      null.String  `json:"unitCode"`
+	MRPPaise      null.Int64   `json:"mrpPaise"`
+	PricePaise    null.Int64   `json:"pricePaise"`
+	Barcode       null.String  `json:"barcode"`
+	StockQuantity null.Int     `json:"stockQuantity"`
+	SortOrder     null.Int     `json:"sortOrder"`
+	IsActive      null.Bool    `json:"isActive"`
+}
+
+// AdjustStockRequest changes stock by Delta, negative when packs leave the shelf.
+type AdjustStockRequest struct {
+	Delta int `json:"delta"`
+}
+
+// ProductFilter narrows a product listing, customers only ever see active products in active categories.
+type ProductFilter struct {
+	CategoryID      null.Int
+	CategorySlug    string
+	IncludeInactive bool
+	Limit           int
+	Offset          int
+}
+
+// ProductAlias is another name a product is searched by, in any script.
+type ProductAlias struct {
+	ID        int       `json:"id" db:"id"`
+	ProductID int       `json:"productId" db:"product_id"`
+	Name      string    `json:"name" db:"name"`
+	Language  string    `json:"language" db:"language"`
+	CreatedAt time.Time `json:"createdAt" db:"created_at"`
+}
+
+type CreateAliasRequest struct {
+	Name     string `json:"name"`
+	Language string `json:"language"`
+}
+
+// ProductSearch is a customer search, out of stock products are left out unless IncludeOutOfStock is set.
+type ProductSearch struct {
+	Query             string
+	CategoryID        null.Int
+	IncludeOutOfStock bool
+	Limit             int
+	Offset            int
+}
+
+// SearchSuggestion is a name or alias to autocomplete, with the product it leads to.
+type SearchSuggestion struct {
+	Text      string `json:"text" db:"text"`
+	ProductID int    `json:"productId" db:"product_id"`
+}
@@ -0,0 +1,58 @@
+package models
+
+import (
+	"time"
+
+	"github.com/volatiletech/null"
+)
+
+type PolicyDocument struct {
+	ID          int        `json:"id" db:"id"`
+	Kind        PolicyKind `json:"kind" db:"kind"`
+	Version     string     `json:"version" db:"version"`
+	Title       string     `json:"title" db:"title"`
+	URL         string     `json:"url" db:"url"`
+	Content     string     `json:"content,omitempty" db:"content"`
+	Mandatory   bool       `json:"mandatory" db:"mandatory"`
+	PublishedAt time.Time  `json:"publishedAt" db:"published_at"`
+	CreatedAt   time.Time  `json:"createdAt" db:"created_at"`
+}
+
+// PublishPolicyRequest adds a new version of a policy, PublishedAt defaults to now and may be set
+// in the future to announce upcoming terms before they take effect.
+type PublishPolicyRequest struct {
+	Kind        PolicyKind `json:"kind"`
+	Version     string     `json:"version"`
+	Title       string     `json:"title"`
+	URL         string     `json:"url"`
+	Content     string     `json:"content"`
+	Mandatory   bool       `json:"mandatory"`
+	PublishedAt null.Time  `json:"publishedAt"`
+}
+
+type UserConsent struct {
+	PolicyDocumentID int        `json:"policyId" db:"policy_document_id"`
+	Kind             PolicyKind `json:"kind" db:"kind"`
+	Version          string     `json:"version" db:"version"`
+	AcceptedAt       time.Time  `json:"acceptedAt" db:"accepted_at"`
+}
+
+// ConsentRecord is who accepted or opted in and from where, kept as evidence.
+type ConsentRecord struct {
+	UserID    int
+	IP        string
+	UserAgent string
+}
+
+type AcceptPoliciesRequest struct {
+	PolicyIDs []int `json:"policyIds"`
+}
+
+type MarketingConsentRequest struct {
+	OptIn bool `json:"optIn"`
+}
+
+type MarketingConsent struct {
+	OptedIn   bool      `json:"optedIn" db:"opted_in"`
+	UpdatedAt null.Time `json:"updatedAt" db:"created_at"`
+}
@@ -0,0 +1,35 @@
+package models
+
+import "time"
+
+type UserDevice struct {
+	ID          int       `json:"id" db:"id"`
+	Name        string    `json:"name" db:"name"`
+	Platform    string    `json:"platform" db:"platform"`
+	ModelName   string    `json:"modelName" db:"model_name"`
+	OSVersion   string    `json:"osVersion" db:"os_version"`
+	DeviceID    string    `json:"deviceId" db:"device_id"`
+	LastIP      string    `json:"lastIp" db:"last_ip"`
+	FirstSeenAt time.Time `json:"firstSeenAt" db:"first_seen_at"`
+	LastSeenAt  time.Time `json:"lastSeenAt" db:"last_seen_at"`
+}
+
+// DeviceLogin tells whether a login came from a device the user has not used before.
+// IsFirstDevice is set for the very first login of an account, which is not suspicious.
+type DeviceLogin struct {
+	UserDeviceID  int
+	IsNewDevice   bool
+	IsFirstDevice bool
+}
+
+// DeviceAlert describes the login a "this wasn't me" link is about, shown before the user confirms.
+type DeviceAlert struct {
+	Platform  string    `db:"platform"`
+	ModelName string    `db:"model_name"`
+	IP        string    `db:"ip"`
+	CreatedAt time.Time `db:"created_at"`
+}
+
+type RenameDeviceRequest struct {
+	Name string `json:"name"`
+}
@@ -5,3 +5,143 @@ type Context string
 const (
 	UserContext Context = "userContext"
 )
+
+type OTPPurpose string
+
+const (
+	OTPPurposeLogin                OTPPurpose = "login"
+	OTPPurposePhoneChange          OTPPurpose = "phone_change"
+	OTPPurposeEmailChange          OTPPurpose = "email_change"
+	OTPPurposeDeliveryConfirmation OTPPurpose = "delivery_confirmation"
+)
+
+// OTPChannel is how an OTP was delivered, a code sent by SMS can only be used to log in with the phone number.
+type OTPChannel string
+
+const (
+	OTPChannelEmail OTPChannel = "email"
+	OTPChannelPhone OTPChannel = "phone"
+)
+
+type Role string
+
+const (
+	RoleCustomer Role = "customer"
+	RoleAdmin    Role = "admin"
+	RoleVendor   Role = "vendor"
+	RoleRider    Role = "rider"
+	RolePartner  Role = "partner"
+)
+
+type Permission string
+
+const (
+	PermissionUsersRead        Permission = "users:read"
+	PermissionUsersWrite       Permission = "users:write"
+	PermissionRolesAssign      Permission = "roles:assign"
+	PermissionCatalogWrite     Permission = "catalog:write"
+	PermissionInventoryWrite   Permission = "inventory:write"
+	PermissionDeliveriesManage Permission = "deliveries:manage"
+	PermissionUsersImpersonate Permission = "users:impersonate"
+	PermissionPoliciesWrite    Permission = "policies:write"
+)
+
+type PolicyKind string
+
+const (
+	PolicyKindTermsOfService PolicyKind = "terms_of_service"
+	PolicyKindPrivacyPolicy  PolicyKind = "privacy_policy"
+)
+
+func (pk PolicyKind) IsValid() bool {
+	switch pk {
+	case PolicyKindTermsOfService, PolicyKindPrivacyPolicy:
+		return true
+	}
+	return false
+}
+
+type PriceChangeSource string
+
+const (
+	PriceChangeSourceManual    PriceChangeSource = "manual"
+	PriceChangeSourceMandiRule PriceChangeSource = "mandi_rule"
+	PriceChangeSourceImport    PriceChangeSource = "import"
+)
+
+type DietaryPreference string
+
+const (
+	DietaryPreferenceVegetarian    DietaryPreference = "vegetarian"
+	DietaryPreferenceVegan         DietaryPreference = "vegan"
+	DietaryPreferenceJain          DietaryPreference = "jain"
+	DietaryPreferenceEggetarian    DietaryPreference = "eggetarian"
+	DietaryPreferenceNoOnionGarlic DietaryPreference = "no_onion_garlic"
+	DietaryPreferenceOrganicOnly   DietaryPreference = "organic_only"
+)
+
+func (dp DietaryPreference) IsValid() bool {
+	switch dp {
+	case DietaryPreferenceVegetarian, DietaryPreferenceVegan, DietaryPreferenceJain,
+		DietaryPreferenceEggetarian, DietaryPreferenceNoOnionGarlic, DietaryPreferenceOrganicOnly:
+		return true
+	}
+	return false
+}
+
+type ExportStatus string
+
+const (
+	ExportStatusQueued     ExportStatus = "queued"
+	ExportStatusProcessing ExportStatus = "processing"
+	ExportStatusReady      ExportStatus = "ready"
+	ExportStatusDownloaded ExportStatus = "downloaded"
+	ExportStatusExpired    ExportStatus = "expired"
+	ExportStatusFailed     ExportStatus = "failed"
+)
+
+type AuthEventType string
+
+const (
+	AuthEventRegister          AuthEventType = "register"
+	AuthEventLogin             AuthEventType = "login"
+	AuthEventOTPRequest        AuthEventType = "otp_request"
+	AuthEventTokenRefresh      AuthEventType = "token_refresh"
+	AuthEventLogout            AuthEventType = "logout"
+	AuthEventSessionRevoke     AuthEventType = "session_revoke"
+	AuthEventSessionsRevokeAll AuthEventType = "sessions_revoke_all"
+	AuthEventTokenRejected     AuthEventType = "token_rejected"
+	AuthEventImpersonation     AuthEventType = "impersonation"
+	AuthEventMFAEnroll         AuthEventType = "mfa_enroll"
+	AuthEventMFAVerify         AuthEventType = "mfa_verify"
+	AuthEventMFADisable        AuthEventType = "mfa_disable"
+	AuthEventAPIKeyRejected    AuthEventType = "api_key_rejected"
+	AuthEventNewDevice         AuthEventType = "new_device"
+	AuthEventDeviceForget      AuthEventType = "device_forget"
+	AuthEventDeviceAlertRevoke AuthEventType = "device_alert_revoke"
+)
+
+type AuthEventOutcome string
+
+const (
+	AuthEventSuccess AuthEventOutcome = "success"
+	AuthEventFailure AuthEventOutcome = "failure"
+)
+
+// APIKeyScope limits what a partner key can do on top of what its account is allowed to.
+type APIKeyScope string
+
+const (
+	APIKeyScopeAccountRead APIKeyScope = "account:read"
+	APIKeyScopeCatalogRead APIKeyScope = "catalog:read"
+	APIKeyScopeOrdersRead  APIKeyScope = "orders:read"
+	APIKeyScopeOrdersWrite APIKeyScope = "orders:write"
+)
+
+func (scope APIKeyScope) IsValid() bool {
+	switch scope {
+	case APIKeyScopeAccountRead, APIKeyScopeCatalogRead, APIKeyScopeOrdersRead, APIKeyScopeOrdersWrite:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,52 @@
+package models
+
+import (
+	"time"
+
+	"github.com/volatiletech/null"
+)
+
+type DataExport struct {
+	ID           int          `json:"id" db:"id"`
+	UserID       int          `json:"userId" db:"user_id"`
+	Status       ExportStatus `json:"status" db:"status"`
+	ExpiresAt    null.Time    `json:"expiresAt" db:"expires_at"`
+	DownloadedAt null.Time    `json:"downloadedAt" db:"downloaded_at"`
+	Error        null.String  `json:"-" db:"error"`
+	CreatedAt    time.Time    `json:"createdAt" db:"created_at"`
+	CompletedAt  null.Time    `json:"completedAt" db:"completed_at"`
+}
+
+type UserAccountExport struct {
+	UserID       int         `json:"userId" db:"id"`
+	Fullname     string      `json:"name" db:"fullname"`
+	Email        null.String `json:"email" db:"email"`
+	Mobilenumber null.String `json:"phone" db:"mobilenumber"`
+	CreatedAt    time.Time   `json:"createdAt" db:"created_at"`
+	UpdatedAt    null.Time   `json:"updatedAt" db:"updated_at"`
+	Deactivated  bool        `json:"deactivated" db:"deactivated"`
+	ArchivedAt   null.Time   `json:"archivedAt" db:"archived_at"`
+}
+
+type SessionHistory struct {
+	ID        int       `json:"id" db:"id"`
+	Platform  string    `json:"platform" db:"platform"`
+	ModelName string    `json:"modelName" db:"model_name"`
+	OSVersion string    `json:"osVersion" db:"os_version"`
+	DeviceID  string    `json:"deviceId" db:"device_id"`
+	StartTime time.Time `json:"startTime" db:"start_time"`
+	EndTime   time.Time `json:"endTime" db:"end_time"`
+	RevokedAt null.Time `json:"revokedAt" db:"revoked_at"`
+}
+
+type UserIdentityExport struct {
+	Provider  string    `json:"provider" db:"provider"`
+	Subject   string    `json:"subject" db:"subject"`
+	Email     string    `json:"email" db:"email"`
+	CreatedAt time.Time `json:"createdAt" db:"created_at"`
+}
+
+type ProductViewExport struct {
+	ProductID int       `json:"productId" db:"product_id"`
+	ViewedOn  time.Time `json:"viewedOn" db:"viewed_on"`
+}
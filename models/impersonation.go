@@ -0,0 +1,16 @@
+package models
+
+type ImpersonationRequest struct {
+	Reason string `json:"reason"`
+}
+
+// ImpersonatedRequest is the audit row written for every request made with an impersonation token.
+type ImpersonatedRequest struct {
+	SessionID  string `db:"session_id"`
+	ActorID    int    `db:"actor_id"`
+	UserID     int    `db:"user_id"`
+	Method     string `db:"method"`
+	Path       string `db:"path"`
+	StatusCode int    `db:"status_code"`
+	IP         string `db:"ip"`
+}
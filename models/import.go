@@ -0,0 +1,44 @@
+package models
+
+import "github.com/volatiletech/null"
+
+// ImportRowError points an admin at the line of an uploaded file that could not be used.
+type ImportRowError struct {
+	Line    int    `json:"line"`
+	Field   string `json:"field,omitempty"`
+	Message string `json:"message"`
+}
+
+// CatalogRow is one line of a catalog sheet: a variant next to the product it belongs to. A product
+// without variants has a row with an empty SKU, and an empty StockQuantity keeps the stock of an
+// existing variant. It is empty unless the import sets stock, new variants then start at zero.
+type CatalogRow struct {
+	Line          int      `db:"-"`
+	ProductSlug   string   `db:"product_slug"`
+	ProductName   string   `db:"product_name"`
+	CategoryID    int      `db:"category_id"`
+	CategorySlug  string   `db:"category_slug"`
+	Description   string   `db:"description"`
+	ImageURL      string   `db:"image_url"`
+	ProductActive bool     `db:"product_active"`
+	SKU           string   `db:"sku"`
+	PackSize      float64  `db:"pack_size"`
+	UnitCode      string   `db:"unit_code"`
+	MRPPaise      int64    `db:"mrp_paise"`
+	PricePaise    int64    `db:"price_paise"`
+	Barcode       string   `db:"barcode"`
+	StockQuantity null.Int `db:"stock_quantity"`
+	SortOrder     int      `db:"sort_order"`
+	VariantActive bool     `db:"variant_active"`
+}
+
+type CatalogImportResult struct {
+	RowsImported    int              `json:"rowsImported"`
+	RowsSkipped     int              `json:"rowsSkipped"`
+	ProductsCreated int              `json:"productsCreated"`
+	ProductsUpdated int              `json:"productsUpdated"`
+	VariantsCreated int              `json:"variantsCreated"`
+	VariantsUpdated int              `json:"variantsUpdated"`
+	PricesChanged   int              `json:"pricesChanged"`
+	Errors          []ImportRowError `json:"errors"`
+}
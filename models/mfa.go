@@ -0,0 +1,20 @@
+package models
+
+import "github.com/volatiletech/null"
+
+type MFAStatus struct {
+	Enabled                bool      `json:"enabled" db:"enabled"`
+	ConfirmedAt            null.Time `json:"confirmedAt" db:"confirmed_at"`
+	RecoveryCodesRemaining int       `json:"recoveryCodesRemaining" db:"recovery_codes_remaining"`
+}
+
+type TOTPEnrollment struct {
+	Secret     string `json:"secret"`
+	OTPAuthURI string `json:"otpauthUri"`
+}
+
+// MFAVerifyRequest takes either the current code from the authenticator app or one unused recovery code.
+type MFAVerifyRequest struct {
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recoveryCode"`
+}
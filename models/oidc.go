@@ -0,0 +1,26 @@
+package models
+
+import "time"
+
+// OIDCIdentity is what we take from a validated ID token.
+type OIDCIdentity struct {
+	Provider      string
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+}
+
+// OIDCLoginState is kept between sending the user to the provider and the callback.
+type OIDCLoginState struct {
+	Provider     string `db:"provider"`
+	Nonce        string `db:"nonce"`
+	CodeVerifier string `db:"code_verifier"`
+	CreateSessionRequest
+	ExpiresAt time.Time `db:"expires_at"`
+}
+
+type OIDCCallbackRequest struct {
+	State string `json:"state"`
+	Code  string `json:"code"`
+}
@@ -0,0 +1,23 @@
+package models
+
+import (
+	"time"
+
+	"github.com/volatiletech/null"
+)
+
+type OTPChallenge struct {
+	ID          int       `json:"id" db:"id"`
+	UserID      int       `json:"userId" db:"user_id"`
+	Purpose     string    `json:"purpose" db:"purpose"`
+	Channel     string    `json:"channel" db:"channel"`
+	Target      string    `json:"target" db:"target"`
+	OTPSalt     string    `json:"-" db:"otp_salt"`
+	OTPHash     string    `json:"-" db:"otp_hash"`
+	Attempts    int       `json:"attempts" db:"attempts"`
+	MaxAttempts int       `json:"maxAttempts" db:"max_attempts"`
+	ExpiresAt   time.Time `json:"expiresAt" db:"expires_at"`
+	Consumed    bool      `json:"consumed" db:"consumed"`
+	ConsumedAt  null.Time `json:"consumedAt" db:"consumed_at"`
+	CreatedAt   time.Time `json:"createdAt" db:"created_at"`
+}
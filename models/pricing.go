@@ -0,0 +1,107 @@
+package models
+
+import (
+	"time"
+
+	"github.com/volatiletech/null"
+)
+
+// MandiPrice is one wholesale rate, ModalPricePaise is quoted per UnitCode which holds BaseQuantity
+// of BaseUnit, so 2150 rupees per quintal is 215000 paise per 100000 g.
+type MandiPrice struct {
+	ID              int       `json:"id" db:"id"`
+	PriceDate       time.Time `json:"priceDate" db:"price_date"`
+	Commodity       string    `json:"commodity" db:"commodity"`
+	Market          string    `json:"market" db:"market"`
+	UnitCode        string    `json:"unitCode" db:"unit_code"`
+	BaseUnit        string    `json:"baseUnit" db:"base_unit"`
+	BaseQuantity    int       `json:"baseQuantity" db:"base_quantity"`
+	ModalPricePaise int64     `json:"modalPricePaise" db:"modal_price_paise"`
+	CreatedAt       time.Time `json:"createdAt" db:"created_at"`
+}
+
+// MandiPriceRow is one entry of the daily file, prices are in rupees as the mandi publishes them.
+type MandiPriceRow struct {
+	Date       string `json:"date"`
+	Commodity  string `json:"commodity"`
+	Market     string `json:"market"`
+	Unit       string `json:"unit"`
+	ModalPrice string `json:"modalPrice"`
+}
+
+// PricingRule derives a product's selling prices from the mandi rate of Commodity. FloorPaise and
+// CeilingPaise are per one UnitCode, for example per kg, and are scaled to each pack size.
+type PricingRule struct {
+	ID            int        `json:"id" db:"id"`
+	ProductID     int        `json:"productId" db:"product_id"`
+	ProductName   string     `json:"productName" db:"product_name"`
+	Commodity     string     `json:"commodity" db:"commodity"`
+	Market        string     `json:"market" db:"market"`
+	MarkupPercent float64    `json:"markupPercent" db:"markup_percent"`
+	RoundingPaise int64      `json:"roundingPaise" db:"rounding_paise"`
+	UnitCode      string     `json:"unitCode" db:"unit_code"`
+	FloorPaise    null.Int64 `json:"floorPaise" db:"floor_paise"`
+	CeilingPaise  null.Int64 `json:"ceilingPaise" db:"ceiling_paise"`
+	IsActive      bool       `json:"isActive" db:"is_active"`
+	CreatedAt     time.Time  `json:"createdAt" db:"created_at"`
+	UpdatedAt     time.Time  `json:"updatedAt" db:"updated_at"`
+}
+
+type PricingRuleRequest struct {
+	Commodity     string     `json:"commodity"`
+	Market        string     `json:"market"`
+	MarkupPercent float64    `json:"markupPercent"`
+	RoundingPaise int64      `json:"roundingPaise"`
+	UnitCode      string     `json:"unitCode"`
+	FloorPaise    null.Int64 `json:"floorPaise"`
+	CeilingPaise  null.Int64 `json:"ceilingPaise"`
+	IsActive      null.Bool  `json:"isActive"`
+}
+
+// PricingInput is everything needed to reprice one variant: the variant, its product's rule and
+// the latest mandi rate for the rule's commodity, averaged over markets when the rule names none.
+type PricingInput struct {
+	VariantID           int         `db:"variant_id"`
+	SKU                 string      `db:"sku"`
+	ProductName         string      `db:"product_name"`
+	PackSize            float64     `db:"pack_size"`
+	VariantBaseUnit     string      `db:"variant_base_unit"`
+	VariantBaseQuantity int         `db:"variant_base_quantity"`
+	MRPPaise            int64       `db:"mrp_paise"`
+	PricePaise          int64       `db:"price_paise"`
+	MarkupPercent       float64     `db:"markup_percent"`
+	RoundingPaise       int64       `db:"rounding_paise"`
+	RuleBaseUnit        string      `db:"rule_base_unit"`
+	RuleBaseQuantity    int         `db:"rule_base_quantity"`
+	FloorPaise          null.Int64  `db:"floor_paise"`
+	CeilingPaise        null.Int64  `db:"ceiling_paise"`
+	MandiPriceDate      null.Time   `db:"mandi_price_date"`
+	MandiBaseUnit       null.String `db:"mandi_base_unit"`
+	// MandiPerBasePaise is the wholesale rate for one base unit, such as one gram
+	MandiPerBasePaise null.Float64 `db:"mandi_per_base_paise"`
+}
+
+// PriceChange is the outcome of repricing one variant. Reason explains a skipped variant or why the
+// computed price was adjusted.
+type PriceChange struct {
+	VariantID      int       `json:"variantId"`
+	SKU            string    `json:"sku"`
+	ProductName    string    `json:"productName"`
+	OldPricePaise  int64     `json:"oldPricePaise"`
+	NewPricePaise  int64     `json:"newPricePaise"`
+	MRPPaise       int64     `json:"mrpPaise"`
+	MandiPriceDate null.Time `json:"mandiPriceDate"`
+	Skipped        bool      `json:"skipped"`
+	Reason         string    `json:"reason,omitempty"`
+}
+
+type PriceHistory struct {
+	ID             int               `json:"id" db:"id"`
+	VariantID      int               `json:"variantId" db:"variant_id"`
+	OldPricePaise  int64             `json:"oldPricePaise" db:"old_price_paise"`
+	NewPricePaise  int64             `json:"newPricePaise" db:"new_price_paise"`
+	Source         PriceChangeSource `json:"source" db:"source"`
+	MandiPriceDate null.Time         `json:"mandiPriceDate" db:"mandi_price_date"`
+	ChangedBy      null.Int          `json:"changedBy" db:"changed_by"`
+	CreatedAt      time.Time         `json:"createdAt" db:"created_at"`
+}
@@ -0,0 +1,76 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+
+	"github.com/lib/pq"
+	"github.com/volatiletech/null"
+)
+
+type Address struct {
+	Line1     string       `json:"line1"`
+	Line2     string       `json:"line2"`
+	Landmark  string       `json:"landmark"`
+	City      string       `json:"city"`
+	State     string       `json:"state"`
+	Pincode   string       `json:"pincode"`
+	Latitude  null.Float64 `json:"latitude"`
+	Longitude null.Float64 `json:"longitude"`
+}
+
+// Value stores the address as jsonb.
+func (a Address) Value() (driver.Value, error) {
+	return json.Marshal(a)
+}
+
+func (a *Address) Scan(src interface{}) error {
+	switch value := src.(type) {
+	case []byte:
+		return json.Unmarshal(value, a)
+	case string:
+		return json.Unmarshal([]byte(value), a)
+	}
+	return errors.New("address: unsupported type for scan")
+}
+
+type UserProfile struct {
+	UserID             int            `json:"userId" db:"id"`
+	Fullname           string         `json:"name" db:"fullname"`
+	Email              string         `json:"email" db:"email"`
+	Mobilenumber       string         `json:"phone" db:"mobilenumber"`
+	AvatarURL          null.String    `json:"avatarUrl" db:"avatar_url"`
+	PreferredLanguage  string         `json:"preferredLanguage" db:"preferred_language"`
+	DietaryPreferences pq.StringArray `json:"dietaryPreferences" db:"dietary_preferences"`
+	DefaultAddress     *Address       `json:"defaultAddress" db:"default_address"`
+}
+
+// Optional is a field of a partial update that may be cleared. Set tells a field sent as null, which
+// clears it, apart from a field left out of the request, which is kept.
+type Optional[T any] struct {
+	Value T
+	Set   bool
+}
+
+func (o *Optional[T]) UnmarshalJSON(data []byte) error {
+	o.Set = true
+	return json.Unmarshal(data, &o.Value)
+}
+
+// UpdateProfileRequest changes only the fields sent. avatarUrl and defaultAddress are cleared by null,
+// the other fields ignore it.
+type UpdateProfileRequest struct {
+	Fullname           null.String           `json:"name"`
+	AvatarURL          Optional[null.String] `json:"avatarUrl"`
+	PreferredLanguage  null.String           `json:"preferredLanguage"`
+	DietaryPreferences *[]string             `json:"dietaryPreferences"`
+	DefaultAddress     Optional[*Address]    `json:"defaultAddress"`
+	Email              null.String           `json:"email"`
+	Mobilenumber       null.String           `json:"mobilenumber"`
+}
+
+type VerifyContactChangeRequest struct {
+	Purpose OTPPurpose `json:"purpose"`
+	OTP     string     `json:"otp"`
+}
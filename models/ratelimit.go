@@ -0,0 +1,43 @@
+package models
+
+import "time"
+
+type RateLimitKey string
+
+const (
+	RateLimitKeyIP     RateLimitKey = "ip"
+	RateLimitKeyEmail  RateLimitKey = "email"
+	RateLimitKeyPhone  RateLimitKey = "phone"
+	RateLimitKeyDevice RateLimitKey = "device"
+	// RateLimitKeyUser only applies behind Middleware, where the user is known
+	RateLimitKeyUser RateLimitKey = "user"
+)
+
+// RateLimitRule allows Limit hits in any sliding Window. Going over locks the key out for Lockout,
+// doubling with every repeat violation up to MaxLockout.
+type RateLimitRule struct {
+	Limit      int
+	Window     time.Duration
+	Lockout    time.Duration
+	MaxLockout time.Duration
+}
+
+// RateLimitPolicy names a group of rules so the same email is counted separately per endpoint.
+type RateLimitPolicy struct {
+	Name  string
+	Rules map[RateLimitKey]RateLimitRule
+	// FailClosed refuses requests while the store is down, for routes where guessing is the threat
+	FailClosed bool
+}
+
+// LockoutFor returns how long a key is locked out after its nth violation.
+func (rule RateLimitRule) LockoutFor(violations int) time.Duration {
+	lockout := rule.Lockout
+	for i := 1; i < violations && lockout < rule.MaxLockout; i++ {
+		lockout *= 2
+	}
+	if rule.MaxLockout > 0 && lockout > rule.MaxLockout {
+		lockout = rule.MaxLockout
+	}
+	return lockout
+}
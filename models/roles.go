@@ -0,0 +1,14 @@
+package models
+
+import "github.com/lib/pq"
+
+type RoleWithPermissions struct {
+	ID          int            `json:"id" db:"id"`
+	Name        string         `json:"name" db:"name"`
+	Description string         `json:"description" db:"description"`
+	Permissions pq.StringArray `json:"permissions" db:"permissions"`
+}
+
+type AssignRoleRequest struct {
+	Role Role `json:"role"`
+}
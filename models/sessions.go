@@ -0,0 +1,37 @@
+package models
+
+import (
+	"time"
+
+	"github.com/volatiletech/null"
+)
+
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refreshToken"`
+}
+
+type RefreshTokenSession struct {
+	RefreshTokenID int       `db:"id"`
+	SessionID      int       `db:"session_id"`
+	UserID         int       `db:"user_id"`
+	UUIDToken      string    `db:"token"`
+	Platform       string    `db:"platform"`
+	ModelName      string    `db:"model_name"`
+	OSVersion      string    `db:"os_version"`
+	DeviceID       string    `db:"device_id"`
+	ExpiresAt      time.Time `db:"expires_at"`
+	RotatedAt      null.Time `db:"rotated_at"`
+	RevokedAt      null.Time `db:"revoked_at"`
+	SessionRevoked null.Time `db:"session_revoked_at"`
+}
+
+type ActiveSession struct {
+	ID        int       `json:"id" db:"id"`
+	Platform  string    `json:"platform" db:"platform"`
+	ModelName string    `json:"modelName" db:"model_name"`
+	OSVersion string    `json:"osVersion" db:"os_version"`
+	DeviceID  string    `json:"deviceId" db:"device_id"`
+	StartTime time.Time `json:"startTime" db:"start_time"`
+	EndTime   time.Time `json:"endTime" db:"end_time"`
+	Current   bool      `json:"current" db:"current"`
+}
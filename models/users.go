@@ -22,11 +22,23 @@ type FetchUserData struct {
 }
 
 type UserContextData struct {
-	UserID       int    `json:"userId" db:"id"`
-	SessionID    string `json:"sessionID" db:"token"`
-	Fullname     string `json:"name" db:"fullname"`
-	Email        string `json:"email" db:"email"`
-	Mobilenumber string `json:"phone" db:"mobilenumber"`
+	UserID       int      `json:"userId" db:"id"`
+	SessionID    string   `json:"sessionID" db:"token"`
+	Fullname     string   `json:"name" db:"fullname"`
+	Email        string   `json:"email" db:"email"`
+	Mobilenumber string   `json:"phone" db:"mobilenumber"`
+	Roles        []string `json:"roles"`
+	Permissions  []string `json:"permissions"`
+	// ActorID is the admin acting as this user, 0 unless the token came from impersonation
+	ActorID      int  `json:"actorId,omitempty"`
+	MFASatisfied bool `json:"mfaSatisfied"`
+	// APIKeyID and Scopes are set instead of SessionID when the request authenticated with X-API-Key
+	APIKeyID int      `json:"apiKeyId,omitempty"`
+	Scopes   []string `json:"scopes,omitempty"`
+}
+
+func (uc *UserContextData) IsImpersonated() bool {
+	return uc.ActorID != 0
 }
 
 type FetchUserSessionsData struct {
@@ -34,6 +46,8 @@ type FetchUserSessionsData struct {
 	UserID    int       `json:"userId" db:"user_id"`
 	UUIDToken string    `json:"UUIDToken" db:"token"`
 	EndTime   time.Time `json:"endTime" db:"end_time"`
+	// MFASatisfiedAt is set once the session passed a second factor
+	MFASatisfiedAt null.Time `json:"mfaSatisfiedAt" db:"mfa_satisfied_at"`
 }
 
 type UserData struct {
@@ -41,24 +55,18 @@ type UserData struct {
 }
 
 type CreateNewUserRequest struct {
-	Fullname       string              `json:"name" db:"name"`
-	Email          null.String         `json:"email" db:"email"`
-	Mobilenumber   null.String         `json:"mobilenumber" db:"mobilenumber"`
-	CreatedAt      time.Time           `db:"created_at"`
-	UpdatedAt      timestamp.Timestamp `db:"updated_at"`
-	OTP            int                 `json:"OTP"`
-	OTPExpiryTime  int                 `db:"OTPExpiry"`
-	UpdatedbyAdmin bool                `db:"UpdatedbyAdmin"`
-	Deleted        bool                `db:"Deleted"`
-	DeletedbyAdmin bool                `db:"DeletedbyAdmin"`
-	CheckActive    bool                `db:"CheckActive"`
-}
-
-type GetUserDataByEmail struct {
-	UserID       int    `json:"userId" db:"id"`
-	Fullname     string `json:"name" db:"name"`
-	Email        string `json:"email" db:"email"`
-	Mobilenumber string `json:"phone" db:"phone"`
+	Fullname     string      `json:"name" db:"name"`
+	Email        null.String `json:"email" db:"email"`
+	Mobilenumber null.String `json:"mobilenumber" db:"mobilenumber"`
+	// AcceptedPolicyIDs lists the policy documents the user agreed to on the sign up screen
+	AcceptedPolicyIDs []int               `json:"acceptedPolicyIds" db:"-"`
+	MarketingOptIn    bool                `json:"marketingOptIn" db:"-"`
+	CreatedAt         time.Time           `db:"created_at"`
+	UpdatedAt         timestamp.Timestamp `db:"updated_at"`
+	UpdatedbyAdmin    bool                `json:"-" db:"UpdatedbyAdmin"`
+	Deleted           bool                `json:"-" db:"Deleted"`
+	DeletedbyAdmin    bool                `json:"-" db:"DeletedbyAdmin"`
+	CheckActive       bool                `json:"-" db:"CheckActive"`
 }
 
 type EmailAndOTP struct {
@@ -66,11 +74,16 @@ type EmailAndOTP struct {
 	Email string `json:"email"`
 }
 
+type PhoneAndOTP struct {
+	OTP          string `json:"otp"`
+	Mobilenumber string `json:"mobilenumber"`
+}
+
 type CreateSessionRequest struct {
-	Platform  string `json:"platform"`
-	ModelName string `json:"modelName"`
-	OSVersion string `json:"osVersion"`
-	DeviceID  string `json:"deviceId"`
+	Platform  string `json:"platform" db:"platform"`
+	ModelName string `json:"modelName" db:"model_name"`
+	OSVersion string `json:"osVersion" db:"os_version"`
+	DeviceID  string `json:"deviceId" db:"device_id"`
 }
 
 type AuthLoginRequest struct {
@@ -82,8 +95,34 @@ type AuthLoginRequest struct {
 	OTP       string      `json:"otp"`
 }
 
+type AuthPhoneLoginRequest struct {
+	Platform     string      `json:"platform"`
+	ModelName    null.String `json:"modelName"`
+	OSVersion    null.String `json:"osVersion"`
+	DeviceID     null.String `json:"deviceId"`
+	Mobilenumber string      `json:"mobilenumber"`
+	OTP          string      `json:"otp"`
+}
+
 type GenerateAndStoreOTP struct {
-	Email        string `json:"email" db:"email"`
-	Mobilenumber string `json:"phone" db:"phone"`
-	OTP          string `json:"otp"`
+	UserID      int           `json:"userId" db:"user_id"`
+	Purpose     OTPPurpose    `json:"purpose" db:"purpose"`
+	Channel     OTPChannel    `json:"channel" db:"channel"`
+	Target      string        `json:"target" db:"target"`
+	OTP         string        `json:"otp"`
+	OTPLength   int           `json:"-"`
+	ExpiresIn   time.Duration `json:"-"`
+	MaxAttempts int           `json:"-"`
+}
+
+type RequestOTPRequest struct {
+	Email        null.String `json:"email"`
+	Mobilenumber null.String `json:"mobilenumber"`
+}
+
+// AccountAction records who changed an account's lifecycle state and whether they did it as an admin.
+type AccountAction struct {
+	UserID  int
+	ActorID int
+	ByAdmin bool
 }
@@ -1,68 +1,172 @@
 package authProvider
 
 import (
-	"github.com/sirupsen/logrus"
-	"github.com/vijaygniit/ApnaSabji/models"
-	"github.com/volatiletech/null"
-
-	//"fmt"
+	"errors"
+	"fmt"
+	"os"
 	"strconv"
 	"time"
 
-	"github.com/golang-jwt/jwt"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/vijaygniit/ApnaSabji/models"
+	"github.com/vijaygniit/ApnaSabji/providers"
+	"github.com/vijaygniit/ApnaSabji/utils"
+)
+
+const (
+	defaultAccessTokenTTLMinutes = 60
+	defaultRefreshTokenTTLHours  = 30 * 24
+	defaultIssuer                = "apnasabji"
+	defaultAudience              = "apnasabji-app"
+	defaultImpersonationTTLMins  = 15
 )
 
+// AccessTokenTTL is how long an issued JWT stays valid, configured through ACCESS_TOKEN_TTL_MINUTES.
+func AccessTokenTTL() time.Duration {
+	return time.Duration(utils.GetEnvInt("ACCESS_TOKEN_TTL_MINUTES", defaultAccessTokenTTLMinutes)) * time.Minute
+}
+
+// RefreshTokenTTL is how long a refresh token can be exchanged, configured through REFRESH_TOKEN_TTL_HOURS.
+func RefreshTokenTTL() time.Duration {
+	return time.Duration(utils.GetEnvInt("REFRESH_TOKEN_TTL_HOURS", defaultRefreshTokenTTLHours)) * time.Hour
+}
+
+// ImpersonationTokenTTL is how long an admin can act as a user on one token, configured through IMPERSONATION_TTL_MINUTES.
+func ImpersonationTokenTTL() time.Duration {
+	return time.Duration(utils.GetEnvInt("IMPERSONATION_TTL_MINUTES", defaultImpersonationTTLMins)) * time.Minute
+}
 
-var secret = []byte("supersecretkey")
+// ActorClaim is the RFC 8693 act claim, sub holds the id of the admin acting as the user.
+type ActorClaim struct {
+	Subject string `json:"sub"`
+}
 
+// JWTClaim is the payload of every access token we issue. sub holds the user id and sid the session token.
 type JWTClaim struct {
-	Platform  string      `json:"platform"`
-	ModelName null.String `json:"modelName"`
-	OSVersion null.String `json:"osVersion"`
-	DeviceID  null.String `json:"deviceId"`
-	Username  string      `json:"username"`
-	Email     string      `json:"email"`
-	UUIDToken string      `json:"token"`
-	jwt.StandardClaims
+	SessionID string                      `json:"sid"`
+	Name      string                      `json:"name,omitempty"`
+	Email     string                      `json:"email,omitempty"`
+	Roles     []string                    `json:"roles,omitempty"`
+	Device    models.CreateSessionRequest `json:"device"`
+	Actor     *ActorClaim                 `json:"act,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// TokenRequest carries what GenerateJWT needs to know about the user and their session.
+type TokenRequest struct {
+	UserID    int
+	SessionID string
+	Name      string
+	Email     string
+	Roles     []string
+	Device    models.CreateSessionRequest
+	// ActorID marks an impersonation token issued to this admin
+	ActorID int
+	// TTL overrides AccessTokenTTL when set
+	TTL time.Duration
+}
+
+// UserID returns the numeric user id held in sub.
+func (claims *JWTClaim) UserID() (int, error) {
+	userID, err := strconv.Atoi(claims.Subject)
+	if err != nil {
+		return 0, fmt.Errorf("invalid sub claim %q", claims.Subject)
+	}
+	return userID, nil
+}
+
+// ActorID returns the id of the admin impersonating the user, or 0 for a regular token.
+func (claims *JWTClaim) ActorID() (int, error) {
+	if claims.Actor == nil {
+		return 0, nil
+	}
+	actorID, err := strconv.Atoi(claims.Actor.Subject)
+	if err != nil || actorID == 0 {
+		return 0, fmt.Errorf("invalid act claim %q", claims.Actor.Subject)
+	}
+	return actorID, nil
 }
 
-func GenerateJWT(devClaims map[string]interface{}) (tokenString string, err error) {
-	// var userInfo models.GetUserDataByEmail
-	var userSessionData models.CreateSessionRequest
-	var ok bool
-	var UUIDToken string
-	userInfo, ok := devClaims["userInfo"].(models.GetUserDataByEmail)
-	if !ok {
-		logrus.Error("GenerateJWT:  error getting values out of the devClaims map 1")
-	}
-	UUIDToken, ok = devClaims["UUIDToken"].(string)
-	if !ok {
-		logrus.Error("GenerateJWT:  error getting values out of the devClaims map 2")
-	}
-	userSessionData, ok = devClaims["UserSession"].(models.CreateSessionRequest)
-	if !ok {
-		logrus.Error("GenerateJWT:  error getting values out of the devClaims map 3")
-	}
-	UserIDString := strconv.Itoa(userInfo.UserID)
-	expirationTime := time.Now().Add(1 * time.Hour)
-
-	claims := &jwt.MapClaims{
-		"iss": UserIDString,
-		"exp": time.Now().Add(time.Hour).Unix(),
-		"data": map[string]string{
-			"id":        UserIDString,
-			"name":      userInfo.Fullname,
-			"modelName": userSessionData.ModelName,
-			"platform":  userSessionData.Platform,
-			"oSVersion": userSessionData.OSVersion,
-			"deviceId":  userSessionData.DeviceID,
-			"email":     userInfo.Email,
-			"uuidToken": UUIDToken,
-			"expiresAt": expirationTime.String(),
-			"issuer":    UserIDString,
+func GenerateJWT(signingKeys providers.SigningKeyProvider, tokenReq TokenRequest) (tokenString string, err error) {
+	if tokenReq.UserID == 0 || tokenReq.SessionID == "" {
+		return "", errors.New("GenerateJWT: user id and session id are required")
+	}
+
+	ttl := tokenReq.TTL
+	if ttl == 0 {
+		ttl = AccessTokenTTL()
+	}
+
+	now := time.Now()
+	claims := &JWTClaim{
+		SessionID: tokenReq.SessionID,
+		Name:      tokenReq.Name,
+		Email:     tokenReq.Email,
+		Roles:     tokenReq.Roles,
+		Device:    tokenReq.Device,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    issuer(),
+			Subject:   strconv.Itoa(tokenReq.UserID),
+			Audience:  jwt.ClaimStrings{audience()},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
 		},
 	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err = token.SignedString(secret)
-	return
+	if tokenReq.ActorID != 0 {
+		claims.Actor = &ActorClaim{Subject: strconv.Itoa(tokenReq.ActorID)}
+	}
+
+	kid, method, key := signingKeys.SigningKey()
+	token := jwt.NewWithClaims(method, claims)
+	token.Header["kid"] = kid
+	return token.SignedString(key)
+}
+
+// ParseJWT verifies the signature, expiry, issuer and audience of a token and returns its claims.
+func ParseJWT(signingKeys providers.SigningKeyProvider, tokenString string) (*JWTClaim, error) {
+	claims := &JWTClaim{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, signingKeys.VerificationKey)
+	if err != nil {
+		return nil, err
+	}
+
+	if !token.Valid {
+		return nil, errors.New("token is not valid")
+	}
+
+	if !claims.VerifyIssuer(issuer(), true) {
+		return nil, errors.New("token has an unexpected issuer")
+	}
+
+	if !claims.VerifyAudience(audience(), true) {
+		return nil, errors.New("token has an unexpected audience")
+	}
+
+	if claims.SessionID == "" {
+		return nil, errors.New("token has no session")
+	}
+
+	if _, err := claims.UserID(); err != nil {
+		return nil, err
+	}
+
+	if _, err := claims.ActorID(); err != nil {
+		return nil, err
+	}
+
+	return claims, nil
+}
+
+func issuer() string {
+	if value := os.Getenv("JWT_ISSUER"); value != "" {
+		return value
+	}
+	return defaultIssuer
+}
+
+func audience() string {
+	if value := os.Getenv("JWT_AUDIENCE"); value != "" {
+		return value
+	}
+	return defaultAudience
 }
@@ -1,17 +1,122 @@
 package providers
 
-import "github.com/vijaygniit/ApnaSabji/models"
+import (
+	"time"
+
+	"github.com/vijaygniit/ApnaSabji/models"
+	"github.com/volatiletech/null"
+)
 
 type DBHelperProvider interface {
-	CreateNewUser(newUserRequest *models.CreateNewUserRequest, userID int) (*int, error)
+	CreateNewUser(newUserRequest *models.CreateNewUserRequest, userID int, consent *models.ConsentRecord) (*int, error)
 	IsUserAlreadyExists(emailID string) (isUserExist bool, user models.UserData, err error)
-	UpdateSession(sessionId string) error
+	UpdateSession(sessionId string, expiresIn time.Duration) error
 	FetchUserData(userID int) (models.FetchUserData, error)
+	FetchActiveUserData(userID int) (models.FetchUserData, error)
 	FetchUserSessionData(userID int) ([]models.FetchUserSessionsData, error)
 	IsPhoneNumberAlreadyExist(mobilenumber string) (bool, error)
-	GetUserInfoByEmail(email string) (models.GetUserDataByEmail, error)
 	LogInUserUsingEmail(loginReq models.EmailAndOTP) (userID int, message string, err error)
-	StartNewSession(userID int, request *models.CreateSessionRequest) (string, error)
-	GenerateAndStoreOTP(email string, mobilenumber string)( models.GenerateAndStoreOTP)
-	// EndSession(sessionId string) error
+	LogInUserUsingPhone(loginReq models.PhoneAndOTP) (userID int, message string, err error)
+	StartNewSession(userID int, request *models.CreateSessionRequest, expiresIn time.Duration) (string, error)
+	CreateRefreshToken(sessionToken string, expiresIn time.Duration) (string, error)
+	RotateRefreshToken(refreshToken string, expiresIn, sessionExtension time.Duration) (session models.RefreshTokenSession, newRefreshToken string, err error)
+	GetOTPLoginUser(email, mobilenumber string) (models.FetchUserData, error)
+	GenerateAndStoreOTP(otpReq models.GenerateAndStoreOTP) (otp string, err error)
+	VerifyOTP(userID int, purpose models.OTPPurpose, channel models.OTPChannel, otp string) (target string, err error)
+	EndSession(sessionToken string) error
+	RevokeUserSession(userID, sessionID int) error
+	RevokeOtherSessions(userID int, currentSessionToken string) error
+	ListActiveSessions(userID int, currentSessionToken string) ([]models.ActiveSession, error)
+	GetUserRolesAndPermissions(userID int) (roles, permissions []string, err error)
+	ListRoles() ([]models.RoleWithPermissions, error)
+	AssignRole(userID int, role models.Role, assignedBy int) error
+	RemoveRole(userID int, role models.Role) error
+	GetUserProfile(userID int) (models.UserProfile, error)
+	UpdateUserProfile(userID int, updateReq models.UpdateProfileRequest) error
+	UpdateUserContact(userID int, purpose models.OTPPurpose, target string) error
+	DeleteUserAccount(action models.AccountAction) error
+	RestoreUserAccount(action models.AccountAction, gracePeriod time.Duration) error
+	SetUserDeactivated(action models.AccountAction, deactivated bool) error
+	PurgeDeletedAccounts(gracePeriod time.Duration) (int, error)
+	CreateDataExport(userID int) (models.DataExport, error)
+	GetDataExport(userID, exportID int) (models.DataExport, error)
+	ClaimNextDataExport(staleAfter time.Duration) (models.DataExport, error)
+	CompleteDataExport(exportID int, archive []byte, expiresAt time.Time) (string, error)
+	FailDataExport(exportID int, reason string) error
+	ConsumeDataExportDownload(downloadToken string) (models.DataExport, []byte, error)
+	ExpireDataExports() (int64, error)
+	GetUserAccountExport(userID int) (models.UserAccountExport, error)
+	FetchUserSessionHistory(userID int) ([]models.SessionHistory, error)
+	ListUserAuthEvents(userID int) ([]models.AuthEvent, error)
+	ListUserIdentities(userID int) ([]models.UserIdentityExport, error)
+	ListUserProductViews(userID int) ([]models.ProductViewExport, error)
+	RecordAuthEvent(event models.AuthEvent) error
+	ListAuthEvents(filter models.AuthEventFilter) (events []models.AuthEvent, total int, err error)
+	PurgeAuthEvents(olderThan time.Duration) (int64, error)
+	StartImpersonationSession(userID, actorID int, expiresIn time.Duration) (string, error)
+	RecordImpersonatedRequest(request models.ImpersonatedRequest) error
+	GetMFAStatus(userID int) (models.MFAStatus, error)
+	StartTOTPEnrollment(userID int, secret string) error
+	ConfirmTOTPEnrollment(userID int, sessionToken, code string) (recoveryCodes []string, err error)
+	VerifyMFA(userID int, sessionToken string, verifyReq models.MFAVerifyRequest) error
+	RegenerateRecoveryCodes(userID int) ([]string, error)
+	DisableMFA(userID int) error
+	CreateAPIKey(createReq models.CreateAPIKeyRequest) (apiKey models.APIKey, plainKey string, err error)
+	ListAPIKeys(userID int) ([]models.APIKey, error)
+	RotateAPIKey(userID, apiKeyID int, gracePeriod time.Duration) (apiKey models.APIKey, plainKey string, err error)
+	RevokeAPIKey(userID, apiKeyID int) error
+	AuthenticateAPIKey(plainKey string) (models.APIKey, error)
+	CreateOIDCLoginState(loginState *models.OIDCLoginState) (string, error)
+	ConsumeOIDCLoginState(state, provider string) (models.OIDCLoginState, error)
+	LinkOIDCIdentity(identity models.OIDCIdentity) (userID int, err error)
+	RecordDeviceLogin(userID int, device models.CreateSessionRequest, ip string) (models.DeviceLogin, error)
+	CreateDeviceAlert(userID int, sessionToken string, device models.CreateSessionRequest, ip string, expiresIn time.Duration) (alertToken string, err error)
+	GetDeviceAlert(alertToken string) (models.DeviceAlert, error)
+	RevokeSessionFromDeviceAlert(alertToken string) (userID int, err error)
+	ListUserDevices(userID int) ([]models.UserDevice, error)
+	RenameUserDevice(userID, userDeviceID int, name string) error
+	ForgetUserDevice(userID, userDeviceID int) error
+	PublishPolicyDocument(policy models.PublishPolicyRequest, createdBy int) (models.PolicyDocument, error)
+	ListPolicyDocuments(kind models.PolicyKind) ([]models.PolicyDocument, error)
+	GetCurrentPolicyDocuments() ([]models.PolicyDocument, error)
+	GetPendingPolicyDocuments(userID int) ([]models.PolicyDocument, error)
+	AcceptPolicyDocuments(record models.ConsentRecord, policyIDs []int) error
+	ListUserConsents(userID int) ([]models.UserConsent, error)
+	SetMarketingConsent(record models.ConsentRecord, optIn bool) error
+	GetMarketingConsent(userID int) (models.MarketingConsent, error)
+	ListUnits() ([]models.Unit, error)
+	CreateUnit(unitReq models.CreateUnitRequest) (models.Unit, error)
+	ListCategories(includeInactive bool) ([]models.Category, error)
+	GetCategory(categoryID int, includeInactive bool) (models.Category, error)
+	CreateCategory(categoryReq models.CreateCategoryRequest) (models.Category, error)
+	UpdateCategory(categoryID int, categoryReq models.UpdateCategoryRequest) (models.Category, error)
+	DeleteCategory(categoryID int) error
+	ListProducts(filter models.ProductFilter) (products []models.Product, total int, err error)
+	GetProduct(productID int, includeInactive bool) (models.Product, error)
+	CreateProduct(productReq models.CreateProductRequest, createdBy int) (models.Product, error)
+	UpdateProduct(productID int, productReq models.UpdateProductRequest) (models.Product, error)
+	DeleteProduct(productID int) error
+	ListProductVariants(productID int, includeInactive bool) ([]models.ProductVariant, error)
+	GetProductVariant(variantID int) (models.ProductVariant, error)
+	CreateProductVariant(productID int, variantReq models.CreateVariantRequest) (models.ProductVariant, error)
+	UpdateProductVariant(variantID int, variantReq models.UpdateVariantRequest, changedBy int) (models.ProductVariant, error)
+	DeleteProductVariant(variantID int) error
+	AdjustVariantStock(variantID, delta int) (stockQuantity int, err error)
+	SearchProducts(search models.ProductSearch) (products []models.Product, total int, err error)
+	SearchSuggestions(query string, includeOutOfStock bool, limit int) ([]models.SearchSuggestion, error)
+	RecordProductView(productID, userID int) error
+	PurgeProductViews(before time.Time) (int64, error)
+	ListProductAliases(productID int) ([]models.ProductAlias, error)
+	CreateProductAlias(productID int, aliasReq models.CreateAliasRequest) (models.ProductAlias, error)
+	DeleteProductAlias(aliasID int) error
+	ImportMandiPrices(prices []models.MandiPrice, importedBy int) error
+	ListMandiPrices(date null.Time) ([]models.MandiPrice, error)
+	ListPricingRules() ([]models.PricingRule, error)
+	UpsertPricingRule(productID int, ruleReq models.PricingRuleRequest, createdBy int) (models.PricingRule, error)
+	DeletePricingRule(productID int) error
+	GetPricingInputs(asOf time.Time) ([]models.PricingInput, error)
+	ApplyPriceChanges(changes []models.PriceChange, source models.PriceChangeSource, changedBy int) (applied int, err error)
+	ListPriceHistory(variantID, limit, offset int) (history []models.PriceHistory, total int, err error)
+	ImportCatalog(rows []models.CatalogRow, skipInvalid bool, importedBy int) (models.CatalogImportResult, error)
+	ExportCatalog() ([]models.CatalogRow, error)
 }
@@ -0,0 +1,289 @@
+package dbhelperprovider
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/sirupsen/logrus"
+	"github.com/vijaygniit/ApnaSabji/models"
+)
+
+// DeleteUserAccount soft deletes an account and signs it out everywhere. The row is anonymized by PurgeDeletedAccounts after the grace period.
+func (dh *DBHelper) DeleteUserAccount(action models.AccountAction) error {
+	tx, err := dh.DB.Beginx()
+	if err != nil {
+		logrus.Errorf("DeleteUserAccount: error starting transaction %v", err)
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	// language=SQL
+	SQL := `UPDATE users
+			SET archived_at = $2,
+			    archived_by = $3,
+			    deleted_by_admin = $4
+			WHERE id = $1
+			  AND archived_at IS NULL`
+
+	if err = execAffectingRow(tx, SQL, action.UserID, time.Now().UTC(), action.ActorID, action.ByAdmin); err != nil {
+		logrus.Errorf("DeleteUserAccount: error archiving user %v", err)
+		return err
+	}
+
+	if err = revokeAllUserSessions(tx, action.UserID); err != nil {
+		logrus.Errorf("DeleteUserAccount: error revoking sessions %v", err)
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// RestoreUserAccount undoes a soft delete that is still inside the grace period.
+func (dh *DBHelper) RestoreUserAccount(action models.AccountAction, gracePeriod time.Duration) error {
+	// language=SQL
+	SQL := `UPDATE users
+			SET archived_at = NULL,
+			    archived_by = NULL,
+			    deleted_by_admin = FALSE,
+			    updated_at = $2,
+			    updated_by = $3,
+			    updated_by_admin = $4
+			WHERE id = $1
+			  AND archived_at IS NOT NULL
+			  AND archived_at > $5
+			  AND anonymized_at IS NULL`
+
+	now := time.Now().UTC()
+	if err := execAffectingRow(dh.DB, SQL, action.UserID, now, action.ActorID, action.ByAdmin, now.Add(-gracePeriod)); err != nil {
+		if err != sql.ErrNoRows {
+			logrus.Errorf("RestoreUserAccount: error restoring user %v", err)
+		}
+		return err
+	}
+
+	return nil
+}
+
+// SetUserDeactivated blocks or unblocks an account. Deactivating also signs the user out everywhere.
+func (dh *DBHelper) SetUserDeactivated(action models.AccountAction, deactivated bool) error {
+	tx, err := dh.DB.Beginx()
+	if err != nil {
+		logrus.Errorf("SetUserDeactivated: error starting transaction %v", err)
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	// language=SQL
+	SQL := `UPDATE users
+			SET deactivated = $2,
+			    deactivated_at = CASE WHEN $2 THEN $3::TIMESTAMPTZ END,
+			    updated_at = $3,
+			    updated_by = $4,
+			    updated_by_admin = $5
+			WHERE id = $1
+			  AND deactivated IS DISTINCT FROM $2
+			  AND archived_at IS NULL`
+
+	if err = execAffectingRow(tx, SQL, action.UserID, deactivated, time.Now().UTC(), action.ActorID, action.ByAdmin); err != nil {
+		if err != sql.ErrNoRows {
+			logrus.Errorf("SetUserDeactivated: error updating user %v", err)
+		}
+		return err
+	}
+
+	if deactivated {
+		if err = revokeAllUserSessions(tx, action.UserID); err != nil {
+			logrus.Errorf("SetUserDeactivated: error revoking sessions %v", err)
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// PurgeDeletedAccounts anonymizes the personal data of accounts deleted longer than gracePeriod ago. Rows
+// kept as a record, such as auth events and consents, lose what identifies the person, everything else
+// keyed by the user is deleted.
+func (dh *DBHelper) PurgeDeletedAccounts(gracePeriod time.Duration) (int, error) {
+	tx, err := dh.DB.Beginx()
+	if err != nil {
+		logrus.Errorf("PurgeDeletedAccounts: error starting transaction %v", err)
+		return 0, err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	// language=SQL
+	SQL := `UPDATE users
+			SET fullname = 'Deleted user',
+			    email = NULL,
+			    email_verified_at = NULL,
+			    mobilenumber = NULL,
+			    anonymized_at = now()
+			FROM (SELECT id, email, mobilenumber
+				  FROM users
+				  WHERE archived_at IS NOT NULL
+				    AND archived_at <= $1
+				    AND anonymized_at IS NULL
+				  FOR UPDATE) AS deleted
+			WHERE users.id = deleted.id
+			RETURNING users.id, COALESCE(deleted.email, '') AS email, COALESCE(deleted.mobilenumber, '') AS mobilenumber`
+
+	deletedUsers := make([]struct {
+		ID           int    `db:"id"`
+		Email        string `db:"email"`
+		Mobilenumber string `db:"mobilenumber"`
+	}, 0)
+	if err = tx.Select(&deletedUsers, SQL, time.Now().UTC().Add(-gracePeriod)); err != nil {
+		logrus.Errorf("PurgeDeletedAccounts: error anonymizing users %v", err)
+		return 0, err
+	}
+
+	if len(deletedUsers) == 0 {
+		return 0, nil
+	}
+
+	userIDs := make([]int, 0, len(deletedUsers))
+	identifiers := make([]string, 0, 2*len(deletedUsers))
+	for _, deletedUser := range deletedUsers {
+		userIDs = append(userIDs, deletedUser.ID)
+		if deletedUser.Email != "" {
+			identifiers = append(identifiers, deletedUser.Email)
+		}
+		if deletedUser.Mobilenumber != "" {
+			identifiers = append(identifiers, deletedUser.Mobilenumber)
+		}
+	}
+
+	purgeSQL := []string{
+		// language=SQL
+		`UPDATE user_profiles
+		SET avatar_url = NULL,
+		    default_address = NULL,
+		    dietary_preferences = '{}'
+		WHERE user_id = ANY($1)`,
+		// language=SQL
+		`UPDATE sessions
+		SET model_name = '',
+		    os_version = '',
+		    device_id = ''
+		WHERE user_id = ANY($1)`,
+		// language=SQL
+		`DELETE FROM otp_challenges
+		WHERE user_id = ANY($1)`,
+		// language=SQL
+		`DELETE FROM device_alerts
+		WHERE user_id = ANY($1)`,
+		// language=SQL
+		`DELETE FROM user_devices
+		WHERE user_id = ANY($1)`,
+		// language=SQL
+		`DELETE FROM user_identities
+		WHERE user_id = ANY($1)`,
+		// language=SQL
+		`DELETE FROM api_keys
+		WHERE user_id = ANY($1)`,
+		// language=SQL
+		`DELETE FROM mfa_recovery_codes
+		WHERE user_id = ANY($1)`,
+		// language=SQL
+		`DELETE FROM user_mfa
+		WHERE user_id = ANY($1)`,
+		// language=SQL
+		`UPDATE user_consents
+		SET ip = '',
+		    user_agent = ''
+		WHERE user_id = ANY($1)`,
+		// language=SQL
+		`UPDATE marketing_consents
+		SET ip = '',
+		    user_agent = ''
+		WHERE user_id = ANY($1)`,
+		// language=SQL
+		`DELETE FROM data_exports
+		WHERE user_id = ANY($1)`,
+		// language=SQL
+		`DELETE FROM product_views
+		WHERE user_id = ANY($1)`,
+		// language=SQL
+		`UPDATE impersonation_requests
+		SET ip = ''
+		WHERE user_id = ANY($1)`,
+	}
+
+	for _, SQL := range purgeSQL {
+		if _, err = tx.Exec(SQL, pq.Array(userIDs)); err != nil {
+			logrus.Errorf("PurgeDeletedAccounts: error purging user data %v", err)
+			return 0, err
+		}
+	}
+
+	// failed logins are recorded without a user, only the email or phone that was typed ties them to one
+	// language=SQL
+	SQL = `UPDATE auth_events
+			SET email = '',
+			    phone = '',
+			    ip = '',
+			    user_agent = '',
+			    model_name = '',
+			    os_version = '',
+			    device_id = ''
+			WHERE user_id = ANY($1)
+			   OR email = ANY($2)
+			   OR phone = ANY($2)`
+	if _, err = tx.Exec(SQL, pq.Array(userIDs), pq.Array(identifiers)); err != nil {
+		logrus.Errorf("PurgeDeletedAccounts: error anonymizing auth events %v", err)
+		return 0, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		logrus.Errorf("PurgeDeletedAccounts: error committing purge %v", err)
+		return 0, err
+	}
+
+	return len(userIDs), nil
+}
+
+func revokeAllUserSessions(tx *sqlx.Tx, userID int) error {
+	sessionIDs := make([]int, 0)
+	// language=SQL
+	SQL := `SELECT id
+			FROM sessions
+			WHERE user_id = $1
+			  AND revoked_at IS NULL
+			FOR UPDATE`
+	if err := tx.Select(&sessionIDs, SQL, userID); err != nil {
+		return err
+	}
+
+	for _, sessionID := range sessionIDs {
+		if err := revokeSession(tx, sessionID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// execAffectingRow runs an update and returns sql.ErrNoRows when it matched nothing.
+func execAffectingRow(db sqlx.Execer, SQL string, args ...interface{}) error {
+	result, err := db.Exec(SQL, args...)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
@@ -0,0 +1,198 @@
+package dbhelperprovider
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/sirupsen/logrus"
+	"github.com/vijaygniit/ApnaSabji/models"
+	"github.com/vijaygniit/ApnaSabji/scmerrors"
+)
+
+// API keys look like "ask_<prefix>_<secret>". The prefix is stored in plain text to find the key,
+// only a hash of the whole key is kept.
+const (
+	apiKeyMarker      = "ask_"
+	apiKeyPrefixBytes = 8
+	apiKeyColumns     = `id, user_id, name, prefix, key_hash, scopes, rate_limit_per_minute, rotated_from, last_used_at, expires_at, revoked_at, created_by, created_at`
+	// apiKeyLastUsedResolution limits last_used_at writes to one per key per minute
+	apiKeyLastUsedResolution = time.Minute
+)
+
+func (dh *DBHelper) CreateAPIKey(createReq models.CreateAPIKeyRequest) (apiKey models.APIKey, plainKey string, err error) {
+	scopes := make(pq.StringArray, 0, len(createReq.Scopes))
+	for _, scope := range createReq.Scopes {
+		scopes = append(scopes, string(scope))
+	}
+
+	apiKey, plainKey, err = insertAPIKey(dh.DB, createReq.UserID, createReq.Name, scopes, createReq.RateLimitPerMinute, sql.NullInt64{}, createReq.CreatedBy)
+	if err != nil {
+		logrus.Errorf("CreateAPIKey: error creating api key %v", err)
+	}
+	return apiKey, plainKey, err
+}
+
+func (dh *DBHelper) ListAPIKeys(userID int) ([]models.APIKey, error) {
+	// language=SQL
+	SQL := `SELECT ` + apiKeyColumns + `
+			FROM api_keys
+			WHERE user_id = $1
+			ORDER BY created_at DESC`
+
+	apiKeys := make([]models.APIKey, 0)
+	if err := dh.DB.Select(&apiKeys, SQL, userID); err != nil {
+		logrus.Errorf("ListAPIKeys: error getting api keys %v", err)
+		return apiKeys, err
+	}
+	return apiKeys, nil
+}
+
+// RotateAPIKey issues a replacement with the same name, scopes and limit. The old key keeps working
+// for gracePeriod so the partner can deploy the new one without downtime.
+func (dh *DBHelper) RotateAPIKey(userID, apiKeyID int, gracePeriod time.Duration) (apiKey models.APIKey, plainKey string, err error) {
+	tx, err := dh.DB.Beginx()
+	if err != nil {
+		logrus.Errorf("RotateAPIKey: error starting transaction %v", err)
+		return apiKey, "", err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	// language=SQL
+	SQL := `SELECT ` + apiKeyColumns + `
+			FROM api_keys
+			WHERE id = $1
+			  AND user_id = $2
+			  AND revoked_at IS NULL
+			  AND (expires_at IS NULL OR expires_at > now())
+			FOR UPDATE`
+
+	var oldKey models.APIKey
+	if err = tx.Get(&oldKey, SQL, apiKeyID, userID); err != nil {
+		if err != sql.ErrNoRows {
+			logrus.Errorf("RotateAPIKey: error getting api key %v", err)
+		}
+		return apiKey, "", err
+	}
+
+	apiKey, plainKey, err = insertAPIKey(tx, userID, oldKey.Name, oldKey.Scopes, oldKey.RateLimitPerMinute, sql.NullInt64{Int64: int64(oldKey.ID), Valid: true}, userID)
+	if err != nil {
+		logrus.Errorf("RotateAPIKey: error creating api key %v", err)
+		return apiKey, "", err
+	}
+
+	// language=SQL
+	SQL = `UPDATE api_keys
+			SET expires_at = LEAST(COALESCE(expires_at, $2), $2)
+			WHERE id = $1`
+	if _, err = tx.Exec(SQL, oldKey.ID, time.Now().UTC().Add(gracePeriod)); err != nil {
+		logrus.Errorf("RotateAPIKey: error expiring old api key %v", err)
+		return apiKey, "", err
+	}
+
+	return apiKey, plainKey, tx.Commit()
+}
+
+func (dh *DBHelper) RevokeAPIKey(userID, apiKeyID int) error {
+	// language=SQL
+	SQL := `UPDATE api_keys
+			SET revoked_at = now()
+			WHERE id = $1
+			  AND user_id = $2
+			  AND revoked_at IS NULL`
+
+	err := execAffectingRow(dh.DB, SQL, apiKeyID, userID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		logrus.Errorf("RevokeAPIKey: error revoking api key %v", err)
+	}
+	return err
+}
+
+// AuthenticateAPIKey returns the live key matching plainKey, whose account must still be active.
+func (dh *DBHelper) AuthenticateAPIKey(plainKey string) (models.APIKey, error) {
+	var apiKey models.APIKey
+
+	prefix, ok := apiKeyPrefix(plainKey)
+	if !ok {
+		return apiKey, scmerrors.ErrAPIKeyInvalid
+	}
+
+	// language=SQL
+	SQL := `SELECT api_keys.id, api_keys.user_id, api_keys.name, api_keys.prefix, api_keys.key_hash, api_keys.scopes,
+				   api_keys.rate_limit_per_minute, api_keys.rotated_from, api_keys.last_used_at, api_keys.expires_at,
+				   api_keys.revoked_at, api_keys.created_by, api_keys.created_at
+			FROM api_keys
+			JOIN users ON users.id = api_keys.user_id
+			WHERE api_keys.prefix = $1
+			  AND api_keys.revoked_at IS NULL
+			  AND (api_keys.expires_at IS NULL OR api_keys.expires_at > now())
+			  AND users.deactivated IS FALSE
+			  AND users.archived_at IS NULL`
+
+	if err := dh.DB.Get(&apiKey, SQL, prefix); err != nil {
+		if err == sql.ErrNoRows {
+			return apiKey, scmerrors.ErrAPIKeyInvalid
+		}
+		logrus.Errorf("AuthenticateAPIKey: error getting api key %v", err)
+		return apiKey, err
+	}
+
+	if subtle.ConstantTimeCompare([]byte(hashToken(plainKey)), []byte(apiKey.KeyHash)) != 1 {
+		return apiKey, scmerrors.ErrAPIKeyInvalid
+	}
+
+	// language=SQL
+	SQL = `UPDATE api_keys
+			SET last_used_at = now()
+			WHERE id = $1
+			  AND (last_used_at IS NULL OR last_used_at < $2)`
+	if _, err := dh.DB.Exec(SQL, apiKey.ID, time.Now().UTC().Add(-apiKeyLastUsedResolution)); err != nil {
+		logrus.Errorf("AuthenticateAPIKey: error updating last used %v", err)
+	}
+
+	return apiKey, nil
+}
+
+func insertAPIKey(db sqlx.Queryer, userID int, name string, scopes pq.StringArray, rateLimitPerMinute int, rotatedFrom sql.NullInt64, createdBy int) (models.APIKey, string, error) {
+	var apiKey models.APIKey
+
+	prefixBytes := make([]byte, apiKeyPrefixBytes)
+	if _, err := rand.Read(prefixBytes); err != nil {
+		return apiKey, "", err
+	}
+	prefix := hex.EncodeToString(prefixBytes)
+
+	secret, err := generateToken()
+	if err != nil {
+		return apiKey, "", err
+	}
+	plainKey := apiKeyMarker + prefix + "_" + secret
+
+	// language=SQL
+	SQL := `INSERT INTO api_keys
+			(user_id, name, prefix, key_hash, scopes, rate_limit_per_minute, rotated_from, created_by)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+			RETURNING ` + apiKeyColumns
+
+	err = sqlx.Get(db, &apiKey, SQL, userID, name, prefix, hashToken(plainKey), scopes, rateLimitPerMinute, rotatedFrom, createdBy)
+	return apiKey, plainKey, err
+}
+
+func apiKeyPrefix(plainKey string) (string, bool) {
+	if !strings.HasPrefix(plainKey, apiKeyMarker) {
+		return "", false
+	}
+	parts := strings.SplitN(strings.TrimPrefix(plainKey, apiKeyMarker), "_", 2)
+	if len(parts) != 2 || len(parts[0]) != apiKeyPrefixBytes*2 || parts[1] == "" {
+		return "", false
+	}
+	return parts[0], true
+}
@@ -0,0 +1,105 @@
+package dbhelperprovider
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/vijaygniit/ApnaSabji/models"
+)
+
+func (dh *DBHelper) RecordAuthEvent(event models.AuthEvent) error {
+	// language=SQL
+	SQL := `INSERT INTO auth_events
+			(user_id, event_type, outcome, reason, email, phone, ip, user_agent, platform, model_name, os_version, device_id)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)`
+
+	_, err := dh.DB.Exec(SQL, event.UserID, event.EventType, event.Outcome, event.Reason, event.Email, event.Phone, event.IP, event.UserAgent,
+		event.Platform, event.ModelName, event.OSVersion, event.DeviceID)
+	if err != nil {
+		logrus.Errorf("RecordAuthEvent: error recording auth event %v", err)
+		return err
+	}
+	return nil
+}
+
+// PurgeAuthEvents deletes events older than olderThan.
+func (dh *DBHelper) PurgeAuthEvents(olderThan time.Duration) (int64, error) {
+	// language=SQL
+	SQL := `DELETE FROM auth_events
+			WHERE created_at < $1`
+
+	result, err := dh.DB.Exec(SQL, time.Now().UTC().Add(-olderThan))
+	if err != nil {
+		logrus.Errorf("PurgeAuthEvents: error deleting auth events %v", err)
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// ListAuthEvents returns the newest events first along with the total number matching the filter.
+func (dh *DBHelper) ListAuthEvents(filter models.AuthEventFilter) (events []models.AuthEvent, total int, err error) {
+	conditions := make([]string, 0)
+	args := make([]interface{}, 0)
+	addCondition := func(condition string, arg interface{}) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if filter.UserID.Valid {
+		addCondition("user_id = $%d", filter.UserID.Int)
+	}
+	if filter.EventType != "" {
+		addCondition("event_type = $%d", filter.EventType)
+	}
+	if filter.Outcome != "" {
+		addCondition("outcome = $%d", filter.Outcome)
+	}
+	if filter.Email != "" {
+		addCondition("email = $%d", filter.Email)
+	}
+	if filter.Phone != "" {
+		addCondition("phone = $%d", filter.Phone)
+	}
+	if filter.IP != "" {
+		addCondition("ip = $%d", filter.IP)
+	}
+	if filter.DeviceID != "" {
+		addCondition("device_id = $%d", filter.DeviceID)
+	}
+	if filter.From.Valid {
+		addCondition("created_at >= $%d", filter.From.Time)
+	}
+	if filter.To.Valid {
+		addCondition("created_at < $%d", filter.To.Time)
+	}
+
+	where := ""
+	if len(conditions) > 0 {
+		where = "WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	// language=SQL
+	SQL := `SELECT count(*) FROM auth_events ` + where
+	if err = dh.DB.Get(&total, SQL, args...); err != nil {
+		logrus.Errorf("ListAuthEvents: error counting auth events %v", err)
+		return events, total, err
+	}
+
+	// language=SQL
+	SQL = fmt.Sprintf(`SELECT id, user_id, event_type, outcome, reason, email, phone, ip, user_agent,
+				   platform, model_name, os_version, device_id, created_at
+			FROM auth_events
+			%s
+			ORDER BY created_at DESC, id DESC
+			LIMIT $%d OFFSET $%d`, where, len(args)+1, len(args)+2)
+
+	events = make([]models.AuthEvent, 0)
+	if err = dh.DB.Select(&events, SQL, append(args, filter.Limit, filter.Offset)...); err != nil {
+		logrus.Errorf("ListAuthEvents: error getting auth events %v", err)
+		return events, total, err
+	}
+
+	return events, total, nil
+}
@@ -0,0 +1,258 @@
+package dbhelperprovider
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/sirupsen/logrus"
+	"github.com/vijaygniit/ApnaSabji/models"
+	"github.com/vijaygniit/ApnaSabji/scmerrors"
+	"github.com/volatiletech/null"
+)
+
+var errSKUOfOtherProduct = errors.New("sku belongs to another product")
+
+// catalogRowMessages explain to a category manager why the database refused a row.
+var catalogRowMessages = map[error]string{
+	errSKUOfOtherProduct:             "sku already belongs to another product",
+	scmerrors.ErrCatalogDuplicate:    "sku or barcode is already used by another variant",
+	scmerrors.ErrInvalidCatalogPrice: "price must not be more than mrp",
+	scmerrors.ErrInvalidPackSize:     "pack_size must be more than zero",
+	scmerrors.ErrInsufficientStock:   "stock must be zero or more",
+	scmerrors.ErrUnknownCategory:     "category does not exist",
+	scmerrors.ErrUnknownUnit:         "unit does not exist",
+}
+
+type catalogRowOutcome struct {
+	productID      int
+	productCreated bool
+	variantCreated bool
+	priceChanged   bool
+}
+
+// importCatalogRow creates or updates the product of a row by slug and its variant by SKU. Product
+// columns are only written for the first row of each product.
+func importCatalogRow(tx *sqlx.Tx, row models.CatalogRow, productID, importedBy int) (outcome catalogRowOutcome, err error) {
+	outcome.productID = productID
+	if productID == 0 {
+		// language=SQL
+		err = tx.Get(&outcome.productID, `SELECT id FROM products WHERE slug = $1 AND archived_at IS NULL FOR UPDATE`, row.ProductSlug)
+		if err != nil && err != sql.ErrNoRows {
+			return outcome, err
+		}
+		outcome.productCreated = err == sql.ErrNoRows
+
+		productArgs := []interface{}{
+			row.CategoryID,
+			row.ProductName,
+			row.ProductSlug,
+			row.Description,
+			row.ImageURL,
+			row.ProductActive,
+		}
+
+		if outcome.productCreated {
+			// language=SQL
+			SQL := `INSERT INTO products
+					(category_id, name, slug, description, image_url, is_active, created_by)
+					VALUES ((SELECT id FROM categories WHERE id = $1 AND archived_at IS NULL),
+					        $2, $3, $4, $5, $6, $7)
+					RETURNING id`
+
+			if err = tx.Get(&outcome.productID, SQL, append(productArgs, importedBy)...); err != nil {
+				return outcome, catalogErr(err)
+			}
+		} else {
+			// language=SQL
+			SQL := `UPDATE products
+					SET category_id = (SELECT id FROM categories WHERE id = $1 AND archived_at IS NULL),
+					    name        = $2,
+					    description = $4,
+					    image_url   = $5,
+					    is_active   = $6,
+					    updated_at  = now()
+					WHERE slug = $3
+					  AND archived_at IS NULL`
+
+			if _, err = tx.Exec(SQL, productArgs...); err != nil {
+				return outcome, catalogErr(err)
+			}
+		}
+	}
+
+	if row.SKU == "" {
+		return outcome, nil
+	}
+
+	var existing struct {
+		ID         int   `db:"id"`
+		ProductID  int   `db:"product_id"`
+		PricePaise int64 `db:"price_paise"`
+	}
+
+	// language=SQL
+	err = tx.Get(&existing, `SELECT id, product_id, price_paise FROM product_variants WHERE sku = $1 AND archived_at IS NULL FOR UPDATE`, row.SKU)
+	if err == sql.ErrNoRows {
+		outcome.variantCreated = true
+		_, err = createProductVariant(tx, outcome.productID, models.CreateVariantRequest{
+			SKU:           row.SKU,
+			PackSize:      row.PackSize,
+			UnitCode:      row.UnitCode,
+			MRPPaise:      row.MRPPaise,
+			PricePaise:    row.PricePaise,
+			Barcode:       row.Barcode,
+			StockQuantity: row.StockQuantity.Int,
+			SortOrder:     row.SortOrder,
+			IsActive:      null.BoolFrom(row.VariantActive),
+		})
+		return outcome, catalogErr(err)
+	}
+	if err != nil {
+		return outcome, err
+	}
+	if existing.ProductID != outcome.productID {
+		return outcome, errSKUOfOtherProduct
+	}
+
+	// language=SQL
+	SQL := `UPDATE product_variants
+			SET pack_size      = $2,
+			    unit_id        = (SELECT id FROM units WHERE code = $3),
+			    mrp_paise      = $4,
+			    price_paise    = $5,
+			    barcode        = $6,
+			    stock_quantity = COALESCE($7, stock_quantity),
+			    sort_order     = $8,
+			    is_active      = $9,
+			    updated_at     = now()
+			WHERE id = $1`
+
+	args := []interface{}{
+		existing.ID,
+		row.PackSize,
+		row.UnitCode,
+		row.MRPPaise,
+		row.PricePaise,
+		row.Barcode,
+		row.StockQuantity,
+		row.SortOrder,
+		row.VariantActive,
+	}
+
+	if _, err = tx.Exec(SQL, args...); err != nil {
+		return outcome, catalogErr(err)
+	}
+
+	if existing.PricePaise != row.PricePaise {
+		outcome.priceChanged = true
+		err = recordPriceChange(tx, existing.ID, existing.PricePaise, row.PricePaise, models.PriceChangeSourceImport, null.Time{}, importedBy)
+	}
+	return outcome, err
+}
+
+// ImportCatalog writes validated sheet rows in one transaction. Rows the database refuses, such as a
+// barcode already in use, are reported in the result's Errors. Without skipInvalid the first such row
+// rolls back the whole import, with skipInvalid each row runs in a savepoint and only bad rows are left out.
+func (dh *DBHelper) ImportCatalog(rows []models.CatalogRow, skipInvalid bool, importedBy int) (models.CatalogImportResult, error) {
+	result := models.CatalogImportResult{Errors: make([]models.ImportRowError, 0)}
+
+	tx, err := dh.DB.Beginx()
+	if err != nil {
+		logrus.Errorf("ImportCatalog: error starting transaction %v", err)
+		return result, err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	productIDs := make(map[string]int)
+	for _, row := range rows {
+		if skipInvalid {
+			if _, err = tx.Exec(`SAVEPOINT catalog_row`); err != nil {
+				logrus.Errorf("ImportCatalog: error creating savepoint %v", err)
+				return result, err
+			}
+		}
+
+		outcome, err := importCatalogRow(tx, row, productIDs[row.ProductSlug], importedBy)
+		if err != nil {
+			message, ok := catalogRowMessages[err]
+			if !ok {
+				logrus.Errorf("ImportCatalog: error importing line %d %v", row.Line, err)
+				return result, err
+			}
+
+			result.Errors = append(result.Errors, models.ImportRowError{Line: row.Line, Message: message})
+			if !skipInvalid {
+				return result, nil
+			}
+			if _, err = tx.Exec(`ROLLBACK TO SAVEPOINT catalog_row`); err != nil {
+				logrus.Errorf("ImportCatalog: error rolling back line %d %v", row.Line, err)
+				return result, err
+			}
+			continue
+		}
+
+		if skipInvalid {
+			if _, err = tx.Exec(`RELEASE SAVEPOINT catalog_row`); err != nil {
+				logrus.Errorf("ImportCatalog: error releasing savepoint %v", err)
+				return result, err
+			}
+		}
+
+		result.RowsImported++
+		if _, ok := productIDs[row.ProductSlug]; !ok {
+			productIDs[row.ProductSlug] = outcome.productID
+			if outcome.productCreated {
+				result.ProductsCreated++
+			} else {
+				result.ProductsUpdated++
+			}
+		}
+		if row.SKU != "" && outcome.variantCreated {
+			result.VariantsCreated++
+		} else if row.SKU != "" {
+			result.VariantsUpdated++
+		}
+		if outcome.priceChanged {
+			result.PricesChanged++
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		logrus.Errorf("ImportCatalog: error committing import %v", err)
+		return result, err
+	}
+	return result, nil
+}
+
+// ExportCatalog returns every product that is not archived with its variants, in the shape ImportCatalog
+// reads. Products of archived categories are left out, they could not be imported back.
+func (dh *DBHelper) ExportCatalog() ([]models.CatalogRow, error) {
+	// language=SQL
+	SQL := `SELECT products.slug AS product_slug, products.name AS product_name, products.category_id,
+			       categories.slug AS category_slug, products.description, products.image_url,
+			       products.is_active AS product_active, COALESCE(product_variants.sku, '') AS sku,
+			       COALESCE(product_variants.pack_size, 0) AS pack_size, COALESCE(units.code, '') AS unit_code,
+			       COALESCE(product_variants.mrp_paise, 0) AS mrp_paise,
+			       COALESCE(product_variants.price_paise, 0) AS price_paise,
+			       COALESCE(product_variants.barcode, '') AS barcode, product_variants.stock_quantity,
+			       COALESCE(product_variants.sort_order, 0) AS sort_order,
+			       COALESCE(product_variants.is_active, FALSE) AS variant_active
+			FROM products
+			         JOIN categories ON categories.id = products.category_id
+			         LEFT JOIN product_variants
+			                   ON product_variants.product_id = products.id AND product_variants.archived_at IS NULL
+			         LEFT JOIN units ON units.id = product_variants.unit_id
+			WHERE products.archived_at IS NULL
+			  AND categories.archived_at IS NULL
+			ORDER BY categories.slug, products.name, products.id, product_variants.sort_order, product_variants.id`
+
+	rows := make([]models.CatalogRow, 0)
+	if err := dh.DB.Select(&rows, SQL); err != nil {
+		logrus.Errorf("ExportCatalog: error getting catalog %v", err)
+		return rows, err
+	}
+	return rows, nil
+}
@@ -0,0 +1,448 @@
+package dbhelperprovider
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/sirupsen/logrus"
+	"github.com/vijaygniit/ApnaSabji/models"
+	"github.com/vijaygniit/ApnaSabji/scmerrors"
+)
+
+// productFromSQL joins what a product listing shows, variant_summary folds its active packs into a
+// starting price and whether any of them is in stock.
+// language=SQL
+const productFromSQL = `FROM products
+			         JOIN categories ON categories.id = products.category_id
+			         LEFT JOIN LATERAL (SELECT count(*)                  AS variant_count,
+			                                   min(price_paise)          AS price_paise,
+			                                   bool_or(stock_quantity > 0) AS in_stock
+			                            FROM product_variants
+			                            WHERE product_variants.product_id = products.id
+			                              AND product_variants.is_active
+			                              AND product_variants.archived_at IS NULL) variant_summary ON TRUE`
+
+// language=SQL
+const productSelectSQL = `SELECT products.id, products.category_id, categories.name AS category_name, categories.slug AS category_slug,
+			       products.name, products.slug, products.description, products.image_url,
+			       COALESCE(variant_summary.price_paise, 0) AS price_paise, COALESCE(variant_summary.in_stock, FALSE) AS in_stock,
+			       products.is_active, products.created_at, products.updated_at
+			` + productFromSQL
+
+// language=SQL
+const categorySelectSQL = `SELECT id, parent_id, name, slug, description, image_url, sort_order, is_active, created_at, updated_at
+			FROM categories`
+
+// publicProductSQL hides products that are switched off, sit in a category that is or have no pack for sale.
+const publicProductSQL = `products.is_active AND categories.is_active AND categories.archived_at IS NULL AND variant_summary.variant_count > 0`
+
+// catalogErr turns constraint violations into the errors handlers report to admins. Unknown units
+// and categories are looked up inside the statements, so they surface as a NULL foreign key.
+func catalogErr(err error) error {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return err
+	}
+
+	switch pqErr.Code {
+	case "23505":
+		return scmerrors.ErrCatalogDuplicate
+	case "23514":
+		switch pqErr.Constraint {
+		case "product_variants_stock_check":
+			return scmerrors.ErrInsufficientStock
+		case "product_variants_pack_size_check":
+			return scmerrors.ErrInvalidPackSize
+		}
+		return scmerrors.ErrInvalidCatalogPrice
+	case "23502", "23503":
+		if pqErr.Column == "unit_id" || strings.Contains(pqErr.Constraint, "unit_id") {
+			return scmerrors.ErrUnknownUnit
+		}
+		return scmerrors.ErrUnknownCategory
+	}
+	return err
+}
+
+func (dh *DBHelper) ListUnits() ([]models.Unit, error) {
+	// language=SQL
+	SQL := `SELECT id, code, name, base_unit, base_quantity
+			FROM units
+			ORDER BY base_unit, base_quantity`
+
+	units := make([]models.Unit, 0)
+	if err := dh.DB.Select(&units, SQL); err != nil {
+		logrus.Errorf("ListUnits: error getting units %v", err)
+		return units, err
+	}
+	return units, nil
+}
+
+func (dh *DBHelper) CreateUnit(unitReq models.CreateUnitRequest) (models.Unit, error) {
+	var unit models.Unit
+
+	// language=SQL
+	SQL := `INSERT INTO units
+			(code, name, base_unit, base_quantity)
+			VALUES ($1, $2, $3, $4)
+			RETURNING id, code, name, base_unit, base_quantity`
+
+	if err := dh.DB.Get(&unit, SQL, unitReq.Code, unitReq.Name, unitReq.BaseUnit, unitReq.BaseQuantity); err != nil {
+		logrus.Errorf("CreateUnit: error creating unit %v", err)
+		return unit, catalogErr(err)
+	}
+	return unit, nil
+}
+
+// ListCategories returns categories in display order, inactive ones only when includeInactive is set.
+func (dh *DBHelper) ListCategories(includeInactive bool) ([]models.Category, error) {
+	// language=SQL
+	SQL := categorySelectSQL + `
+			WHERE archived_at IS NULL
+			  AND (is_active OR $1)
+			ORDER BY sort_order, name`
+
+	categories := make([]models.Category, 0)
+	if err := dh.DB.Select(&categories, SQL, includeInactive); err != nil {
+		logrus.Errorf("ListCategories: error getting categories %v", err)
+		return categories, err
+	}
+	return categories, nil
+}
+
+func (dh *DBHelper) GetCategory(categoryID int, includeInactive bool) (models.Category, error) {
+	var category models.Category
+
+	// language=SQL
+	SQL := categorySelectSQL + `
+			WHERE id = $1
+			  AND archived_at IS NULL
+			  AND (is_active OR $2)`
+
+	err := dh.DB.Get(&category, SQL, categoryID, includeInactive)
+	if err != nil && err != sql.ErrNoRows {
+		logrus.Errorf("GetCategory: error getting category %v", err)
+	}
+	return category, err
+}
+
+func (dh *DBHelper) CreateCategory(categoryReq models.CreateCategoryRequest) (models.Category, error) {
+	var category models.Category
+
+	// language=SQL
+	SQL := `INSERT INTO categories
+			(parent_id, name, slug, description, image_url, sort_order, is_active)
+			VALUES ($1, $2, $3, $4, $5, $6, COALESCE($7, TRUE))
+			RETURNING id, parent_id, name, slug, description, image_url, sort_order, is_active, created_at, updated_at`
+
+	args := []interface{}{
+		categoryReq.ParentID,
+		categoryReq.Name,
+		categoryReq.Slug,
+		categoryReq.Description,
+		categoryReq.ImageURL,
+		categoryReq.SortOrder,
+		categoryReq.IsActive,
+	}
+
+	if err := dh.DB.Get(&category, SQL, args...); err != nil {
+		logrus.Errorf("CreateCategory: error creating category %v", err)
+		return category, catalogErr(err)
+	}
+	return category, nil
+}
+
+func (dh *DBHelper) UpdateCategory(categoryID int, categoryReq models.UpdateCategoryRequest) (models.Category, error) {
+	var category models.Category
+
+	if categoryReq.ParentID.Valid {
+		// a category can not move below itself or one of its own subcategories
+		var createsCycle bool
+		// language=SQL
+		SQL := `WITH RECURSIVE ancestors AS (SELECT id, parent_id
+			                                 FROM categories
+			                                 WHERE id = $2
+			                                 UNION
+			                                 SELECT categories.id, categories.parent_id
+			                                 FROM categories
+			                                          JOIN ancestors ON categories.id = ancestors.parent_id)
+			SELECT EXISTS(SELECT 1 FROM ancestors WHERE id = $1)`
+		if err := dh.DB.Get(&createsCycle, SQL, categoryID, categoryReq.ParentID.Int); err != nil {
+			logrus.Errorf("UpdateCategory: error checking parent %v", err)
+			return category, err
+		}
+		if createsCycle {
+			return category, scmerrors.ErrUnknownCategory
+		}
+	}
+
+	// language=SQL
+	SQL := `UPDATE categories
+			SET parent_id   = COALESCE($2, parent_id),
+			    name        = COALESCE($3, name),
+			    slug        = COALESCE($4, slug),
+			    description = COALESCE($5, description),
+			    image_url   = COALESCE($6, image_url),
+			    sort_order  = COALESCE($7, sort_order),
+			    is_active   = COALESCE($8, is_active),
+			    updated_at  = $9
+			WHERE id = $1
+			  AND archived_at IS NULL
+			RETURNING id, parent_id, name, slug, description, image_url, sort_order, is_active, created_at, updated_at`
+
+	args := []interface{}{
+		categoryID,
+		categoryReq.ParentID,
+		categoryReq.Name,
+		categoryReq.Slug,
+		categoryReq.Description,
+		categoryReq.ImageURL,
+		categoryReq.SortOrder,
+		categoryReq.IsActive,
+		time.Now().UTC(),
+	}
+
+	err := dh.DB.Get(&category, SQL, args...)
+	if err != nil && err != sql.ErrNoRows {
+		logrus.Errorf("UpdateCategory: error updating category %v", err)
+		return category, catalogErr(err)
+	}
+	return category, err
+}
+
+// DeleteCategory archives an empty category, products and subcategories have to be moved out first.
+func (dh *DBHelper) DeleteCategory(categoryID int) error {
+	tx, err := dh.DB.Beginx()
+	if err != nil {
+		logrus.Errorf("DeleteCategory: error starting transaction %v", err)
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	var inUse bool
+	// language=SQL
+	SQL := `SELECT EXISTS(SELECT 1 FROM products WHERE category_id = $1 AND archived_at IS NULL)
+			    OR EXISTS(SELECT 1 FROM categories WHERE parent_id = $1 AND archived_at IS NULL)`
+	if err = tx.Get(&inUse, SQL, categoryID); err != nil {
+		logrus.Errorf("DeleteCategory: error checking category %v", err)
+		return err
+	}
+	if inUse {
+		return scmerrors.ErrCategoryNotEmpty
+	}
+
+	// language=SQL
+	SQL = `UPDATE categories
+			SET archived_at = $2
+			WHERE id = $1
+			  AND archived_at IS NULL`
+	if err = execAffectingRow(tx, SQL, categoryID, time.Now().UTC()); err != nil {
+		if err != sql.ErrNoRows {
+			logrus.Errorf("DeleteCategory: error archiving category %v", err)
+		}
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (dh *DBHelper) ListProducts(filter models.ProductFilter) (products []models.Product, total int, err error) {
+	conditions := []string{"products.archived_at IS NULL"}
+	args := make([]interface{}, 0)
+	addCondition := func(condition string, arg interface{}) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if !filter.IncludeInactive {
+		conditions = append(conditions, publicProductSQL)
+	}
+	if filter.CategoryID.Valid {
+		addCondition("products.category_id = $%d", filter.CategoryID.Int)
+	}
+	if filter.CategorySlug != "" {
+		addCondition("categories.slug = $%d", filter.CategorySlug)
+	}
+
+	where := "WHERE " + strings.Join(conditions, " AND ")
+
+	SQL := `SELECT count(*)
+			` + productFromSQL + `
+			` + where
+	if err = dh.DB.Get(&total, SQL, args...); err != nil {
+		logrus.Errorf("ListProducts: error counting products %v", err)
+		return products, total, err
+	}
+
+	products = make([]models.Product, 0)
+	SQL = fmt.Sprintf(`%s
+			%s
+			ORDER BY categories.sort_order, products.name, products.id
+			LIMIT $%d OFFSET $%d`, productSelectSQL, where, len(args)+1, len(args)+2)
+	if err = dh.DB.Select(&products, SQL, append(args, filter.Limit, filter.Offset)...); err != nil {
+		logrus.Errorf("ListProducts: error getting products %v", err)
+		return products, total, err
+	}
+	return products, total, nil
+}
+
+func (dh *DBHelper) GetProduct(productID int, includeInactive bool) (models.Product, error) {
+	var product models.Product
+
+	SQL := productSelectSQL + `
+			WHERE products.id = $1
+			  AND products.archived_at IS NULL`
+	if !includeInactive {
+		SQL += ` AND ` + publicProductSQL
+	}
+
+	if err := dh.DB.Get(&product, SQL, productID); err != nil {
+		if err != sql.ErrNoRows {
+			logrus.Errorf("GetProduct: error getting product %v", err)
+		}
+		return product, err
+	}
+
+	variants, err := dh.ListProductVariants(productID, includeInactive)
+	if err != nil {
+		return product, err
+	}
+	product.Variants = variants
+
+	aliases, err := dh.ListProductAliases(productID)
+	if err != nil {
+		return product, err
+	}
+	product.Aliases = aliases
+	return product, nil
+}
+
+// CreateProduct stores the product together with its first packs, so it never shows up half made.
+func (dh *DBHelper) CreateProduct(productReq models.CreateProductRequest, createdBy int) (models.Product, error) {
+	tx, err := dh.DB.Beginx()
+	if err != nil {
+		logrus.Errorf("CreateProduct: error starting transaction %v", err)
+		return models.Product{}, err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	var productID int
+
+	// language=SQL
+	SQL := `INSERT INTO products
+			(category_id, name, slug, description, image_url, is_active, created_by)
+			VALUES ((SELECT id FROM categories WHERE id = $1 AND archived_at IS NULL),
+			        $2, $3, $4, $5, COALESCE($6, TRUE), $7)
+			RETURNING id`
+
+	args := []interface{}{
+		productReq.CategoryID,
+		productReq.Name,
+		productReq.Slug,
+		productReq.Description,
+		productReq.ImageURL,
+		productReq.IsActive,
+		createdBy,
+	}
+
+	if err = tx.Get(&productID, SQL, args...); err != nil {
+		logrus.Errorf("CreateProduct: error creating product %v", err)
+		return models.Product{}, catalogErr(err)
+	}
+
+	for _, variantReq := range productReq.Variants {
+		if _, err = createProductVariant(tx, productID, variantReq); err != nil {
+			logrus.Errorf("CreateProduct: error creating variant %s %v", variantReq.SKU, err)
+			return models.Product{}, catalogErr(err)
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		logrus.Errorf("CreateProduct: error committing product %v", err)
+		return models.Product{}, err
+	}
+	return dh.GetProduct(productID, true)
+}
+
+func (dh *DBHelper) UpdateProduct(productID int, productReq models.UpdateProductRequest) (models.Product, error) {
+	// language=SQL
+	SQL := `UPDATE products
+			SET category_id = CASE
+			                      WHEN $2::INTEGER IS NULL THEN category_id
+			                      ELSE (SELECT id FROM categories WHERE id = $2 AND archived_at IS NULL) END,
+			    name        = COALESCE($3, name),
+			    slug        = COALESCE($4, slug),
+			    description = COALESCE($5, description),
+			    image_url   = COALESCE($6, image_url),
+			    is_active   = COALESCE($7, is_active),
+			    updated_at  = $8
+			WHERE id = $1
+			  AND archived_at IS NULL`
+
+	args := []interface{}{
+		productID,
+		productReq.CategoryID,
+		productReq.Name,
+		productReq.Slug,
+		productReq.Description,
+		productReq.ImageURL,
+		productReq.IsActive,
+		time.Now().UTC(),
+	}
+
+	if err := execAffectingRow(dh.DB, SQL, args...); err != nil {
+		if err != sql.ErrNoRows {
+			logrus.Errorf("UpdateProduct: error updating product %v", err)
+		}
+		return models.Product{}, catalogErr(err)
+	}
+	return dh.GetProduct(productID, true)
+}
+
+// DeleteProduct archives the product and its variants so past orders keep pointing at them.
+func (dh *DBHelper) DeleteProduct(productID int) error {
+	tx, err := dh.DB.Beginx()
+	if err != nil {
+		logrus.Errorf("DeleteProduct: error starting transaction %v", err)
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	now := time.Now().UTC()
+
+	// language=SQL
+	SQL := `UPDATE products
+			SET archived_at = $2,
+			    is_active   = FALSE
+			WHERE id = $1
+			  AND archived_at IS NULL`
+	if err = execAffectingRow(tx, SQL, productID, now); err != nil {
+		if err != sql.ErrNoRows {
+			logrus.Errorf("DeleteProduct: error archiving product %v", err)
+		}
+		return err
+	}
+
+	// language=SQL
+	SQL = `UPDATE product_variants
+			SET archived_at = $2,
+			    is_active   = FALSE
+			WHERE product_id = $1
+			  AND archived_at IS NULL`
+	if _, err = tx.Exec(SQL, productID, now); err != nil {
+		logrus.Errorf("DeleteProduct: error archiving variants %v", err)
+		return err
+	}
+
+	return tx.Commit()
+}
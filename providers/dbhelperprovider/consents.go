@@ -0,0 +1,207 @@
+package dbhelperprovider
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/sirupsen/logrus"
+	"github.com/vijaygniit/ApnaSabji/models"
+)
+
+// currentPoliciesSQL picks the latest published version of every kind of policy.
+// language=SQL
+const currentPoliciesSQL = `SELECT DISTINCT ON (kind) id, kind, version, title, url, content, mandatory, published_at, created_at
+			FROM policy_documents
+			WHERE published_at <= now()
+			ORDER BY kind, published_at DESC, id DESC`
+
+// PublishPolicyDocument stores a new policy version, sql.ErrNoRows means the version already exists.
+func (dh *DBHelper) PublishPolicyDocument(policy models.PublishPolicyRequest, createdBy int) (models.PolicyDocument, error) {
+	var document models.PolicyDocument
+
+	publishedAt := time.Now().UTC()
+	if policy.PublishedAt.Valid {
+		publishedAt = policy.PublishedAt.Time
+	}
+
+	// language=SQL
+	SQL := `INSERT INTO policy_documents
+			(kind, version, title, url, content, mandatory, published_at, created_by)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+			ON CONFLICT (kind, version) DO NOTHING
+			RETURNING id, kind, version, title, url, content, mandatory, published_at, created_at`
+
+	err := dh.DB.Get(&document, SQL, policy.Kind, policy.Version, policy.Title, policy.URL, policy.Content, policy.Mandatory, publishedAt, createdBy)
+	if err != nil && err != sql.ErrNoRows {
+		logrus.Errorf("PublishPolicyDocument: error storing policy %v", err)
+	}
+	return document, err
+}
+
+// ListPolicyDocuments returns every version of a kind of policy, newest first, including scheduled ones.
+func (dh *DBHelper) ListPolicyDocuments(kind models.PolicyKind) ([]models.PolicyDocument, error) {
+	// language=SQL
+	SQL := `SELECT id, kind, version, title, url, '' AS content, mandatory, published_at, created_at
+			FROM policy_documents
+			WHERE kind = $1
+			ORDER BY published_at DESC, id DESC`
+
+	documents := make([]models.PolicyDocument, 0)
+	if err := dh.DB.Select(&documents, SQL, kind); err != nil {
+		logrus.Errorf("ListPolicyDocuments: error getting policies %v", err)
+		return documents, err
+	}
+	return documents, nil
+}
+
+// GetCurrentPolicyDocuments returns the version of every policy in force right now.
+func (dh *DBHelper) GetCurrentPolicyDocuments() ([]models.PolicyDocument, error) {
+	documents := make([]models.PolicyDocument, 0)
+	if err := dh.DB.Select(&documents, currentPoliciesSQL); err != nil {
+		logrus.Errorf("GetCurrentPolicyDocuments: error getting policies %v", err)
+		return documents, err
+	}
+	return documents, nil
+}
+
+// GetPendingPolicyDocuments returns the latest mandatory version of each policy the user has not
+// accepted yet. Accepting a later optional version of the same kind counts as well. A user id of 0
+// returns everything a new user has to accept.
+func (dh *DBHelper) GetPendingPolicyDocuments(userID int) ([]models.PolicyDocument, error) {
+	// language=SQL
+	SQL := `SELECT required.id, required.kind, required.version, required.title, required.url, '' AS content,
+			       required.mandatory, required.published_at, required.created_at
+			FROM (SELECT DISTINCT ON (kind) *
+			      FROM policy_documents
+			      WHERE mandatory
+			        AND published_at <= now()
+			      ORDER BY kind, published_at DESC, id DESC) required
+			WHERE NOT EXISTS(SELECT 1
+			                 FROM user_consents
+			                          JOIN policy_documents accepted ON accepted.id = user_consents.policy_document_id
+			                 WHERE user_consents.user_id = $1
+			                   AND accepted.kind = required.kind
+			                   AND accepted.published_at >= required.published_at)
+			ORDER BY required.kind`
+
+	documents := make([]models.PolicyDocument, 0)
+	if err := dh.DB.Select(&documents, SQL, userID); err != nil {
+		logrus.Errorf("GetPendingPolicyDocuments: error getting policies %v", err)
+		return documents, err
+	}
+	return documents, nil
+}
+
+// AcceptPolicyDocuments records the user's acceptance. sql.ErrNoRows means one of the ids is not a
+// published policy, in which case nothing is recorded.
+func (dh *DBHelper) AcceptPolicyDocuments(record models.ConsentRecord, policyIDs []int) error {
+	tx, err := dh.DB.Beginx()
+	if err != nil {
+		logrus.Errorf("AcceptPolicyDocuments: error starting transaction %v", err)
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	if err = acceptPolicyDocuments(tx, record, policyIDs); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func acceptPolicyDocuments(tx *sqlx.Tx, record models.ConsentRecord, policyIDs []int) error {
+	var publishedIDs []int
+	// language=SQL
+	SQL := `SELECT id
+			FROM policy_documents
+			WHERE id = ANY ($1)
+			  AND published_at <= now()`
+	if err := tx.Select(&publishedIDs, SQL, pq.Array(policyIDs)); err != nil {
+		logrus.Errorf("AcceptPolicyDocuments: error getting policies %v", err)
+		return err
+	}
+
+	for _, policyID := range policyIDs {
+		if !containsInt(publishedIDs, policyID) {
+			return sql.ErrNoRows
+		}
+	}
+
+	// language=SQL
+	SQL = `INSERT INTO user_consents
+			(user_id, policy_document_id, ip, user_agent)
+			SELECT $1, unnest($2::INTEGER[]), $3, $4
+			ON CONFLICT (user_id, policy_document_id) DO NOTHING`
+	if _, err := tx.Exec(SQL, record.UserID, pq.Array(publishedIDs), record.IP, record.UserAgent); err != nil {
+		logrus.Errorf("AcceptPolicyDocuments: error storing consents %v", err)
+		return err
+	}
+	return nil
+}
+
+func (dh *DBHelper) ListUserConsents(userID int) ([]models.UserConsent, error) {
+	// language=SQL
+	SQL := `SELECT user_consents.policy_document_id, policy_documents.kind, policy_documents.version, user_consents.accepted_at
+			FROM user_consents
+			         JOIN policy_documents ON policy_documents.id = user_consents.policy_document_id
+			WHERE user_consents.user_id = $1
+			ORDER BY user_consents.accepted_at DESC`
+
+	consents := make([]models.UserConsent, 0)
+	if err := dh.DB.Select(&consents, SQL, userID); err != nil {
+		logrus.Errorf("ListUserConsents: error getting consents %v", err)
+		return consents, err
+	}
+	return consents, nil
+}
+
+// SetMarketingConsent appends the user's marketing choice, earlier choices are kept as history.
+func (dh *DBHelper) SetMarketingConsent(record models.ConsentRecord, optIn bool) error {
+	return setMarketingConsent(dh.DB, record, optIn)
+}
+
+func setMarketingConsent(db sqlx.Execer, record models.ConsentRecord, optIn bool) error {
+	// language=SQL
+	SQL := `INSERT INTO marketing_consents
+			(user_id, opted_in, ip, user_agent)
+			VALUES ($1, $2, $3, $4)`
+
+	if _, err := db.Exec(SQL, record.UserID, optIn, record.IP, record.UserAgent); err != nil {
+		logrus.Errorf("SetMarketingConsent: error storing consent %v", err)
+		return err
+	}
+	return nil
+}
+
+// GetMarketingConsent returns the user's latest choice, users who never chose are opted out.
+func (dh *DBHelper) GetMarketingConsent(userID int) (models.MarketingConsent, error) {
+	var consent models.MarketingConsent
+
+	// language=SQL
+	SQL := `SELECT opted_in, created_at
+			FROM marketing_consents
+			WHERE user_id = $1
+			ORDER BY created_at DESC, id DESC
+			LIMIT 1`
+
+	err := dh.DB.Get(&consent, SQL, userID)
+	if err == sql.ErrNoRows {
+		return consent, nil
+	}
+	if err != nil {
+		logrus.Errorf("GetMarketingConsent: error getting consent %v", err)
+	}
+	return consent, err
+}
+
+func containsInt(values []int, value int) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
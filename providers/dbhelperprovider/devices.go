@@ -0,0 +1,222 @@
+package dbhelperprovider
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/vijaygniit/ApnaSabji/models"
+)
+
+// sessionDeviceKeySQL computes deviceKey for a sessions row, it has to stay in sync with deviceKey.
+const sessionDeviceKeySQL = `COALESCE(NULLIF(sessions.device_id, ''), 'fp:' || COALESCE(sessions.platform, '') || '|' || COALESCE(sessions.model_name, ''))`
+
+// deviceKey identifies a device by its device id. Apps that send none are told apart by platform and
+// model only, the OS version is left out since it changes with every update. Both come from the
+// client, so someone who knows the id or model of a device the user already has can log in without
+// raising an alert. New device alerts are a courtesy to the user, not a control against attackers.
+func deviceKey(device models.CreateSessionRequest) string {
+	if device.DeviceID != "" {
+		return device.DeviceID
+	}
+	return "fp:" + device.Platform + "|" + device.ModelName
+}
+
+// RecordDeviceLogin remembers the device a user just logged in from and reports whether it was new.
+func (dh *DBHelper) RecordDeviceLogin(userID int, device models.CreateSessionRequest, ip string) (models.DeviceLogin, error) {
+	var deviceLogin models.DeviceLogin
+
+	tx, err := dh.DB.Beginx()
+	if err != nil {
+		logrus.Errorf("RecordDeviceLogin: error starting transaction %v", err)
+		return deviceLogin, err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	var knownDevices int
+	// language=SQL
+	if err = tx.Get(&knownDevices, `SELECT count(*) FROM user_devices WHERE user_id = $1`, userID); err != nil {
+		logrus.Errorf("RecordDeviceLogin: error counting devices %v", err)
+		return deviceLogin, err
+	}
+
+	// xmax = 0 only for rows this statement inserted
+	// language=SQL
+	SQL := `INSERT INTO user_devices
+			(user_id, device_key, platform, model_name, os_version, device_id, last_ip)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)
+			ON CONFLICT (user_id, device_key) DO UPDATE
+			SET platform = excluded.platform,
+			    model_name = excluded.model_name,
+			    os_version = excluded.os_version,
+			    last_ip = excluded.last_ip,
+			    last_seen_at = now()
+			RETURNING id, (xmax = 0) AS inserted`
+
+	var upserted = struct {
+		ID       int  `db:"id"`
+		Inserted bool `db:"inserted"`
+	}{}
+	err = tx.Get(&upserted, SQL, userID, deviceKey(device), device.Platform, device.ModelName, device.OSVersion, device.DeviceID, ip)
+	if err != nil {
+		logrus.Errorf("RecordDeviceLogin: error storing device %v", err)
+		return deviceLogin, err
+	}
+
+	deviceLogin.UserDeviceID = upserted.ID
+	deviceLogin.IsNewDevice = upserted.Inserted
+	deviceLogin.IsFirstDevice = upserted.Inserted && knownDevices == 0
+	return deviceLogin, tx.Commit()
+}
+
+// CreateDeviceAlert returns a one-time token for the "this wasn't me" link sent about a new device login.
+func (dh *DBHelper) CreateDeviceAlert(userID int, sessionToken string, device models.CreateSessionRequest, ip string, expiresIn time.Duration) (alertToken string, err error) {
+	alertToken, err = generateToken()
+	if err != nil {
+		logrus.Errorf("CreateDeviceAlert: error generating token %v", err)
+		return "", err
+	}
+
+	// language=SQL
+	SQL := `INSERT INTO device_alerts
+			(user_id, session_id, device_key, token_hash, ip, expires_at)
+			SELECT $1, id, $3, $4, $5, $6
+			FROM sessions
+			WHERE token = $2
+			  AND user_id = $1`
+
+	err = execAffectingRow(dh.DB, SQL, userID, sessionToken, deviceKey(device), hashToken(alertToken), ip, time.Now().UTC().Add(expiresIn))
+	if err != nil {
+		logrus.Errorf("CreateDeviceAlert: error storing alert %v", err)
+		return "", err
+	}
+	return alertToken, nil
+}
+
+// GetDeviceAlert returns the login behind an alert link that is still usable, without using it.
+func (dh *DBHelper) GetDeviceAlert(alertToken string) (models.DeviceAlert, error) {
+	// language=SQL
+	SQL := `SELECT COALESCE(sessions.platform, '')   AS platform,
+				   COALESCE(sessions.model_name, '') AS model_name,
+				   device_alerts.ip,
+				   device_alerts.created_at
+			FROM device_alerts
+			JOIN sessions ON sessions.id = device_alerts.session_id
+			WHERE device_alerts.token_hash = $1
+			  AND device_alerts.used_at IS NULL
+			  AND device_alerts.expires_at > now()`
+
+	var alert models.DeviceAlert
+	err := dh.DB.Get(&alert, SQL, hashToken(alertToken))
+	if err != nil && err != sql.ErrNoRows {
+		logrus.Errorf("GetDeviceAlert: error getting alert %v", err)
+	}
+	return alert, err
+}
+
+// RevokeSessionFromDeviceAlert ends the session the alert was about and forgets the device,
+// so logging in from it again raises a new alert.
+func (dh *DBHelper) RevokeSessionFromDeviceAlert(alertToken string) (userID int, err error) {
+	tx, err := dh.DB.Beginx()
+	if err != nil {
+		logrus.Errorf("RevokeSessionFromDeviceAlert: error starting transaction %v", err)
+		return 0, err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	var alert = struct {
+		ID        int    `db:"id"`
+		UserID    int    `db:"user_id"`
+		SessionID int    `db:"session_id"`
+		DeviceKey string `db:"device_key"`
+	}{}
+
+	// language=SQL
+	SQL := `SELECT id, user_id, session_id, device_key
+			FROM device_alerts
+			WHERE token_hash = $1
+			  AND used_at IS NULL
+			  AND expires_at > now()
+			FOR UPDATE`
+	if err = tx.Get(&alert, SQL, hashToken(alertToken)); err != nil {
+		if err != sql.ErrNoRows {
+			logrus.Errorf("RevokeSessionFromDeviceAlert: error getting alert %v", err)
+		}
+		return 0, err
+	}
+
+	if err = revokeSession(tx, alert.SessionID); err != nil {
+		logrus.Errorf("RevokeSessionFromDeviceAlert: error revoking session %v", err)
+		return 0, err
+	}
+
+	// language=SQL
+	if _, err = tx.Exec(`DELETE FROM user_devices WHERE user_id = $1 AND device_key = $2`, alert.UserID, alert.DeviceKey); err != nil {
+		logrus.Errorf("RevokeSessionFromDeviceAlert: error forgetting device %v", err)
+		return 0, err
+	}
+
+	// language=SQL
+	if _, err = tx.Exec(`UPDATE device_alerts SET used_at = now() WHERE id = $1`, alert.ID); err != nil {
+		logrus.Errorf("RevokeSessionFromDeviceAlert: error using alert %v", err)
+		return 0, err
+	}
+
+	return alert.UserID, tx.Commit()
+}
+
+func (dh *DBHelper) ListUserDevices(userID int) ([]models.UserDevice, error) {
+	// language=SQL
+	SQL := `SELECT id, name, platform, model_name, os_version, device_id, last_ip, first_seen_at, last_seen_at
+			FROM user_devices
+			WHERE user_id = $1
+			ORDER BY last_seen_at DESC`
+
+	devices := make([]models.UserDevice, 0)
+	if err := dh.DB.Select(&devices, SQL, userID); err != nil {
+		logrus.Errorf("ListUserDevices: error getting devices %v", err)
+		return devices, err
+	}
+	return devices, nil
+}
+
+func (dh *DBHelper) RenameUserDevice(userID, userDeviceID int, name string) error {
+	// language=SQL
+	SQL := `UPDATE user_devices
+			SET name = $3
+			WHERE id = $1
+			  AND user_id = $2`
+
+	err := execAffectingRow(dh.DB, SQL, userDeviceID, userID, name)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		logrus.Errorf("RenameUserDevice: error renaming device %v", err)
+	}
+	return err
+}
+
+// ForgetUserDevice removes the device and logs out every session opened from it.
+func (dh *DBHelper) ForgetUserDevice(userID, userDeviceID int) error {
+	var key string
+	// language=SQL
+	SQL := `DELETE FROM user_devices
+			WHERE id = $1
+			  AND user_id = $2
+			RETURNING device_key`
+	if err := dh.DB.Get(&key, SQL, userDeviceID, userID); err != nil {
+		if err != sql.ErrNoRows {
+			logrus.Errorf("ForgetUserDevice: error deleting device %v", err)
+		}
+		return err
+	}
+
+	return dh.revokeSessions(`SELECT id
+			FROM sessions
+			WHERE user_id = $1
+			  AND revoked_at IS NULL
+			  AND `+sessionDeviceKeySQL+` = $2`, userID, key)
+}
@@ -0,0 +1,282 @@
+package dbhelperprovider
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/vijaygniit/ApnaSabji/models"
+)
+
+const dataExportColumns = `id, user_id, status, expires_at, downloaded_at, error, created_at, completed_at`
+
+// CreateDataExport queues an export, reusing one that is still waiting to be built.
+func (dh *DBHelper) CreateDataExport(userID int) (models.DataExport, error) {
+	var dataExport models.DataExport
+
+	// language=SQL
+	SQL := `SELECT ` + dataExportColumns + `
+			FROM data_exports
+			WHERE user_id = $1
+			  AND status IN ($2, $3)
+			ORDER BY created_at DESC
+			LIMIT 1`
+
+	err := dh.DB.Get(&dataExport, SQL, userID, models.ExportStatusQueued, models.ExportStatusProcessing)
+	if err == nil {
+		return dataExport, nil
+	}
+	if err != sql.ErrNoRows {
+		logrus.Errorf("CreateDataExport: error getting pending export %v", err)
+		return dataExport, err
+	}
+
+	// language=SQL
+	SQL = `INSERT INTO data_exports
+			(user_id, status)
+			VALUES ($1, $2)
+			RETURNING ` + dataExportColumns
+
+	if err = dh.DB.Get(&dataExport, SQL, userID, models.ExportStatusQueued); err != nil {
+		logrus.Errorf("CreateDataExport: error queueing export %v", err)
+		return dataExport, err
+	}
+
+	return dataExport, nil
+}
+
+func (dh *DBHelper) GetDataExport(userID, exportID int) (models.DataExport, error) {
+	// language=SQL
+	SQL := `SELECT ` + dataExportColumns + `
+			FROM data_exports
+			WHERE id = $1
+			  AND user_id = $2`
+
+	var dataExport models.DataExport
+	err := dh.DB.Get(&dataExport, SQL, exportID, userID)
+	if err != nil && err != sql.ErrNoRows {
+		logrus.Errorf("GetDataExport: error getting export %v", err)
+	}
+	return dataExport, err
+}
+
+// ClaimNextDataExport marks the oldest queued export as processing. SKIP LOCKED lets several replicas share
+// the queue. An export left processing longer than staleAfter, by a replica that died building it, is
+// claimed again.
+func (dh *DBHelper) ClaimNextDataExport(staleAfter time.Duration) (models.DataExport, error) {
+	// language=SQL
+	SQL := `UPDATE data_exports
+			SET status = $2,
+			    claimed_at = now()
+			WHERE id = (SELECT id
+						FROM data_exports
+						WHERE status = $1
+						   OR (status = $2 AND (claimed_at IS NULL OR claimed_at < $3))
+						ORDER BY created_at
+						LIMIT 1 FOR UPDATE SKIP LOCKED)
+			RETURNING ` + dataExportColumns
+
+	var dataExport models.DataExport
+	err := dh.DB.Get(&dataExport, SQL, models.ExportStatusQueued, models.ExportStatusProcessing, time.Now().UTC().Add(-staleAfter))
+	if err != nil && err != sql.ErrNoRows {
+		logrus.Errorf("ClaimNextDataExport: error claiming export %v", err)
+	}
+	return dataExport, err
+}
+
+// CompleteDataExport stores the archive and returns the one time download token. An export that was
+// claimed again after going stale is only completed once.
+func (dh *DBHelper) CompleteDataExport(exportID int, archive []byte, expiresAt time.Time) (string, error) {
+	downloadToken, err := generateToken()
+	if err != nil {
+		logrus.Errorf("CompleteDataExport: error generating download token %v", err)
+		return "", err
+	}
+
+	// language=SQL
+	SQL := `UPDATE data_exports
+			SET status = $2,
+			    archive = $3,
+			    download_token_hash = $4,
+			    expires_at = $5,
+			    completed_at = now()
+			WHERE id = $1
+			  AND status = $6`
+
+	err = execAffectingRow(dh.DB, SQL, exportID, models.ExportStatusReady, archive, hashToken(downloadToken), expiresAt, models.ExportStatusProcessing)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			logrus.Errorf("CompleteDataExport: error completing export %v", err)
+		}
+		return "", err
+	}
+	return downloadToken, nil
+}
+
+func (dh *DBHelper) FailDataExport(exportID int, reason string) error {
+	// language=SQL
+	SQL := `UPDATE data_exports
+			SET status = $2,
+			    error = $3,
+			    completed_at = now()
+			WHERE id = $1`
+
+	if _, err := dh.DB.Exec(SQL, exportID, models.ExportStatusFailed, reason); err != nil {
+		logrus.Errorf("FailDataExport: error failing export %v", err)
+		return err
+	}
+	return nil
+}
+
+// ConsumeDataExportDownload redeems a download link and returns its archive. Each link works once and only
+// until it expires, the archive is read before the link is spent and deleted with it.
+func (dh *DBHelper) ConsumeDataExportDownload(downloadToken string) (models.DataExport, []byte, error) {
+	var dataExport models.DataExport
+
+	tx, err := dh.DB.Beginx()
+	if err != nil {
+		logrus.Errorf("ConsumeDataExportDownload: error starting transaction %v", err)
+		return dataExport, nil, err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	// language=SQL
+	SQL := `SELECT id, archive
+			FROM data_exports
+			WHERE download_token_hash = $1
+			  AND status = $2
+			  AND expires_at > now()
+			  AND archive IS NOT NULL
+			FOR UPDATE`
+
+	var stored struct {
+		ID      int    `db:"id"`
+		Archive []byte `db:"archive"`
+	}
+	if err = tx.Get(&stored, SQL, hashToken(downloadToken), models.ExportStatusReady); err != nil {
+		if err != sql.ErrNoRows {
+			logrus.Errorf("ConsumeDataExportDownload: error getting archive %v", err)
+		}
+		return dataExport, nil, err
+	}
+
+	// language=SQL
+	SQL = `UPDATE data_exports
+			SET status = $2,
+			    archive = NULL,
+			    downloaded_at = now()
+			WHERE id = $1
+			RETURNING ` + dataExportColumns
+
+	if err = tx.Get(&dataExport, SQL, stored.ID, models.ExportStatusDownloaded); err != nil {
+		logrus.Errorf("ConsumeDataExportDownload: error consuming download %v", err)
+		return dataExport, nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		logrus.Errorf("ConsumeDataExportDownload: error committing download %v", err)
+		return dataExport, nil, err
+	}
+	return dataExport, stored.Archive, nil
+}
+
+// ExpireDataExports marks ready exports past their link expiry as expired and deletes their archives.
+func (dh *DBHelper) ExpireDataExports() (int64, error) {
+	// language=SQL
+	SQL := `UPDATE data_exports
+			SET status = $2,
+			    archive = NULL
+			WHERE status = $1
+			  AND expires_at <= now()`
+
+	result, err := dh.DB.Exec(SQL, models.ExportStatusReady, models.ExportStatusExpired)
+	if err != nil {
+		logrus.Errorf("ExpireDataExports: error expiring exports %v", err)
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+func (dh *DBHelper) GetUserAccountExport(userID int) (models.UserAccountExport, error) {
+	// language=SQL
+	SQL := `SELECT id, fullname, email, mobilenumber, created_at, updated_at, deactivated, archived_at
+			FROM users
+			WHERE id = $1`
+
+	var account models.UserAccountExport
+	if err := dh.DB.Get(&account, SQL, userID); err != nil {
+		logrus.Errorf("GetUserAccountExport: error getting user %v", err)
+		return account, err
+	}
+	return account, nil
+}
+
+func (dh *DBHelper) FetchUserSessionHistory(userID int) ([]models.SessionHistory, error) {
+	// language=SQL
+	SQL := `SELECT id,
+				   COALESCE(platform, '')   AS platform,
+				   COALESCE(model_name, '') AS model_name,
+				   COALESCE(os_version, '') AS os_version,
+				   COALESCE(device_id, '')  AS device_id,
+				   start_time,
+				   end_time,
+				   revoked_at
+			FROM sessions
+			WHERE user_id = $1
+			ORDER BY start_time DESC`
+
+	sessions := make([]models.SessionHistory, 0)
+	if err := dh.DB.Select(&sessions, SQL, userID); err != nil {
+		logrus.Errorf("FetchUserSessionHistory: error getting sessions %v", err)
+		return sessions, err
+	}
+	return sessions, nil
+}
+
+func (dh *DBHelper) ListUserAuthEvents(userID int) ([]models.AuthEvent, error) {
+	// language=SQL
+	SQL := `SELECT id, user_id, event_type, outcome, reason, email, phone, ip, user_agent,
+				   platform, model_name, os_version, device_id, created_at
+			FROM auth_events
+			WHERE user_id = $1
+			ORDER BY created_at DESC, id DESC`
+
+	events := make([]models.AuthEvent, 0)
+	if err := dh.DB.Select(&events, SQL, userID); err != nil {
+		logrus.Errorf("ListUserAuthEvents: error getting auth events %v", err)
+		return events, err
+	}
+	return events, nil
+}
+
+func (dh *DBHelper) ListUserIdentities(userID int) ([]models.UserIdentityExport, error) {
+	// language=SQL
+	SQL := `SELECT provider, subject, email, created_at
+			FROM user_identities
+			WHERE user_id = $1
+			ORDER BY created_at`
+
+	identities := make([]models.UserIdentityExport, 0)
+	if err := dh.DB.Select(&identities, SQL, userID); err != nil {
+		logrus.Errorf("ListUserIdentities: error getting identities %v", err)
+		return identities, err
+	}
+	return identities, nil
+}
+
+func (dh *DBHelper) ListUserProductViews(userID int) ([]models.ProductViewExport, error) {
+	// language=SQL
+	SQL := `SELECT product_id, viewed_on
+			FROM product_views
+			WHERE user_id = $1
+			ORDER BY viewed_on DESC, product_id`
+
+	views := make([]models.ProductViewExport, 0)
+	if err := dh.DB.Select(&views, SQL, userID); err != nil {
+		logrus.Errorf("ListUserProductViews: error getting product views %v", err)
+		return views, err
+	}
+	return views, nil
+}
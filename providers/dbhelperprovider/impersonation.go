@@ -0,0 +1,41 @@
+package dbhelperprovider
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"github.com/vijaygniit/ApnaSabji/models"
+)
+
+// StartImpersonationSession opens a session for userID on behalf of actorID. Unlike regular sessions
+// it is not extended by UpdateSession and gets no refresh token, so it ends with the token that uses it.
+// It never counts as having passed MFA, the admin proved their own second factor, not the user's.
+func (dh *DBHelper) StartImpersonationSession(userID, actorID int, expiresIn time.Duration) (string, error) {
+	// language=SQL
+	SQL := `INSERT INTO sessions
+			(user_id, start_time, end_time, platform, model_name, os_version, device_id, token, impersonated_by)
+			VALUES ($1, $2, $3, 'impersonation', '', '', '', $4, $5)
+			RETURNING token`
+
+	now := time.Now()
+	var token string
+	if err := dh.DB.Get(&token, SQL, userID, now, now.Add(expiresIn), uuid.New(), actorID); err != nil {
+		logrus.Errorf("StartImpersonationSession: error starting session %v", err)
+		return token, err
+	}
+	return token, nil
+}
+
+func (dh *DBHelper) RecordImpersonatedRequest(request models.ImpersonatedRequest) error {
+	// language=SQL
+	SQL := `INSERT INTO impersonation_requests
+			(session_id, actor_id, user_id, method, path, status_code, ip)
+			VALUES (:session_id, :actor_id, :user_id, :method, :path, :status_code, :ip)`
+
+	if _, err := dh.DB.NamedExec(SQL, request); err != nil {
+		logrus.Errorf("RecordImpersonatedRequest: error recording request %v", err)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,391 @@
+package dbhelperprovider
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/sirupsen/logrus"
+	"github.com/vijaygniit/ApnaSabji/models"
+	"github.com/vijaygniit/ApnaSabji/providers/authProvider"
+	"github.com/vijaygniit/ApnaSabji/scmerrors"
+)
+
+const (
+	recoveryCodeCount    = 10
+	recoveryCodeAlphabet = "abcdefghjkmnpqrstuvwxyz23456789"
+	recoveryCodeLength   = 10
+)
+
+func (dh *DBHelper) GetMFAStatus(userID int) (models.MFAStatus, error) {
+	// language=SQL
+	SQL := `SELECT user_mfa.confirmed_at IS NOT NULL AS enabled,
+				   user_mfa.confirmed_at,
+				   (SELECT count(*)
+					FROM mfa_recovery_codes
+					WHERE mfa_recovery_codes.user_id = users.id
+					  AND mfa_recovery_codes.used_at IS NULL) AS recovery_codes_remaining
+			FROM users
+			LEFT JOIN user_mfa ON user_mfa.user_id = users.id
+			WHERE users.id = $1`
+
+	var status models.MFAStatus
+	if err := dh.DB.Get(&status, SQL, userID); err != nil {
+		logrus.Errorf("GetMFAStatus: error getting mfa status %v", err)
+		return status, err
+	}
+	return status, nil
+}
+
+// StartTOTPEnrollment stores a new secret waiting for its first code, replacing an earlier unconfirmed one.
+func (dh *DBHelper) StartTOTPEnrollment(userID int, secret string) error {
+	encryptedSecret, err := encryptMFASecret(secret)
+	if err != nil {
+		logrus.Errorf("StartTOTPEnrollment: error encrypting secret %v", err)
+		return err
+	}
+
+	// language=SQL
+	SQL := `INSERT INTO user_mfa
+			(user_id, secret_encrypted)
+			VALUES ($1, $2)
+			ON CONFLICT (user_id) DO UPDATE
+			SET secret_encrypted = excluded.secret_encrypted,
+			    last_used_step = 0,
+			    created_at = now()
+			WHERE user_mfa.confirmed_at IS NULL`
+
+	err = execAffectingRow(dh.DB, SQL, userID, encryptedSecret)
+	if errors.Is(err, sql.ErrNoRows) {
+		return scmerrors.ErrMFAAlreadyEnabled
+	}
+	if err != nil {
+		logrus.Errorf("StartTOTPEnrollment: error storing secret %v", err)
+	}
+	return err
+}
+
+// ConfirmTOTPEnrollment turns MFA on once the user proves their app generates the right codes.
+// The current session counts as verified and the recovery codes are returned in plain text only this once.
+func (dh *DBHelper) ConfirmTOTPEnrollment(userID int, sessionToken, code string) (recoveryCodes []string, err error) {
+	tx, err := dh.DB.Beginx()
+	if err != nil {
+		logrus.Errorf("ConfirmTOTPEnrollment: error starting transaction %v", err)
+		return nil, err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	mfa, err := getUserMFAForUpdate(tx, userID)
+	if err == sql.ErrNoRows {
+		return nil, scmerrors.ErrMFANotEnabled
+	}
+	if err != nil {
+		logrus.Errorf("ConfirmTOTPEnrollment: error getting secret %v", err)
+		return nil, err
+	}
+	if mfa.ConfirmedAt.Valid {
+		return nil, scmerrors.ErrMFAAlreadyEnabled
+	}
+
+	step, ok := authProvider.ValidateTOTP(mfa.Secret, code, time.Now())
+	if !ok {
+		return nil, scmerrors.ErrMFAInvalidCode
+	}
+
+	// language=SQL
+	SQL := `UPDATE user_mfa
+			SET confirmed_at = now(),
+			    last_used_step = $2
+			WHERE user_id = $1`
+	if _, err = tx.Exec(SQL, userID, step); err != nil {
+		logrus.Errorf("ConfirmTOTPEnrollment: error confirming secret %v", err)
+		return nil, err
+	}
+
+	if recoveryCodes, err = replaceRecoveryCodes(tx, userID); err != nil {
+		logrus.Errorf("ConfirmTOTPEnrollment: error storing recovery codes %v", err)
+		return nil, err
+	}
+
+	if err = markSessionMFASatisfied(tx, userID, sessionToken); err != nil {
+		logrus.Errorf("ConfirmTOTPEnrollment: error updating session %v", err)
+		return nil, err
+	}
+
+	return recoveryCodes, tx.Commit()
+}
+
+// VerifyMFA checks an authenticator or recovery code and marks the session as having passed MFA.
+// A TOTP code is accepted once, a recovery code is used up.
+func (dh *DBHelper) VerifyMFA(userID int, sessionToken string, verifyReq models.MFAVerifyRequest) error {
+	tx, err := dh.DB.Beginx()
+	if err != nil {
+		logrus.Errorf("VerifyMFA: error starting transaction %v", err)
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	mfa, err := getUserMFAForUpdate(tx, userID)
+	if err == sql.ErrNoRows || (err == nil && !mfa.ConfirmedAt.Valid) {
+		return scmerrors.ErrMFANotEnabled
+	}
+	if err != nil {
+		logrus.Errorf("VerifyMFA: error getting secret %v", err)
+		return err
+	}
+
+	switch {
+	case verifyReq.Code != "":
+		step, ok := authProvider.ValidateTOTP(mfa.Secret, verifyReq.Code, time.Now())
+		if !ok || step <= mfa.LastUsedStep {
+			return scmerrors.ErrMFAInvalidCode
+		}
+
+		// language=SQL
+		if _, err = tx.Exec(`UPDATE user_mfa SET last_used_step = $2 WHERE user_id = $1`, userID, step); err != nil {
+			logrus.Errorf("VerifyMFA: error storing used step %v", err)
+			return err
+		}
+	case verifyReq.RecoveryCode != "":
+		// language=SQL
+		SQL := `UPDATE mfa_recovery_codes
+				SET used_at = now()
+				WHERE user_id = $1
+				  AND code_hash = $2
+				  AND used_at IS NULL`
+		err = execAffectingRow(tx, SQL, userID, hashRecoveryCode(userID, verifyReq.RecoveryCode))
+		if errors.Is(err, sql.ErrNoRows) {
+			return scmerrors.ErrMFAInvalidCode
+		}
+		if err != nil {
+			logrus.Errorf("VerifyMFA: error using recovery code %v", err)
+			return err
+		}
+	default:
+		return scmerrors.ErrMFAInvalidCode
+	}
+
+	if err = markSessionMFASatisfied(tx, userID, sessionToken); err != nil {
+		logrus.Errorf("VerifyMFA: error updating session %v", err)
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// RegenerateRecoveryCodes throws away every unused code and returns a fresh set.
+func (dh *DBHelper) RegenerateRecoveryCodes(userID int) ([]string, error) {
+	tx, err := dh.DB.Beginx()
+	if err != nil {
+		logrus.Errorf("RegenerateRecoveryCodes: error starting transaction %v", err)
+		return nil, err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	recoveryCodes, err := replaceRecoveryCodes(tx, userID)
+	if err != nil {
+		logrus.Errorf("RegenerateRecoveryCodes: error storing recovery codes %v", err)
+		return nil, err
+	}
+
+	return recoveryCodes, tx.Commit()
+}
+
+func (dh *DBHelper) DisableMFA(userID int) error {
+	tx, err := dh.DB.Beginx()
+	if err != nil {
+		logrus.Errorf("DisableMFA: error starting transaction %v", err)
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	// language=SQL
+	if err = execAffectingRow(tx, `DELETE FROM user_mfa WHERE user_id = $1`, userID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return scmerrors.ErrMFANotEnabled
+		}
+		logrus.Errorf("DisableMFA: error deleting secret %v", err)
+		return err
+	}
+
+	// language=SQL
+	if _, err = tx.Exec(`DELETE FROM mfa_recovery_codes WHERE user_id = $1`, userID); err != nil {
+		logrus.Errorf("DisableMFA: error deleting recovery codes %v", err)
+		return err
+	}
+
+	return tx.Commit()
+}
+
+type userMFA struct {
+	Secret       string
+	LastUsedStep int64
+	ConfirmedAt  sql.NullTime
+}
+
+func getUserMFAForUpdate(tx *sqlx.Tx, userID int) (userMFA, error) {
+	var row = struct {
+		SecretEncrypted string       `db:"secret_encrypted"`
+		LastUsedStep    int64        `db:"last_used_step"`
+		ConfirmedAt     sql.NullTime `db:"confirmed_at"`
+	}{}
+
+	// language=SQL
+	SQL := `SELECT secret_encrypted, last_used_step, confirmed_at
+			FROM user_mfa
+			WHERE user_id = $1
+			FOR UPDATE`
+	if err := tx.Get(&row, SQL, userID); err != nil {
+		return userMFA{}, err
+	}
+
+	secret, err := decryptMFASecret(row.SecretEncrypted)
+	if err != nil {
+		return userMFA{}, err
+	}
+
+	return userMFA{
+		Secret:       secret,
+		LastUsedStep: row.LastUsedStep,
+		ConfirmedAt:  row.ConfirmedAt,
+	}, nil
+}
+
+func replaceRecoveryCodes(tx *sqlx.Tx, userID int) ([]string, error) {
+	// language=SQL
+	if _, err := tx.Exec(`DELETE FROM mfa_recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return nil, err
+	}
+
+	recoveryCodes := make([]string, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		recoveryCode, err := generateRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+
+		// language=SQL
+		SQL := `INSERT INTO mfa_recovery_codes
+				(user_id, code_hash)
+				VALUES ($1, $2)`
+		if _, err = tx.Exec(SQL, userID, hashRecoveryCode(userID, recoveryCode)); err != nil {
+			return nil, err
+		}
+		recoveryCodes = append(recoveryCodes, recoveryCode)
+	}
+	return recoveryCodes, nil
+}
+
+func markSessionMFASatisfied(tx *sqlx.Tx, userID int, sessionToken string) error {
+	// language=SQL
+	SQL := `UPDATE sessions
+			SET mfa_satisfied_at = now()
+			WHERE user_id = $1
+			  AND token = $2
+			  AND revoked_at IS NULL`
+	return execAffectingRow(tx, SQL, userID, sessionToken)
+}
+
+// generateRecoveryCode returns a code like "k3mz7-qp2xa", avoiding characters that are easy to misread.
+func generateRecoveryCode() (string, error) {
+	randomBytes := make([]byte, recoveryCodeLength)
+	if _, err := rand.Read(randomBytes); err != nil {
+		return "", err
+	}
+
+	var code strings.Builder
+	for i, b := range randomBytes {
+		if i == recoveryCodeLength/2 {
+			code.WriteByte('-')
+		}
+		code.WriteByte(recoveryCodeAlphabet[int(b)%len(recoveryCodeAlphabet)])
+	}
+	return code.String(), nil
+}
+
+// hashRecoveryCode salts with the user id so equal codes of different users do not collide.
+func hashRecoveryCode(userID int, recoveryCode string) string {
+	normalized := strings.ToLower(strings.ReplaceAll(strings.TrimSpace(recoveryCode), "-", ""))
+	return hashOTP(strconv.Itoa(userID), normalized)
+}
+
+// minMFAEncryptionKeyBytes keeps the key at least as strong as the AES-256 key derived from it.
+const minMFAEncryptionKeyBytes = 32
+
+// ValidateMFAEncryptionKey refuses a missing or short MFA_ENCRYPTION_KEY, the server must not start
+// encrypting TOTP secrets with a key anyone could guess.
+func ValidateMFAEncryptionKey(key string) error {
+	if len(key) < minMFAEncryptionKeyBytes {
+		return fmt.Errorf("MFA_ENCRYPTION_KEY must be at least %d bytes", minMFAEncryptionKeyBytes)
+	}
+	return nil
+}
+
+// mfaCipher derives an AES-256 key from MFA_ENCRYPTION_KEY. TOTP secrets have to be read back
+// to check codes, so unlike OTPs they are encrypted rather than hashed.
+func mfaCipher() (cipher.AEAD, error) {
+	if err := ValidateMFAEncryptionKey(os.Getenv("MFA_ENCRYPTION_KEY")); err != nil {
+		return nil, err
+	}
+
+	key := sha256.Sum256([]byte(os.Getenv("MFA_ENCRYPTION_KEY")))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func encryptMFASecret(secret string) (string, error) {
+	gcm, err := mfaCipher()
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err = rand.Read(nonce); err != nil {
+		return "", err
+	}
+
+	sealed := gcm.Seal(nonce, nonce, []byte(secret), nil)
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+func decryptMFASecret(encryptedSecret string) (string, error) {
+	gcm, err := mfaCipher()
+	if err != nil {
+		return "", err
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(encryptedSecret)
+	if err != nil {
+		return "", err
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return "", errors.New("encrypted secret too short")
+	}
+
+	secret, err := gcm.Open(nil, sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():], nil)
+	if err != nil {
+		return "", err
+	}
+	return string(secret), nil
+}
@@ -0,0 +1,178 @@
+package dbhelperprovider
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/vijaygniit/ApnaSabji/models"
+	"github.com/vijaygniit/ApnaSabji/scmerrors"
+	"github.com/volatiletech/null"
+)
+
+// CreateOIDCLoginState generates the state, nonce and PKCE verifier of a login and stores them until the
+// provider calls back. Only a hash of state is kept, it is the only thing tying the callback to the
+// browser that started the login.
+func (dh *DBHelper) CreateOIDCLoginState(loginState *models.OIDCLoginState) (string, error) {
+	// the PKCE verifier is 43 url safe characters, within the 43-128 RFC 7636 allows
+	secrets := make([]string, 3)
+	for i := range secrets {
+		secret, err := generateToken()
+		if err != nil {
+			logrus.Errorf("CreateOIDCLoginState: error generating state %v", err)
+			return "", err
+		}
+		secrets[i] = secret
+	}
+	state := secrets[0]
+	loginState.Nonce, loginState.CodeVerifier = secrets[1], secrets[2]
+
+	// language=SQL
+	SQL := `INSERT INTO oidc_login_states
+			(state_hash, provider, nonce, code_verifier, platform, model_name, os_version, device_id, expires_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`
+
+	_, err := dh.DB.Exec(SQL, hashToken(state), loginState.Provider, loginState.Nonce, loginState.CodeVerifier,
+		loginState.Platform, loginState.ModelName, loginState.OSVersion, loginState.DeviceID, loginState.ExpiresAt)
+	if err != nil {
+		logrus.Errorf("CreateOIDCLoginState: error storing state %v", err)
+		return "", err
+	}
+
+	// language=SQL
+	if _, err = dh.DB.Exec(`DELETE FROM oidc_login_states WHERE expires_at < now()`); err != nil {
+		logrus.Errorf("CreateOIDCLoginState: error deleting expired states %v", err)
+	}
+	return state, nil
+}
+
+// ConsumeOIDCLoginState returns and deletes the state so a callback can not be replayed.
+func (dh *DBHelper) ConsumeOIDCLoginState(state, provider string) (models.OIDCLoginState, error) {
+	// language=SQL
+	SQL := `DELETE FROM oidc_login_states
+			WHERE state_hash = $1
+			  AND provider = $2
+			RETURNING provider, nonce, code_verifier, platform, model_name, os_version, device_id, expires_at`
+
+	var loginState models.OIDCLoginState
+	if err := dh.DB.Get(&loginState, SQL, hashToken(state), provider); err != nil {
+		if err == sql.ErrNoRows {
+			return loginState, scmerrors.ErrOIDCStateInvalid
+		}
+		logrus.Errorf("ConsumeOIDCLoginState: error getting state %v", err)
+		return loginState, err
+	}
+
+	if time.Now().After(loginState.ExpiresAt) {
+		return loginState, scmerrors.ErrOIDCStateInvalid
+	}
+	return loginState, nil
+}
+
+// LinkOIDCIdentity finds the account for an external identity. An identity seen before maps to its
+// account, otherwise a verified email links to the account with that email or signs up a new one. An
+// account whose email was never proven by an OTP is not linked, whoever registered it first may not
+// own the email. Everything runs in one transaction so concurrent callbacks link a single account.
+func (dh *DBHelper) LinkOIDCIdentity(identity models.OIDCIdentity) (userID int, err error) {
+	tx, err := dh.DB.Beginx()
+	if err != nil {
+		logrus.Errorf("LinkOIDCIdentity: error starting transaction %v", err)
+		return 0, err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	// serializes callbacks for the same identity and for the same email until commit
+	// language=SQL
+	SQL := `SELECT pg_advisory_xact_lock(hashtext('oidc:' || $1 || ':' || $2)),
+				   pg_advisory_xact_lock(hashtext('email:' || lower($3)))`
+	if _, err = tx.Exec(SQL, identity.Provider, identity.Subject, identity.Email); err != nil {
+		logrus.Errorf("LinkOIDCIdentity: error locking identity %v", err)
+		return 0, err
+	}
+
+	var linked = struct {
+		UserID        int  `db:"user_id"`
+		IsActive      bool `db:"is_active"`
+		EmailVerified bool `db:"email_verified"`
+	}{}
+
+	// language=SQL
+	SQL = `SELECT users.id                                                  AS user_id,
+				   users.deactivated IS FALSE AND users.archived_at IS NULL AS is_active
+			FROM user_identities
+			JOIN users ON users.id = user_identities.user_id
+			WHERE user_identities.provider = $1
+			  AND user_identities.subject = $2`
+
+	err = tx.Get(&linked, SQL, identity.Provider, identity.Subject)
+	if err == nil {
+		if !linked.IsActive {
+			return 0, scmerrors.ErrOIDCAccountDisabled
+		}
+		return linked.UserID, nil
+	}
+	if err != sql.ErrNoRows {
+		logrus.Errorf("LinkOIDCIdentity: error getting identity %v", err)
+		return 0, err
+	}
+
+	// an unverified email could belong to someone else, linking on it would hand over their account
+	if identity.Email == "" || !identity.EmailVerified {
+		return 0, scmerrors.ErrOIDCEmailNotVerified
+	}
+
+	// language=SQL
+	SQL = `SELECT id                                             AS user_id,
+				  deactivated IS FALSE AND archived_at IS NULL AS is_active,
+				  email_verified_at IS NOT NULL                AS email_verified
+			FROM users
+			WHERE email = lower($1)
+			ORDER BY archived_at IS NULL DESC, id DESC
+			LIMIT 1`
+
+	err = tx.Get(&linked, SQL, identity.Email)
+	switch {
+	case err == nil && !linked.IsActive:
+		return 0, scmerrors.ErrOIDCAccountDisabled
+	case err == nil && !linked.EmailVerified:
+		return 0, scmerrors.ErrOIDCAccountUnverified
+	case err == nil:
+		userID = linked.UserID
+	case err == sql.ErrNoRows:
+		userID, err = createUser(tx, &models.CreateNewUserRequest{
+			Fullname: identity.Name,
+			Email:    null.StringFrom(identity.Email),
+		}, 0, nil)
+		if err != nil {
+			logrus.Errorf("LinkOIDCIdentity: error creating user %v", err)
+			return 0, err
+		}
+
+		// the provider verified the email of the account it signs up
+		// language=SQL
+		if _, err = tx.Exec(`UPDATE users SET email_verified_at = now() WHERE id = $1`, userID); err != nil {
+			logrus.Errorf("LinkOIDCIdentity: error marking email verified %v", err)
+			return 0, err
+		}
+	default:
+		logrus.Errorf("LinkOIDCIdentity: error getting user by email %v", err)
+		return 0, err
+	}
+
+	// language=SQL
+	SQL = `INSERT INTO user_identities
+			(user_id, provider, subject, email)
+			VALUES ($1, $2, $3, lower($4))`
+	if _, err = tx.Exec(SQL, userID, identity.Provider, identity.Subject, identity.Email); err != nil {
+		logrus.Errorf("LinkOIDCIdentity: error linking identity %v", err)
+		return 0, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		logrus.Errorf("LinkOIDCIdentity: error committing identity %v", err)
+		return 0, err
+	}
+	return userID, nil
+}
@@ -0,0 +1,209 @@
+package dbhelperprovider
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/vijaygniit/ApnaSabji/models"
+	"github.com/vijaygniit/ApnaSabji/scmerrors"
+)
+
+// GetOTPLoginUser finds the active account with the given email or phone number. The OTP is sent to the
+// contact stored on it, never to the one in the request.
+func (dh *DBHelper) GetOTPLoginUser(email, mobilenumber string) (models.FetchUserData, error) {
+	// language=SQL
+	SQL := `SELECT id, fullname, COALESCE(email, '') AS email, COALESCE(mobilenumber, '') AS mobilenumber
+			FROM users
+			WHERE ((email = lower($1) AND $1 != '') OR (mobilenumber = $2 AND $2 != ''))
+			  AND deactivated IS FALSE
+			  AND archived_at IS NULL
+			LIMIT 1`
+
+	var user models.FetchUserData
+	err := dh.DB.Get(&user, SQL, email, mobilenumber)
+	if err != nil && err != sql.ErrNoRows {
+		logrus.Errorf("GetOTPLoginUser: error getting user %v", err)
+	}
+	return user, err
+}
+
+func (dh *DBHelper) GenerateAndStoreOTP(otpReq models.GenerateAndStoreOTP) (otp string, err error) {
+	otp, err = generateRandomOTP(otpReq.OTPLength)
+	if err != nil {
+		logrus.Errorf("GenerateAndStoreOTP: error generating OTP %v", err)
+		return "", err
+	}
+
+	salt, err := generateOTPSalt()
+	if err != nil {
+		logrus.Errorf("GenerateAndStoreOTP: error generating OTP salt %v", err)
+		return "", err
+	}
+
+	// language=SQL
+	SQL := `INSERT INTO otp_challenges
+			(user_id, purpose, channel, target, otp_salt, otp_hash, max_attempts, expires_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`
+
+	args := []interface{}{
+		otpReq.UserID,
+		otpReq.Purpose,
+		otpReq.Channel,
+		otpReq.Target,
+		salt,
+		hashOTP(salt, otp),
+		otpReq.MaxAttempts,
+		time.Now().UTC().Add(otpReq.ExpiresIn),
+	}
+
+	if _, err = dh.DB.Exec(SQL, args...); err != nil {
+		logrus.Errorf("GenerateAndStoreOTP: error storing OTP challenge in the database %v", err)
+		return "", err
+	}
+
+	return otp, nil
+}
+
+// VerifyOTP checks otp against the latest challenge of the given purpose sent over channel and consumes it on success.
+// Wrong guesses are counted and the challenge is locked once its attempts are exhausted.
+func (dh *DBHelper) VerifyOTP(userID int, purpose models.OTPPurpose, channel models.OTPChannel, otp string) (target string, err error) {
+	tx, err := dh.DB.Beginx()
+	if err != nil {
+		logrus.Errorf("VerifyOTP: error starting transaction %v", err)
+		return "", err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	// language=SQL
+	SQL := `SELECT id, user_id, purpose, channel, target, otp_salt, otp_hash, attempts, max_attempts, expires_at, consumed, consumed_at, created_at
+			FROM otp_challenges
+			WHERE user_id = $1
+			  AND purpose = $2
+			  AND channel = $3
+			ORDER BY created_at DESC, id DESC
+			LIMIT 1
+			FOR UPDATE`
+
+	var challenge models.OTPChallenge
+	if err = tx.Get(&challenge, SQL, userID, purpose, channel); err != nil {
+		if err == sql.ErrNoRows {
+			return "", scmerrors.ErrOTPNotFound
+		}
+		logrus.Errorf("VerifyOTP: error getting otp challenge %v", err)
+		return "", err
+	}
+
+	switch {
+	case challenge.Consumed:
+		return "", scmerrors.ErrOTPAlreadyUsed
+	case challenge.Attempts >= challenge.MaxAttempts:
+		return "", scmerrors.ErrOTPLocked
+	case time.Now().After(challenge.ExpiresAt):
+		return "", scmerrors.ErrOTPExpired
+	}
+
+	if subtle.ConstantTimeCompare([]byte(hashOTP(challenge.OTPSalt, otp)), []byte(challenge.OTPHash)) != 1 {
+		// language=SQL
+		SQL = `UPDATE otp_challenges
+				SET attempts = attempts + 1
+				WHERE id = $1`
+		if _, err = tx.Exec(SQL, challenge.ID); err != nil {
+			logrus.Errorf("VerifyOTP: error counting failed attempt %v", err)
+			return "", err
+		}
+		if err = tx.Commit(); err != nil {
+			logrus.Errorf("VerifyOTP: error committing failed attempt %v", err)
+			return "", err
+		}
+		if challenge.Attempts+1 >= challenge.MaxAttempts {
+			return "", scmerrors.ErrOTPLocked
+		}
+		return "", scmerrors.ErrOTPInvalid
+	}
+
+	// language=SQL
+	SQL = `UPDATE otp_challenges
+			SET consumed = TRUE,
+			    consumed_at = now()
+			WHERE id = $1`
+	if _, err = tx.Exec(SQL, challenge.ID); err != nil {
+		logrus.Errorf("VerifyOTP: error consuming otp challenge %v", err)
+		return "", err
+	}
+
+	// an OTP sent to the account's own email proves the email, which is what lets a social login link to it
+	// language=SQL
+	SQL = `UPDATE users
+			SET email_verified_at = COALESCE(email_verified_at, now())
+			WHERE id = $1
+			  AND email = lower($2)`
+	if _, err = tx.Exec(SQL, userID, challenge.Target); err != nil {
+		logrus.Errorf("VerifyOTP: error marking email verified %v", err)
+		return "", err
+	}
+
+	if err = tx.Commit(); err != nil {
+		logrus.Errorf("VerifyOTP: error committing otp challenge %v", err)
+		return "", err
+	}
+
+	return challenge.Target, nil
+}
+
+// generateRandomOTP returns a numeric OTP of the given length using a cryptographically secure source.
+func generateRandomOTP(length int) (string, error) {
+	if length <= 0 {
+		return "", errors.New("length should be a positive integer")
+	}
+
+	otp := make([]byte, length)
+	for i := range otp {
+		digit, err := rand.Int(rand.Reader, big.NewInt(10))
+		if err != nil {
+			return "", err
+		}
+		otp[i] = byte('0' + digit.Int64())
+	}
+
+	return string(otp), nil
+}
+
+func generateOTPSalt() (string, error) {
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(salt), nil
+}
+
+// minOTPHashSecretBytes matches the output of the HMAC-SHA256 keyed with OTP_HASH_SECRET.
+const minOTPHashSecretBytes = 32
+
+// ValidateOTPHashSecret refuses a missing or short OTP_HASH_SECRET, with a guessable key a leaked
+// otp_challenges table could be brute forced offline.
+func ValidateOTPHashSecret(secret string) error {
+	if len(secret) < minOTPHashSecretBytes {
+		return fmt.Errorf("OTP_HASH_SECRET must be at least %d bytes", minOTPHashSecretBytes)
+	}
+	return nil
+}
+
+// hashOTP keys the hash with OTP_HASH_SECRET so a leaked table cannot be brute forced offline.
+func hashOTP(salt, otp string) string {
+	mac := hmac.New(sha256.New, []byte(os.Getenv("OTP_HASH_SECRET")))
+	mac.Write([]byte(salt))
+	mac.Write([]byte(otp))
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,281 @@
+package dbhelperprovider
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/sirupsen/logrus"
+	"github.com/vijaygniit/ApnaSabji/models"
+	"github.com/volatiletech/null"
+)
+
+// language=SQL
+const pricingRuleSelectSQL = `SELECT pricing_rules.id, pricing_rules.product_id, products.name AS product_name, pricing_rules.commodity,
+			       pricing_rules.market, pricing_rules.markup_percent, pricing_rules.rounding_paise, units.code AS unit_code,
+			       pricing_rules.floor_paise, pricing_rules.ceiling_paise, pricing_rules.is_active, pricing_rules.created_at,
+			       pricing_rules.updated_at
+			FROM pricing_rules
+			         JOIN products ON products.id = pricing_rules.product_id
+			         JOIN units ON units.id = pricing_rules.unit_id`
+
+// recordPriceChange writes the price_history row every change of a selling price needs.
+func recordPriceChange(tx sqlx.Execer, variantID int, oldPricePaise, newPricePaise int64, source models.PriceChangeSource, mandiPriceDate null.Time, changedBy int) error {
+	// language=SQL
+	SQL := `INSERT INTO price_history
+			(variant_id, old_price_paise, new_price_paise, source, mandi_price_date, changed_by)
+			VALUES ($1, $2, $3, $4, $5, NULLIF($6, 0))`
+
+	_, err := tx.Exec(SQL, variantID, oldPricePaise, newPricePaise, source, mandiPriceDate, changedBy)
+	return err
+}
+
+// ImportMandiPrices stores a day's wholesale rates, importing the same day and market again replaces them.
+func (dh *DBHelper) ImportMandiPrices(prices []models.MandiPrice, importedBy int) error {
+	tx, err := dh.DB.Beginx()
+	if err != nil {
+		logrus.Errorf("ImportMandiPrices: error starting transaction %v", err)
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	// language=SQL
+	SQL := `INSERT INTO mandi_prices
+			(price_date, commodity, market, unit_code, base_unit, base_quantity, modal_price_paise, imported_by)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+			ON CONFLICT (price_date, commodity, market) DO UPDATE
+			SET unit_code         = excluded.unit_code,
+			    base_unit         = excluded.base_unit,
+			    base_quantity     = excluded.base_quantity,
+			    modal_price_paise = excluded.modal_price_paise,
+			    imported_by       = excluded.imported_by,
+			    created_at        = now()`
+
+	for _, price := range prices {
+		args := []interface{}{
+			price.PriceDate,
+			price.Commodity,
+			price.Market,
+			price.UnitCode,
+			price.BaseUnit,
+			price.BaseQuantity,
+			price.ModalPricePaise,
+			importedBy,
+		}
+		if _, err = tx.Exec(SQL, args...); err != nil {
+			logrus.Errorf("ImportMandiPrices: error storing %s %v", price.Commodity, err)
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// ListMandiPrices returns the rates of one day, or of the latest imported day when date is not set.
+func (dh *DBHelper) ListMandiPrices(date null.Time) ([]models.MandiPrice, error) {
+	// language=SQL
+	SQL := `SELECT id, price_date, commodity, market, unit_code, base_unit, base_quantity, modal_price_paise, created_at
+			FROM mandi_prices
+			WHERE price_date = COALESCE($1::DATE, (SELECT max(price_date) FROM mandi_prices))
+			ORDER BY commodity, market`
+
+	prices := make([]models.MandiPrice, 0)
+	if err := dh.DB.Select(&prices, SQL, date); err != nil {
+		logrus.Errorf("ListMandiPrices: error getting prices %v", err)
+		return prices, err
+	}
+	return prices, nil
+}
+
+func (dh *DBHelper) ListPricingRules() ([]models.PricingRule, error) {
+	// language=SQL
+	SQL := pricingRuleSelectSQL + `
+			WHERE products.archived_at IS NULL
+			ORDER BY products.name`
+
+	rules := make([]models.PricingRule, 0)
+	if err := dh.DB.Select(&rules, SQL); err != nil {
+		logrus.Errorf("ListPricingRules: error getting rules %v", err)
+		return rules, err
+	}
+	return rules, nil
+}
+
+// UpsertPricingRule sets the rule of a product, sql.ErrNoRows means the product does not exist.
+func (dh *DBHelper) UpsertPricingRule(productID int, ruleReq models.PricingRuleRequest, createdBy int) (models.PricingRule, error) {
+	var rule models.PricingRule
+
+	// language=SQL
+	SQL := `INSERT INTO pricing_rules
+			(product_id, commodity, market, markup_percent, rounding_paise, unit_id, floor_paise, ceiling_paise, is_active, created_by)
+			SELECT id, $2, $3, $4, $5, (SELECT id FROM units WHERE code = $6), $7, $8, COALESCE($9, TRUE), $10
+			FROM products
+			WHERE id = $1
+			  AND archived_at IS NULL
+			ON CONFLICT (product_id) DO UPDATE
+			SET commodity      = excluded.commodity,
+			    market         = excluded.market,
+			    markup_percent = excluded.markup_percent,
+			    rounding_paise = excluded.rounding_paise,
+			    unit_id        = excluded.unit_id,
+			    floor_paise    = excluded.floor_paise,
+			    ceiling_paise  = excluded.ceiling_paise,
+			    is_active      = excluded.is_active,
+			    updated_at     = now()`
+
+	args := []interface{}{
+		productID,
+		ruleReq.Commodity,
+		ruleReq.Market,
+		ruleReq.MarkupPercent,
+		ruleReq.RoundingPaise,
+		ruleReq.UnitCode,
+		ruleReq.FloorPaise,
+		ruleReq.CeilingPaise,
+		ruleReq.IsActive,
+		createdBy,
+	}
+
+	if err := execAffectingRow(dh.DB, SQL, args...); err != nil {
+		if err != sql.ErrNoRows {
+			logrus.Errorf("UpsertPricingRule: error storing rule %v", err)
+		}
+		return rule, catalogErr(err)
+	}
+
+	// language=SQL
+	err := dh.DB.Get(&rule, pricingRuleSelectSQL+` WHERE pricing_rules.product_id = $1`, productID)
+	if err != nil {
+		logrus.Errorf("UpsertPricingRule: error getting rule %v", err)
+	}
+	return rule, err
+}
+
+func (dh *DBHelper) DeletePricingRule(productID int) error {
+	// language=SQL
+	SQL := `DELETE FROM pricing_rules
+			WHERE product_id = $1`
+
+	err := execAffectingRow(dh.DB, SQL, productID)
+	if err != nil && err != sql.ErrNoRows {
+		logrus.Errorf("DeletePricingRule: error deleting rule %v", err)
+	}
+	return err
+}
+
+// GetPricingInputs returns every active variant with an active rule, next to the latest mandi rate
+// on or before asOf. Rates of several markets are averaged per base unit, the mandi columns are
+// NULL when no rate was imported for the commodity yet.
+func (dh *DBHelper) GetPricingInputs(asOf time.Time) ([]models.PricingInput, error) {
+	// language=SQL
+	SQL := `SELECT product_variants.id AS variant_id, product_variants.sku, products.name AS product_name,
+			       product_variants.pack_size, variant_units.base_unit AS variant_base_unit,
+			       variant_units.base_quantity AS variant_base_quantity, product_variants.mrp_paise,
+			       product_variants.price_paise, pricing_rules.markup_percent, pricing_rules.rounding_paise,
+			       rule_units.base_unit AS rule_base_unit, rule_units.base_quantity AS rule_base_quantity,
+			       pricing_rules.floor_paise, pricing_rules.ceiling_paise, mandi.price_date AS mandi_price_date,
+			       mandi.base_unit AS mandi_base_unit, mandi.per_base_paise AS mandi_per_base_paise
+			FROM pricing_rules
+			         JOIN products ON products.id = pricing_rules.product_id
+			         JOIN product_variants ON product_variants.product_id = products.id
+			         JOIN units variant_units ON variant_units.id = product_variants.unit_id
+			         JOIN units rule_units ON rule_units.id = pricing_rules.unit_id
+			         LEFT JOIN LATERAL (SELECT mandi_prices.price_date,
+			                                   mandi_prices.base_unit,
+			                                   avg(mandi_prices.modal_price_paise::NUMERIC / mandi_prices.base_quantity)::FLOAT8 AS per_base_paise
+			                            FROM mandi_prices
+			                            WHERE mandi_prices.commodity = pricing_rules.commodity
+			                              AND (pricing_rules.market = '' OR mandi_prices.market = pricing_rules.market)
+			                              AND mandi_prices.price_date <= $1
+			                            GROUP BY mandi_prices.price_date, mandi_prices.base_unit
+			                            ORDER BY mandi_prices.price_date DESC, count(*) DESC
+			                            LIMIT 1) mandi ON TRUE
+			WHERE pricing_rules.is_active
+			  AND products.archived_at IS NULL
+			  AND product_variants.archived_at IS NULL
+			  AND product_variants.is_active
+			ORDER BY products.name, product_variants.sort_order, product_variants.id`
+
+	inputs := make([]models.PricingInput, 0)
+	if err := dh.DB.Select(&inputs, SQL, asOf); err != nil {
+		logrus.Errorf("GetPricingInputs: error getting pricing inputs %v", err)
+		return inputs, err
+	}
+	return inputs, nil
+}
+
+// ApplyPriceChanges writes new selling prices with their history in one transaction. A variant whose
+// price moved since the preview was computed is left alone and not counted in applied.
+func (dh *DBHelper) ApplyPriceChanges(changes []models.PriceChange, source models.PriceChangeSource, changedBy int) (applied int, err error) {
+	tx, err := dh.DB.Beginx()
+	if err != nil {
+		logrus.Errorf("ApplyPriceChanges: error starting transaction %v", err)
+		return 0, err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	// language=SQL
+	SQL := `UPDATE product_variants
+			SET price_paise = $3,
+			    updated_at  = now()
+			WHERE id = $1
+			  AND price_paise = $2
+			  AND archived_at IS NULL`
+
+	for _, change := range changes {
+		if change.Skipped || change.NewPricePaise == change.OldPricePaise {
+			continue
+		}
+
+		err = execAffectingRow(tx, SQL, change.VariantID, change.OldPricePaise, change.NewPricePaise)
+		if err == sql.ErrNoRows {
+			continue
+		}
+		if err != nil {
+			logrus.Errorf("ApplyPriceChanges: error updating variant %d %v", change.VariantID, err)
+			return 0, catalogErr(err)
+		}
+
+		if err = recordPriceChange(tx, change.VariantID, change.OldPricePaise, change.NewPricePaise, source, change.MandiPriceDate, changedBy); err != nil {
+			logrus.Errorf("ApplyPriceChanges: error recording price history %v", err)
+			return 0, err
+		}
+		applied++
+	}
+
+	if err = tx.Commit(); err != nil {
+		logrus.Errorf("ApplyPriceChanges: error committing prices %v", err)
+		return 0, err
+	}
+	return applied, nil
+}
+
+func (dh *DBHelper) ListPriceHistory(variantID, limit, offset int) (history []models.PriceHistory, total int, err error) {
+	// language=SQL
+	countSQL := `SELECT count(*)
+			FROM price_history
+			WHERE variant_id = $1`
+
+	if err = dh.DB.Get(&total, countSQL, variantID); err != nil {
+		logrus.Errorf("ListPriceHistory: error counting history %v", err)
+		return history, total, err
+	}
+
+	// language=SQL
+	SQL := `SELECT id, variant_id, old_price_paise, new_price_paise, source, mandi_price_date, changed_by, created_at
+			FROM price_history
+			WHERE variant_id = $1
+			ORDER BY created_at DESC, id DESC
+			LIMIT $2 OFFSET $3`
+
+	history = make([]models.PriceHistory, 0)
+	if err = dh.DB.Select(&history, SQL, variantID, limit, offset); err != nil {
+		logrus.Errorf("ListPriceHistory: error getting history %v", err)
+		return history, total, err
+	}
+	return history, total, nil
+}
@@ -0,0 +1,120 @@
+package dbhelperprovider
+
+import (
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/sirupsen/logrus"
+	"github.com/vijaygniit/ApnaSabji/models"
+)
+
+func (dh *DBHelper) GetUserProfile(userID int) (models.UserProfile, error) {
+	// language=SQL
+	SQL := `SELECT users.id,
+				   users.fullname,
+				   COALESCE(users.email, '')                     AS email,
+				   COALESCE(users.mobilenumber, '')              AS mobilenumber,
+				   user_profiles.avatar_url,
+				   COALESCE(user_profiles.preferred_language, 'en') AS preferred_language,
+				   COALESCE(user_profiles.dietary_preferences, '{}') AS dietary_preferences,
+				   user_profiles.default_address
+			FROM users
+			LEFT JOIN user_profiles ON user_profiles.user_id = users.id
+			WHERE users.id = $1
+			  AND users.archived_at IS NULL`
+
+	var profile models.UserProfile
+	if err := dh.DB.Get(&profile, SQL, userID); err != nil {
+		logrus.Errorf("GetUserProfile: error getting user profile %v", err)
+		return profile, err
+	}
+
+	return profile, nil
+}
+
+// UpdateUserProfile only changes the fields present in the request. Email and phone are changed through UpdateUserContact.
+func (dh *DBHelper) UpdateUserProfile(userID int, updateReq models.UpdateProfileRequest) error {
+	tx, err := dh.DB.Beginx()
+	if err != nil {
+		logrus.Errorf("UpdateUserProfile: error starting transaction %v", err)
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	if updateReq.Fullname.Valid {
+		// language=SQL
+		SQL := `UPDATE users
+				SET fullname = trim($2),
+				    updated_at = $3
+				WHERE id = $1`
+		if _, err = tx.Exec(SQL, userID, updateReq.Fullname.String, time.Now().UTC()); err != nil {
+			logrus.Errorf("UpdateUserProfile: error updating name %v", err)
+			return err
+		}
+	}
+
+	var dietaryPreferences interface{}
+	if updateReq.DietaryPreferences != nil {
+		dietaryPreferences = pq.StringArray(*updateReq.DietaryPreferences)
+	}
+
+	var defaultAddress interface{}
+	if updateReq.DefaultAddress.Value != nil {
+		defaultAddress = *updateReq.DefaultAddress.Value
+	}
+
+	// language=SQL
+	SQL := `INSERT INTO user_profiles
+			(user_id, avatar_url, preferred_language, dietary_preferences, default_address, updated_at)
+			VALUES ($1, $2, COALESCE($3, 'en'), COALESCE($4::TEXT[], '{}'), $5, $6)
+			ON CONFLICT (user_id) DO UPDATE
+			SET avatar_url          = CASE WHEN $7 THEN $2 ELSE user_profiles.avatar_url END,
+			    preferred_language  = COALESCE($3, user_profiles.preferred_language),
+			    dietary_preferences = COALESCE($4::TEXT[], user_profiles.dietary_preferences),
+			    default_address     = CASE WHEN $8 THEN $5::JSONB ELSE user_profiles.default_address END,
+			    updated_at          = $6`
+
+	args := []interface{}{
+		userID,
+		updateReq.AvatarURL.Value,
+		updateReq.PreferredLanguage,
+		dietaryPreferences,
+		defaultAddress,
+		time.Now().UTC(),
+		updateReq.AvatarURL.Set,
+		updateReq.DefaultAddress.Set,
+	}
+
+	if _, err = tx.Exec(SQL, args...); err != nil {
+		logrus.Errorf("UpdateUserProfile: error updating profile %v", err)
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// UpdateUserContact applies a verified email or phone change. The new email was proven by its OTP.
+func (dh *DBHelper) UpdateUserContact(userID int, purpose models.OTPPurpose, target string) error {
+	// language=SQL
+	SQL := `UPDATE users
+			SET email = lower($2),
+			    email_verified_at = now(),
+			    updated_at = $3
+			WHERE id = $1`
+	if purpose == models.OTPPurposePhoneChange {
+		// language=SQL
+		SQL = `UPDATE users
+				SET mobilenumber = $2,
+				    updated_at = $3
+				WHERE id = $1`
+	}
+
+	if _, err := dh.DB.Exec(SQL, userID, target, time.Now().UTC()); err != nil {
+		logrus.Errorf("UpdateUserContact: error updating %s %v", purpose, err)
+		return err
+	}
+
+	return nil
+}
@@ -2,18 +2,41 @@ package dbhelperprovider
 
 import (
 	"database/sql"
-	"encoding/base64"
-	"errors"
-	"math/rand"
-	"strings"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
 	"github.com/sirupsen/logrus"
 	"github.com/vijaygniit/ApnaSabji/models"
+	"github.com/vijaygniit/ApnaSabji/scmerrors"
 )
 
-func (dh *DBHelper) CreateNewUser(newUserRequest *models.CreateNewUserRequest, userID int) (*int, error) {
+// CreateNewUser creates the user with their profile and customer role in one transaction. When consent
+// is given the user's AcceptedPolicyIDs and marketing choice are recorded in the same transaction, so a
+// failure never leaves an account without a role or without the consent it signed up with.
+func (dh *DBHelper) CreateNewUser(newUserRequest *models.CreateNewUserRequest, userID int, consent *models.ConsentRecord) (*int, error) {
+	tx, err := dh.DB.Beginx()
+	if err != nil {
+		logrus.Errorf("CreateNewUser: error starting transaction %v", err)
+		return nil, err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	newUserID, err := createUser(tx, newUserRequest, userID, consent)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		logrus.Errorf("CreateNewUser: error committing user %v", err)
+		return nil, err
+	}
+	return &newUserID, nil
+}
+
+func createUser(tx *sqlx.Tx, newUserRequest *models.CreateNewUserRequest, createdBy int, consent *models.ConsentRecord) (int, error) {
 	var newUserID int
 
 	SQL := `
@@ -25,16 +48,16 @@ func (dh *DBHelper) CreateNewUser(newUserRequest *models.CreateNewUserRequest, u
 
 	args := []interface{}{
 		newUserRequest.Fullname,
-		newUserRequest.Email.String,
-		newUserRequest.Mobilenumber.String,
+		newUserRequest.Email,
+		newUserRequest.Mobilenumber,
 		time.Now().UTC(),
-		userID,
+		createdBy,
 	}
 
-	err := dh.DB.Get(&newUserID, SQL, args...)
+	err := tx.Get(&newUserID, SQL, args...)
 	if err != nil {
 		logrus.Errorf("CreateNewUser: error creating user %v", err)
-		return nil, err
+		return 0, err
 	}
 
 	SQL = `
@@ -43,13 +66,43 @@ func (dh *DBHelper) CreateNewUser(newUserRequest *models.CreateNewUserRequest, u
 		VALUES ($1)
 	`
 
-	_, err = dh.DB.Exec(SQL, newUserID)
+	_, err = tx.Exec(SQL, newUserID)
 	if err != nil {
 		logrus.Errorf("CreateNewUser: error creating user profile %v", err)
-		return nil, err
+		return 0, err
 	}
 
-	return &newUserID, nil
+	// language=SQL
+	SQL = `INSERT INTO user_roles
+			(user_id, role_id, created_by)
+			SELECT $1, id, $3
+			FROM roles
+			WHERE name = $2`
+
+	if err = execAffectingRow(tx, SQL, newUserID, models.RoleCustomer, createdBy); err != nil {
+		logrus.Errorf("CreateNewUser: error assigning customer role %v", err)
+		return 0, err
+	}
+
+	if consent == nil {
+		return newUserID, nil
+	}
+
+	record := *consent
+	record.UserID = newUserID
+	if len(newUserRequest.AcceptedPolicyIDs) > 0 {
+		if err = acceptPolicyDocuments(tx, record, newUserRequest.AcceptedPolicyIDs); err != nil {
+			logrus.Errorf("CreateNewUser: error recording accepted policies %v", err)
+			return 0, err
+		}
+	}
+
+	if err = setMarketingConsent(tx, record, newUserRequest.MarketingOptIn); err != nil {
+		logrus.Errorf("CreateNewUser: error recording marketing consent %v", err)
+		return 0, err
+	}
+
+	return newUserID, nil
 }
 
 func (dh *DBHelper) IsPhoneNumberAlreadyExist(mobilenumber string) (bool, error) {
@@ -93,9 +146,10 @@ func (dh *DBHelper) IsUserAlreadyExists(emailID string) (isUserExist bool, user
 
 func (dh *DBHelper) FetchUserSessionData(userID int) ([]models.FetchUserSessionsData, error) {
 	SQL := `
-		SELECT id, user_id, end_time, token
+		SELECT id, user_id, end_time, token, mfa_satisfied_at
 		FROM sessions
 		WHERE user_id = $1
+		  AND revoked_at IS NULL
 	`
 
 	fetchUserSessionData := make([]models.FetchUserSessionsData, 0)
@@ -107,14 +161,17 @@ func (dh *DBHelper) FetchUserSessionData(userID int) ([]models.FetchUserSessions
 	return fetchUserSessionData, nil
 }
 
-func (dh *DBHelper) UpdateSession(sessionID string) error {
+// UpdateSession keeps an active session open for another expiresIn, the lifetime of an access token.
+func (dh *DBHelper) UpdateSession(sessionID string, expiresIn time.Duration) error {
 	SQL := `
 		UPDATE sessions
 		SET end_time = $2
 		WHERE token = $1
+		  AND revoked_at IS NULL
+		  AND impersonated_by IS NULL
 	`
 
-	_, err := dh.DB.Exec(SQL, sessionID, time.Now().Add(1*time.Hour))
+	_, err := dh.DB.Exec(SQL, sessionID, time.Now().Add(expiresIn))
 	if err != nil {
 		logrus.Errorf("UpdateSession: error updating user session data in the database: %v", err)
 		return err
@@ -126,7 +183,7 @@ func (dh *DBHelper) FetchUserData(userID int) (models.FetchUserData, error) {
 	var fetchUserData models.FetchUserData
 
 	SQL := `
-		SELECT id, fullname, email, mobilenumber
+		SELECT id, fullname, COALESCE(email, '') AS email, COALESCE(mobilenumber, '') AS mobilenumber
 		FROM users
 		WHERE id = $1
 	`
@@ -140,49 +197,73 @@ func (dh *DBHelper) FetchUserData(userID int) (models.FetchUserData, error) {
 	return fetchUserData, nil
 }
 
-func (dh *DBHelper) GetUserInfoByEmail(email string) (models.GetUserDataByEmail, error) {
-	//language=sql
-	SQL := `SELECT  users.id, users.fullname, mobilenumber
-			FROM users `
-	var getUserDataByEmail models.GetUserDataByEmail
-	err := dh.DB.Get(&getUserDataByEmail, SQL, email)
+// FetchActiveUserData is FetchUserData for an account that can still log in, it returns sql.ErrNoRows
+// once the account is deactivated or archived.
+func (dh *DBHelper) FetchActiveUserData(userID int) (models.FetchUserData, error) {
+	var fetchUserData models.FetchUserData
+
+	// language=SQL
+	SQL := `SELECT id, fullname, COALESCE(email, '') AS email, COALESCE(mobilenumber, '') AS mobilenumber
+			FROM users
+			WHERE id = $1
+			  AND deactivated IS FALSE
+			  AND archived_at IS NULL`
+
+	err := dh.DB.Get(&fetchUserData, SQL, userID)
 	if err != nil && err != sql.ErrNoRows {
-		logrus.Errorf("GetUserInfoByEmail: error getting user data: %v", err)
-		return getUserDataByEmail, err
+		logrus.Errorf("FetchActiveUserData: error getting user data: %v", err)
 	}
-	if err == sql.ErrNoRows {
-		return getUserDataByEmail, errors.New("email does not exist")
-	}
-	return getUserDataByEmail, nil
+	return fetchUserData, err
 }
 
 func (dh *DBHelper) LogInUserUsingEmail(loginReq models.EmailAndOTP) (userID int, message string, err error) {
 	// language=SQL
-	SQL := `SELECT 	id,   
-					otp
+	SQL := `SELECT 	id
 			FROM users
 		WHERE email = $1
 		AND deactivated IS FALSE
 		AND archived_at IS NULL`
 
-	var user = struct {
-		ID  int    `db:"id"`
-		OTP string `db:"otp"`
-	}{}
-
-	if err = dh.DB.Get(&user, SQL, loginReq.Email); err != nil && err != sql.ErrNoRows {
+	if err = dh.DB.Get(&userID, SQL, loginReq.Email); err != nil {
+		if err == sql.ErrNoRows {
+			// an unknown email fails like a wrong code so the endpoint does not reveal who has an account
+			return userID, "email does not exist", scmerrors.ErrOTPInvalid
+		}
 		logrus.Errorf("LogInUserUsingEmail: error while getting user %v", err)
 		return userID, "error getting user", err
 	}
 
-	if user.OTP != loginReq.OTP {
-		return userID, "OTP Not Correct", errors.New("OTP not matched")
+	if _, err = dh.VerifyOTP(userID, models.OTPPurposeLogin, models.OTPChannelEmail, loginReq.OTP); err != nil {
+		return userID, err.Error(), err
 	}
 
-	return user.ID, "", nil
+	return userID, "", nil
 }
 
-func (dh *DBHelper) StartNewSession(userID int, request *models.CreateSessionRequest) (string, error) {
+func (dh *DBHelper) LogInUserUsingPhone(loginReq models.PhoneAndOTP) (userID int, message string, err error) {
+	// language=SQL
+	SQL := `SELECT 	id
+			FROM users
+		WHERE mobilenumber = $1
+		AND deactivated IS FALSE
+		AND archived_at IS NULL`
+
+	if err = dh.DB.Get(&userID, SQL, loginReq.Mobilenumber); err != nil {
+		if err == sql.ErrNoRows {
+			return userID, "phone number does not exist", scmerrors.ErrOTPInvalid
+		}
+		logrus.Errorf("LogInUserUsingPhone: error while getting user %v", err)
+		return userID, "error getting user", err
+	}
+
+	if _, err = dh.VerifyOTP(userID, models.OTPPurposeLogin, models.OTPChannelPhone, loginReq.OTP); err != nil {
+		return userID, err.Error(), err
+	}
+
+	return userID, "", nil
+}
+
+func (dh *DBHelper) StartNewSession(userID int, request *models.CreateSessionRequest, expiresIn time.Duration) (string, error) {
 
 	// language=sql
 	SQL := `INSERT INTO sessions 
@@ -192,7 +273,7 @@ func (dh *DBHelper) StartNewSession(userID int, request *models.CreateSessionReq
 	args := []interface{}{
 		userID,
 		time.Now(),
-		time.Now().Add(1 * time.Hour),
+		time.Now().Add(expiresIn),
 		request.Platform,
 		request.ModelName,
 		request.OSVersion,
@@ -213,66 +294,3 @@ func (dh *DBHelper) StartNewSession(userID int, request *models.CreateSessionReq
 
 	return session.Token, nil
 }
-
-func (dh *DBHelper) GenerateAndStoreOTP(email string) (otp string, err error) {
-	// Generate a random 6-digit OTP
-	otp, err = generateRandomOTP(6)
-	if err != nil {
-		logrus.Errorf("GenerateAndStoreOTP: error generating OTP %v", err)
-		return "", err
-	}
-
-	// Store the OTP in the database
-	if err := dh.storeOTPInDatabase(email, otp); err != nil {
-		logrus.Errorf("GenerateAndStoreOTP: error storing OTP in the database %v", err)
-		return "", err
-	}
-
-	return otp, nil
-}
-
-func (dh *DBHelper) storeOTPInDatabase(email, otp string) error {
-	// language=SQL
-	SQL := `UPDATE users
-            SET otp = $1
-            WHERE email = $2
-              AND deactivated IS FALSE
-              AND archived_at IS NULL`
-
-	_, err := dh.DB.Exec(SQL, otp, email)
-	return err
-}
-
-func generateRandomOTP(length int) (string, error) {
-	if length <= 0 {
-		return "", errors.New("length should be a positive integer")
-	}
-
-	rand.Seed(time.Now().UnixNano())
-
-	// Generate random bytes
-	randomBytes := make([]byte, length)
-	_, err := rand.Read(randomBytes)
-	if err != nil {
-		return "", err
-	}
-
-	// Encode random bytes to base64
-	otp := base64.URLEncoding.EncodeToString(randomBytes)
-
-	// Remove non-alphanumeric characters
-	otp = removeNonAlphanumeric(otp)
-
-	// Truncate or pad to the desired length
-	otp = otp[:length]
-
-	return otp, nil
-}
-func removeNonAlphanumeric(s string) string {
-	return strings.Map(func(r rune) rune {
-		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
-			return r
-		}
-		return -1
-	}, s)
-}
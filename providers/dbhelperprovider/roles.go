@@ -0,0 +1,116 @@
+package dbhelperprovider
+
+import (
+	"database/sql"
+
+	"github.com/lib/pq"
+	"github.com/sirupsen/logrus"
+	"github.com/vijaygniit/ApnaSabji/models"
+)
+
+func (dh *DBHelper) GetUserRolesAndPermissions(userID int) (roles, permissions []string, err error) {
+	// language=SQL
+	SQL := `SELECT COALESCE(array_agg(DISTINCT roles.name), '{}')                                                AS roles,
+				   COALESCE(array_agg(DISTINCT permissions.name) FILTER (WHERE permissions.name IS NOT NULL), '{}') AS permissions
+			FROM user_roles
+			JOIN roles ON roles.id = user_roles.role_id
+			LEFT JOIN role_permissions ON role_permissions.role_id = roles.id
+			LEFT JOIN permissions ON permissions.id = role_permissions.permission_id
+			WHERE user_roles.user_id = $1`
+
+	var rolesAndPermissions = struct {
+		Roles       pq.StringArray `db:"roles"`
+		Permissions pq.StringArray `db:"permissions"`
+	}{}
+
+	if err = dh.DB.Get(&rolesAndPermissions, SQL, userID); err != nil {
+		logrus.Errorf("GetUserRolesAndPermissions: error getting roles %v", err)
+		return roles, permissions, err
+	}
+
+	return rolesAndPermissions.Roles, rolesAndPermissions.Permissions, nil
+}
+
+func (dh *DBHelper) ListRoles() ([]models.RoleWithPermissions, error) {
+	// language=SQL
+	SQL := `SELECT roles.id,
+				   roles.name,
+				   roles.description,
+				   COALESCE(array_agg(permissions.name ORDER BY permissions.name) FILTER (WHERE permissions.name IS NOT NULL), '{}') AS permissions
+			FROM roles
+			LEFT JOIN role_permissions ON role_permissions.role_id = roles.id
+			LEFT JOIN permissions ON permissions.id = role_permissions.permission_id
+			GROUP BY roles.id
+			ORDER BY roles.id`
+
+	roles := make([]models.RoleWithPermissions, 0)
+	if err := dh.DB.Select(&roles, SQL); err != nil {
+		logrus.Errorf("ListRoles: error getting roles %v", err)
+		return roles, err
+	}
+
+	return roles, nil
+}
+
+func (dh *DBHelper) AssignRole(userID int, role models.Role, assignedBy int) error {
+	// language=SQL
+	SQL := `INSERT INTO user_roles
+			(user_id, role_id, created_by)
+			SELECT $1, id, $3
+			FROM roles
+			WHERE name = $2
+			ON CONFLICT DO NOTHING`
+
+	result, err := dh.DB.Exec(SQL, userID, role, assignedBy)
+	if err != nil {
+		logrus.Errorf("AssignRole: error assigning role %v", err)
+		return err
+	}
+
+	return dh.checkRoleFound(result, role)
+}
+
+func (dh *DBHelper) RemoveRole(userID int, role models.Role) error {
+	// language=SQL
+	SQL := `DELETE FROM user_roles
+			USING roles
+			WHERE user_roles.role_id = roles.id
+			  AND user_roles.user_id = $1
+			  AND roles.name = $2`
+
+	result, err := dh.DB.Exec(SQL, userID, role)
+	if err != nil {
+		logrus.Errorf("RemoveRole: error removing role %v", err)
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// checkRoleFound tells an unknown role apart from a role the user already had.
+func (dh *DBHelper) checkRoleFound(result sql.Result, role models.Role) error {
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected > 0 {
+		return nil
+	}
+
+	var isRoleExist bool
+	// language=SQL
+	if err := dh.DB.Get(&isRoleExist, `SELECT count(*) > 0 FROM roles WHERE name = $1`, role); err != nil {
+		return err
+	}
+	if !isRoleExist {
+		return sql.ErrNoRows
+	}
+	return nil
+}
@@ -0,0 +1,237 @@
+package dbhelperprovider
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/vijaygniit/ApnaSabji/models"
+)
+
+// searchTermMatchSQL is true when the name column matches the query, in a form the trigram and full-text
+// indexes on that column can answer. $1 is the lower cased query and $3 its substring pattern.
+func searchTermMatchSQL(column string) string {
+	return strings.ReplaceAll(`(lower({name}) LIKE $3 ESCAPE '\'
+			       OR to_tsvector('simple', {name}) @@ plainto_tsquery('simple', $1)
+			       OR lower({name}) % $1
+			       OR $1 <% lower({name}))`, "{name}", column)
+}
+
+// searchCandidatesSQL finds the products with any matching name, each table on its own so that every
+// branch uses its indexes, then the candidate ids are merged.
+// language=SQL
+var searchCandidatesSQL = `SELECT products.id AS product_id
+			FROM products
+			WHERE products.archived_at IS NULL
+			  AND ` + searchTermMatchSQL("products.name") + `
+			UNION
+			SELECT product_aliases.product_id
+			FROM product_aliases
+			WHERE ` + searchTermMatchSQL("product_aliases.name") + `
+			UNION
+			SELECT products.id
+			FROM categories
+			         JOIN products ON products.category_id = categories.id
+			WHERE products.archived_at IS NULL
+			  AND ` + searchTermMatchSQL("categories.name")
+
+// searchTermsSQL lists every name a candidate can be found by. Category names count for less so that
+// "fruits" finds fruits without outranking a product actually called that.
+// language=SQL
+const searchTermsSQL = `SELECT products.id AS product_id, products.name AS term, 1.0::FLOAT8 AS weight
+			FROM candidates
+			         JOIN products ON products.id = candidates.product_id
+			UNION ALL
+			SELECT product_aliases.product_id, product_aliases.name, 1.0::FLOAT8
+			FROM candidates
+			         JOIN product_aliases ON product_aliases.product_id = candidates.product_id
+			UNION ALL
+			SELECT products.id, categories.name, 0.5::FLOAT8
+			FROM candidates
+			         JOIN products ON products.id = candidates.product_id
+			         JOIN categories ON categories.id = products.category_id`
+
+// searchMatchesSQL scores each candidate by its best matching term. Exact and prefix matches win,
+// full-text catches whole words in any order and the trigram operators forgive typos like "bhindy".
+// $1 is the lower cased query, $2 its prefix pattern and $3 its substring pattern.
+// language=SQL
+var searchMatchesSQL = `WITH candidates AS (` + searchCandidatesSQL + `),
+			     matches AS (SELECT search_terms.product_id,
+			                        max(search_terms.weight * GREATEST(
+			                                CASE WHEN lower(search_terms.term) = $1 THEN 1.0 ELSE 0 END,
+			                                CASE WHEN lower(search_terms.term) LIKE $2 ESCAPE '\' THEN 0.9 ELSE 0 END,
+			                                ts_rank(to_tsvector('simple', search_terms.term), plainto_tsquery('simple', $1))::FLOAT8,
+			                                similarity(lower(search_terms.term), $1)::FLOAT8,
+			                                word_similarity($1, lower(search_terms.term))::FLOAT8)) AS relevance
+			                 FROM (` + searchTermsSQL + `) search_terms
+			                 GROUP BY search_terms.product_id)`
+
+// escapeLike makes user input safe to use inside a LIKE pattern.
+func escapeLike(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(value)
+}
+
+// SearchProducts ranks matching products by relevance, then popularity, then whether they are in stock.
+func (dh *DBHelper) SearchProducts(search models.ProductSearch) (products []models.Product, total int, err error) {
+	query := strings.ToLower(strings.TrimSpace(search.Query))
+	args := []interface{}{query, escapeLike(query) + "%", "%" + escapeLike(query) + "%"}
+
+	conditions := []string{"products.archived_at IS NULL", publicProductSQL}
+	if !search.IncludeOutOfStock {
+		conditions = append(conditions, "variant_summary.in_stock")
+	}
+	if search.CategoryID.Valid {
+		args = append(args, search.CategoryID.Int)
+		conditions = append(conditions, fmt.Sprintf("products.category_id = $%d", len(args)))
+	}
+
+	join := `
+			         JOIN matches ON matches.product_id = products.id
+			WHERE ` + strings.Join(conditions, " AND ")
+
+	SQL := searchMatchesSQL + `
+			SELECT count(*)
+			` + productFromSQL + join
+	if err = dh.DB.Get(&total, SQL, args...); err != nil {
+		logrus.Errorf("SearchProducts: error counting products %v", err)
+		return products, total, err
+	}
+
+	products = make([]models.Product, 0)
+	SQL = fmt.Sprintf(`%s
+			%s%s
+			ORDER BY matches.relevance
+			             + 0.1 * ln(1 + products.popularity)::FLOAT8
+			             + CASE WHEN COALESCE(variant_summary.in_stock, FALSE) THEN 0.2 ELSE 0 END DESC,
+			         products.name, products.id
+			LIMIT $%d OFFSET $%d`, searchMatchesSQL, productSelectSQL, join, len(args)+1, len(args)+2)
+	if err = dh.DB.Select(&products, SQL, append(args, search.Limit, search.Offset)...); err != nil {
+		logrus.Errorf("SearchProducts: error searching products %v", err)
+		return products, total, err
+	}
+	return products, total, nil
+}
+
+// SearchSuggestions autocompletes names and aliases of products customers can see, best match first.
+// Out of stock products are left out unless includeOutOfStock, as in SearchProducts.
+func (dh *DBHelper) SearchSuggestions(query string, includeOutOfStock bool, limit int) ([]models.SearchSuggestion, error) {
+	query = strings.ToLower(strings.TrimSpace(query))
+
+	conditions := []string{"products.archived_at IS NULL", publicProductSQL}
+	if !includeOutOfStock {
+		conditions = append(conditions, "variant_summary.in_stock")
+	}
+
+	// language=SQL
+	SQL := `SELECT text, product_id
+			FROM (SELECT DISTINCT ON (lower(search_terms.term)) search_terms.term AS text,
+			             search_terms.product_id,
+			             GREATEST(CASE WHEN lower(search_terms.term) LIKE $2 ESCAPE '\' THEN 1.0 ELSE 0 END,
+			                      word_similarity($1, lower(search_terms.term))::FLOAT8) AS relevance,
+			             products.popularity
+			      ` + productFromSQL + `
+			               JOIN (SELECT products.id AS product_id, products.name AS term
+			                     FROM products
+			                     WHERE lower(products.name) LIKE $2 ESCAPE '\'
+			                        OR $1 <% lower(products.name)
+			                     UNION ALL
+			                     SELECT product_aliases.product_id, product_aliases.name
+			                     FROM product_aliases
+			                     WHERE lower(product_aliases.name) LIKE $2 ESCAPE '\'
+			                        OR $1 <% lower(product_aliases.name)) search_terms ON search_terms.product_id = products.id
+			      WHERE ` + strings.Join(conditions, " AND ") + `
+			      ORDER BY lower(search_terms.term), products.popularity DESC) suggestions
+			ORDER BY relevance DESC, popularity DESC, text
+			LIMIT $3`
+
+	suggestions := make([]models.SearchSuggestion, 0)
+	if err := dh.DB.Select(&suggestions, SQL, query, escapeLike(query)+"%", limit); err != nil {
+		logrus.Errorf("SearchSuggestions: error getting suggestions %v", err)
+		return suggestions, err
+	}
+	return suggestions, nil
+}
+
+// RecordProductView counts a customer opening the product, once a day per customer.
+func (dh *DBHelper) RecordProductView(productID, userID int) error {
+	// language=SQL
+	SQL := `WITH viewed AS (
+				INSERT INTO product_views
+				(product_id, user_id)
+				VALUES ($1, $2)
+				ON CONFLICT DO NOTHING
+				RETURNING product_id)
+			UPDATE products
+			SET popularity = popularity + 1
+			WHERE id IN (SELECT product_id FROM viewed)`
+
+	if _, err := dh.DB.Exec(SQL, productID, userID); err != nil {
+		logrus.Errorf("RecordProductView: error recording view %v", err)
+		return err
+	}
+	return nil
+}
+
+// PurgeProductViews forgets views from before the given day, they no longer keep a view from being counted twice.
+func (dh *DBHelper) PurgeProductViews(before time.Time) (int64, error) {
+	// language=SQL
+	SQL := `DELETE FROM product_views
+			WHERE viewed_on < $1::DATE`
+
+	result, err := dh.DB.Exec(SQL, before)
+	if err != nil {
+		logrus.Errorf("PurgeProductViews: error deleting views %v", err)
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+func (dh *DBHelper) ListProductAliases(productID int) ([]models.ProductAlias, error) {
+	// language=SQL
+	SQL := `SELECT id, product_id, name, language, created_at
+			FROM product_aliases
+			WHERE product_id = $1
+			ORDER BY language, name`
+
+	aliases := make([]models.ProductAlias, 0)
+	if err := dh.DB.Select(&aliases, SQL, productID); err != nil {
+		logrus.Errorf("ListProductAliases: error getting aliases %v", err)
+		return aliases, err
+	}
+	return aliases, nil
+}
+
+// CreateProductAlias adds a search name to a product, sql.ErrNoRows means the product does not exist.
+func (dh *DBHelper) CreateProductAlias(productID int, aliasReq models.CreateAliasRequest) (models.ProductAlias, error) {
+	var alias models.ProductAlias
+
+	// language=SQL
+	SQL := `INSERT INTO product_aliases
+			(product_id, name, language)
+			SELECT id, $2, $3
+			FROM products
+			WHERE id = $1
+			  AND archived_at IS NULL
+			RETURNING id, product_id, name, language, created_at`
+
+	err := dh.DB.Get(&alias, SQL, productID, aliasReq.Name, aliasReq.Language)
+	if err != nil && err != sql.ErrNoRows {
+		logrus.Errorf("CreateProductAlias: error creating alias %v", err)
+		return alias, catalogErr(err)
+	}
+	return alias, err
+}
+
+func (dh *DBHelper) DeleteProductAlias(aliasID int) error {
+	// language=SQL
+	SQL := `DELETE FROM product_aliases
+			WHERE id = $1`
+
+	err := execAffectingRow(dh.DB, SQL, aliasID)
+	if err != nil && err != sql.ErrNoRows {
+		logrus.Errorf("DeleteProductAlias: error deleting alias %v", err)
+	}
+	return err
+}
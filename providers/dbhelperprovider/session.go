@@ -0,0 +1,274 @@
+package dbhelperprovider
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/sirupsen/logrus"
+	"github.com/vijaygniit/ApnaSabji/models"
+	"github.com/vijaygniit/ApnaSabji/scmerrors"
+)
+
+func (dh *DBHelper) CreateRefreshToken(sessionToken string, expiresIn time.Duration) (string, error) {
+	refreshToken, err := generateToken()
+	if err != nil {
+		logrus.Errorf("CreateRefreshToken: error generating refresh token %v", err)
+		return "", err
+	}
+
+	// language=SQL
+	SQL := `INSERT INTO refresh_tokens
+			(session_id, token_hash, expires_at)
+			SELECT id, $2, $3
+			FROM sessions
+			WHERE token = $1
+			  AND revoked_at IS NULL`
+
+	result, err := dh.DB.Exec(SQL, sessionToken, hashToken(refreshToken), time.Now().UTC().Add(expiresIn))
+	if err != nil {
+		logrus.Errorf("CreateRefreshToken: error storing refresh token %v", err)
+		return "", err
+	}
+
+	if rowsAffected, err := result.RowsAffected(); err != nil || rowsAffected == 0 {
+		return "", scmerrors.ErrSessionRevoked
+	}
+
+	return refreshToken, nil
+}
+
+// RotateRefreshToken exchanges a refresh token for a new one and extends the session it belongs to.
+// Presenting a token that was already rotated is treated as theft and revokes the whole session.
+func (dh *DBHelper) RotateRefreshToken(refreshToken string, expiresIn, sessionExtension time.Duration) (session models.RefreshTokenSession, newRefreshToken string, err error) {
+	tx, err := dh.DB.Beginx()
+	if err != nil {
+		logrus.Errorf("RotateRefreshToken: error starting transaction %v", err)
+		return session, "", err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	// language=SQL
+	SQL := `SELECT refresh_tokens.id,
+				   refresh_tokens.session_id,
+				   refresh_tokens.expires_at,
+				   refresh_tokens.rotated_at,
+				   refresh_tokens.revoked_at,
+				   sessions.user_id,
+				   sessions.token,
+				   COALESCE(sessions.platform, '')   AS platform,
+				   COALESCE(sessions.model_name, '') AS model_name,
+				   COALESCE(sessions.os_version, '') AS os_version,
+				   COALESCE(sessions.device_id, '')  AS device_id,
+				   sessions.revoked_at               AS session_revoked_at
+			FROM refresh_tokens
+			JOIN sessions ON sessions.id = refresh_tokens.session_id
+			WHERE refresh_tokens.token_hash = $1
+			FOR UPDATE`
+
+	if err = tx.Get(&session, SQL, hashToken(refreshToken)); err != nil {
+		if err == sql.ErrNoRows {
+			return session, "", scmerrors.ErrRefreshTokenInvalid
+		}
+		logrus.Errorf("RotateRefreshToken: error getting refresh token %v", err)
+		return session, "", err
+	}
+
+	if session.SessionRevoked.Valid || session.RevokedAt.Valid {
+		return session, "", scmerrors.ErrSessionRevoked
+	}
+
+	if session.RotatedAt.Valid {
+		if err = revokeSession(tx, session.SessionID); err != nil {
+			logrus.Errorf("RotateRefreshToken: error revoking session after refresh token reuse %v", err)
+			return session, "", err
+		}
+		if err = tx.Commit(); err != nil {
+			logrus.Errorf("RotateRefreshToken: error committing session revocation %v", err)
+			return session, "", err
+		}
+		logrus.Warnf("RotateRefreshToken: refresh token reused, revoked session %d", session.SessionID)
+		return session, "", scmerrors.ErrRefreshTokenReused
+	}
+
+	if time.Now().After(session.ExpiresAt) {
+		return session, "", scmerrors.ErrRefreshTokenExpired
+	}
+
+	newRefreshToken, err = generateToken()
+	if err != nil {
+		logrus.Errorf("RotateRefreshToken: error generating refresh token %v", err)
+		return session, "", err
+	}
+
+	// language=SQL
+	SQL = `UPDATE refresh_tokens
+			SET rotated_at = now()
+			WHERE id = $1`
+	if _, err = tx.Exec(SQL, session.RefreshTokenID); err != nil {
+		logrus.Errorf("RotateRefreshToken: error rotating refresh token %v", err)
+		return session, "", err
+	}
+
+	// language=SQL
+	SQL = `INSERT INTO refresh_tokens
+			(session_id, token_hash, expires_at)
+			VALUES ($1, $2, $3)`
+	if _, err = tx.Exec(SQL, session.SessionID, hashToken(newRefreshToken), time.Now().UTC().Add(expiresIn)); err != nil {
+		logrus.Errorf("RotateRefreshToken: error storing refresh token %v", err)
+		return session, "", err
+	}
+
+	// language=SQL
+	SQL = `UPDATE sessions
+			SET end_time = $2
+			WHERE id = $1`
+	if _, err = tx.Exec(SQL, session.SessionID, time.Now().Add(sessionExtension)); err != nil {
+		logrus.Errorf("RotateRefreshToken: error extending session %v", err)
+		return session, "", err
+	}
+
+	if err = tx.Commit(); err != nil {
+		logrus.Errorf("RotateRefreshToken: error committing refresh token rotation %v", err)
+		return session, "", err
+	}
+
+	return session, newRefreshToken, nil
+}
+
+// revokeSession ends a session and every refresh token issued for it.
+func revokeSession(tx *sqlx.Tx, sessionID int) error {
+	// language=SQL
+	SQL := `UPDATE sessions
+			SET revoked_at = now(),
+			    end_time = now()
+			WHERE id = $1
+			  AND revoked_at IS NULL`
+	if _, err := tx.Exec(SQL, sessionID); err != nil {
+		return err
+	}
+
+	// language=SQL
+	SQL = `UPDATE refresh_tokens
+			SET revoked_at = now()
+			WHERE session_id = $1
+			  AND revoked_at IS NULL`
+	_, err := tx.Exec(SQL, sessionID)
+	return err
+}
+
+func generateToken() (string, error) {
+	tokenBytes := make([]byte, 32)
+	if _, err := rand.Read(tokenBytes); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(tokenBytes), nil
+}
+
+func hashToken(refreshToken string) string {
+	sum := sha256.Sum256([]byte(refreshToken))
+	return hex.EncodeToString(sum[:])
+}
+
+func (dh *DBHelper) EndSession(sessionToken string) error {
+	return dh.revokeSessions(`SELECT id
+			FROM sessions
+			WHERE token = $1
+			  AND revoked_at IS NULL`, sessionToken)
+}
+
+func (dh *DBHelper) RevokeUserSession(userID, sessionID int) error {
+	// language=SQL
+	SQL := `SELECT count(*) > 0
+			FROM sessions
+			WHERE id = $1
+			  AND user_id = $2
+			  AND revoked_at IS NULL`
+
+	var isSessionActive bool
+	if err := dh.DB.Get(&isSessionActive, SQL, sessionID, userID); err != nil {
+		logrus.Errorf("RevokeUserSession: error getting session %v", err)
+		return err
+	}
+
+	if !isSessionActive {
+		return sql.ErrNoRows
+	}
+
+	return dh.revokeSessions(`SELECT id
+			FROM sessions
+			WHERE id = $1
+			  AND revoked_at IS NULL`, sessionID)
+}
+
+func (dh *DBHelper) RevokeOtherSessions(userID int, currentSessionToken string) error {
+	return dh.revokeSessions(`SELECT id
+			FROM sessions
+			WHERE user_id = $1
+			  AND token != $2
+			  AND revoked_at IS NULL`, userID, currentSessionToken)
+}
+
+func (dh *DBHelper) ListActiveSessions(userID int, currentSessionToken string) ([]models.ActiveSession, error) {
+	// language=SQL
+	SQL := `SELECT id,
+				   COALESCE(platform, '')   AS platform,
+				   COALESCE(model_name, '') AS model_name,
+				   COALESCE(os_version, '') AS os_version,
+				   COALESCE(device_id, '')  AS device_id,
+				   start_time,
+				   end_time,
+				   token = $2               AS current
+			FROM sessions
+			WHERE user_id = $1
+			  AND revoked_at IS NULL
+			  AND (end_time > now()
+				OR EXISTS(SELECT 1
+						  FROM refresh_tokens
+						  WHERE refresh_tokens.session_id = sessions.id
+							AND refresh_tokens.rotated_at IS NULL
+							AND refresh_tokens.revoked_at IS NULL
+							AND refresh_tokens.expires_at > now()))
+			ORDER BY start_time DESC`
+
+	activeSessions := make([]models.ActiveSession, 0)
+	if err := dh.DB.Select(&activeSessions, SQL, userID, currentSessionToken); err != nil {
+		logrus.Errorf("ListActiveSessions: error getting active sessions %v", err)
+		return activeSessions, err
+	}
+
+	return activeSessions, nil
+}
+
+// revokeSessions revokes every session whose id is returned by selectSQL in a single transaction.
+func (dh *DBHelper) revokeSessions(selectSQL string, args ...interface{}) error {
+	tx, err := dh.DB.Beginx()
+	if err != nil {
+		logrus.Errorf("revokeSessions: error starting transaction %v", err)
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	sessionIDs := make([]int, 0)
+	if err = tx.Select(&sessionIDs, selectSQL+" FOR UPDATE", args...); err != nil {
+		logrus.Errorf("revokeSessions: error getting sessions %v", err)
+		return err
+	}
+
+	for _, sessionID := range sessionIDs {
+		if err = revokeSession(tx, sessionID); err != nil {
+			logrus.Errorf("revokeSessions: error revoking session %d %v", sessionID, err)
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,204 @@
+package dbhelperprovider
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/sirupsen/logrus"
+	"github.com/vijaygniit/ApnaSabji/models"
+	"github.com/volatiletech/null"
+)
+
+// language=SQL
+const variantSelectSQL = `SELECT product_variants.id, product_variants.product_id, product_variants.sku, product_variants.pack_size,
+			       units.code AS unit_code, units.name AS unit_name, product_variants.mrp_paise, product_variants.price_paise,
+			       product_variants.barcode, product_variants.stock_quantity, product_variants.stock_quantity > 0 AS in_stock,
+			       product_variants.sort_order, product_variants.is_active, product_variants.created_at, product_variants.updated_at
+			FROM product_variants
+			         JOIN units ON units.id = product_variants.unit_id`
+
+// createProductVariant adds a pack to a product that is not archived, sql.ErrNoRows means there is no such product.
+func createProductVariant(db sqlx.Queryer, productID int, variantReq models.CreateVariantRequest) (int, error) {
+	var variantID int
+
+	// language=SQL
+	SQL := `INSERT INTO product_variants
+			(product_id, sku, pack_size, unit_id, mrp_paise, price_paise, barcode, stock_quantity, sort_order, is_active)
+			SELECT id, $2, $3, (SELECT id FROM units WHERE code = $4), $5, $6, $7, $8, $9, COALESCE($10, TRUE)
+			FROM products
+			WHERE id = $1
+			  AND archived_at IS NULL
+			RETURNING id`
+
+	args := []interface{}{
+		productID,
+		variantReq.SKU,
+		variantReq.PackSize,
+		variantReq.UnitCode,
+		variantReq.MRPPaise,
+		variantReq.PricePaise,
+		variantReq.Barcode,
+		variantReq.StockQuantity,
+		variantReq.SortOrder,
+		variantReq.IsActive,
+	}
+
+	err := sqlx.Get(db, &variantID, SQL, args...)
+	return variantID, err
+}
+
+// ListProductVariants returns the packs of a product in display order.
+func (dh *DBHelper) ListProductVariants(productID int, includeInactive bool) ([]models.ProductVariant, error) {
+	// language=SQL
+	SQL := variantSelectSQL + `
+			WHERE product_variants.product_id = $1
+			  AND product_variants.archived_at IS NULL
+			  AND (product_variants.is_active OR $2)
+			ORDER BY product_variants.sort_order, product_variants.pack_size, product_variants.id`
+
+	variants := make([]models.ProductVariant, 0)
+	if err := dh.DB.Select(&variants, SQL, productID, includeInactive); err != nil {
+		logrus.Errorf("ListProductVariants: error getting variants %v", err)
+		return variants, err
+	}
+	return variants, nil
+}
+
+func (dh *DBHelper) GetProductVariant(variantID int) (models.ProductVariant, error) {
+	var variant models.ProductVariant
+
+	// language=SQL
+	SQL := variantSelectSQL + `
+			WHERE product_variants.id = $1
+			  AND product_variants.archived_at IS NULL`
+
+	err := dh.DB.Get(&variant, SQL, variantID)
+	if err != nil && err != sql.ErrNoRows {
+		logrus.Errorf("GetProductVariant: error getting variant %v", err)
+	}
+	return variant, err
+}
+
+// CreateProductVariant adds a pack to a product, sql.ErrNoRows means the product does not exist.
+func (dh *DBHelper) CreateProductVariant(productID int, variantReq models.CreateVariantRequest) (models.ProductVariant, error) {
+	variantID, err := createProductVariant(dh.DB, productID, variantReq)
+	if err == sql.ErrNoRows {
+		return models.ProductVariant{}, err
+	}
+	if err != nil {
+		logrus.Errorf("CreateProductVariant: error creating variant %v", err)
+		return models.ProductVariant{}, catalogErr(err)
+	}
+	return dh.GetProductVariant(variantID)
+}
+
+// UpdateProductVariant applies a partial update, a changed selling price is written to price_history.
+func (dh *DBHelper) UpdateProductVariant(variantID int, variantReq models.UpdateVariantRequest, changedBy int) (models.ProductVariant, error) {
+	tx, err := dh.DB.Beginx()
+	if err != nil {
+		logrus.Errorf("UpdateProductVariant: error starting transaction %v", err)
+		return models.ProductVariant{}, err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	var oldPricePaise int64
+
+	// language=SQL
+	err = tx.Get(&oldPricePaise, `SELECT price_paise FROM product_variants WHERE id = $1 AND archived_at IS NULL FOR UPDATE`, variantID)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			logrus.Errorf("UpdateProductVariant: error locking variant %v", err)
+		}
+		return models.ProductVariant{}, err
+	}
+
+	// language=SQL
+	SQL := `UPDATE product_variants
+			SET sku            = COALESCE($2, sku),
+			    pack_size      = COALESCE($3, pack_size),
+			    unit_id        = CASE
+			                         WHEN $4::TEXT IS NULL THEN unit_id
+			                         ELSE (SELECT id FROM units WHERE code = $4) END,
+			    mrp_paise      = COALESCE($5, mrp_paise),
+			    price_paise    = COALESCE($6, price_paise),
+			    barcode        = COALESCE($7, barcode),
+			    stock_quantity = COALESCE($8, stock_quantity),
+			    sort_order     = COALESCE($9, sort_order),
+			    is_active      = COALESCE($10, is_active),
+			    updated_at     = $11
+			WHERE id = $1
+			  AND archived_at IS NULL`
+
+	args := []interface{}{
+		variantID,
+		variantReq.SKU,
+		variantReq.PackSize,
+		variantReq.UnitCode,
+		variantReq.MRPPaise,
+		variantReq.PricePaise,
+		variantReq.Barcode,
+		variantReq.StockQuantity,
+		variantReq.SortOrder,
+		variantReq.IsActive,
+		time.Now().UTC(),
+	}
+
+	if err = execAffectingRow(tx, SQL, args...); err != nil {
+		if err != sql.ErrNoRows {
+			logrus.Errorf("UpdateProductVariant: error updating variant %v", err)
+		}
+		return models.ProductVariant{}, catalogErr(err)
+	}
+
+	if variantReq.PricePaise.Valid && variantReq.PricePaise.Int64 != oldPricePaise {
+		err = recordPriceChange(tx, variantID, oldPricePaise, variantReq.PricePaise.Int64, models.PriceChangeSourceManual, null.Time{}, changedBy)
+		if err != nil {
+			logrus.Errorf("UpdateProductVariant: error recording price history %v", err)
+			return models.ProductVariant{}, err
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		logrus.Errorf("UpdateProductVariant: error committing variant %v", err)
+		return models.ProductVariant{}, err
+	}
+	return dh.GetProductVariant(variantID)
+}
+
+// DeleteProductVariant archives the pack so past orders keep pointing at it.
+func (dh *DBHelper) DeleteProductVariant(variantID int) error {
+	// language=SQL
+	SQL := `UPDATE product_variants
+			SET archived_at = $2,
+			    is_active   = FALSE
+			WHERE id = $1
+			  AND archived_at IS NULL`
+
+	err := execAffectingRow(dh.DB, SQL, variantID, time.Now().UTC())
+	if err != nil && err != sql.ErrNoRows {
+		logrus.Errorf("DeleteProductVariant: error archiving variant %v", err)
+	}
+	return err
+}
+
+// AdjustVariantStock moves stock by delta in one statement, so concurrent orders and restocks never
+// overwrite each other. Going below zero fails with scmerrors.ErrInsufficientStock.
+func (dh *DBHelper) AdjustVariantStock(variantID, delta int) (stockQuantity int, err error) {
+	// language=SQL
+	SQL := `UPDATE product_variants
+			SET stock_quantity = stock_quantity + $2,
+			    updated_at     = $3
+			WHERE id = $1
+			  AND archived_at IS NULL
+			RETURNING stock_quantity`
+
+	err = dh.DB.Get(&stockQuantity, SQL, variantID, delta, time.Now().UTC())
+	if err != nil && err != sql.ErrNoRows {
+		logrus.Errorf("AdjustVariantStock: error adjusting stock %v", err)
+		return 0, catalogErr(err)
+	}
+	return stockQuantity, err
+}
@@ -0,0 +1,253 @@
+package keyprovider
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/sirupsen/logrus"
+	"github.com/vijaygniit/ApnaSabji/models"
+	"github.com/vijaygniit/ApnaSabji/providers"
+)
+
+// Config lists the keys as comma separated kid=value pairs.
+// Keys other than ActiveKID are kept for verification only, so tokens signed
+// before a rotation stay valid until they expire.
+type Config struct {
+	ActiveKID string
+	// HMACKeys holds kid=secret pairs used with HS256.
+	HMACKeys string
+	// PrivateKeyFiles holds kid=path pairs of RSA (RS256) or Ed25519 (EdDSA) PEM private keys.
+	PrivateKeyFiles string
+	// PublicKeyFiles holds kid=path pairs of retired keys whose private half is no longer available.
+	PublicKeyFiles string
+}
+
+type signingKey struct {
+	kid       string
+	method    jwt.SigningMethod
+	signKey   interface{}
+	verifyKey interface{}
+}
+
+type keyProvider struct {
+	activeKey *signingKey
+	keys      map[string]*signingKey
+}
+
+func NewSigningKeyProvider(cfg Config) (providers.SigningKeyProvider, error) {
+	kp := &keyProvider{
+		keys: make(map[string]*signingKey),
+	}
+
+	hmacKeys, err := parseKeyList(cfg.HMACKeys)
+	if err != nil {
+		return nil, err
+	}
+	for kid, secret := range hmacKeys {
+		if err := kp.addKey(&signingKey{
+			kid:       kid,
+			method:    jwt.SigningMethodHS256,
+			signKey:   []byte(secret),
+			verifyKey: []byte(secret),
+		}); err != nil {
+			return nil, err
+		}
+	}
+
+	privateKeyFiles, err := parseKeyList(cfg.PrivateKeyFiles)
+	if err != nil {
+		return nil, err
+	}
+	for kid, path := range privateKeyFiles {
+		key, err := loadPrivateKey(kid, path)
+		if err != nil {
+			return nil, err
+		}
+		if err := kp.addKey(key); err != nil {
+			return nil, err
+		}
+	}
+
+	publicKeyFiles, err := parseKeyList(cfg.PublicKeyFiles)
+	if err != nil {
+		return nil, err
+	}
+	for kid, path := range publicKeyFiles {
+		key, err := loadPublicKey(kid, path)
+		if err != nil {
+			return nil, err
+		}
+		if err := kp.addKey(key); err != nil {
+			return nil, err
+		}
+	}
+
+	activeKey, ok := kp.keys[cfg.ActiveKID]
+	if !ok {
+		return nil, fmt.Errorf("NewSigningKeyProvider: active key %q is not configured", cfg.ActiveKID)
+	}
+	if activeKey.signKey == nil {
+		return nil, fmt.Errorf("NewSigningKeyProvider: active key %q has no private key", cfg.ActiveKID)
+	}
+	kp.activeKey = activeKey
+
+	logrus.Infof("loaded %d jwt signing keys, active kid %q (%s)", len(kp.keys), activeKey.kid, activeKey.method.Alg())
+
+	return kp, nil
+}
+
+func (kp *keyProvider) SigningKey() (kid string, method jwt.SigningMethod, key interface{}) {
+	return kp.activeKey.kid, kp.activeKey.method, kp.activeKey.signKey
+}
+
+func (kp *keyProvider) VerificationKey(token *jwt.Token) (interface{}, error) {
+	key := kp.activeKey
+
+	// tokens issued before kid headers were introduced are checked against the active key
+	if kid, ok := token.Header["kid"]; ok {
+		kidString, ok := kid.(string)
+		if !ok {
+			return nil, errors.New("kid header is not a string")
+		}
+		key, ok = kp.keys[kidString]
+		if !ok {
+			return nil, fmt.Errorf("unknown signing key %q", kidString)
+		}
+	}
+
+	// the algorithm is pinned to the key so an RSA public key can never be used as an HMAC secret
+	if token.Method.Alg() != key.method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	}
+
+	return key.verifyKey, nil
+}
+
+func (kp *keyProvider) JWKS() models.JWKS {
+	jwks := models.JWKS{
+		Keys: make([]models.JWK, 0),
+	}
+
+	kids := make([]string, 0, len(kp.keys))
+	for kid := range kp.keys {
+		kids = append(kids, kid)
+	}
+	sort.Strings(kids)
+
+	for _, kid := range kids {
+		key := kp.keys[kid]
+		switch publicKey := key.verifyKey.(type) {
+		case *rsa.PublicKey:
+			jwks.Keys = append(jwks.Keys, models.JWK{
+				Kty: "RSA",
+				Kid: kid,
+				Use: "sig",
+				Alg: key.method.Alg(),
+				N:   base64.RawURLEncoding.EncodeToString(publicKey.N.Bytes()),
+				E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(publicKey.E)).Bytes()),
+			})
+		case ed25519.PublicKey:
+			jwks.Keys = append(jwks.Keys, models.JWK{
+				Kty: "OKP",
+				Kid: kid,
+				Use: "sig",
+				Alg: key.method.Alg(),
+				Crv: "Ed25519",
+				X:   base64.RawURLEncoding.EncodeToString(publicKey),
+			})
+		}
+	}
+
+	return jwks
+}
+
+func (kp *keyProvider) addKey(key *signingKey) error {
+	if _, ok := kp.keys[key.kid]; ok {
+		return fmt.Errorf("duplicate signing key %q", key.kid)
+	}
+	kp.keys[key.kid] = key
+	return nil
+}
+
+func loadPrivateKey(kid, path string) (*signingKey, error) {
+	pemBytes, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("loadPrivateKey: error reading key %q: %w", kid, err)
+	}
+
+	if rsaKey, err := jwt.ParseRSAPrivateKeyFromPEM(pemBytes); err == nil {
+		return &signingKey{
+			kid:       kid,
+			method:    jwt.SigningMethodRS256,
+			signKey:   rsaKey,
+			verifyKey: &rsaKey.PublicKey,
+		}, nil
+	}
+
+	edKey, err := jwt.ParseEdPrivateKeyFromPEM(pemBytes)
+	if err != nil {
+		return nil, fmt.Errorf("loadPrivateKey: key %q is neither an RSA nor an Ed25519 private key", kid)
+	}
+	edPrivateKey, ok := edKey.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("loadPrivateKey: key %q is not an Ed25519 private key", kid)
+	}
+
+	return &signingKey{
+		kid:       kid,
+		method:    jwt.SigningMethodEdDSA,
+		signKey:   edPrivateKey,
+		verifyKey: edPrivateKey.Public(),
+	}, nil
+}
+
+func loadPublicKey(kid, path string) (*signingKey, error) {
+	pemBytes, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("loadPublicKey: error reading key %q: %w", kid, err)
+	}
+
+	if rsaKey, err := jwt.ParseRSAPublicKeyFromPEM(pemBytes); err == nil {
+		return &signingKey{
+			kid:       kid,
+			method:    jwt.SigningMethodRS256,
+			verifyKey: rsaKey,
+		}, nil
+	}
+
+	edKey, err := jwt.ParseEdPublicKeyFromPEM(pemBytes)
+	if err != nil {
+		return nil, fmt.Errorf("loadPublicKey: key %q is neither an RSA nor an Ed25519 public key", kid)
+	}
+
+	return &signingKey{
+		kid:       kid,
+		method:    jwt.SigningMethodEdDSA,
+		verifyKey: edKey,
+	}, nil
+}
+
+// parseKeyList splits "kid1=value1,kid2=value2" into a map.
+func parseKeyList(keyList string) (map[string]string, error) {
+	keys := make(map[string]string)
+	for _, entry := range strings.Split(keyList, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		kid, value, ok := strings.Cut(entry, "=")
+		if !ok || kid == "" || value == "" {
+			return nil, fmt.Errorf("parseKeyList: invalid key entry %q, expected kid=value", entry)
+		}
+		keys[kid] = value
+	}
+	return keys, nil
+}
@@ -0,0 +1,127 @@
+package middlewareprovider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/vijaygniit/ApnaSabji/models"
+	"github.com/vijaygniit/ApnaSabji/scmerrors"
+	"github.com/vijaygniit/ApnaSabji/utils"
+	"github.com/volatiletech/null"
+)
+
+const apiKeyHeader = "X-API-Key"
+
+// APIKeyMiddleware authenticates partner systems by X-API-Key instead of a session JWT. The owning
+// organisation account is loaded into UserContextData along with the key's scopes. Requests are refused
+// while the key's rate limit can not be checked.
+func (AM Middleware) APIKeyMiddleware() func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			plainKey := r.Header.Get(apiKeyHeader)
+			if plainKey == "" {
+				scmerrors.RespondAPIKeyErr(w, scmerrors.ErrAPIKeyInvalid)
+				return
+			}
+
+			apiKey, err := AM.DBHelper.AuthenticateAPIKey(plainKey)
+			if err != nil {
+				if errors.Is(err, scmerrors.ErrAPIKeyInvalid) {
+					AM.recordAPIKeyRejected(r, apiKey.UserID, "unknown, revoked or expired key")
+				}
+				scmerrors.RespondAPIKeyErr(w, err)
+				return
+			}
+
+			allowed, retryAfter, err := AM.RateLimits.Hit(fmt.Sprintf("api_key:%d", apiKey.ID), models.RateLimitRule{
+				Limit:      apiKey.RateLimitPerMinute,
+				Window:     time.Minute,
+				Lockout:    time.Minute,
+				MaxLockout: time.Minute,
+			})
+			if err != nil {
+				logrus.Error("APIKeyMiddleware: error checking rate limit ", err)
+				scmerrors.RespondClientErr(w, err, http.StatusServiceUnavailable, "Please try again in a moment", "api key rate limit unavailable")
+				return
+			}
+			if !allowed {
+				scmerrors.RespondRateLimitErr(w, retryAfter, "api key rate limit exceeded")
+				return
+			}
+
+			userData, err := AM.DBHelper.FetchUserData(apiKey.UserID)
+			if err != nil {
+				scmerrors.RespondGenericServerErr(w, err, "FetchUserData: error getting api key owner")
+				return
+			}
+
+			roles, permissions, err := AM.DBHelper.GetUserRolesAndPermissions(apiKey.UserID)
+			if err != nil {
+				scmerrors.RespondGenericServerErr(w, err, "GetUserRolesAndPermissions: error getting roles")
+				return
+			}
+
+			// keys stop working as soon as the account is no longer a partner
+			if !contains(roles, string(models.RolePartner)) {
+				AM.recordAPIKeyRejected(r, apiKey.UserID, "owner is not a partner")
+				scmerrors.RespondAPIKeyErr(w, scmerrors.ErrAPIKeyInvalid)
+				return
+			}
+
+			userContextData := models.UserContextData{
+				UserID:       apiKey.UserID,
+				Fullname:     userData.Fullname,
+				Email:        userData.Email,
+				Mobilenumber: userData.Mobilenumber,
+				Roles:        roles,
+				Permissions:  permissions,
+				APIKeyID:     apiKey.ID,
+				Scopes:       apiKey.Scopes,
+			}
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), models.UserContext, &userContextData)))
+		})
+	}
+}
+
+// RequireScope only restricts API key requests, session users are limited by their roles instead.
+func (AM Middleware) RequireScope(scopes ...models.APIKeyScope) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			uc := AM.UserFromContext(r.Context())
+			if uc.APIKeyID == 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			for _, scope := range scopes {
+				if !contains(uc.Scopes, string(scope)) {
+					scmerrors.RespondAPIKeyErr(w, scmerrors.ErrAPIKeyScope)
+					return
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func (AM Middleware) recordAPIKeyRejected(r *http.Request, userID int, reason string) {
+	if !AM.auditRejection(r, models.AuthEventAPIKeyRejected) {
+		return
+	}
+
+	err := AM.DBHelper.RecordAuthEvent(models.AuthEvent{
+		UserID:    null.NewInt(userID, userID != 0),
+		EventType: models.AuthEventAPIKeyRejected,
+		Outcome:   models.AuthEventFailure,
+		Reason:    reason,
+		IP:        utils.ClientIP(r),
+		UserAgent: r.UserAgent(),
+	})
+	if err != nil {
+		logrus.Error("recordAPIKeyRejected: error recording auth event ", err)
+	}
+}
@@ -0,0 +1,32 @@
+package middlewareprovider
+
+import (
+	"net/http"
+
+	"github.com/vijaygniit/ApnaSabji/scmerrors"
+)
+
+// RequireConsent must be used after Middleware. Admins impersonating a user are let through, only the
+// user can accept terms on their own behalf.
+func (AM Middleware) RequireConsent() func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			uc := AM.UserFromContext(r.Context())
+			if uc.IsImpersonated() {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			pending, err := AM.DBHelper.GetPendingPolicyDocuments(uc.UserID)
+			if err != nil {
+				scmerrors.RespondGenericServerErr(w, err, "error checking consent")
+				return
+			}
+			if len(pending) > 0 {
+				scmerrors.RespondConsentErr(w, scmerrors.ErrConsentRequired)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
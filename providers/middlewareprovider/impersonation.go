@@ -0,0 +1,53 @@
+package middlewareprovider
+
+import (
+	"net/http"
+
+	"github.com/sirupsen/logrus"
+	"github.com/vijaygniit/ApnaSabji/models"
+	"github.com/vijaygniit/ApnaSabji/scmerrors"
+	"github.com/vijaygniit/ApnaSabji/utils"
+)
+
+// statusRecorder remembers the status code a handler wrote so it can be audited afterwards.
+type statusRecorder struct {
+	http.ResponseWriter
+	statusCode int
+}
+
+func (sr *statusRecorder) WriteHeader(statusCode int) {
+	sr.statusCode = statusCode
+	sr.ResponseWriter.WriteHeader(statusCode)
+}
+
+// serveImpersonated runs the request and writes an audit row naming both the admin and the user.
+func (AM Middleware) serveImpersonated(w http.ResponseWriter, r *http.Request, next http.Handler, uc *models.UserContextData) {
+	recorder := &statusRecorder{ResponseWriter: w, statusCode: http.StatusOK}
+	next.ServeHTTP(recorder, r)
+
+	err := AM.DBHelper.RecordImpersonatedRequest(models.ImpersonatedRequest{
+		SessionID:  uc.SessionID,
+		ActorID:    uc.ActorID,
+		UserID:     uc.UserID,
+		Method:     r.Method,
+		Path:       r.URL.Path,
+		StatusCode: recorder.statusCode,
+		IP:         utils.ClientIP(r),
+	})
+	if err != nil {
+		logrus.Error("serveImpersonated: error recording impersonated request ", err)
+	}
+}
+
+// BlockImpersonation must be used after Middleware, it relies on the actor loaded into UserContextData.
+func (AM Middleware) BlockImpersonation() func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if AM.UserFromContext(r.Context()).IsImpersonated() {
+				scmerrors.RespondImpersonationErr(w)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
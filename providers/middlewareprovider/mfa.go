@@ -0,0 +1,20 @@
+package middlewareprovider
+
+import (
+	"net/http"
+
+	"github.com/vijaygniit/ApnaSabji/scmerrors"
+)
+
+// RequireMFA must be used after Middleware, it relies on the session flag loaded into UserContextData.
+func (AM Middleware) RequireMFA() func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !AM.UserFromContext(r.Context()).MFASatisfied {
+				scmerrors.RespondMFAErr(w, scmerrors.ErrMFARequired)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -5,21 +5,20 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
-	"strconv"
 	"strings"
 	"time"
 
 	"github.com/go-chi/chi"
-	"github.com/golang-jwt/jwt/v4"
 	"github.com/rs/cors"
 	"github.com/sirupsen/logrus"
 	"github.com/vijaygniit/ApnaSabji/models"
 	"github.com/vijaygniit/ApnaSabji/providers"
+	"github.com/vijaygniit/ApnaSabji/providers/authProvider"
 	"github.com/vijaygniit/ApnaSabji/scmerrors"
+	"github.com/vijaygniit/ApnaSabji/utils"
+	"github.com/volatiletech/null"
 )
 
-var secret = []byte("supersecretkey")
-
 const (
 	authorization = "Authorization"
 	bearerScheme  = "bearer"
@@ -31,6 +30,10 @@ const (
 	userContext   = "userData"
 )
 
+// rejectionAuditRule caps how many rejected requests one IP writes to auth_events. A client stuck on an
+// expired token or a scanner trying keys would otherwise add a row per request.
+var rejectionAuditRule = models.RateLimitRule{Limit: 20, Window: 10 * time.Minute, Lockout: 10 * time.Minute, MaxLockout: time.Hour}
+
 type StructuredLogger struct{}
 
 func NewStructuredLogger() *StructuredLogger {
@@ -38,7 +41,9 @@ func NewStructuredLogger() *StructuredLogger {
 }
 
 type Middleware struct {
-	DBHelper providers.DBHelperProvider
+	DBHelper    providers.DBHelperProvider
+	SigningKeys providers.SigningKeyProvider
+	RateLimits  providers.RateLimitStore
 }
 
 func corsOptions() *cors.Cors {
@@ -52,9 +57,11 @@ func corsOptions() *cors.Cors {
 	})
 }
 
-func NewMiddleware(dbHelper providers.DBHelperProvider) providers.MiddlewareProvider {
+func NewMiddleware(dbHelper providers.DBHelperProvider, signingKeys providers.SigningKeyProvider, rateLimits providers.RateLimitStore) providers.MiddlewareProvider {
 	return &Middleware{
-		DBHelper: dbHelper,
+		DBHelper:    dbHelper,
+		SigningKeys: signingKeys,
+		RateLimits:  rateLimits,
 	}
 }
 
@@ -65,133 +72,152 @@ func (AM Middleware) Middleware() func(next http.Handler) http.Handler {
 
 			tokenParts := strings.Split(r.Header.Get(authorization), space)
 			if len(tokenParts) != 2 {
+				AM.recordTokenRejected(r, 0, "missing bearer token")
 				scmerrors.RespondClientErr(w, errors.New("token not Bearer"), http.StatusUnauthorized, "Invalid token", "Invalid token")
 				return
 			}
 
 			if !strings.EqualFold(tokenParts[0], bearerScheme) {
+				AM.recordTokenRejected(r, 0, "token not bearer")
 				scmerrors.RespondClientErr(w, errors.New("token not Bearer"), http.StatusUnauthorized, "Invalid token", "Invalid token")
 				return
 			}
 			token = tokenParts[1]
-			claims, err := GetClaimsFromToken(token)
+			claims, err := GetClaimsFromToken(AM.SigningKeys, token)
 			if err != nil {
-				scmerrors.RespondClientErr(w, err, http.StatusUnauthorized, "GetClaimsFromToken :Invalid token", "Invalid token")
+				AM.recordTokenRejected(r, 0, err.Error())
+				scmerrors.RespondClientErr(w, err, http.StatusUnauthorized, "Invalid token", "GetClaimsFromToken: invalid token")
 				return
 			}
 
-			SessionId, isClaimsVerified, err := AM.getUserDataFromClaims(claims)
+			userIDInt, session, err := AM.getUserDataFromClaims(claims)
 			if err != nil {
-				scmerrors.RespondClientErr(w, err, http.StatusUnauthorized, "getUserDataFromClaims: Invalid token", "Invalid token")
+				AM.recordTokenRejected(r, userIDInt, err.Error())
+				scmerrors.RespondClientErr(w, err, http.StatusUnauthorized, "Invalid token", "getUserDataFromClaims: invalid session")
 				return
 			}
 
-			if !isClaimsVerified {
-				scmerrors.RespondClientErr(w, errors.New("invalid token"), http.StatusUnauthorized, "Invalid token", "Invalid token")
-				return
-			}
-			err = AM.DBHelper.UpdateSession(SessionId)
+			SessionId := session.UUIDToken
+			err = AM.DBHelper.UpdateSession(SessionId, authProvider.AccessTokenTTL())
 			if err != nil {
 				scmerrors.RespondClientErr(w, err, http.StatusUnauthorized, "UpdateSession: error updating sessions ", "UpdateSession error updating sessions ")
 				return
 			}
 
-			issuer := claims["iss"].(string)
-			userIDInt, err := strconv.Atoi(issuer)
+			UserData, err := AM.DBHelper.FetchUserData(userIDInt)
 			if err != nil {
 				scmerrors.RespondClientErr(w, err, http.StatusUnauthorized, "UpdateSession: error updating sessions ", "UpdateSession error updating sessions ")
 				return
 			}
-			UserData, err := AM.DBHelper.FetchUserData(userIDInt)
+			roles, permissions, err := AM.DBHelper.GetUserRolesAndPermissions(userIDInt)
 			if err != nil {
-				scmerrors.RespondClientErr(w, err, http.StatusUnauthorized, "UpdateSession: error updating sessions ", "UpdateSession error updating sessions ")
+				scmerrors.RespondGenericServerErr(w, err, "GetUserRolesAndPermissions: error getting roles")
 				return
 			}
+
 			var userContextData models.UserContextData
 			userContextData.UserID = userIDInt
 			userContextData.Fullname = UserData.Fullname
 			userContextData.Email = UserData.Email
 			userContextData.Mobilenumber = UserData.Mobilenumber
 			userContextData.SessionID = SessionId
-			logrus.Info(userContextData)
+			userContextData.Roles = roles
+			userContextData.Permissions = permissions
+			userContextData.ActorID, _ = claims.ActorID()
+			userContextData.MFASatisfied = session.MFASatisfiedAt.Valid
 			ctxWithUser := context.WithValue(r.Context(), models.UserContext, &userContextData)
 			rWithUser := r.WithContext(ctxWithUser)
+
+			if userContextData.IsImpersonated() {
+				AM.serveImpersonated(w, rWithUser, next, &userContextData)
+				return
+			}
 			next.ServeHTTP(w, rWithUser)
 
 		})
 	}
 }
 
-func (AM Middleware) UserFromContext(ctx context.Context) *models.UserContextData {
-	return ctx.Value(models.UserContext).(*models.UserContextData)
+// OptionalAuth runs Middleware for requests that carry an Authorization header and lets anonymous
+// ones through, for public routes that behave differently for signed in users.
+func (AM Middleware) OptionalAuth() func(next http.Handler) http.Handler {
+	authenticate := AM.Middleware()
+	return func(next http.Handler) http.Handler {
+		authenticated := authenticate(next)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get(authorization) == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			authenticated.ServeHTTP(w, r)
+		})
+	}
 }
 
-func (AM Middleware) Default() chi.Middlewares {
-	return chi.Chain(corsOptions().Handler)
+// auditRejection reports whether a rejected request is still within rejectionAuditRule for its IP. Rejections
+// are recorded while the store is down, losing the audit trail is worse than the extra rows.
+func (AM Middleware) auditRejection(r *http.Request, eventType models.AuthEventType) bool {
+	allowed, _, err := AM.RateLimits.Hit(fmt.Sprintf("audit_%s:%s:%s", eventType, models.RateLimitKeyIP, utils.ClientIP(r)), rejectionAuditRule)
+	if err != nil {
+		logrus.Error("auditRejection: error checking rate limit ", err)
+		return true
+	}
+	return allowed
 }
 
-func GetClaimsFromToken(tokenString string) (jwt.MapClaims, error) {
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		return secret, nil
+// recordTokenRejected audits a request turned away by Middleware, userID is 0 when the token could not be read.
+func (AM Middleware) recordTokenRejected(r *http.Request, userID int, reason string) {
+	if !AM.auditRejection(r, models.AuthEventTokenRejected) {
+		return
+	}
+
+	err := AM.DBHelper.RecordAuthEvent(models.AuthEvent{
+		UserID:    null.NewInt(userID, userID != 0),
+		EventType: models.AuthEventTokenRejected,
+		Outcome:   models.AuthEventFailure,
+		Reason:    reason,
+		IP:        utils.ClientIP(r),
+		UserAgent: r.UserAgent(),
 	})
 	if err != nil {
-		return jwt.MapClaims{}, err
+		logrus.Error("recordTokenRejected: error recording auth event ", err)
 	}
-	if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
+}
 
-		return claims, nil
+func (AM Middleware) UserFromContext(ctx context.Context) *models.UserContextData {
+	userContextData, ok := ctx.Value(models.UserContext).(*models.UserContextData)
+	if !ok {
+		// unauthenticated routes such as register have no user in the context
+		return &models.UserContextData{}
 	}
-	return jwt.MapClaims{}, err
+	return userContextData
+}
+
+func (AM Middleware) Default() chi.Middlewares {
+	return chi.Chain(corsOptions().Handler)
+}
+
+func GetClaimsFromToken(signingKeys providers.SigningKeyProvider, tokenString string) (*authProvider.JWTClaim, error) {
+	return authProvider.ParseJWT(signingKeys, tokenString)
 }
 
-func (AM Middleware) getUserDataFromClaims(claims jwt.MapClaims) (string, bool, error) {
-	var validToken bool
-	var standardClaims jwt.StandardClaims
-	//var UserData models.FetchUserData
-	//data := make(map[string]interface{})
-	data := claims["data"].(map[string]interface{})
-
-	var issuer, sessionID string
-
-	issuer = claims["iss"].(string)
-	//name = data["name"].(string)
-	//role := data["role"].(string)
-	//email := data["email"]
-	UUIDToken := data["uuidToken"].(string)
-	//deviceId := data["deviceId"].(string)
-	//modelName := data["modelName"].(string)
-	//osVersion := data["oSVersion"].(string)
-	//platform := data["platform"].(string)
-
-	UserIDInt, err := strconv.Atoi(issuer)
+// getUserDataFromClaims checks that the session named in sid belongs to the user in sub and is still alive.
+func (AM Middleware) getUserDataFromClaims(claims *authProvider.JWTClaim) (userID int, session models.FetchUserSessionsData, err error) {
+	userID, err = claims.UserID()
 	if err != nil {
-		logrus.Error("GetUserDataFromClaims: error converting userId string to integer ", err)
-		return sessionID, validToken, errors.New(fmt.Sprintln("GetUserDataFromClaims: error converting userId string to integer & \n", err))
+		return userID, session, err
 	}
 
-	UserSessionsData, err := AM.DBHelper.FetchUserSessionData(UserIDInt)
+	UserSessionsData, err := AM.DBHelper.FetchUserSessionData(userID)
 	if err != nil {
 		logrus.Error("GetUserDataFromClaims: error fetching user session  Data from database ", err)
-		return sessionID, validToken, errors.New(fmt.Sprintln("GetUserDataFromClaims: error fetching user Data from database  & \n", err))
+		return userID, session, errors.New(fmt.Sprintln("GetUserDataFromClaims: error fetching user Data from database  & \n", err))
 	}
-	//fmt.Println("UserSessionsData ", UserSessionsData)
-	//fmt.Println("UserIDInt ", UserIDInt)
-	//fmt.Println("data ", data)
-	//sessionId = UserSessionsData[0].UUIDToken
 
-	var sessionEndTime time.Time
 	for _, sessionData := range UserSessionsData {
-		sessionID = sessionData.UUIDToken
-		sessionEndTime = sessionData.EndTime
-		if standardClaims.ExpiresAt < time.Now().Unix() && sessionID == UUIDToken && sessionEndTime.Unix() > time.Now().Unix() {
-			return sessionID, true, nil
+		if sessionData.UUIDToken == claims.SessionID && sessionData.EndTime.After(time.Now()) {
+			return userID, sessionData, nil
 		}
-		// else {
-		//  	return sessionId, validToken, errors.New(fmt.Sprintln("invalid session id ", err))
-		// }
 	}
-	return sessionID, validToken, errors.New(fmt.Sprintln("invalid session id or Session is expired", err))
+	return userID, session, errors.New("invalid session id or Session is expired")
 }
@@ -0,0 +1,99 @@
+package middlewareprovider
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/vijaygniit/ApnaSabji/models"
+	"github.com/vijaygniit/ApnaSabji/scmerrors"
+	"github.com/vijaygniit/ApnaSabji/utils"
+)
+
+const maxRateLimitBodyBytes = 1 << 20
+
+// rateLimitFields are the parts of the auth request bodies we count attempts against.
+type rateLimitFields struct {
+	Email        string `json:"email"`
+	Mobilenumber string `json:"mobilenumber"`
+	DeviceID     string `json:"deviceId"`
+}
+
+// RateLimit checks every key the policy has a rule for. Keys missing from the request are skipped,
+// so a rule for email does nothing on a phone login. If the store fails the request is let through,
+// unless the policy fails closed.
+func (AM Middleware) RateLimit(policy models.RateLimitPolicy) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			keys := rateLimitKeys(r)
+			if uc := AM.UserFromContext(r.Context()); uc.UserID != 0 {
+				keys[models.RateLimitKeyUser] = strconv.Itoa(uc.UserID)
+			}
+
+			var retryAfter time.Duration
+			for kind, rule := range policy.Rules {
+				value := keys[kind]
+				if value == "" {
+					continue
+				}
+
+				allowed, wait, err := AM.RateLimits.Hit(fmt.Sprintf("%s:%s:%s", policy.Name, kind, value), rule)
+				if err != nil {
+					logrus.Error("RateLimit: error checking rate limit ", err)
+					if policy.FailClosed {
+						scmerrors.RespondClientErr(w, err, http.StatusServiceUnavailable, "Please try again in a moment", fmt.Sprintf("rate limit %s unavailable", policy.Name))
+						return
+					}
+					continue
+				}
+				if !allowed && wait > retryAfter {
+					retryAfter = wait
+				}
+			}
+
+			if retryAfter > 0 {
+				scmerrors.RespondRateLimitErr(w, retryAfter, fmt.Sprintf("rate limit %s exceeded", policy.Name))
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// rateLimitKeys reads the JSON body without consuming it, the handler decodes it again afterwards.
+func rateLimitKeys(r *http.Request) map[models.RateLimitKey]string {
+	keys := map[models.RateLimitKey]string{
+		models.RateLimitKeyIP: utils.ClientIP(r),
+	}
+
+	if r.Body == nil {
+		return keys
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxRateLimitBodyBytes))
+	if err != nil {
+		logrus.Error("rateLimitKeys: error reading body ", err)
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+
+	var fields rateLimitFields
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return keys
+	}
+
+	keys[models.RateLimitKeyEmail] = strings.ToLower(strings.TrimSpace(fields.Email))
+	// every spelling of a number shares one counter, numbers that do not parse are counted as typed
+	keys[models.RateLimitKeyPhone] = strings.NewReplacer(" ", "", "-", "", "(", "", ")", "").Replace(fields.Mobilenumber)
+	if phone, err := utils.NormalizePhoneNumber(fields.Mobilenumber); err == nil {
+		keys[models.RateLimitKeyPhone] = phone
+	}
+	keys[models.RateLimitKeyDevice] = strings.TrimSpace(fields.DeviceID)
+	return keys
+}
@@ -0,0 +1,55 @@
+package middlewareprovider
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/vijaygniit/ApnaSabji/models"
+	"github.com/vijaygniit/ApnaSabji/scmerrors"
+)
+
+// RequireRole must be used after Middleware, it relies on the roles loaded into UserContextData.
+func (AM Middleware) RequireRole(roles ...models.Role) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			uc := AM.UserFromContext(r.Context())
+
+			for _, role := range roles {
+				if contains(uc.Roles, string(role)) {
+					next.ServeHTTP(w, r)
+					return
+				}
+			}
+
+			scmerrors.RespondClientErr(w, errors.New("missing role"), http.StatusForbidden, "You are not allowed to access this resource", fmt.Sprintf("requires one of roles %v", roles))
+		})
+	}
+}
+
+// RequirePermission must be used after Middleware, it relies on the permissions loaded into UserContextData.
+func (AM Middleware) RequirePermission(permissions ...models.Permission) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			uc := AM.UserFromContext(r.Context())
+
+			for _, permission := range permissions {
+				if !contains(uc.Permissions, string(permission)) {
+					scmerrors.RespondClientErr(w, errors.New("missing permission"), http.StatusForbidden, "You are not allowed to perform this action", fmt.Sprintf("requires permission %s", permission))
+					return
+				}
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
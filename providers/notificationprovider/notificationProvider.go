@@ -0,0 +1,64 @@
+package notificationprovider
+
+import (
+	"github.com/sirupsen/logrus"
+	"github.com/vijaygniit/ApnaSabji/providers"
+)
+
+const (
+	DriverSMTP = "smtp"
+	DriverHTTP = "http"
+	DriverSink = "sink"
+)
+
+type emailChannel interface {
+	SendEmail(to, subject, body string) error
+}
+
+type smsChannel interface {
+	SendSMS(to, message string) error
+}
+
+type notificationProvider struct {
+	email emailChannel
+	sms   smsChannel
+}
+
+// NewNotificationProvider picks the email and SMS channels by driver name.
+// Unknown or empty drivers fall back to the local sink so dev and test setups need no outside services.
+func NewNotificationProvider(emailDriver, smsDriver string) providers.NotificationProvider {
+	sink := newSinkChannel()
+
+	var email emailChannel = sink
+	switch emailDriver {
+	case DriverSMTP:
+		email = newSMTPChannel()
+	case DriverSink, "":
+	default:
+		logrus.Errorf("NewNotificationProvider: unknown email driver %q, using sink", emailDriver)
+	}
+
+	var sms smsChannel = sink
+	switch smsDriver {
+	case DriverHTTP:
+		sms = newHTTPSMSChannel()
+	case DriverSink, "":
+	default:
+		logrus.Errorf("NewNotificationProvider: unknown sms driver %q, using sink", smsDriver)
+	}
+
+	logrus.Infof("notification channels: email=%T sms=%T", email, sms)
+
+	return &notificationProvider{
+		email: email,
+		sms:   sms,
+	}
+}
+
+func (np *notificationProvider) SendEmail(to, subject, body string) error {
+	return np.email.SendEmail(to, subject, body)
+}
+
+func (np *notificationProvider) SendSMS(to, message string) error {
+	return np.sms.SendSMS(to, message)
+}
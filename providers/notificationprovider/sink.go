@@ -0,0 +1,68 @@
+package notificationprovider
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// sinkChannel writes every notification as a JSON line to NOTIFICATION_SINK_FILE, or stdout when unset.
+type sinkChannel struct {
+	mu   sync.Mutex
+	path string
+}
+
+type sinkRecord struct {
+	Channel string    `json:"channel"`
+	To      string    `json:"to"`
+	Subject string    `json:"subject,omitempty"`
+	Body    string    `json:"body"`
+	SentAt  time.Time `json:"sentAt"`
+}
+
+func newSinkChannel() *sinkChannel {
+	return &sinkChannel{
+		path: os.Getenv("NOTIFICATION_SINK_FILE"),
+	}
+}
+
+func (sc *sinkChannel) SendEmail(to, subject, body string) error {
+	return sc.write(sinkRecord{
+		Channel: "email",
+		To:      to,
+		Subject: subject,
+		Body:    body,
+		SentAt:  time.Now().UTC(),
+	})
+}
+
+func (sc *sinkChannel) SendSMS(to, message string) error {
+	return sc.write(sinkRecord{
+		Channel: "sms",
+		To:      to,
+		Body:    message,
+		SentAt:  time.Now().UTC(),
+	})
+}
+
+func (sc *sinkChannel) write(record sinkRecord) error {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	var out io.Writer = os.Stdout
+	if sc.path != "" {
+		file, err := os.OpenFile(sc.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+		if err != nil {
+			logrus.Errorf("sinkChannel: error opening sink file %v", err)
+			return err
+		}
+		defer file.Close()
+		out = file
+	}
+
+	return json.NewEncoder(out).Encode(record)
+}
@@ -0,0 +1,65 @@
+package notificationprovider
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+)
+
+const smsGatewayTimeout = 10 * time.Second
+
+type httpSMSChannel struct {
+	gatewayURL string
+	apiKey     string
+	senderID   string
+	client     *http.Client
+}
+
+type smsGatewayRequest struct {
+	To       string `json:"to"`
+	Message  string `json:"message"`
+	SenderID string `json:"senderId,omitempty"`
+}
+
+func newHTTPSMSChannel() *httpSMSChannel {
+	return &httpSMSChannel{
+		gatewayURL: os.Getenv("SMS_GATEWAY_URL"),
+		apiKey:     os.Getenv("SMS_GATEWAY_API_KEY"),
+		senderID:   os.Getenv("SMS_GATEWAY_SENDER_ID"),
+		client:     &http.Client{Timeout: smsGatewayTimeout},
+	}
+}
+
+func (hc *httpSMSChannel) SendSMS(to, message string) error {
+	body, err := json.Marshal(smsGatewayRequest{
+		To:       to,
+		Message:  message,
+		SenderID: hc.senderID,
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, hc.gatewayURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if hc.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+hc.apiKey)
+	}
+
+	resp, err := hc.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("SendSMS: error calling sms gateway: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("SendSMS: sms gateway responded with status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+package notificationprovider
+
+import (
+	"fmt"
+	"net"
+	"net/smtp"
+	"os"
+	"strings"
+)
+
+type smtpChannel struct {
+	host     string
+	port     string
+	username string
+	password string
+	from     string
+}
+
+func newSMTPChannel() *smtpChannel {
+	return &smtpChannel{
+		host:     os.Getenv("SMTP_HOST"),
+		port:     os.Getenv("SMTP_PORT"),
+		username: os.Getenv("SMTP_USERNAME"),
+		password: os.Getenv("SMTP_PASSWORD"),
+		from:     os.Getenv("SMTP_FROM"),
+	}
+}
+
+func (sc *smtpChannel) SendEmail(to, subject, body string) error {
+	var auth smtp.Auth
+	if sc.username != "" {
+		auth = smtp.PlainAuth("", sc.username, sc.password, sc.host)
+	}
+
+	msg := strings.Join([]string{
+		"From: " + sc.from,
+		"To: " + to,
+		"Subject: " + subject,
+		"MIME-Version: 1.0",
+		"Content-Type: text/plain; charset=\"UTF-8\"",
+		"",
+		body,
+	}, "\r\n")
+
+	if err := smtp.SendMail(net.JoinHostPort(sc.host, sc.port), auth, sc.from, []string{to}, []byte(msg)); err != nil {
+		return fmt.Errorf("SendEmail: error sending email via smtp: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,164 @@
+package oidcprovider
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/vijaygniit/ApnaSabji/models"
+)
+
+// ID tokens must be signed with a public key algorithm, HS256 would let anyone holding the client secret mint them.
+var idTokenMethods = []string{"RS256", "RS384", "RS512", "ES256", "ES384", "ES512"}
+
+// flexibleBool accepts email_verified as true or "true", providers disagree on the type.
+type flexibleBool bool
+
+func (fb *flexibleBool) UnmarshalJSON(data []byte) error {
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	switch v := value.(type) {
+	case bool:
+		*fb = flexibleBool(v)
+	case string:
+		*fb = v == "true"
+	}
+	return nil
+}
+
+type idTokenClaims struct {
+	Nonce         string       `json:"nonce"`
+	Email         string       `json:"email"`
+	EmailVerified flexibleBool `json:"email_verified"`
+	Name          string       `json:"name"`
+	jwt.RegisteredClaims
+}
+
+func (op *oidcProvider) validateIDToken(ctx context.Context, discovery *discoveryDocument, idToken, nonce string) (*idTokenClaims, error) {
+	claims := &idTokenClaims{}
+	parser := jwt.NewParser(jwt.WithValidMethods(idTokenMethods))
+
+	_, err := parser.ParseWithClaims(idToken, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		return op.verificationKey(ctx, discovery, kid)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Issuer and audience are checked against our config, not the discovery document.
+	if !claims.VerifyIssuer(op.cfg.Issuer, true) && !claims.VerifyIssuer(op.cfg.Issuer+"/", true) {
+		return nil, errors.New("unexpected issuer")
+	}
+	if !claims.VerifyAudience(op.cfg.ClientID, true) {
+		return nil, errors.New("unexpected audience")
+	}
+	if !claims.VerifyExpiresAt(time.Now(), true) {
+		return nil, errors.New("token expired")
+	}
+	if claims.Nonce == "" || claims.Nonce != nonce {
+		return nil, errors.New("nonce mismatch")
+	}
+	if claims.Subject == "" {
+		return nil, errors.New("missing subject")
+	}
+	return claims, nil
+}
+
+// verificationKey looks kid up in the cached JWKS, refetching it when the provider rotated keys.
+func (op *oidcProvider) verificationKey(ctx context.Context, discovery *discoveryDocument, kid string) (interface{}, error) {
+	op.mu.Lock()
+	defer op.mu.Unlock()
+
+	if key, ok := op.lookupKey(kid); ok {
+		return key, nil
+	}
+
+	if time.Since(op.keysFetchedAt) < jwksMinInterval {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+
+	var jwks models.JWKS
+	if err := op.getJSON(ctx, discovery.JWKSURI, &jwks); err != nil {
+		return nil, fmt.Errorf("fetching jwks: %w", err)
+	}
+
+	keys := make(map[string]interface{}, len(jwks.Keys))
+	for _, jwk := range jwks.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := parseJWK(jwk)
+		if err != nil {
+			continue
+		}
+		keys[jwk.Kid] = key
+	}
+	op.keys = keys
+	op.keysFetchedAt = time.Now()
+
+	if key, ok := op.lookupKey(kid); ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown signing key %q", kid)
+}
+
+// lookupKey falls back to the only key when the token has no kid.
+func (op *oidcProvider) lookupKey(kid string) (interface{}, bool) {
+	if key, ok := op.keys[kid]; ok {
+		return key, true
+	}
+	if kid == "" && len(op.keys) == 1 {
+		for _, key := range op.keys {
+			return key, true
+		}
+	}
+	return nil, false
+}
+
+func parseJWK(jwk models.JWK) (interface{}, error) {
+	switch jwk.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(jwk.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := base64.RawURLEncoding.DecodeString(jwk.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch jwk.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", jwk.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(jwk.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := base64.RawURLEncoding.DecodeString(jwk.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}, nil
+	}
+	return nil, fmt.Errorf("unsupported key type %q", jwk.Kty)
+}
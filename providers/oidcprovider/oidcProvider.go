@@ -0,0 +1,188 @@
+package oidcprovider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/vijaygniit/ApnaSabji/models"
+	"github.com/vijaygniit/ApnaSabji/providers"
+	"github.com/vijaygniit/ApnaSabji/scmerrors"
+)
+
+const (
+	discoveryPath   = "/.well-known/openid-configuration"
+	httpTimeout     = 10 * time.Second
+	defaultScopes   = "openid email profile"
+	jwksMinInterval = time.Minute
+)
+
+// Config describes one OpenID Connect provider. Any standards compliant issuer works,
+// endpoints are read from its discovery document.
+type Config struct {
+	Name         string
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       string
+}
+
+type discoveryDocument struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+type oidcProvider struct {
+	cfg        Config
+	httpClient *http.Client
+
+	mu            sync.Mutex
+	discovery     *discoveryDocument
+	keys          map[string]interface{}
+	keysFetchedAt time.Time
+}
+
+func NewOIDCProvider(cfg Config) providers.IdentityProvider {
+	if cfg.Scopes == "" {
+		cfg.Scopes = defaultScopes
+	}
+	cfg.Issuer = strings.TrimSuffix(cfg.Issuer, "/")
+
+	return &oidcProvider{
+		cfg:        cfg,
+		httpClient: &http.Client{Timeout: httpTimeout},
+		keys:       make(map[string]interface{}),
+	}
+}
+
+func (op *oidcProvider) Name() string {
+	return op.cfg.Name
+}
+
+func (op *oidcProvider) AuthCodeURL(state, nonce, codeChallenge string) (string, error) {
+	discovery, err := op.getDiscovery(context.Background())
+	if err != nil {
+		return "", err
+	}
+
+	query := url.Values{}
+	query.Set("response_type", "code")
+	query.Set("client_id", op.cfg.ClientID)
+	query.Set("redirect_uri", op.cfg.RedirectURL)
+	query.Set("scope", op.cfg.Scopes)
+	query.Set("state", state)
+	query.Set("nonce", nonce)
+	query.Set("code_challenge", codeChallenge)
+	query.Set("code_challenge_method", "S256")
+
+	separator := "?"
+	if strings.Contains(discovery.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+	return discovery.AuthorizationEndpoint + separator + query.Encode(), nil
+}
+
+func (op *oidcProvider) Exchange(ctx context.Context, code, codeVerifier, nonce string) (models.OIDCIdentity, error) {
+	identity := models.OIDCIdentity{Provider: op.cfg.Name}
+
+	discovery, err := op.getDiscovery(ctx)
+	if err != nil {
+		return identity, err
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", op.cfg.RedirectURL)
+	form.Set("client_id", op.cfg.ClientID)
+	form.Set("code_verifier", codeVerifier)
+	if op.cfg.ClientSecret != "" {
+		form.Set("client_secret", op.cfg.ClientSecret)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, discovery.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return identity, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := op.httpClient.Do(req)
+	if err != nil {
+		return identity, err
+	}
+	defer resp.Body.Close()
+
+	var tokenResp struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tokenResp); err != nil {
+		return identity, fmt.Errorf("%w: decoding token response: %v", scmerrors.ErrOIDCExchangeFailed, err)
+	}
+	if resp.StatusCode != http.StatusOK || tokenResp.IDToken == "" {
+		logrus.Errorf("oidcProvider.Exchange: %s token endpoint returned %d %s %s", op.cfg.Name, resp.StatusCode, tokenResp.Error, tokenResp.ErrorDescription)
+		return identity, fmt.Errorf("%w: %s", scmerrors.ErrOIDCExchangeFailed, tokenResp.Error)
+	}
+
+	claims, err := op.validateIDToken(ctx, discovery, tokenResp.IDToken, nonce)
+	if err != nil {
+		return identity, fmt.Errorf("%w: %v", scmerrors.ErrOIDCTokenInvalid, err)
+	}
+
+	identity.Subject = claims.Subject
+	identity.Email = strings.ToLower(strings.TrimSpace(claims.Email))
+	identity.EmailVerified = bool(claims.EmailVerified)
+	identity.Name = strings.TrimSpace(claims.Name)
+	return identity, nil
+}
+
+// getDiscovery fetches the discovery document once and keeps it for the life of the process.
+func (op *oidcProvider) getDiscovery(ctx context.Context) (*discoveryDocument, error) {
+	op.mu.Lock()
+	defer op.mu.Unlock()
+
+	if op.discovery != nil {
+		return op.discovery, nil
+	}
+
+	var discovery discoveryDocument
+	if err := op.getJSON(ctx, op.cfg.Issuer+discoveryPath, &discovery); err != nil {
+		return nil, fmt.Errorf("oidc discovery for %s: %w", op.cfg.Name, err)
+	}
+
+	if strings.TrimSuffix(discovery.Issuer, "/") != op.cfg.Issuer {
+		return nil, fmt.Errorf("oidc discovery for %s: issuer %q does not match %q", op.cfg.Name, discovery.Issuer, op.cfg.Issuer)
+	}
+
+	op.discovery = &discovery
+	return op.discovery, nil
+}
+
+func (op *oidcProvider) getJSON(ctx context.Context, url string, target interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := op.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s returned %d", url, resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(target)
+}
@@ -0,0 +1,273 @@
+package oidcprovider
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/vijaygniit/ApnaSabji/models"
+	"github.com/vijaygniit/ApnaSabji/scmerrors"
+)
+
+const (
+	testClientID     = "apnasabji-web"
+	testClientSecret = "client-secret"
+	testKeyID        = "test-key"
+	testNonce        = "nonce-1"
+	testCode         = "code-1"
+	testVerifier     = "verifier-0123456789-0123456789-0123456789"
+)
+
+// testIssuer is an OpenID provider serving discovery, JWKS and a token endpoint that checks PKCE and
+// answers with whatever ID token the test sets.
+type testIssuer struct {
+	*httptest.Server
+	key     *rsa.PrivateKey
+	idToken string
+}
+
+func newTestIssuer(t *testing.T) *testIssuer {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	issuer := &testIssuer{key: key}
+	mux := http.NewServeMux()
+	mux.HandleFunc(discoveryPath, func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(discoveryDocument{
+			Issuer:                issuer.URL,
+			AuthorizationEndpoint: issuer.URL + "/authorize",
+			TokenEndpoint:         issuer.URL + "/token",
+			JWKSURI:               issuer.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(models.JWKS{Keys: []models.JWK{{
+			Kty: "RSA",
+			Kid: testKeyID,
+			Use: "sig",
+			N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if r.PostForm.Get("grant_type") != "authorization_code" || r.PostForm.Get("code") != testCode ||
+			r.PostForm.Get("client_id") != testClientID || pkceChallenge(r.PostForm.Get("code_verifier")) != pkceChallenge(testVerifier) {
+			w.WriteHeader(http.StatusBadRequest)
+			_ = json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]string{"id_token": issuer.idToken})
+	})
+
+	issuer.Server = httptest.NewServer(mux)
+	t.Cleanup(issuer.Close)
+	return issuer
+}
+
+func pkceChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+func (issuer *testIssuer) claims() jwt.MapClaims {
+	return jwt.MapClaims{
+		"iss":            issuer.URL,
+		"aud":            testClientID,
+		"sub":            "subject-1",
+		"exp":            time.Now().Add(time.Hour).Unix(),
+		"iat":            time.Now().Unix(),
+		"nonce":          testNonce,
+		"email":          " Ravi@Example.com ",
+		"email_verified": true,
+		"name":           "Ravi Kumar",
+	}
+}
+
+func (issuer *testIssuer) sign(t *testing.T, claims jwt.MapClaims) string {
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = testKeyID
+	signed, err := token.SignedString(issuer.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func (issuer *testIssuer) provider() *oidcProvider {
+	return NewOIDCProvider(Config{
+		Name:         "test",
+		Issuer:       issuer.URL,
+		ClientID:     testClientID,
+		ClientSecret: testClientSecret,
+		RedirectURL:  "https://apnasabji.example/oidc/callback",
+	}).(*oidcProvider)
+}
+
+func TestAuthCodeURL(t *testing.T) {
+	issuer := newTestIssuer(t)
+
+	authURL, err := issuer.provider().AuthCodeURL("state-1", testNonce, pkceChallenge(testVerifier))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parsed, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	query := parsed.Query()
+	if parsed.Path != "/authorize" || query.Get("client_id") != testClientID || query.Get("state") != "state-1" ||
+		query.Get("nonce") != testNonce || query.Get("code_challenge") != pkceChallenge(testVerifier) ||
+		query.Get("code_challenge_method") != "S256" {
+		t.Errorf("AuthCodeURL() = %s", authURL)
+	}
+}
+
+func TestExchange(t *testing.T) {
+	issuer := newTestIssuer(t)
+
+	issuer.idToken = issuer.sign(t, issuer.claims())
+
+	identity, err := issuer.provider().Exchange(context.Background(), testCode, testVerifier, testNonce)
+	if err != nil {
+		t.Fatalf("Exchange() error = %v", err)
+	}
+	want := models.OIDCIdentity{Provider: "test", Subject: "subject-1", Email: "ravi@example.com", EmailVerified: true, Name: "Ravi Kumar"}
+	if identity != want {
+		t.Errorf("Exchange() = %+v, want %+v", identity, want)
+	}
+
+	if _, err = issuer.provider().Exchange(context.Background(), testCode, "another-verifier", testNonce); !errors.Is(err, scmerrors.ErrOIDCExchangeFailed) {
+		t.Errorf("Exchange() with a wrong code verifier error = %v, want %v", err, scmerrors.ErrOIDCExchangeFailed)
+	}
+}
+
+func TestExchangeRejectsInvalidIDTokens(t *testing.T) {
+	issuer := newTestIssuer(t)
+
+	tests := []struct {
+		name    string
+		idToken func() string
+	}{
+		{"wrong issuer", func() string {
+			claims := issuer.claims()
+			claims["iss"] = "https://accounts.attacker.example"
+			return issuer.sign(t, claims)
+		}},
+		{"wrong audience", func() string {
+			claims := issuer.claims()
+			claims["aud"] = "another-client"
+			return issuer.sign(t, claims)
+		}},
+		{"wrong nonce", func() string {
+			claims := issuer.claims()
+			claims["nonce"] = "replayed-nonce"
+			return issuer.sign(t, claims)
+		}},
+		{"missing nonce", func() string {
+			claims := issuer.claims()
+			delete(claims, "nonce")
+			return issuer.sign(t, claims)
+		}},
+		{"expired", func() string {
+			claims := issuer.claims()
+			claims["exp"] = time.Now().Add(-time.Minute).Unix()
+			return issuer.sign(t, claims)
+		}},
+		{"missing expiry", func() string {
+			claims := issuer.claims()
+			delete(claims, "exp")
+			return issuer.sign(t, claims)
+		}},
+		{"alg none", func() string {
+			token := jwt.NewWithClaims(jwt.SigningMethodNone, issuer.claims())
+			signed, err := token.SignedString(jwt.UnsafeAllowNoneSignatureType)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return signed
+		}},
+		{"HS256 with the client secret", func() string {
+			token := jwt.NewWithClaims(jwt.SigningMethodHS256, issuer.claims())
+			token.Header["kid"] = testKeyID
+			signed, err := token.SignedString([]byte(testClientSecret))
+			if err != nil {
+				t.Fatal(err)
+			}
+			return signed
+		}},
+		{"unknown key", func() string {
+			key, err := rsa.GenerateKey(rand.Reader, 2048)
+			if err != nil {
+				t.Fatal(err)
+			}
+			token := jwt.NewWithClaims(jwt.SigningMethodRS256, issuer.claims())
+			token.Header["kid"] = testKeyID
+			signed, err := token.SignedString(key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return signed
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			issuer.idToken = tt.idToken()
+			_, err := issuer.provider().Exchange(context.Background(), testCode, testVerifier, testNonce)
+			if !errors.Is(err, scmerrors.ErrOIDCTokenInvalid) {
+				t.Errorf("Exchange() error = %v, want %v", err, scmerrors.ErrOIDCTokenInvalid)
+			}
+		})
+	}
+}
+
+// An unverified email must reach LinkOIDCIdentity as unverified, whichever type the provider uses for it.
+func TestExchangeEmailVerified(t *testing.T) {
+	issuer := newTestIssuer(t)
+
+	tests := []struct {
+		emailVerified interface{}
+		want          bool
+	}{
+		{true, true},
+		{"true", true},
+		{false, false},
+		{"false", false},
+		{nil, false},
+	}
+
+	for _, tt := range tests {
+		claims := issuer.claims()
+		claims["email_verified"] = tt.emailVerified
+		if tt.emailVerified == nil {
+			delete(claims, "email_verified")
+		}
+		issuer.idToken = issuer.sign(t, claims)
+
+		identity, err := issuer.provider().Exchange(context.Background(), testCode, testVerifier, testNonce)
+		if err != nil {
+			t.Fatalf("Exchange() with email_verified %v error = %v", tt.emailVerified, err)
+		}
+		if identity.EmailVerified != tt.want {
+			t.Errorf("Exchange() with email_verified %v EmailVerified = %v, want %v", tt.emailVerified, identity.EmailVerified, tt.want)
+		}
+	}
+}
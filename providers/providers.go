@@ -3,8 +3,10 @@ package providers
 import (
 	"context"
 	"net/http"
+	"time"
 
 	"github.com/go-chi/chi"
+	"github.com/golang-jwt/jwt/v4"
 	"github.com/jmoiron/sqlx"
 	"github.com/vijaygniit/ApnaSabji/models"
 )
@@ -22,9 +24,58 @@ type DBProvider interface {
 
 type MiddlewareProvider interface {
 	Middleware() func(next http.Handler) http.Handler
+	// OptionalAuth authenticates requests that carry a token and lets anonymous ones through.
+	OptionalAuth() func(next http.Handler) http.Handler
 	UserFromContext(ctx context.Context) *models.UserContextData
 
 	// Default has default middleware written on the top levels of router such as CORS.
 	Default() chi.Middlewares
-	//SuperAdminCheck() chi.Middlewares
+	// RequireRole lets a request through when the user holds any of the given roles.
+	RequireRole(roles ...models.Role) func(next http.Handler) http.Handler
+	// RequirePermission lets a request through when the user's roles grant all of the given permissions.
+	RequirePermission(permissions ...models.Permission) func(next http.Handler) http.Handler
+	// RateLimit counts requests per IP, email, phone and device id and answers 429 once a rule is exceeded.
+	RateLimit(policy models.RateLimitPolicy) func(next http.Handler) http.Handler
+	// BlockImpersonation turns away requests made with an impersonation token, for actions only the user may take.
+	BlockImpersonation() func(next http.Handler) http.Handler
+	// RequireMFA lets a request through only when its session has passed a second factor.
+	RequireMFA() func(next http.Handler) http.Handler
+	// APIKeyMiddleware authenticates partner systems by X-API-Key instead of a session token.
+	APIKeyMiddleware() func(next http.Handler) http.Handler
+	// RequireScope lets an API key request through only when the key has all of the given scopes.
+	RequireScope(scopes ...models.APIKeyScope) func(next http.Handler) http.Handler
+	// RequireConsent turns away users who have not accepted the latest mandatory terms and privacy policy.
+	RequireConsent() func(next http.Handler) http.Handler
+}
+
+type NotificationProvider interface {
+	// SendEmail delivers a plain text email to the given address.
+	SendEmail(to, subject, body string) error
+	// SendSMS delivers a text message to the given E.164 phone number.
+	SendSMS(to, message string) error
+}
+
+type SigningKeyProvider interface {
+	// SigningKey returns the active key id, signing method and private key used to issue tokens.
+	SigningKey() (kid string, method jwt.SigningMethod, key interface{})
+	// VerificationKey is a jwt.Keyfunc that picks the key matching the token's kid.
+	VerificationKey(token *jwt.Token) (interface{}, error)
+	// JWKS returns the public halves of the asymmetric keys for clients and other services.
+	JWKS() models.JWKS
+}
+
+type RateLimitStore interface {
+	// Hit records an attempt for key and reports whether it is allowed, or how long the key is locked out.
+	Hit(key string, rule models.RateLimitRule) (allowed bool, retryAfter time.Duration, err error)
+	// Cleanup forgets hits and expired lockouts older than olderThan.
+	Cleanup(olderThan time.Duration) error
+}
+
+// IdentityProvider is an external login such as "Continue with Google".
+type IdentityProvider interface {
+	Name() string
+	// AuthCodeURL is where the user is sent to sign in, codeChallenge is the S256 PKCE challenge.
+	AuthCodeURL(state, nonce, codeChallenge string) (string, error)
+	// Exchange redeems the authorization code and returns the identity from the validated ID token.
+	Exchange(ctx context.Context, code, codeVerifier, nonce string) (models.OIDCIdentity, error)
 }
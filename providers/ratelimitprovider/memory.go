@@ -0,0 +1,87 @@
+package ratelimitprovider
+
+import (
+	"sync"
+	"time"
+
+	"github.com/vijaygniit/ApnaSabji/models"
+	"github.com/vijaygniit/ApnaSabji/providers"
+)
+
+type memoryEntry struct {
+	hits            []time.Time
+	lockedUntil     time.Time
+	violations      int
+	lastViolationAt time.Time
+}
+
+type memoryStore struct {
+	mu      sync.Mutex
+	entries map[string]*memoryEntry
+}
+
+func NewMemoryStore() providers.RateLimitStore {
+	return &memoryStore{
+		entries: make(map[string]*memoryEntry),
+	}
+}
+
+func (ms *memoryStore) Hit(key string, rule models.RateLimitRule) (bool, time.Duration, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	now := time.Now()
+	entry, ok := ms.entries[key]
+	if !ok {
+		entry = &memoryEntry{}
+		ms.entries[key] = entry
+	}
+
+	if now.Before(entry.lockedUntil) {
+		return false, entry.lockedUntil.Sub(now), nil
+	}
+
+	// slide the window: drop hits that fell out of it
+	windowStart := now.Add(-rule.Window)
+	hits := entry.hits[:0]
+	for _, hit := range entry.hits {
+		if hit.After(windowStart) {
+			hits = append(hits, hit)
+		}
+	}
+	entry.hits = append(hits, now)
+
+	if len(entry.hits) <= rule.Limit {
+		return true, 0, nil
+	}
+
+	// violations are forgiven once the key has behaved for a full max lockout period
+	if now.Sub(entry.lastViolationAt) > rule.MaxLockout {
+		entry.violations = 0
+	}
+	entry.violations++
+	entry.lastViolationAt = now
+
+	lockout := rule.LockoutFor(entry.violations)
+	entry.lockedUntil = now.Add(lockout)
+	entry.hits = entry.hits[:0]
+
+	return false, lockout, nil
+}
+
+func (ms *memoryStore) Cleanup(olderThan time.Duration) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	cutoff := time.Now().Add(-olderThan)
+	for key, entry := range ms.entries {
+		isStale := entry.lockedUntil.Before(cutoff) && entry.lastViolationAt.Before(cutoff)
+		if len(entry.hits) > 0 && entry.hits[len(entry.hits)-1].After(cutoff) {
+			isStale = false
+		}
+		if isStale {
+			delete(ms.entries, key)
+		}
+	}
+	return nil
+}
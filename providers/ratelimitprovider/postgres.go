@@ -0,0 +1,137 @@
+package ratelimitprovider
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/sirupsen/logrus"
+	"github.com/vijaygniit/ApnaSabji/models"
+	"github.com/vijaygniit/ApnaSabji/providers"
+	"github.com/volatiletech/null"
+)
+
+type postgresStore struct {
+	db *sqlx.DB
+}
+
+func NewPostgresStore(db *sqlx.DB) providers.RateLimitStore {
+	return &postgresStore{
+		db: db,
+	}
+}
+
+func (ps *postgresStore) Hit(key string, rule models.RateLimitRule) (allowed bool, retryAfter time.Duration, err error) {
+	tx, err := ps.db.Beginx()
+	if err != nil {
+		logrus.Errorf("postgresStore.Hit: error starting transaction %v", err)
+		return false, 0, err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	// serialize hits for the same key across replicas
+	// language=SQL
+	if _, err = tx.Exec(`SELECT pg_advisory_xact_lock(hashtext($1))`, key); err != nil {
+		logrus.Errorf("postgresStore.Hit: error locking key %v", err)
+		return false, 0, err
+	}
+
+	now := time.Now().UTC()
+
+	var lockout = struct {
+		LockedUntil     null.Time `db:"locked_until"`
+		Violations      int       `db:"violations"`
+		LastViolationAt null.Time `db:"last_violation_at"`
+	}{}
+
+	// language=SQL
+	SQL := `SELECT locked_until, violations, last_violation_at
+			FROM rate_limit_lockouts
+			WHERE key = $1`
+	if err = tx.Get(&lockout, SQL, key); err != nil && err != sql.ErrNoRows {
+		logrus.Errorf("postgresStore.Hit: error getting lockout %v", err)
+		return false, 0, err
+	}
+
+	if lockout.LockedUntil.Valid && now.Before(lockout.LockedUntil.Time) {
+		return false, lockout.LockedUntil.Time.Sub(now), nil
+	}
+
+	// language=SQL
+	SQL = `DELETE FROM rate_limit_hits
+			WHERE key = $1
+			  AND hit_at <= $2`
+	if _, err = tx.Exec(SQL, key, now.Add(-rule.Window)); err != nil {
+		logrus.Errorf("postgresStore.Hit: error sliding window %v", err)
+		return false, 0, err
+	}
+
+	// language=SQL
+	SQL = `INSERT INTO rate_limit_hits
+			(key, hit_at)
+			VALUES ($1, $2)`
+	if _, err = tx.Exec(SQL, key, now); err != nil {
+		logrus.Errorf("postgresStore.Hit: error recording hit %v", err)
+		return false, 0, err
+	}
+
+	var hits int
+	// language=SQL
+	if err = tx.Get(&hits, `SELECT count(*) FROM rate_limit_hits WHERE key = $1`, key); err != nil {
+		logrus.Errorf("postgresStore.Hit: error counting hits %v", err)
+		return false, 0, err
+	}
+
+	if hits <= rule.Limit {
+		return true, 0, tx.Commit()
+	}
+
+	violations := lockout.Violations + 1
+	if !lockout.LastViolationAt.Valid || now.Sub(lockout.LastViolationAt.Time) > rule.MaxLockout {
+		violations = 1
+	}
+	retryAfter = rule.LockoutFor(violations)
+
+	// language=SQL
+	SQL = `INSERT INTO rate_limit_lockouts
+			(key, locked_until, violations, last_violation_at)
+			VALUES ($1, $2, $3, $4)
+			ON CONFLICT (key) DO UPDATE
+			SET locked_until = excluded.locked_until,
+			    violations = excluded.violations,
+			    last_violation_at = excluded.last_violation_at`
+	if _, err = tx.Exec(SQL, key, now.Add(retryAfter), violations, now); err != nil {
+		logrus.Errorf("postgresStore.Hit: error storing lockout %v", err)
+		return false, 0, err
+	}
+
+	// language=SQL
+	if _, err = tx.Exec(`DELETE FROM rate_limit_hits WHERE key = $1`, key); err != nil {
+		logrus.Errorf("postgresStore.Hit: error clearing hits %v", err)
+		return false, 0, err
+	}
+
+	return false, retryAfter, tx.Commit()
+}
+
+func (ps *postgresStore) Cleanup(olderThan time.Duration) error {
+	cutoff := time.Now().UTC().Add(-olderThan)
+
+	// language=SQL
+	if _, err := ps.db.Exec(`DELETE FROM rate_limit_hits WHERE hit_at <= $1`, cutoff); err != nil {
+		logrus.Errorf("postgresStore.Cleanup: error deleting hits %v", err)
+		return err
+	}
+
+	// language=SQL
+	SQL := `DELETE FROM rate_limit_lockouts
+			WHERE locked_until <= $1
+			  AND last_violation_at <= $1`
+	if _, err := ps.db.Exec(SQL, cutoff); err != nil {
+		logrus.Errorf("postgresStore.Cleanup: error deleting lockouts %v", err)
+		return err
+	}
+	return nil
+}
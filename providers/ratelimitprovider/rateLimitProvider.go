@@ -0,0 +1,25 @@
+package ratelimitprovider
+
+import (
+	"github.com/jmoiron/sqlx"
+	"github.com/sirupsen/logrus"
+	"github.com/vijaygniit/ApnaSabji/providers"
+)
+
+const (
+	StoreMemory   = "memory"
+	StorePostgres = "postgres"
+)
+
+// NewRateLimitStore picks the store by name. The in-memory store only limits a single replica,
+// use the postgres store when running more than one.
+func NewRateLimitStore(store string, db *sqlx.DB) providers.RateLimitStore {
+	switch store {
+	case StorePostgres:
+		return NewPostgresStore(db)
+	case StoreMemory, "":
+	default:
+		logrus.Errorf("NewRateLimitStore: unknown store %q, using memory", store)
+	}
+	return NewMemoryStore()
+}
@@ -0,0 +1,23 @@
+package scmerrors
+
+import (
+	"errors"
+	"net/http"
+)
+
+var (
+	ErrAPIKeyInvalid = errors.New("api key invalid")
+	ErrAPIKeyScope   = errors.New("api key scope missing")
+)
+
+// RespondAPIKeyErr responds with a client error for a rejected X-API-Key.
+func RespondAPIKeyErr(resp http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrAPIKeyInvalid):
+		RespondClientErr(resp, err, http.StatusUnauthorized, "Invalid API key", "api key unknown, revoked or expired")
+	case errors.Is(err, ErrAPIKeyScope):
+		RespondClientErr(resp, err, http.StatusForbidden, "This API key is not allowed to perform this action", "api key lacks the required scope")
+	default:
+		RespondGenericServerErr(resp, err, "error checking api key")
+	}
+}
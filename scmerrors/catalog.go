@@ -0,0 +1,41 @@
+package scmerrors
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+)
+
+var (
+	ErrCatalogDuplicate    = errors.New("catalog entry already exists")
+	ErrUnknownCategory     = errors.New("category not found")
+	ErrUnknownUnit         = errors.New("unit not found")
+	ErrCategoryNotEmpty    = errors.New("category has products or subcategories")
+	ErrInvalidCatalogPrice = errors.New("selling price above mrp")
+	ErrInvalidPackSize     = errors.New("pack size not more than zero")
+	ErrInsufficientStock   = errors.New("not enough stock")
+)
+
+// RespondCatalogErr responds with a client error telling apart the reasons a catalog change was rejected.
+func RespondCatalogErr(resp http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		RespondClientErr(resp, err, http.StatusNotFound, "Not found", "no catalog entry with this id")
+	case errors.Is(err, ErrCatalogDuplicate):
+		RespondClientErr(resp, err, http.StatusConflict, "This name is already used", "slug or code already taken")
+	case errors.Is(err, ErrUnknownCategory):
+		RespondClientErr(resp, err, http.StatusBadRequest, "Please choose a valid category", "categoryId or parentId does not exist")
+	case errors.Is(err, ErrUnknownUnit):
+		RespondClientErr(resp, err, http.StatusBadRequest, "Please choose a valid unit", "unitCode does not exist, see GET /api/units")
+	case errors.Is(err, ErrCategoryNotEmpty):
+		RespondClientErr(resp, err, http.StatusConflict, "Move or delete the products in this category first", "category still has products or subcategories")
+	case errors.Is(err, ErrInvalidCatalogPrice):
+		RespondClientErr(resp, err, http.StatusBadRequest, "Selling price can not be more than MRP", "price must be between 0 and mrp")
+	case errors.Is(err, ErrInvalidPackSize):
+		RespondClientErr(resp, err, http.StatusBadRequest, "Pack size must be more than zero", "packSize must be positive")
+	case errors.Is(err, ErrInsufficientStock):
+		RespondClientErr(resp, err, http.StatusConflict, "Not enough stock", "stock can not go below zero")
+	default:
+		RespondGenericServerErr(resp, err, "error changing catalog")
+	}
+}
@@ -0,0 +1,26 @@
+package scmerrors
+
+import (
+	"errors"
+	"net/http"
+)
+
+// CodeConsentRequired tells the apps to show the latest terms and ask the user to accept them.
+const CodeConsentRequired = "CONSENT_REQUIRED"
+
+var (
+	ErrConsentRequired = errors.New("policy consent required")
+	ErrPolicyNotFound  = errors.New("policy not found")
+)
+
+// RespondConsentErr responds with a client error telling apart the reasons a consent was not accepted.
+func RespondConsentErr(resp http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrConsentRequired):
+		respondClientErrWithCode(resp, err, http.StatusForbidden, CodeConsentRequired, "Please review and accept our updated terms to continue", "latest mandatory policy versions not accepted, see GET /api/me/consents")
+	case errors.Is(err, ErrPolicyNotFound):
+		RespondClientErr(resp, err, http.StatusBadRequest, "These terms are no longer available. Please reload and try again", "policy id unknown or not yet published")
+	default:
+		RespondGenericServerErr(resp, err, "error checking consent")
+	}
+}
@@ -13,9 +13,15 @@ type clientError struct {
 	DeveloperInfo string `json:"developerInfo"`
 	StatusCode    int    `json:"statusCode"`
 	IsClientError bool   `json:"isClientError"`
+	// Code lets the apps react to specific errors without matching on messages
+	Code string `json:"code,omitempty"`
 } // @name clientError
 
 func RespondClientErr(resp http.ResponseWriter, err error, statusCode int, messageToUser, developerInfo string) {
+	respondClientErrWithCode(resp, err, statusCode, "", messageToUser, developerInfo)
+}
+
+func respondClientErrWithCode(resp http.ResponseWriter, err error, statusCode int, code, messageToUser, developerInfo string) {
 	resp.WriteHeader(statusCode)
 
 	clientErr := &clientError{
@@ -24,6 +30,7 @@ func RespondClientErr(resp http.ResponseWriter, err error, statusCode int, messa
 		Err:           err.Error(),
 		StatusCode:    statusCode,
 		IsClientError: true,
+		Code:          code,
 	}
 
 	if err := json.NewEncoder(resp).Encode(clientErr); err != nil {
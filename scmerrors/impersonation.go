@@ -0,0 +1,13 @@
+package scmerrors
+
+import (
+	"errors"
+	"net/http"
+)
+
+var ErrImpersonationForbidden = errors.New("action not allowed while impersonating")
+
+// RespondImpersonationErr tells support staff the action needs the real user.
+func RespondImpersonationErr(resp http.ResponseWriter) {
+	RespondClientErr(resp, ErrImpersonationForbidden, http.StatusForbidden, "This action can only be performed by the account owner", "blocked for impersonation tokens")
+}
@@ -0,0 +1,37 @@
+package scmerrors
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/sirupsen/logrus"
+	"github.com/vijaygniit/ApnaSabji/models"
+)
+
+var ErrInvalidImport = errors.New("import has invalid rows")
+
+type importError struct {
+	clientError
+	Rows []models.ImportRowError `json:"rows"`
+}
+
+// RespondImportErr rejects an uploaded file, listing every line that needs fixing.
+func RespondImportErr(resp http.ResponseWriter, rows []models.ImportRowError) {
+	resp.WriteHeader(http.StatusBadRequest)
+
+	importErr := &importError{
+		clientError: clientError{
+			Err:           ErrInvalidImport.Error(),
+			MessageToUser: "Some rows of the file could not be read, please fix them and upload again",
+			DeveloperInfo: "see rows for the line and field of each problem",
+			StatusCode:    http.StatusBadRequest,
+			IsClientError: true,
+		},
+		Rows: rows,
+	}
+
+	if err := json.NewEncoder(resp).Encode(importErr); err != nil {
+		logrus.Error(err)
+	}
+}
@@ -0,0 +1,29 @@
+package scmerrors
+
+import (
+	"errors"
+	"net/http"
+)
+
+var (
+	ErrMFANotEnabled     = errors.New("mfa not enabled")
+	ErrMFAAlreadyEnabled = errors.New("mfa already enabled")
+	ErrMFAInvalidCode    = errors.New("mfa code invalid")
+	ErrMFARequired       = errors.New("mfa required")
+)
+
+// RespondMFAErr responds with a client error telling apart the reasons a second factor was rejected.
+func RespondMFAErr(resp http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrMFANotEnabled):
+		RespondClientErr(resp, err, http.StatusBadRequest, "Two-factor authentication is not set up", "no confirmed totp secret")
+	case errors.Is(err, ErrMFAAlreadyEnabled):
+		RespondClientErr(resp, err, http.StatusConflict, "Two-factor authentication is already set up", "totp secret already confirmed")
+	case errors.Is(err, ErrMFAInvalidCode):
+		RespondClientErr(resp, err, http.StatusUnauthorized, "The code is not correct", "totp or recovery code did not match")
+	case errors.Is(err, ErrMFARequired):
+		RespondClientErr(resp, err, http.StatusForbidden, "Please verify with your authenticator app to continue", "session has not passed mfa")
+	default:
+		RespondGenericServerErr(resp, err, "error verifying second factor")
+	}
+}
@@ -0,0 +1,36 @@
+package scmerrors
+
+import (
+	"errors"
+	"net/http"
+)
+
+var (
+	ErrOIDCProviderUnknown   = errors.New("identity provider unknown")
+	ErrOIDCStateInvalid      = errors.New("oidc state invalid or expired")
+	ErrOIDCTokenInvalid      = errors.New("oidc id token invalid")
+	ErrOIDCEmailNotVerified  = errors.New("oidc email not verified")
+	ErrOIDCAccountUnverified = errors.New("account with the oidc email never verified it")
+	ErrOIDCAccountDisabled   = errors.New("account linked to identity is disabled")
+	ErrOIDCExchangeFailed    = errors.New("oidc code exchange failed")
+)
+
+// RespondOIDCErr responds with a client error telling apart the reasons a social login failed.
+func RespondOIDCErr(resp http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrOIDCProviderUnknown):
+		RespondClientErr(resp, err, http.StatusNotFound, "This login method is not available", "identity provider not configured")
+	case errors.Is(err, ErrOIDCStateInvalid):
+		RespondClientErr(resp, err, http.StatusBadRequest, "Your login has expired. Please try again", "state unknown, used or expired")
+	case errors.Is(err, ErrOIDCTokenInvalid), errors.Is(err, ErrOIDCExchangeFailed):
+		RespondClientErr(resp, err, http.StatusUnauthorized, "We could not sign you in. Please try again", err.Error())
+	case errors.Is(err, ErrOIDCEmailNotVerified):
+		RespondClientErr(resp, err, http.StatusForbidden, "Please verify your email with the provider before signing in", "email missing or not verified")
+	case errors.Is(err, ErrOIDCAccountUnverified):
+		RespondClientErr(resp, err, http.StatusConflict, "An account with this email already exists. Please log in with an OTP sent to your email first", "existing account has not verified its email, log in with an email otp to link")
+	case errors.Is(err, ErrOIDCAccountDisabled):
+		RespondClientErr(resp, err, http.StatusForbidden, "This account is not active", "linked account is deactivated or deleted")
+	default:
+		RespondGenericServerErr(resp, err, "error signing in with identity provider")
+	}
+}
@@ -0,0 +1,32 @@
+package scmerrors
+
+import (
+	"errors"
+	"net/http"
+)
+
+var (
+	ErrOTPNotFound    = errors.New("otp not requested")
+	ErrOTPInvalid     = errors.New("otp not matched")
+	ErrOTPExpired     = errors.New("otp expired")
+	ErrOTPAlreadyUsed = errors.New("otp already used")
+	ErrOTPLocked      = errors.New("otp locked after too many failed attempts")
+)
+
+// RespondOTPErr responds with a client error telling apart the reasons an OTP could not be verified.
+func RespondOTPErr(resp http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrOTPNotFound):
+		RespondClientErr(resp, err, http.StatusBadRequest, "Please request an OTP first", "no otp challenge found")
+	case errors.Is(err, ErrOTPInvalid):
+		RespondClientErr(resp, err, http.StatusUnauthorized, "OTP Not Correct", "otp does not match the latest challenge")
+	case errors.Is(err, ErrOTPExpired):
+		RespondClientErr(resp, err, http.StatusUnauthorized, "This OTP has expired. Please request a new one", "otp challenge expired")
+	case errors.Is(err, ErrOTPAlreadyUsed):
+		RespondClientErr(resp, err, http.StatusUnauthorized, "This OTP has already been used. Please request a new one", "otp challenge already consumed")
+	case errors.Is(err, ErrOTPLocked):
+		RespondClientErr(resp, err, http.StatusTooManyRequests, "Too many wrong attempts. Please request a new OTP", "otp challenge locked")
+	default:
+		RespondGenericServerErr(resp, err, "error verifying otp")
+	}
+}
@@ -0,0 +1,21 @@
+package scmerrors
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+var ErrRateLimited = errors.New("rate limit exceeded")
+
+// RespondRateLimitErr responds 429 and tells the client through Retry-After when it may try again.
+func RespondRateLimitErr(resp http.ResponseWriter, retryAfter time.Duration, developerInfo string) {
+	seconds := int(math.Ceil(retryAfter.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	resp.Header().Set("Retry-After", strconv.Itoa(seconds))
+	RespondClientErr(resp, ErrRateLimited, http.StatusTooManyRequests, "Too many attempts. Please try again later", developerInfo)
+}
@@ -0,0 +1,29 @@
+package scmerrors
+
+import (
+	"errors"
+	"net/http"
+)
+
+var (
+	ErrRefreshTokenInvalid = errors.New("refresh token invalid")
+	ErrRefreshTokenExpired = errors.New("refresh token expired")
+	ErrRefreshTokenReused  = errors.New("refresh token reused")
+	ErrSessionRevoked      = errors.New("session revoked")
+)
+
+// RespondRefreshTokenErr responds with a client error telling apart the reasons a refresh token was rejected.
+func RespondRefreshTokenErr(resp http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrRefreshTokenInvalid):
+		RespondClientErr(resp, err, http.StatusUnauthorized, "Please login again", "refresh token not found")
+	case errors.Is(err, ErrRefreshTokenExpired):
+		RespondClientErr(resp, err, http.StatusUnauthorized, "Your session has expired. Please login again", "refresh token expired")
+	case errors.Is(err, ErrRefreshTokenReused):
+		RespondClientErr(resp, err, http.StatusUnauthorized, "Please login again", "refresh token was already used, session revoked")
+	case errors.Is(err, ErrSessionRevoked):
+		RespondClientErr(resp, err, http.StatusUnauthorized, "You have been logged out. Please login again", "session revoked")
+	default:
+		RespondGenericServerErr(resp, err, "error refreshing token")
+	}
+}
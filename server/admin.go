@@ -0,0 +1,292 @@
+package server
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/sirupsen/logrus"
+	"github.com/vijaygniit/ApnaSabji/models"
+	"github.com/vijaygniit/ApnaSabji/providers/authProvider"
+	"github.com/vijaygniit/ApnaSabji/scmerrors"
+	"github.com/vijaygniit/ApnaSabji/utils"
+	"github.com/volatiletech/null"
+)
+
+func (srv *Server) listRoles(resp http.ResponseWriter, req *http.Request) {
+	roles, err := srv.DBHelper.ListRoles()
+	if err != nil {
+		logrus.Error("listRoles: error getting roles ", err)
+		scmerrors.RespondGenericServerErr(resp, err, "error getting roles")
+		return
+	}
+
+	utils.EncodeJSONBody(resp, http.StatusOK, map[string]interface{}{
+		"roles": roles,
+	})
+}
+
+func (srv *Server) assignRole(resp http.ResponseWriter, req *http.Request) {
+	uc := srv.MiddlewareProvider.UserFromContext(req.Context())
+
+	userID, ok := srv.userIDFromURL(resp, req)
+	if !ok {
+		return
+	}
+
+	var assignRoleReq models.AssignRoleRequest
+	if err := json.NewDecoder(req.Body).Decode(&assignRoleReq); err != nil {
+		scmerrors.RespondClientErr(resp, err, http.StatusBadRequest, "Error assigning role", "Error parsing request")
+		return
+	}
+
+	if err := srv.DBHelper.AssignRole(userID, assignRoleReq.Role, uc.UserID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			scmerrors.RespondClientErr(resp, err, http.StatusBadRequest, "Unknown role", "role does not exist")
+			return
+		}
+		logrus.Error("assignRole: error assigning role ", err)
+		scmerrors.RespondGenericServerErr(resp, err, "error assigning role")
+		return
+	}
+
+	utils.EncodeJSONBody(resp, http.StatusOK, map[string]interface{}{
+		"message": "success",
+	})
+}
+
+func (srv *Server) removeRole(resp http.ResponseWriter, req *http.Request) {
+	userID, ok := srv.userIDFromURL(resp, req)
+	if !ok {
+		return
+	}
+
+	if err := srv.DBHelper.RemoveRole(userID, models.Role(chi.URLParam(req, "role"))); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			scmerrors.RespondClientErr(resp, err, http.StatusNotFound, "User does not have this role", "role not assigned to user")
+			return
+		}
+		logrus.Error("removeRole: error removing role ", err)
+		scmerrors.RespondGenericServerErr(resp, err, "error removing role")
+		return
+	}
+
+	utils.EncodeJSONBody(resp, http.StatusOK, map[string]interface{}{
+		"message": "success",
+	})
+}
+
+// userIDFromURL reads the {id} url param and checks the user exists, responding with an error otherwise.
+func (srv *Server) userIDFromURL(resp http.ResponseWriter, req *http.Request) (int, bool) {
+	userID, err := strconv.Atoi(chi.URLParam(req, "id"))
+	if err != nil {
+		scmerrors.RespondClientErr(resp, err, http.StatusBadRequest, "Invalid user", "user id must be an integer")
+		return 0, false
+	}
+
+	if _, err := srv.DBHelper.FetchUserData(userID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			scmerrors.RespondClientErr(resp, err, http.StatusNotFound, "User not found", "user does not exist")
+			return 0, false
+		}
+		scmerrors.RespondGenericServerErr(resp, err, "error getting user")
+		return 0, false
+	}
+
+	return userID, true
+}
+
+func (srv *Server) deactivateUser(resp http.ResponseWriter, req *http.Request) {
+	srv.setUserDeactivated(resp, req, true)
+}
+
+func (srv *Server) reactivateUser(resp http.ResponseWriter, req *http.Request) {
+	srv.setUserDeactivated(resp, req, false)
+}
+
+func (srv *Server) setUserDeactivated(resp http.ResponseWriter, req *http.Request, deactivated bool) {
+	uc := srv.MiddlewareProvider.UserFromContext(req.Context())
+
+	userID, ok := srv.userIDFromURL(resp, req)
+	if !ok {
+		return
+	}
+
+	err := srv.DBHelper.SetUserDeactivated(models.AccountAction{
+		UserID:  userID,
+		ActorID: uc.UserID,
+		ByAdmin: true,
+	}, deactivated)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			scmerrors.RespondClientErr(resp, err, http.StatusConflict, "The account is already in this state or has been deleted", "no active user row changed")
+			return
+		}
+		logrus.Error("setUserDeactivated: error updating user ", err)
+		scmerrors.RespondGenericServerErr(resp, err, "error updating user")
+		return
+	}
+
+	utils.EncodeJSONBody(resp, http.StatusOK, map[string]interface{}{
+		"message": "success",
+	})
+}
+
+func (srv *Server) deleteUser(resp http.ResponseWriter, req *http.Request) {
+	uc := srv.MiddlewareProvider.UserFromContext(req.Context())
+
+	userID, ok := srv.userIDFromURL(resp, req)
+	if !ok {
+		return
+	}
+
+	err := srv.DBHelper.DeleteUserAccount(models.AccountAction{
+		UserID:  userID,
+		ActorID: uc.UserID,
+		ByAdmin: true,
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			scmerrors.RespondClientErr(resp, err, http.StatusConflict, "The account is already deleted", "user already archived")
+			return
+		}
+		logrus.Error("deleteUser: error deleting user ", err)
+		scmerrors.RespondGenericServerErr(resp, err, "error deleting user")
+		return
+	}
+
+	utils.EncodeJSONBody(resp, http.StatusOK, map[string]interface{}{
+		"message": "success",
+	})
+}
+
+func (srv *Server) restoreUser(resp http.ResponseWriter, req *http.Request) {
+	uc := srv.MiddlewareProvider.UserFromContext(req.Context())
+
+	userID, ok := srv.userIDFromURL(resp, req)
+	if !ok {
+		return
+	}
+
+	err := srv.DBHelper.RestoreUserAccount(models.AccountAction{
+		UserID:  userID,
+		ActorID: uc.UserID,
+		ByAdmin: true,
+	}, accountDeletionGracePeriod())
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			scmerrors.RespondClientErr(resp, err, http.StatusConflict, "The account is not deleted or can no longer be restored", "user not archived or past the grace period")
+			return
+		}
+		logrus.Error("restoreUser: error restoring user ", err)
+		scmerrors.RespondGenericServerErr(resp, err, "error restoring user")
+		return
+	}
+
+	utils.EncodeJSONBody(resp, http.StatusOK, map[string]interface{}{
+		"message": "success",
+	})
+}
+
+// impersonateUser issues a short lived token for the user carrying an act claim naming the admin.
+// No refresh token is issued, support has to start again once it expires.
+func (srv *Server) impersonateUser(resp http.ResponseWriter, req *http.Request) {
+	uc := srv.MiddlewareProvider.UserFromContext(req.Context())
+
+	userID, ok := srv.userIDFromURL(resp, req)
+	if !ok {
+		return
+	}
+
+	var impersonationReq models.ImpersonationRequest
+	if err := json.NewDecoder(req.Body).Decode(&impersonationReq); err != nil {
+		scmerrors.RespondClientErr(resp, err, http.StatusBadRequest, "Error starting impersonation", "Error parsing request")
+		return
+	}
+
+	if strings.TrimSpace(impersonationReq.Reason) == "" {
+		scmerrors.RespondClientErr(resp, errors.New("reason is required"), http.StatusBadRequest, "Please give a reason for accessing this account", "reason can not be empty")
+		return
+	}
+
+	if userID == uc.UserID {
+		scmerrors.RespondClientErr(resp, errors.New("can not impersonate yourself"), http.StatusBadRequest, "You can not impersonate yourself", "target is the current user")
+		return
+	}
+
+	// a deactivated or deleted account can not log in, so nobody may act as it either
+	userInfo, err := srv.DBHelper.FetchActiveUserData(userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			scmerrors.RespondClientErr(resp, err, http.StatusConflict, "Deactivated or deleted accounts can not be impersonated", "target is deactivated, archived or missing")
+			return
+		}
+		logrus.Error("impersonateUser: error getting user info ", err)
+		scmerrors.RespondGenericServerErr(resp, err, "error getting user")
+		return
+	}
+
+	roles, _, err := srv.DBHelper.GetUserRolesAndPermissions(userID)
+	if err != nil {
+		logrus.Error("impersonateUser: error getting roles ", err)
+		scmerrors.RespondGenericServerErr(resp, err, "error getting roles")
+		return
+	}
+
+	// acting as another admin would hand out their permissions
+	if contains(roles, string(models.RoleAdmin)) {
+		scmerrors.RespondClientErr(resp, errors.New("can not impersonate an admin"), http.StatusForbidden, "Admin accounts can not be impersonated", "target has the admin role")
+		return
+	}
+
+	ttl := authProvider.ImpersonationTokenTTL()
+	sessionToken, err := srv.DBHelper.StartImpersonationSession(userID, uc.UserID, ttl)
+	if err != nil {
+		logrus.Error("impersonateUser: error starting session ", err)
+		scmerrors.RespondGenericServerErr(resp, err, "error starting session")
+		return
+	}
+
+	token, err := authProvider.GenerateJWT(srv.SigningKeys, authProvider.TokenRequest{
+		UserID:    userID,
+		SessionID: sessionToken,
+		Name:      userInfo.Fullname,
+		Email:     userInfo.Email,
+		Roles:     roles,
+		ActorID:   uc.UserID,
+		TTL:       ttl,
+	})
+	if err != nil {
+		logrus.Error("impersonateUser: error generating JWT ", err)
+		scmerrors.RespondGenericServerErr(resp, err, "error generating token")
+		return
+	}
+
+	srv.recordAuthEvent(req, models.AuthEvent{
+		UserID:    null.IntFrom(userID),
+		EventType: models.AuthEventImpersonation,
+		Outcome:   models.AuthEventSuccess,
+		Reason:    fmt.Sprintf("admin %d: %s", uc.UserID, impersonationReq.Reason),
+	})
+
+	utils.EncodeJSONBody(resp, http.StatusOK, map[string]interface{}{
+		"userInfo":  userInfo,
+		"token":     token,
+		"expiresAt": time.Now().Add(ttl),
+	})
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,149 @@
+package server
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/sirupsen/logrus"
+	"github.com/vijaygniit/ApnaSabji/models"
+	"github.com/vijaygniit/ApnaSabji/scmerrors"
+	"github.com/vijaygniit/ApnaSabji/utils"
+)
+
+func apiKeyRotationGracePeriod() time.Duration {
+	return time.Duration(utils.GetEnvInt("API_KEY_ROTATION_GRACE_HOURS", defaultAPIKeyRotationGraceHours)) * time.Hour
+}
+
+func (srv *Server) listAPIKeys(resp http.ResponseWriter, req *http.Request) {
+	uc := srv.MiddlewareProvider.UserFromContext(req.Context())
+
+	apiKeys, err := srv.DBHelper.ListAPIKeys(uc.UserID)
+	if err != nil {
+		logrus.Error("listAPIKeys: error getting api keys ", err)
+		scmerrors.RespondGenericServerErr(resp, err, "error getting api keys")
+		return
+	}
+
+	utils.EncodeJSONBody(resp, http.StatusOK, map[string]interface{}{
+		"apiKeys": apiKeys,
+	})
+}
+
+// createAPIKey returns the plain key once, only its hash is stored.
+func (srv *Server) createAPIKey(resp http.ResponseWriter, req *http.Request) {
+	uc := srv.MiddlewareProvider.UserFromContext(req.Context())
+
+	var createReq models.CreateAPIKeyRequest
+	if err := json.NewDecoder(req.Body).Decode(&createReq); err != nil {
+		scmerrors.RespondClientErr(resp, err, http.StatusBadRequest, "Error creating api key", "Error parsing request")
+		return
+	}
+
+	createReq.Name = strings.TrimSpace(createReq.Name)
+	if createReq.Name == "" {
+		scmerrors.RespondClientErr(resp, errors.New("name can not be empty"), http.StatusBadRequest, "Please name the api key", "name can not be empty")
+		return
+	}
+
+	if len(createReq.Scopes) == 0 {
+		scmerrors.RespondClientErr(resp, errors.New("scopes can not be empty"), http.StatusBadRequest, "Please choose at least one scope", "scopes can not be empty")
+		return
+	}
+	for _, scope := range createReq.Scopes {
+		if !scope.IsValid() {
+			scmerrors.RespondClientErr(resp, errors.New("invalid scope"), http.StatusBadRequest, "Unknown scope", fmt.Sprintf("scope %q is not supported", scope))
+			return
+		}
+	}
+
+	maxRateLimit := utils.GetEnvInt("API_KEY_MAX_RATE_LIMIT_PER_MINUTE", defaultAPIKeyMaxRateLimitPerMinute)
+	switch {
+	case createReq.RateLimitPerMinute == 0:
+		createReq.RateLimitPerMinute = utils.GetEnvInt("API_KEY_DEFAULT_RATE_LIMIT_PER_MINUTE", defaultAPIKeyRateLimitPerMinute)
+	case createReq.RateLimitPerMinute < 0 || createReq.RateLimitPerMinute > maxRateLimit:
+		scmerrors.RespondClientErr(resp, errors.New("invalid rate limit"), http.StatusBadRequest, fmt.Sprintf("Rate limit must be between 1 and %d requests per minute", maxRateLimit), "rateLimitPerMinute out of range")
+		return
+	}
+
+	createReq.UserID = uc.UserID
+	createReq.CreatedBy = uc.UserID
+
+	apiKey, plainKey, err := srv.DBHelper.CreateAPIKey(createReq)
+	if err != nil {
+		logrus.Error("createAPIKey: error creating api key ", err)
+		scmerrors.RespondGenericServerErr(resp, err, "error creating api key")
+		return
+	}
+
+	utils.EncodeJSONBody(resp, http.StatusCreated, map[string]interface{}{
+		"apiKey": apiKey,
+		"key":    plainKey,
+	})
+}
+
+// rotateAPIKey replaces a key, the old one keeps working for API_KEY_ROTATION_GRACE_HOURS.
+func (srv *Server) rotateAPIKey(resp http.ResponseWriter, req *http.Request) {
+	uc := srv.MiddlewareProvider.UserFromContext(req.Context())
+
+	apiKeyID, err := strconv.Atoi(chi.URLParam(req, "id"))
+	if err != nil {
+		scmerrors.RespondClientErr(resp, err, http.StatusBadRequest, "Invalid api key", "api key id must be an integer")
+		return
+	}
+
+	apiKey, plainKey, err := srv.DBHelper.RotateAPIKey(uc.UserID, apiKeyID, apiKeyRotationGracePeriod())
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			scmerrors.RespondClientErr(resp, err, http.StatusNotFound, "API key not found", "no live api key with this id for the user")
+			return
+		}
+		logrus.Error("rotateAPIKey: error rotating api key ", err)
+		scmerrors.RespondGenericServerErr(resp, err, "error rotating api key")
+		return
+	}
+
+	utils.EncodeJSONBody(resp, http.StatusCreated, map[string]interface{}{
+		"apiKey": apiKey,
+		"key":    plainKey,
+	})
+}
+
+func (srv *Server) revokeAPIKey(resp http.ResponseWriter, req *http.Request) {
+	uc := srv.MiddlewareProvider.UserFromContext(req.Context())
+
+	apiKeyID, err := strconv.Atoi(chi.URLParam(req, "id"))
+	if err != nil {
+		scmerrors.RespondClientErr(resp, err, http.StatusBadRequest, "Invalid api key", "api key id must be an integer")
+		return
+	}
+
+	if err := srv.DBHelper.RevokeAPIKey(uc.UserID, apiKeyID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			scmerrors.RespondClientErr(resp, err, http.StatusNotFound, "API key not found", "no active api key with this id for the user")
+			return
+		}
+		logrus.Error("revokeAPIKey: error revoking api key ", err)
+		scmerrors.RespondGenericServerErr(resp, err, "error revoking api key")
+		return
+	}
+
+	utils.EncodeJSONBody(resp, http.StatusOK, map[string]interface{}{
+		"message": "success",
+	})
+}
+
+// partnerAccount lets partners check their key works and see which account and scopes it carries.
+func (srv *Server) partnerAccount(resp http.ResponseWriter, req *http.Request) {
+	uc := srv.MiddlewareProvider.UserFromContext(req.Context())
+
+	utils.EncodeJSONBody(resp, http.StatusOK, map[string]interface{}{
+		"account": uc,
+	})
+}
@@ -0,0 +1,86 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/vijaygniit/ApnaSabji/models"
+	"github.com/vijaygniit/ApnaSabji/scmerrors"
+	"github.com/vijaygniit/ApnaSabji/utils"
+	"github.com/volatiletech/null"
+)
+
+// recordAuthEvent writes to the audit log, filling in where the request came from.
+// Failing to write is logged but never fails the request being audited.
+func (srv *Server) recordAuthEvent(req *http.Request, event models.AuthEvent) {
+	event.IP = utils.ClientIP(req)
+	event.UserAgent = req.UserAgent()
+
+	if err := srv.DBHelper.RecordAuthEvent(event); err != nil {
+		logrus.Error("recordAuthEvent: error recording auth event ", err)
+	}
+}
+
+func (srv *Server) listAuthEvents(resp http.ResponseWriter, req *http.Request) {
+	query := req.URL.Query()
+	page, limit, offset := pagination(req)
+
+	filter := models.AuthEventFilter{
+		EventType: models.AuthEventType(query.Get("eventType")),
+		Outcome:   models.AuthEventOutcome(query.Get("outcome")),
+		Email:     strings.ToLower(strings.TrimSpace(query.Get("email"))),
+		IP:        query.Get("ip"),
+		DeviceID:  query.Get("deviceId"),
+		Limit:     limit,
+		Offset:    offset,
+	}
+
+	if rawPhone := query.Get("phone"); rawPhone != "" {
+		phone, err := utils.NormalizePhoneNumber(rawPhone)
+		if err != nil {
+			scmerrors.RespondClientErr(resp, err, http.StatusBadRequest, "Invalid phone number", "phone must be a valid phone number")
+			return
+		}
+		filter.Phone = phone
+	}
+
+	if rawUserID := query.Get("userId"); rawUserID != "" {
+		userID, err := strconv.Atoi(rawUserID)
+		if err != nil {
+			scmerrors.RespondClientErr(resp, err, http.StatusBadRequest, "Invalid user", "userId must be an integer")
+			return
+		}
+		filter.UserID = null.IntFrom(userID)
+	}
+
+	for param, target := range map[string]*null.Time{"from": &filter.From, "to": &filter.To} {
+		rawTime := query.Get(param)
+		if rawTime == "" {
+			continue
+		}
+		parsedTime, err := time.Parse(time.RFC3339, rawTime)
+		if err != nil {
+			scmerrors.RespondClientErr(resp, errors.New("invalid time"), http.StatusBadRequest, "Invalid date", param+" must be an RFC 3339 timestamp")
+			return
+		}
+		*target = null.TimeFrom(parsedTime)
+	}
+
+	events, total, err := srv.DBHelper.ListAuthEvents(filter)
+	if err != nil {
+		logrus.Error("listAuthEvents: error getting auth events ", err)
+		scmerrors.RespondGenericServerErr(resp, err, "error getting auth events")
+		return
+	}
+
+	utils.EncodeJSONBody(resp, http.StatusOK, map[string]interface{}{
+		"events": events,
+		"total":  total,
+		"page":   page,
+		"limit":  limit,
+	})
+}
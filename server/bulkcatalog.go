@@ -0,0 +1,427 @@
+package server
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/vijaygniit/ApnaSabji/models"
+	"github.com/vijaygniit/ApnaSabji/scmerrors"
+	"github.com/vijaygniit/ApnaSabji/utils"
+	"github.com/volatiletech/null"
+)
+
+const (
+	catalogImportAtomic = "atomic"
+	catalogImportSkip   = "skip"
+
+	// stock counts go stale while a sheet is being edited, they are only written when asked for
+	catalogStockKeep = "keep"
+	catalogStockSet  = "set"
+)
+
+// catalogColumns is the layout of catalog sheets, one row per variant. Prices are in rupees as
+// category managers write them.
+var catalogColumns = []string{
+	"product_slug", "product_name", "category_slug", "description", "image_url", "product_active",
+	"sku", "pack_size", "unit", "mrp", "price", "barcode", "stock", "sort_order", "variant_active",
+}
+
+// catalogProductColumns repeat on every row of a product and may be left empty after its first row.
+var catalogProductColumns = []string{"product_name", "category_slug", "description", "image_url", "product_active"}
+
+// catalogVariantColumns are the columns that only make sense with a SKU.
+var catalogVariantColumns = []string{"pack_size", "unit", "mrp", "price", "barcode", "stock", "sort_order", "variant_active"}
+
+// catalogNumericColumns are stored as numbers in exported workbooks.
+var catalogNumericColumns = func() map[int]bool {
+	numeric := make(map[int]bool)
+	for i, column := range catalogColumns {
+		numeric[i] = contains([]string{"pack_size", "mrp", "price", "stock", "sort_order"}, column)
+	}
+	return numeric
+}()
+
+// parseImportBool reads the yes/no cells of a sheet, an empty cell meaning defaultValue.
+func parseImportBool(value string, defaultValue bool) (bool, error) {
+	switch strings.ToLower(value) {
+	case "":
+		return defaultValue, nil
+	case "true", "yes", "y", "1":
+		return true, nil
+	case "false", "no", "n", "0":
+		return false, nil
+	}
+	return false, errors.New("must be true or false")
+}
+
+func formatRupees(paise int64) string {
+	return fmt.Sprintf("%d.%02d", paise/100, paise%100)
+}
+
+// catalogProduct is the product of the first row naming a slug, later rows of the slug inherit it.
+type catalogProduct struct {
+	line  int
+	cells map[string]string
+	row   models.CatalogRow
+	valid bool
+}
+
+func newCatalogProduct(table importTable, i int, slug string, categories map[string]int, fail func(field, message string)) *catalogProduct {
+	product := &catalogProduct{line: table.lines[i], cells: make(map[string]string, len(catalogProductColumns))}
+	for _, column := range catalogProductColumns {
+		product.cells[column] = table.value(i, column)
+	}
+
+	failed := false
+	productFail := func(field, message string) {
+		failed = true
+		fail(field, message)
+	}
+
+	product.row.ProductSlug = slug
+	if !validSlug(slug) {
+		productFail("product_slug", "must be lowercase letters, digits and dashes")
+	}
+
+	product.row.ProductName = product.cells["product_name"]
+	if product.row.ProductName == "" {
+		productFail("product_name", "is required on the first row of a product")
+	}
+
+	product.row.CategorySlug = strings.ToLower(product.cells["category_slug"])
+	categoryID, ok := categories[product.row.CategorySlug]
+	if !ok {
+		productFail("category_slug", "category does not exist")
+	}
+	product.row.CategoryID = categoryID
+
+	product.row.Description = product.cells["description"]
+	product.row.ImageURL = product.cells["image_url"]
+
+	active, err := parseImportBool(product.cells["product_active"], true)
+	if err != nil {
+		productFail("product_active", err.Error())
+	}
+	product.row.ProductActive = active
+
+	product.valid = !failed
+	return product
+}
+
+// fillCatalogVariant validates the variant columns of a row with a SKU. The stock column is only read
+// with setStock, otherwise it is left as the warehouse last counted it.
+func fillCatalogVariant(table importTable, i int, row *models.CatalogRow, units map[string]string, setStock bool, fail func(field, message string)) {
+	if len(row.SKU) > maxSKULength {
+		fail("sku", "must be at most 64 characters")
+	}
+
+	row.PackSize = 1
+	if packSize := table.value(i, "pack_size"); packSize != "" {
+		value, err := strconv.ParseFloat(packSize, 64)
+		if err != nil || value <= 0 {
+			fail("pack_size", "must be a number more than zero")
+		}
+		row.PackSize = value
+	}
+
+	unitCode, ok := units[strings.ToLower(table.value(i, "unit"))]
+	if !ok {
+		fail("unit", "must be a unit code such as kg, see GET /api/units")
+	}
+	row.UnitCode = unitCode
+
+	mrp, err := parseRupees(table.value(i, "mrp"))
+	if err != nil {
+		fail("mrp", err.Error())
+	}
+	row.MRPPaise = mrp
+
+	price, err := parseRupees(table.value(i, "price"))
+	if err != nil {
+		fail("price", err.Error())
+	} else if price > mrp {
+		fail("price", "must not be more than mrp")
+	}
+	row.PricePaise = price
+
+	row.Barcode = table.value(i, "barcode")
+
+	if stock := table.value(i, "stock"); stock != "" && setStock {
+		value, err := strconv.Atoi(stock)
+		if err != nil || value < 0 {
+			fail("stock", "must be a whole number, zero or more")
+		}
+		row.StockQuantity = null.IntFrom(value)
+	}
+
+	if sortOrder := table.value(i, "sort_order"); sortOrder != "" {
+		value, err := strconv.Atoi(sortOrder)
+		if err != nil {
+			fail("sort_order", "must be a whole number")
+		}
+		row.SortOrder = value
+	}
+
+	active, err := parseImportBool(table.value(i, "variant_active"), true)
+	if err != nil {
+		fail("variant_active", err.Error())
+	}
+	row.VariantActive = active
+}
+
+// catalogRows validates a catalog sheet. Products are matched by product_slug, or by the slug of
+// product_name when it is empty, and variants by sku.
+func catalogRows(table importTable, categories map[string]int, units map[string]string, setStock bool) ([]models.CatalogRow, []models.ImportRowError) {
+	rows := make([]models.CatalogRow, 0, len(table.rows))
+	rowErrors := make([]models.ImportRowError, 0)
+	products := make(map[string]*catalogProduct)
+	skus := make(map[string]int)
+
+	for i := range table.rows {
+		line := table.lines[i]
+		failed := false
+		fail := func(field, message string) {
+			failed = true
+			rowErrors = append(rowErrors, models.ImportRowError{Line: line, Field: field, Message: message})
+		}
+
+		slug := strings.ToLower(table.value(i, "product_slug"))
+		if slug == "" {
+			slug = slugify(table.value(i, "product_name"))
+		}
+		if slug == "" {
+			fail("product_slug", "product_slug or product_name is required")
+			continue
+		}
+
+		product, ok := products[slug]
+		if !ok {
+			product = newCatalogProduct(table, i, slug, categories, fail)
+			products[slug] = product
+		} else {
+			if !product.valid {
+				fail("product_slug", fmt.Sprintf("product on line %d has errors", product.line))
+			}
+			for _, column := range catalogProductColumns {
+				if value := table.value(i, column); value != "" && value != product.cells[column] {
+					fail(column, fmt.Sprintf("differs from line %d", product.line))
+				}
+			}
+		}
+
+		row := product.row
+		row.Line = line
+		row.SKU = strings.ToUpper(table.value(i, "sku"))
+		if row.SKU == "" {
+			for _, column := range catalogVariantColumns {
+				if table.value(i, column) != "" {
+					fail("sku", "is required for a variant")
+					break
+				}
+			}
+		} else {
+			if first, ok := skus[row.SKU]; ok {
+				fail("sku", fmt.Sprintf("repeats the sku on line %d", first))
+			} else {
+				skus[row.SKU] = line
+			}
+			fillCatalogVariant(table, i, &row, units, setStock, fail)
+		}
+
+		if !failed {
+			rows = append(rows, row)
+		}
+	}
+	return rows, rowErrors
+}
+
+// importCatalog creates and updates products, variants and prices from a CSV or XLSX sheet laid out like
+// exportCatalog's. With mode=atomic, the default, any bad row rejects the file and nothing is written.
+// With mode=skip good rows are imported and bad ones are listed in the result. The stock column is
+// ignored unless stock=set, a sheet exported in the morning would otherwise undo the day's sales.
+func (srv *Server) importCatalog(resp http.ResponseWriter, req *http.Request) {
+	uc := srv.MiddlewareProvider.UserFromContext(req.Context())
+
+	mode := req.URL.Query().Get("mode")
+	if mode == "" {
+		mode = catalogImportAtomic
+	}
+	if mode != catalogImportAtomic && mode != catalogImportSkip {
+		scmerrors.RespondClientErr(resp, errors.New("invalid mode"), http.StatusBadRequest, "Please choose how to import", "mode must be atomic or skip")
+		return
+	}
+
+	stock := req.URL.Query().Get("stock")
+	if stock == "" {
+		stock = catalogStockKeep
+	}
+	if stock != catalogStockKeep && stock != catalogStockSet {
+		scmerrors.RespondClientErr(resp, errors.New("invalid stock"), http.StatusBadRequest, "Please choose whether to update stock", "stock must be keep or set")
+		return
+	}
+
+	data, format, ok := importFile(resp, req, importFormatCSV, importFormatXLSX)
+	if !ok {
+		return
+	}
+
+	table, rowErrors := readImportTable(data, format)
+	if rowErrors != nil {
+		scmerrors.RespondImportErr(resp, rowErrors)
+		return
+	}
+	if rowErrors = table.missingColumns("product_name", "category_slug", "sku", "unit", "mrp", "price"); len(rowErrors) > 0 {
+		scmerrors.RespondImportErr(resp, rowErrors)
+		return
+	}
+	if len(table.rows) == 0 {
+		scmerrors.RespondImportErr(resp, []models.ImportRowError{{Line: 1, Message: "file has no rows"}})
+		return
+	}
+
+	allCategories, err := srv.DBHelper.ListCategories(true)
+	if err != nil {
+		scmerrors.RespondGenericServerErr(resp, err, "error getting categories")
+		return
+	}
+	categories := make(map[string]int, len(allCategories))
+	for _, category := range allCategories {
+		categories[category.Slug] = category.ID
+	}
+
+	allUnits, err := srv.DBHelper.ListUnits()
+	if err != nil {
+		scmerrors.RespondGenericServerErr(resp, err, "error getting units")
+		return
+	}
+	units := make(map[string]string, len(allUnits))
+	for _, unit := range allUnits {
+		units[strings.ToLower(unit.Code)] = unit.Code
+	}
+
+	rows, rowErrors := catalogRows(table, categories, units, stock == catalogStockSet)
+	if mode == catalogImportAtomic && len(rowErrors) > 0 {
+		scmerrors.RespondImportErr(resp, rowErrors)
+		return
+	}
+
+	result := models.CatalogImportResult{Errors: make([]models.ImportRowError, 0)}
+	if len(rows) > 0 {
+		result, err = srv.DBHelper.ImportCatalog(rows, mode == catalogImportSkip, uc.UserID)
+		if err != nil {
+			scmerrors.RespondGenericServerErr(resp, err, "error importing catalog")
+			return
+		}
+	}
+	if mode == catalogImportAtomic && len(result.Errors) > 0 {
+		scmerrors.RespondImportErr(resp, result.Errors)
+		return
+	}
+
+	result.Errors = append(rowErrors, result.Errors...)
+	sort.SliceStable(result.Errors, func(i, j int) bool {
+		return result.Errors[i].Line < result.Errors[j].Line
+	})
+	result.RowsSkipped = len(table.rows) - result.RowsImported
+
+	utils.EncodeJSONBody(resp, http.StatusOK, map[string]interface{}{
+		"result": result,
+	})
+}
+
+func catalogSheetRow(row models.CatalogRow) []string {
+	cells := []string{
+		row.ProductSlug,
+		row.ProductName,
+		row.CategorySlug,
+		row.Description,
+		row.ImageURL,
+		strconv.FormatBool(row.ProductActive),
+	}
+	if row.SKU == "" {
+		return append(cells, make([]string, len(catalogVariantColumns)+1)...)
+	}
+
+	stock := ""
+	if row.StockQuantity.Valid {
+		stock = strconv.Itoa(row.StockQuantity.Int)
+	}
+
+	return append(cells,
+		row.SKU,
+		strconv.FormatFloat(row.PackSize, 'f', -1, 64),
+		row.UnitCode,
+		formatRupees(row.MRPPaise),
+		formatRupees(row.PricePaise),
+		row.Barcode,
+		stock,
+		strconv.Itoa(row.SortOrder),
+		strconv.FormatBool(row.VariantActive),
+	)
+}
+
+// csvSafeSheet quotes every cell that would start a formula. Workbooks need no quoting, their cells are
+// written as text.
+func csvSafeSheet(sheet [][]string) [][]string {
+	safe := make([][]string, len(sheet))
+	for i, row := range sheet {
+		safe[i] = make([]string, len(row))
+		for j, value := range row {
+			safe[i][j] = csvSafeCell(value)
+		}
+	}
+	return safe
+}
+
+// exportCatalog downloads the catalog as ?format=csv, the default, or xlsx. The file imports back
+// unchanged through importCatalog, stock included only with stock=set.
+func (srv *Server) exportCatalog(resp http.ResponseWriter, req *http.Request) {
+	format := strings.ToLower(req.URL.Query().Get("format"))
+	if format == "" {
+		format = importFormatCSV
+	}
+	if format != importFormatCSV && format != importFormatXLSX {
+		scmerrors.RespondClientErr(resp, errors.New("unsupported format"), http.StatusBadRequest, "Please choose csv or xlsx", "format must be csv or xlsx")
+		return
+	}
+
+	catalog, err := srv.DBHelper.ExportCatalog()
+	if err != nil {
+		scmerrors.RespondGenericServerErr(resp, err, "error exporting catalog")
+		return
+	}
+
+	sheet := make([][]string, 0, len(catalog)+1)
+	sheet = append(sheet, catalogColumns)
+	for _, row := range catalog {
+		sheet = append(sheet, catalogSheetRow(row))
+	}
+
+	var file bytes.Buffer
+	contentType := "text/csv; charset=utf-8"
+	if format == importFormatXLSX {
+		contentType = xlsxMediaType
+		err = utils.WriteXLSX(&file, "Catalog", sheet, catalogNumericColumns)
+	} else {
+		err = csv.NewWriter(&file).WriteAll(csvSafeSheet(sheet))
+	}
+	if err != nil {
+		scmerrors.RespondGenericServerErr(resp, err, "error writing catalog file")
+		return
+	}
+
+	resp.Header().Set("Content-Type", contentType)
+	resp.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"apnasabji-catalog-%s.%s\"", time.Now().UTC().Format("2006-01-02"), format))
+	resp.WriteHeader(http.StatusOK)
+	if _, err := file.WriteTo(resp); err != nil {
+		logrus.Error("exportCatalog: error writing catalog file ", err)
+	}
+}
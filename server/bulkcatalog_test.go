@@ -0,0 +1,82 @@
+package server
+
+import (
+	"bytes"
+	"encoding/csv"
+	"reflect"
+	"testing"
+
+	"github.com/vijaygniit/ApnaSabji/models"
+	"github.com/vijaygniit/ApnaSabji/utils"
+	"github.com/volatiletech/null"
+)
+
+func TestCatalogSheetRoundTrip(t *testing.T) {
+	categories := map[string]int{"vegetables": 1, "fruits": 2}
+	units := map[string]string{"kg": "kg", "g": "g", "pc": "pc"}
+
+	exported := []models.CatalogRow{
+		{
+			ProductSlug: "tomato-hybrid", ProductName: "Tomato, Hybrid", CategoryID: 1, CategorySlug: "vegetables",
+			Description: "Firm \"desi\" tomatoes\nfor curries", ImageURL: "https://cdn.example/tomato.jpg", ProductActive: true,
+			SKU: "TOM-500G", PackSize: 500, UnitCode: "g", MRPPaise: 3000, PricePaise: 2450, Barcode: "8901234567890",
+			StockQuantity: null.IntFrom(12), SortOrder: 1, VariantActive: true,
+		},
+		{
+			ProductSlug: "tomato-hybrid", ProductName: "Tomato, Hybrid", CategoryID: 1, CategorySlug: "vegetables",
+			Description: "Firm \"desi\" tomatoes\nfor curries", ImageURL: "https://cdn.example/tomato.jpg", ProductActive: true,
+			SKU: "TOM-1KG", PackSize: 1, UnitCode: "kg", MRPPaise: 5550, PricePaise: 4805,
+			StockQuantity: null.IntFrom(0), SortOrder: 2, VariantActive: false,
+		},
+		{
+			ProductSlug: "alphonso-mango", ProductName: "आम Alphonso", CategoryID: 2, CategorySlug: "fruits",
+			ProductActive: false, SKU: "MANGO-1PC", PackSize: 0.5, UnitCode: "pc", MRPPaise: 9900, PricePaise: 9900,
+			StockQuantity: null.IntFrom(3), SortOrder: 0, VariantActive: true,
+		},
+		{
+			ProductSlug: "jackfruit", ProductName: "Jackfruit", CategoryID: 2, CategorySlug: "fruits", ProductActive: true,
+			Description: `=HYPERLINK("https://attacker.example","Click")`, ImageURL: "-",
+		},
+	}
+
+	sheet := [][]string{catalogColumns}
+	for _, row := range exported {
+		sheet = append(sheet, catalogSheetRow(row))
+	}
+
+	var xlsxFile, csvFile bytes.Buffer
+	if err := utils.WriteXLSX(&xlsxFile, "Catalog", sheet, catalogNumericColumns); err != nil {
+		t.Fatal(err)
+	}
+	if err := csv.NewWriter(&csvFile).WriteAll(csvSafeSheet(sheet)); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(csvFile.Bytes(), []byte(`"'=HYPERLINK(`)) {
+		t.Errorf("CSV export does not quote a formula: %s", csvFile.String())
+	}
+
+	for format, data := range map[string][]byte{importFormatXLSX: xlsxFile.Bytes(), importFormatCSV: csvFile.Bytes()} {
+		t.Run(format, func(t *testing.T) {
+			table, rowErrors := readImportTable(data, format)
+			if rowErrors != nil {
+				t.Fatalf("readImportTable() errors = %+v", rowErrors)
+			}
+
+			rows, rowErrors := catalogRows(table, categories, units, true)
+			if len(rowErrors) > 0 {
+				t.Fatalf("catalogRows() errors = %+v", rowErrors)
+			}
+			if len(rows) != len(exported) {
+				t.Fatalf("catalogRows() returned %d rows, want %d", len(rows), len(exported))
+			}
+
+			for i, row := range rows {
+				want := exported[i]
+				want.Line = table.lines[i]
+				if !reflect.DeepEqual(row, want) {
+					t.Errorf("row %d = %+v, want %+v", i, row, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,387 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi"
+	"github.com/vijaygniit/ApnaSabji/models"
+	"github.com/vijaygniit/ApnaSabji/scmerrors"
+	"github.com/vijaygniit/ApnaSabji/utils"
+	"github.com/volatiletech/null"
+)
+
+// baseUnits are what a unit's base quantity can be counted in.
+var baseUnits = []string{"g", "ml", "piece", "bunch"}
+
+var nonSlugCharacters = regexp.MustCompile(`[^a-z0-9]+`)
+
+// slugify turns a name like "Lady Finger (Bhindi)" into "lady-finger-bhindi". Names without any latin
+// letters give an empty slug and need one set explicitly.
+func slugify(name string) string {
+	return strings.Trim(nonSlugCharacters.ReplaceAllString(strings.ToLower(name), "-"), "-")
+}
+
+func validSlug(slug string) bool {
+	return slug != "" && slugify(slug) == slug
+}
+
+func catalogIDFromURL(resp http.ResponseWriter, req *http.Request, entity string) (int, bool) {
+	id, err := strconv.Atoi(chi.URLParam(req, "id"))
+	if err != nil {
+		scmerrors.RespondClientErr(resp, err, http.StatusBadRequest, "Invalid "+entity, entity+" id must be an integer")
+		return 0, false
+	}
+	return id, true
+}
+
+func (srv *Server) listUnits(resp http.ResponseWriter, req *http.Request) {
+	units, err := srv.DBHelper.ListUnits()
+	if err != nil {
+		scmerrors.RespondGenericServerErr(resp, err, "error getting units")
+		return
+	}
+
+	utils.EncodeJSONBody(resp, http.StatusOK, map[string]interface{}{
+		"units": units,
+	})
+}
+
+func (srv *Server) listCategories(resp http.ResponseWriter, req *http.Request) {
+	categories, err := srv.DBHelper.ListCategories(false)
+	if err != nil {
+		scmerrors.RespondGenericServerErr(resp, err, "error getting categories")
+		return
+	}
+
+	utils.EncodeJSONBody(resp, http.StatusOK, map[string]interface{}{
+		"categories": categories,
+	})
+}
+
+func (srv *Server) getCategory(resp http.ResponseWriter, req *http.Request) {
+	categoryID, ok := catalogIDFromURL(resp, req, "category")
+	if !ok {
+		return
+	}
+
+	category, err := srv.DBHelper.GetCategory(categoryID, false)
+	if err != nil {
+		scmerrors.RespondCatalogErr(resp, err)
+		return
+	}
+
+	utils.EncodeJSONBody(resp, http.StatusOK, map[string]interface{}{
+		"category": category,
+	})
+}
+
+// listCategoryProducts is the category browsing screen, it 404s for hidden categories.
+func (srv *Server) listCategoryProducts(resp http.ResponseWriter, req *http.Request) {
+	categoryID, ok := catalogIDFromURL(resp, req, "category")
+	if !ok {
+		return
+	}
+
+	if _, err := srv.DBHelper.GetCategory(categoryID, false); err != nil {
+		scmerrors.RespondCatalogErr(resp, err)
+		return
+	}
+
+	srv.respondProducts(resp, req, models.ProductFilter{CategoryID: null.IntFrom(categoryID)})
+}
+
+// productFilterFromQuery reads the category a listing is narrowed to, by id or by slug.
+func productFilterFromQuery(resp http.ResponseWriter, req *http.Request) (models.ProductFilter, bool) {
+	filter := models.ProductFilter{CategorySlug: req.URL.Query().Get("category")}
+
+	if rawCategoryID := req.URL.Query().Get("categoryId"); rawCategoryID != "" {
+		categoryID, err := strconv.Atoi(rawCategoryID)
+		if err != nil {
+			scmerrors.RespondClientErr(resp, err, http.StatusBadRequest, "Invalid category", "categoryId must be an integer")
+			return filter, false
+		}
+		filter.CategoryID = null.IntFrom(categoryID)
+	}
+	return filter, true
+}
+
+func (srv *Server) listProducts(resp http.ResponseWriter, req *http.Request) {
+	filter, ok := productFilterFromQuery(resp, req)
+	if !ok {
+		return
+	}
+
+	srv.respondProducts(resp, req, filter)
+}
+
+func (srv *Server) respondProducts(resp http.ResponseWriter, req *http.Request, filter models.ProductFilter) {
+	page, limit, offset := pagination(req)
+	filter.Limit = limit
+	filter.Offset = offset
+
+	products, total, err := srv.DBHelper.ListProducts(filter)
+	if err != nil {
+		scmerrors.RespondGenericServerErr(resp, err, "error getting products")
+		return
+	}
+
+	utils.EncodeJSONBody(resp, http.StatusOK, map[string]interface{}{
+		"products": products,
+		"total":    total,
+		"page":     page,
+		"limit":    limit,
+	})
+}
+
+func (srv *Server) getProduct(resp http.ResponseWriter, req *http.Request) {
+	if srv.respondProduct(resp, req, false) {
+		srv.countProductView(req)
+	}
+}
+
+func (srv *Server) respondProduct(resp http.ResponseWriter, req *http.Request, includeInactive bool) bool {
+	productID, ok := catalogIDFromURL(resp, req, "product")
+	if !ok {
+		return false
+	}
+
+	product, err := srv.DBHelper.GetProduct(productID, includeInactive)
+	if err != nil {
+		scmerrors.RespondCatalogErr(resp, err)
+		return false
+	}
+
+	utils.EncodeJSONBody(resp, http.StatusOK, map[string]interface{}{
+		"product": product,
+	})
+	return true
+}
+
+// Admin
+
+func (srv *Server) createUnit(resp http.ResponseWriter, req *http.Request) {
+	var unitReq models.CreateUnitRequest
+	if err := json.NewDecoder(req.Body).Decode(&unitReq); err != nil {
+		scmerrors.RespondClientErr(resp, err, http.StatusBadRequest, "Error creating unit", "Error parsing request")
+		return
+	}
+
+	unitReq.Code = strings.ToLower(strings.TrimSpace(unitReq.Code))
+	unitReq.Name = strings.TrimSpace(unitReq.Name)
+	if unitReq.Code == "" || unitReq.Name == "" || unitReq.BaseQuantity <= 0 || !contains(baseUnits, unitReq.BaseUnit) {
+		scmerrors.RespondClientErr(resp, errors.New("invalid unit"), http.StatusBadRequest, "Error creating unit", "code, name, baseUnit (g, ml, piece or bunch) and a positive baseQuantity are required")
+		return
+	}
+
+	unit, err := srv.DBHelper.CreateUnit(unitReq)
+	if err != nil {
+		scmerrors.RespondCatalogErr(resp, err)
+		return
+	}
+
+	utils.EncodeJSONBody(resp, http.StatusCreated, map[string]interface{}{
+		"unit": unit,
+	})
+}
+
+func (srv *Server) adminListCategories(resp http.ResponseWriter, req *http.Request) {
+	categories, err := srv.DBHelper.ListCategories(true)
+	if err != nil {
+		scmerrors.RespondGenericServerErr(resp, err, "error getting categories")
+		return
+	}
+
+	utils.EncodeJSONBody(resp, http.StatusOK, map[string]interface{}{
+		"categories": categories,
+	})
+}
+
+func (srv *Server) createCategory(resp http.ResponseWriter, req *http.Request) {
+	var categoryReq models.CreateCategoryRequest
+	if err := json.NewDecoder(req.Body).Decode(&categoryReq); err != nil {
+		scmerrors.RespondClientErr(resp, err, http.StatusBadRequest, "Error creating category", "Error parsing request")
+		return
+	}
+
+	categoryReq.Name = strings.TrimSpace(categoryReq.Name)
+	if categoryReq.Name == "" {
+		scmerrors.RespondClientErr(resp, errors.New("name cannot be empty"), http.StatusBadRequest, "Name cannot be empty", "Name cannot be empty")
+		return
+	}
+
+	if categoryReq.Slug == "" {
+		categoryReq.Slug = slugify(categoryReq.Name)
+	}
+	if !validSlug(categoryReq.Slug) {
+		scmerrors.RespondClientErr(resp, errors.New("invalid slug"), http.StatusBadRequest, "Please enter a valid slug", "slug must be lowercase letters, digits and dashes")
+		return
+	}
+
+	category, err := srv.DBHelper.CreateCategory(categoryReq)
+	if err != nil {
+		scmerrors.RespondCatalogErr(resp, err)
+		return
+	}
+
+	utils.EncodeJSONBody(resp, http.StatusCreated, map[string]interface{}{
+		"category": category,
+	})
+}
+
+func (srv *Server) updateCategory(resp http.ResponseWriter, req *http.Request) {
+	categoryID, ok := catalogIDFromURL(resp, req, "category")
+	if !ok {
+		return
+	}
+
+	var categoryReq models.UpdateCategoryRequest
+	if err := json.NewDecoder(req.Body).Decode(&categoryReq); err != nil {
+		scmerrors.RespondClientErr(resp, err, http.StatusBadRequest, "Error updating category", "Error parsing request")
+		return
+	}
+
+	if categoryReq.Name.Valid && strings.TrimSpace(categoryReq.Name.String) == "" {
+		scmerrors.RespondClientErr(resp, errors.New("name cannot be empty"), http.StatusBadRequest, "Name cannot be empty", "Name cannot be empty")
+		return
+	}
+
+	if categoryReq.Slug.Valid && !validSlug(categoryReq.Slug.String) {
+		scmerrors.RespondClientErr(resp, errors.New("invalid slug"), http.StatusBadRequest, "Please enter a valid slug", "slug must be lowercase letters, digits and dashes")
+		return
+	}
+
+	category, err := srv.DBHelper.UpdateCategory(categoryID, categoryReq)
+	if err != nil {
+		scmerrors.RespondCatalogErr(resp, err)
+		return
+	}
+
+	utils.EncodeJSONBody(resp, http.StatusOK, map[string]interface{}{
+		"category": category,
+	})
+}
+
+func (srv *Server) deleteCategory(resp http.ResponseWriter, req *http.Request) {
+	categoryID, ok := catalogIDFromURL(resp, req, "category")
+	if !ok {
+		return
+	}
+
+	if err := srv.DBHelper.DeleteCategory(categoryID); err != nil {
+		scmerrors.RespondCatalogErr(resp, err)
+		return
+	}
+
+	utils.EncodeJSONBody(resp, http.StatusOK, map[string]interface{}{
+		"message": "success",
+	})
+}
+
+// adminListProducts lists every product including switched off ones, filtered like the public list.
+func (srv *Server) adminListProducts(resp http.ResponseWriter, req *http.Request) {
+	filter, ok := productFilterFromQuery(resp, req)
+	if !ok {
+		return
+	}
+
+	filter.IncludeInactive = true
+	srv.respondProducts(resp, req, filter)
+}
+
+func (srv *Server) adminGetProduct(resp http.ResponseWriter, req *http.Request) {
+	_ = srv.respondProduct(resp, req, true)
+}
+
+func (srv *Server) createProduct(resp http.ResponseWriter, req *http.Request) {
+	uc := srv.MiddlewareProvider.UserFromContext(req.Context())
+
+	var productReq models.CreateProductRequest
+	if err := json.NewDecoder(req.Body).Decode(&productReq); err != nil {
+		scmerrors.RespondClientErr(resp, err, http.StatusBadRequest, "Error creating product", "Error parsing request")
+		return
+	}
+
+	productReq.Name = strings.TrimSpace(productReq.Name)
+	if productReq.Name == "" {
+		scmerrors.RespondClientErr(resp, errors.New("name cannot be empty"), http.StatusBadRequest, "Name cannot be empty", "Name cannot be empty")
+		return
+	}
+
+	if productReq.Slug == "" {
+		productReq.Slug = slugify(productReq.Name)
+	}
+	if !validSlug(productReq.Slug) {
+		scmerrors.RespondClientErr(resp, errors.New("invalid slug"), http.StatusBadRequest, "Please enter a valid slug", "slug must be lowercase letters, digits and dashes")
+		return
+	}
+
+	for i := range productReq.Variants {
+		if !validateVariant(resp, &productReq.Variants[i]) {
+			return
+		}
+	}
+
+	product, err := srv.DBHelper.CreateProduct(productReq, uc.UserID)
+	if err != nil {
+		scmerrors.RespondCatalogErr(resp, err)
+		return
+	}
+
+	utils.EncodeJSONBody(resp, http.StatusCreated, map[string]interface{}{
+		"product": product,
+	})
+}
+
+func (srv *Server) updateProduct(resp http.ResponseWriter, req *http.Request) {
+	productID, ok := catalogIDFromURL(resp, req, "product")
+	if !ok {
+		return
+	}
+
+	var productReq models.UpdateProductRequest
+	if err := json.NewDecoder(req.Body).Decode(&productReq); err != nil {
+		scmerrors.RespondClientErr(resp, err, http.StatusBadRequest, "Error updating product", "Error parsing request")
+		return
+	}
+
+	if productReq.Name.Valid && strings.TrimSpace(productReq.Name.String) == "" {
+		scmerrors.RespondClientErr(resp, errors.New("name cannot be empty"), http.StatusBadRequest, "Name cannot be empty", "Name cannot be empty")
+		return
+	}
+
+	if productReq.Slug.Valid && !validSlug(productReq.Slug.String) {
+		scmerrors.RespondClientErr(resp, errors.New("invalid slug"), http.StatusBadRequest, "Please enter a valid slug", "slug must be lowercase letters, digits and dashes")
+		return
+	}
+
+	product, err := srv.DBHelper.UpdateProduct(productID, productReq)
+	if err != nil {
+		scmerrors.RespondCatalogErr(resp, err)
+		return
+	}
+
+	utils.EncodeJSONBody(resp, http.StatusOK, map[string]interface{}{
+		"product": product,
+	})
+}
+
+func (srv *Server) deleteProduct(resp http.ResponseWriter, req *http.Request) {
+	productID, ok := catalogIDFromURL(resp, req, "product")
+	if !ok {
+		return
+	}
+
+	if err := srv.DBHelper.DeleteProduct(productID); err != nil {
+		scmerrors.RespondCatalogErr(resp, err)
+		return
+	}
+
+	utils.EncodeJSONBody(resp, http.StatusOK, map[string]interface{}{
+		"message": "success",
+	})
+}
@@ -0,0 +1,205 @@
+package server
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"github.com/vijaygniit/ApnaSabji/models"
+	"github.com/vijaygniit/ApnaSabji/scmerrors"
+	"github.com/vijaygniit/ApnaSabji/utils"
+)
+
+func consentRecord(req *http.Request, userID int) models.ConsentRecord {
+	return models.ConsentRecord{
+		UserID:    userID,
+		IP:        utils.ClientIP(req),
+		UserAgent: req.UserAgent(),
+	}
+}
+
+// registrationPolicyIDs checks that a new user accepted every mandatory policy and returns the
+// accepted ids that are still current, stale ids from an outdated sign up screen are dropped.
+func (srv *Server) registrationPolicyIDs(acceptedIDs []int) ([]int, error) {
+	accepted := make(map[int]bool, len(acceptedIDs))
+	for _, policyID := range acceptedIDs {
+		accepted[policyID] = true
+	}
+
+	pending, err := srv.DBHelper.GetPendingPolicyDocuments(0)
+	if err != nil {
+		return nil, err
+	}
+	for _, policy := range pending {
+		if !accepted[policy.ID] {
+			return nil, scmerrors.ErrConsentRequired
+		}
+	}
+
+	current, err := srv.DBHelper.GetCurrentPolicyDocuments()
+	if err != nil {
+		return nil, err
+	}
+	policyIDs := make([]int, 0, len(current))
+	for _, policy := range current {
+		if accepted[policy.ID] {
+			policyIDs = append(policyIDs, policy.ID)
+		}
+	}
+	return policyIDs, nil
+}
+
+// listPolicies returns the terms and privacy policy in force, for the sign up screen.
+func (srv *Server) listPolicies(resp http.ResponseWriter, req *http.Request) {
+	policies, err := srv.DBHelper.GetCurrentPolicyDocuments()
+	if err != nil {
+		scmerrors.RespondGenericServerErr(resp, err, "error getting policies")
+		return
+	}
+
+	utils.EncodeJSONBody(resp, http.StatusOK, map[string]interface{}{
+		"policies": policies,
+	})
+}
+
+func (srv *Server) getConsents(resp http.ResponseWriter, req *http.Request) {
+	uc := srv.MiddlewareProvider.UserFromContext(req.Context())
+
+	pending, err := srv.DBHelper.GetPendingPolicyDocuments(uc.UserID)
+	if err != nil {
+		scmerrors.RespondGenericServerErr(resp, err, "error getting pending policies")
+		return
+	}
+
+	accepted, err := srv.DBHelper.ListUserConsents(uc.UserID)
+	if err != nil {
+		scmerrors.RespondGenericServerErr(resp, err, "error getting consents")
+		return
+	}
+
+	marketing, err := srv.DBHelper.GetMarketingConsent(uc.UserID)
+	if err != nil {
+		scmerrors.RespondGenericServerErr(resp, err, "error getting marketing consent")
+		return
+	}
+
+	utils.EncodeJSONBody(resp, http.StatusOK, map[string]interface{}{
+		"pending":   pending,
+		"accepted":  accepted,
+		"marketing": marketing,
+	})
+}
+
+func (srv *Server) acceptPolicies(resp http.ResponseWriter, req *http.Request) {
+	uc := srv.MiddlewareProvider.UserFromContext(req.Context())
+
+	var acceptReq models.AcceptPoliciesRequest
+	if err := json.NewDecoder(req.Body).Decode(&acceptReq); err != nil {
+		scmerrors.RespondClientErr(resp, err, http.StatusBadRequest, "Error accepting terms", "Error parsing request")
+		return
+	}
+
+	if len(acceptReq.PolicyIDs) == 0 {
+		scmerrors.RespondClientErr(resp, errors.New("policyIds is required"), http.StatusBadRequest, "Error accepting terms", "policyIds can not be empty")
+		return
+	}
+
+	if err := srv.DBHelper.AcceptPolicyDocuments(consentRecord(req, uc.UserID), acceptReq.PolicyIDs); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			scmerrors.RespondConsentErr(resp, scmerrors.ErrPolicyNotFound)
+			return
+		}
+		scmerrors.RespondGenericServerErr(resp, err, "error accepting policies")
+		return
+	}
+
+	pending, err := srv.DBHelper.GetPendingPolicyDocuments(uc.UserID)
+	if err != nil {
+		scmerrors.RespondGenericServerErr(resp, err, "error getting pending policies")
+		return
+	}
+
+	utils.EncodeJSONBody(resp, http.StatusOK, map[string]interface{}{
+		"message": "success",
+		"pending": pending,
+	})
+}
+
+func (srv *Server) setMarketingConsent(resp http.ResponseWriter, req *http.Request) {
+	uc := srv.MiddlewareProvider.UserFromContext(req.Context())
+
+	var marketingReq models.MarketingConsentRequest
+	if err := json.NewDecoder(req.Body).Decode(&marketingReq); err != nil {
+		scmerrors.RespondClientErr(resp, err, http.StatusBadRequest, "Error updating preferences", "Error parsing request")
+		return
+	}
+
+	if err := srv.DBHelper.SetMarketingConsent(consentRecord(req, uc.UserID), marketingReq.OptIn); err != nil {
+		scmerrors.RespondGenericServerErr(resp, err, "error updating marketing consent")
+		return
+	}
+
+	utils.EncodeJSONBody(resp, http.StatusOK, map[string]interface{}{
+		"message": "success",
+	})
+}
+
+func (srv *Server) listPolicyVersions(resp http.ResponseWriter, req *http.Request) {
+	kind := models.PolicyKind(req.URL.Query().Get("kind"))
+	if !kind.IsValid() {
+		scmerrors.RespondClientErr(resp, errors.New("invalid kind"), http.StatusBadRequest, "Invalid policy", "kind must be terms_of_service or privacy_policy")
+		return
+	}
+
+	policies, err := srv.DBHelper.ListPolicyDocuments(kind)
+	if err != nil {
+		scmerrors.RespondGenericServerErr(resp, err, "error getting policies")
+		return
+	}
+
+	utils.EncodeJSONBody(resp, http.StatusOK, map[string]interface{}{
+		"policies": policies,
+	})
+}
+
+// publishPolicy adds a policy version. Publishing a mandatory version makes every user accept it
+// before they can use the app again.
+func (srv *Server) publishPolicy(resp http.ResponseWriter, req *http.Request) {
+	uc := srv.MiddlewareProvider.UserFromContext(req.Context())
+
+	var publishReq models.PublishPolicyRequest
+	if err := json.NewDecoder(req.Body).Decode(&publishReq); err != nil {
+		scmerrors.RespondClientErr(resp, err, http.StatusBadRequest, "Error publishing policy", "Error parsing request")
+		return
+	}
+
+	publishReq.Version = strings.TrimSpace(publishReq.Version)
+	publishReq.Title = strings.TrimSpace(publishReq.Title)
+	if !publishReq.Kind.IsValid() || publishReq.Version == "" || publishReq.Title == "" {
+		scmerrors.RespondClientErr(resp, errors.New("invalid policy"), http.StatusBadRequest, "Error publishing policy", "kind, version and title are required")
+		return
+	}
+
+	if publishReq.URL == "" && strings.TrimSpace(publishReq.Content) == "" {
+		scmerrors.RespondClientErr(resp, errors.New("invalid policy"), http.StatusBadRequest, "Error publishing policy", "url or content is required")
+		return
+	}
+
+	policy, err := srv.DBHelper.PublishPolicyDocument(publishReq, uc.UserID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			scmerrors.RespondClientErr(resp, err, http.StatusConflict, "This version already exists", "a policy with this kind and version was already published")
+			return
+		}
+		logrus.Error("publishPolicy: error publishing policy ", err)
+		scmerrors.RespondGenericServerErr(resp, err, "error publishing policy")
+		return
+	}
+
+	utils.EncodeJSONBody(resp, http.StatusCreated, map[string]interface{}{
+		"policy": policy,
+	})
+}
@@ -0,0 +1,34 @@
+package server
+
+const (
+	defaultOTPLength        = 6
+	defaultOTPExpiryMinutes = 10
+	defaultOTPMaxAttempts   = 5
+
+	defaultAccountDeletionGraceDays    = 30
+	defaultAccountPurgeIntervalMinutes = 60
+	defaultAuthEventRetentionDays      = 180
+
+	defaultExportLinkTTLHours          = 48
+	defaultExportWorkerIntervalSecs    = 30
+	defaultExportProcessingTimeoutMins = 30
+
+	defaultAPIKeyRateLimitPerMinute    = 120
+	defaultAPIKeyMaxRateLimitPerMinute = 1200
+	defaultAPIKeyRotationGraceHours    = 24
+
+	defaultOIDCStateTTLMinutes = 10
+
+	defaultDeviceAlertTTLHours = 72
+
+	maxSearchQueryLength   = 100
+	defaultSuggestionLimit = 8
+	maxSuggestionLimit     = 20
+
+	maxImportBytes          = 10 << 20
+	maxImportUnzippedBytes  = 4 * maxImportBytes
+	maxImportRows           = 50000
+	defaultRoundingPaise    = 100
+	maxMarkupPercent        = 1000
+	maxMandiCommodityLength = 100
+)
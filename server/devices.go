@@ -0,0 +1,256 @@
+package server
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"mime"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/sirupsen/logrus"
+	"github.com/vijaygniit/ApnaSabji/models"
+	"github.com/vijaygniit/ApnaSabji/scmerrors"
+	"github.com/vijaygniit/ApnaSabji/utils"
+	"github.com/volatiletech/null"
+)
+
+const maxDeviceNameLength = 64
+
+// checkLoginDevice remembers the device of a fresh session and, when the user has logged in from
+// other devices before but never this one, sends them a link to revoke the session. Failures are
+// logged only, they must not block the login itself.
+func (srv *Server) checkLoginDevice(req *http.Request, userInfo models.FetchUserData, sessionToken string, device models.CreateSessionRequest) bool {
+	ip := utils.ClientIP(req)
+
+	deviceLogin, err := srv.DBHelper.RecordDeviceLogin(userInfo.UserID, device, ip)
+	if err != nil {
+		logrus.Error("checkLoginDevice: error recording device ", err)
+		return false
+	}
+
+	if !deviceLogin.IsNewDevice || deviceLogin.IsFirstDevice {
+		return false
+	}
+
+	srv.recordAuthEvent(req, models.AuthEvent{
+		UserID:               null.IntFrom(userInfo.UserID),
+		EventType:            models.AuthEventNewDevice,
+		Outcome:              models.AuthEventSuccess,
+		CreateSessionRequest: device,
+	})
+
+	expiresIn := time.Duration(utils.GetEnvInt("DEVICE_ALERT_TTL_HOURS", defaultDeviceAlertTTLHours)) * time.Hour
+	alertToken, err := srv.DBHelper.CreateDeviceAlert(userInfo.UserID, sessionToken, device, ip, expiresIn)
+	if err != nil {
+		logrus.Error("checkLoginDevice: error creating device alert ", err)
+		return true
+	}
+
+	if err := srv.notifyNewDeviceLogin(userInfo, alertToken, device, ip); err != nil {
+		logrus.Error("checkLoginDevice: error sending device alert ", err)
+	}
+	return true
+}
+
+func (srv *Server) notifyNewDeviceLogin(userInfo models.FetchUserData, alertToken string, device models.CreateSessionRequest, ip string) error {
+	deviceName := strings.TrimSpace(device.Platform + " " + device.ModelName)
+	if deviceName == "" {
+		deviceName = "an unknown device"
+	}
+
+	link := os.Getenv("PUBLIC_BASE_URL") + "/api/device-alerts/" + alertToken + "/revoke"
+	message := fmt.Sprintf("Your ApnaSabji account was just used to log in from %s (IP %s). If this wasn't you, sign that device out here: %s", deviceName, ip, link)
+
+	if userInfo.Email != "" {
+		return srv.Notifier.SendEmail(userInfo.Email, "New login to your ApnaSabji account", message)
+	}
+	return srv.Notifier.SendSMS(userInfo.Mobilenumber, message)
+}
+
+// deviceAlertTemplate is the page the "this wasn't me" link opens. Following a link must not change
+// anything, mail scanners and link previews open it too, so signing out takes a press of the button.
+var deviceAlertTemplate = template.Must(template.New("deviceAlert").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>ApnaSabji account security</title>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<p>{{.Message}}</p>
+{{if .Confirm}}<form method="post"><button type="submit">Sign out this device</button></form>{{end}}
+</body>
+</html>
+`))
+
+type deviceAlertPage struct {
+	Title   string
+	Message string
+	Confirm bool
+}
+
+func renderDeviceAlertPage(resp http.ResponseWriter, statusCode int, page deviceAlertPage) {
+	resp.Header().Set("Content-Type", "text/html; charset=utf-8")
+	resp.Header().Set("Content-Security-Policy", "default-src 'none'; form-action 'self'")
+	// the token is in the URL, it must not leak to other sites
+	resp.Header().Set("Referrer-Policy", "no-referrer")
+	resp.Header().Set("Cache-Control", "no-store")
+	resp.WriteHeader(statusCode)
+	if err := deviceAlertTemplate.Execute(resp, page); err != nil {
+		logrus.Error("renderDeviceAlertPage: error rendering page ", err)
+	}
+}
+
+// deviceAlertExpiredPage is shown for a link that is unknown, used or expired.
+var deviceAlertExpiredPage = deviceAlertPage{
+	Title:   "Link expired",
+	Message: "This link has expired or was already used.",
+}
+
+// confirmDeviceAlertRevoke serves the "this wasn't me" link, it is opened from an email or SMS so it
+// needs no login. It only describes the login, the form on it posts to revokeFromDeviceAlert.
+func (srv *Server) confirmDeviceAlertRevoke(resp http.ResponseWriter, req *http.Request) {
+	alert, err := srv.DBHelper.GetDeviceAlert(chi.URLParam(req, "token"))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			renderDeviceAlertPage(resp, http.StatusGone, deviceAlertExpiredPage)
+			return
+		}
+		scmerrors.RespondGenericServerErr(resp, err, "error getting device alert")
+		return
+	}
+
+	deviceName := strings.TrimSpace(alert.Platform + " " + alert.ModelName)
+	if deviceName == "" {
+		deviceName = "an unknown device"
+	}
+
+	renderDeviceAlertPage(resp, http.StatusOK, deviceAlertPage{
+		Title:   "Was this you?",
+		Message: fmt.Sprintf("Your ApnaSabji account was used to log in from %s (IP %s) on %s. If this wasn't you, sign that device out.", deviceName, alert.IP, alert.CreatedAt.Format(time.RFC1123)),
+		Confirm: true,
+	})
+}
+
+// revokeFromDeviceAlert signs out the device an alert was about. The confirmation page posts a form
+// and gets a page back, apps posting to it directly get JSON.
+func (srv *Server) revokeFromDeviceAlert(resp http.ResponseWriter, req *http.Request) {
+	mediaType, _, _ := mime.ParseMediaType(req.Header.Get("Content-Type"))
+	fromPage := mediaType == "application/x-www-form-urlencoded"
+
+	userID, err := srv.DBHelper.RevokeSessionFromDeviceAlert(chi.URLParam(req, "token"))
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows) && fromPage:
+			renderDeviceAlertPage(resp, http.StatusGone, deviceAlertExpiredPage)
+		case errors.Is(err, sql.ErrNoRows):
+			scmerrors.RespondClientErr(resp, err, http.StatusGone, "This link has expired or was already used", "device alert not found, used or expired")
+		default:
+			scmerrors.RespondGenericServerErr(resp, err, "error revoking session")
+		}
+		return
+	}
+
+	srv.recordAuthEvent(req, models.AuthEvent{
+		UserID:    null.IntFrom(userID),
+		EventType: models.AuthEventDeviceAlertRevoke,
+		Outcome:   models.AuthEventSuccess,
+	})
+
+	message := "The device has been signed out. Consider changing your contact details if you don't recognise this login."
+	if fromPage {
+		renderDeviceAlertPage(resp, http.StatusOK, deviceAlertPage{Title: "Device signed out", Message: message})
+		return
+	}
+	utils.EncodeJSONBody(resp, http.StatusOK, map[string]interface{}{
+		"message": message,
+	})
+}
+
+func (srv *Server) listDevices(resp http.ResponseWriter, req *http.Request) {
+	uc := srv.MiddlewareProvider.UserFromContext(req.Context())
+
+	devices, err := srv.DBHelper.ListUserDevices(uc.UserID)
+	if err != nil {
+		scmerrors.RespondGenericServerErr(resp, err, "error getting devices")
+		return
+	}
+
+	utils.EncodeJSONBody(resp, http.StatusOK, map[string]interface{}{
+		"devices": devices,
+	})
+}
+
+func (srv *Server) renameDevice(resp http.ResponseWriter, req *http.Request) {
+	uc := srv.MiddlewareProvider.UserFromContext(req.Context())
+
+	userDeviceID, err := strconv.Atoi(chi.URLParam(req, "id"))
+	if err != nil {
+		scmerrors.RespondClientErr(resp, err, http.StatusBadRequest, "Invalid device", "device id must be an integer")
+		return
+	}
+
+	var renameReq models.RenameDeviceRequest
+	if err := json.NewDecoder(req.Body).Decode(&renameReq); err != nil {
+		scmerrors.RespondClientErr(resp, err, http.StatusBadRequest, "Error renaming device", "Error parsing request")
+		return
+	}
+
+	renameReq.Name = strings.TrimSpace(renameReq.Name)
+	if renameReq.Name == "" || len(renameReq.Name) > maxDeviceNameLength {
+		scmerrors.RespondClientErr(resp, errors.New("invalid device name"), http.StatusBadRequest, "Please enter a device name", fmt.Sprintf("name must be 1 to %d characters", maxDeviceNameLength))
+		return
+	}
+
+	if err := srv.DBHelper.RenameUserDevice(uc.UserID, userDeviceID, renameReq.Name); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			scmerrors.RespondClientErr(resp, err, http.StatusNotFound, "Device not found", "no device with this id for the user")
+			return
+		}
+		scmerrors.RespondGenericServerErr(resp, err, "error renaming device")
+		return
+	}
+
+	utils.EncodeJSONBody(resp, http.StatusOK, map[string]interface{}{
+		"message": "success",
+	})
+}
+
+// forgetDevice drops the device from the known list and signs out its sessions.
+func (srv *Server) forgetDevice(resp http.ResponseWriter, req *http.Request) {
+	uc := srv.MiddlewareProvider.UserFromContext(req.Context())
+
+	userDeviceID, err := strconv.Atoi(chi.URLParam(req, "id"))
+	if err != nil {
+		scmerrors.RespondClientErr(resp, err, http.StatusBadRequest, "Invalid device", "device id must be an integer")
+		return
+	}
+
+	if err := srv.DBHelper.ForgetUserDevice(uc.UserID, userDeviceID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			scmerrors.RespondClientErr(resp, err, http.StatusNotFound, "Device not found", "no device with this id for the user")
+			return
+		}
+		scmerrors.RespondGenericServerErr(resp, err, "error forgetting device")
+		return
+	}
+
+	srv.recordAuthEvent(req, models.AuthEvent{
+		UserID:    null.IntFrom(uc.UserID),
+		EventType: models.AuthEventDeviceForget,
+		Outcome:   models.AuthEventSuccess,
+		Reason:    "device " + strconv.Itoa(userDeviceID),
+	})
+
+	utils.EncodeJSONBody(resp, http.StatusOK, map[string]interface{}{
+		"message": "success",
+	})
+}
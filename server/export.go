@@ -0,0 +1,199 @@
+package server
+
+import (
+	"archive/zip"
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/sirupsen/logrus"
+	"github.com/vijaygniit/ApnaSabji/models"
+	"github.com/vijaygniit/ApnaSabji/scmerrors"
+	"github.com/vijaygniit/ApnaSabji/utils"
+)
+
+// exportSection is one file in the data export archive. New domains (orders, addresses, payments)
+// join the export by adding a section here.
+type exportSection struct {
+	name    string
+	collect func(userID int) (interface{}, error)
+}
+
+func (srv *Server) exportSections() []exportSection {
+	return []exportSection{
+		{name: "account", collect: func(userID int) (interface{}, error) {
+			return srv.DBHelper.GetUserAccountExport(userID)
+		}},
+		{name: "profile", collect: func(userID int) (interface{}, error) {
+			return srv.DBHelper.GetUserProfile(userID)
+		}},
+		{name: "sessions", collect: func(userID int) (interface{}, error) {
+			return srv.DBHelper.FetchUserSessionHistory(userID)
+		}},
+		{name: "devices", collect: func(userID int) (interface{}, error) {
+			return srv.DBHelper.ListUserDevices(userID)
+		}},
+		{name: "consents", collect: func(userID int) (interface{}, error) {
+			return srv.DBHelper.ListUserConsents(userID)
+		}},
+		{name: "auth_events", collect: func(userID int) (interface{}, error) {
+			return srv.DBHelper.ListUserAuthEvents(userID)
+		}},
+		{name: "user_identities", collect: func(userID int) (interface{}, error) {
+			return srv.DBHelper.ListUserIdentities(userID)
+		}},
+		// key hashes are never serialized, only the metadata the user can already see is exported
+		{name: "api_keys", collect: func(userID int) (interface{}, error) {
+			return srv.DBHelper.ListAPIKeys(userID)
+		}},
+		{name: "product_views", collect: func(userID int) (interface{}, error) {
+			return srv.DBHelper.ListUserProductViews(userID)
+		}},
+	}
+}
+
+func (srv *Server) requestDataExport(resp http.ResponseWriter, req *http.Request) {
+	uc := srv.MiddlewareProvider.UserFromContext(req.Context())
+
+	dataExport, err := srv.DBHelper.CreateDataExport(uc.UserID)
+	if err != nil {
+		logrus.Error("requestDataExport: error queueing export ", err)
+		scmerrors.RespondGenericServerErr(resp, err, "error queueing export")
+		return
+	}
+
+	utils.EncodeJSONBody(resp, http.StatusAccepted, map[string]interface{}{
+		"export": dataExport,
+	})
+}
+
+func (srv *Server) getDataExport(resp http.ResponseWriter, req *http.Request) {
+	uc := srv.MiddlewareProvider.UserFromContext(req.Context())
+
+	exportID, err := strconv.Atoi(chi.URLParam(req, "id"))
+	if err != nil {
+		scmerrors.RespondClientErr(resp, err, http.StatusBadRequest, "Invalid export", "export id must be an integer")
+		return
+	}
+
+	dataExport, err := srv.DBHelper.GetDataExport(uc.UserID, exportID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			scmerrors.RespondClientErr(resp, err, http.StatusNotFound, "Export not found", "no export with this id for the user")
+			return
+		}
+		scmerrors.RespondGenericServerErr(resp, err, "error getting export")
+		return
+	}
+
+	utils.EncodeJSONBody(resp, http.StatusOK, map[string]interface{}{
+		"export": dataExport,
+	})
+}
+
+// downloadDataExport serves the archive behind a one time link, which deletes it.
+func (srv *Server) downloadDataExport(resp http.ResponseWriter, req *http.Request) {
+	dataExport, archive, err := srv.DBHelper.ConsumeDataExportDownload(chi.URLParam(req, "token"))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			scmerrors.RespondClientErr(resp, err, http.StatusGone, "This download link has expired or was already used", "export link not ready, used or expired")
+			return
+		}
+		scmerrors.RespondGenericServerErr(resp, err, "error getting export")
+		return
+	}
+
+	resp.Header().Set("Content-Type", "application/zip")
+	resp.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"apnasabji-data-%d.zip\"", dataExport.UserID))
+	resp.Header().Set("Content-Length", strconv.Itoa(len(archive)))
+	resp.WriteHeader(http.StatusOK)
+	if _, err := resp.Write(archive); err != nil {
+		logrus.Error("downloadDataExport: error writing export archive ", err)
+	}
+}
+
+// processDataExports builds every queued export.
+func (srv *Server) processDataExports() {
+	staleAfter := time.Duration(utils.GetEnvInt("EXPORT_PROCESSING_TIMEOUT_MINUTES", defaultExportProcessingTimeoutMins)) * time.Minute
+	for {
+		dataExport, err := srv.DBHelper.ClaimNextDataExport(staleAfter)
+		if err != nil {
+			return
+		}
+
+		if err := srv.buildDataExport(dataExport); err != nil {
+			logrus.Errorf("processDataExports: error building export %d %v", dataExport.ID, err)
+			_ = srv.DBHelper.FailDataExport(dataExport.ID, err.Error())
+		}
+	}
+}
+
+func (srv *Server) buildDataExport(dataExport models.DataExport) error {
+	var archive bytes.Buffer
+	if err := srv.writeExportArchive(&archive, dataExport.UserID); err != nil {
+		return err
+	}
+
+	expiresAt := time.Now().UTC().Add(time.Duration(utils.GetEnvInt("EXPORT_LINK_TTL_HOURS", defaultExportLinkTTLHours)) * time.Hour)
+	downloadToken, err := srv.DBHelper.CompleteDataExport(dataExport.ID, archive.Bytes(), expiresAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		// another replica claimed the export again after this one was too slow, and finished it
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	return srv.notifyDataExportReady(dataExport.UserID, downloadToken, expiresAt)
+}
+
+func (srv *Server) writeExportArchive(w io.Writer, userID int) error {
+	archive := zip.NewWriter(w)
+	for _, section := range srv.exportSections() {
+		data, err := section.collect(userID)
+		if err != nil {
+			return fmt.Errorf("collecting %s: %w", section.name, err)
+		}
+
+		entry, err := archive.Create(section.name + ".json")
+		if err != nil {
+			return err
+		}
+
+		encoder := json.NewEncoder(entry)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(data); err != nil {
+			return fmt.Errorf("encoding %s: %w", section.name, err)
+		}
+	}
+
+	return archive.Close()
+}
+
+func (srv *Server) notifyDataExportReady(userID int, downloadToken string, expiresAt time.Time) error {
+	userInfo, err := srv.DBHelper.FetchUserData(userID)
+	if err != nil {
+		return err
+	}
+
+	link := os.Getenv("PUBLIC_BASE_URL") + "/api/exports/" + downloadToken
+	message := fmt.Sprintf("Your ApnaSabji data export is ready. Download it once from %s before %s.", link, expiresAt.Format(time.RFC1123))
+
+	if userInfo.Email != "" {
+		return srv.Notifier.SendEmail(userInfo.Email, "Your ApnaSabji data export", message)
+	}
+	return srv.Notifier.SendSMS(userInfo.Mobilenumber, message)
+}
+
+// expireDataExports deletes archives whose download link ran out unused.
+func (srv *Server) expireDataExports() {
+	_, _ = srv.DBHelper.ExpireDataExports()
+}
@@ -0,0 +1,210 @@
+package server
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"github.com/vijaygniit/ApnaSabji/models"
+	"github.com/vijaygniit/ApnaSabji/scmerrors"
+	"github.com/vijaygniit/ApnaSabji/utils"
+)
+
+const (
+	importFormatCSV  = "csv"
+	importFormatJSON = "json"
+	importFormatXLSX = "xlsx"
+
+	xlsxMediaType = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+)
+
+var importMediaTypes = map[string]string{
+	"text/csv":                 importFormatCSV,
+	"application/csv":          importFormatCSV,
+	"application/vnd.ms-excel": importFormatCSV,
+	"application/json":         importFormatJSON,
+	xlsxMediaType:              importFormatXLSX,
+}
+
+// importFile reads an upload sent either as the raw body or as the multipart field file. The format is
+// taken from ?format, then the file extension, then the Content-Type.
+func importFile(resp http.ResponseWriter, req *http.Request, formats ...string) ([]byte, string, bool) {
+	req.Body = http.MaxBytesReader(resp, req.Body, maxImportBytes)
+
+	format := strings.ToLower(strings.TrimSpace(req.URL.Query().Get("format")))
+	mediaType, _, _ := mime.ParseMediaType(req.Header.Get("Content-Type"))
+
+	var file io.Reader = req.Body
+	if mediaType == "multipart/form-data" {
+		upload, header, err := req.FormFile("file")
+		if err != nil {
+			scmerrors.RespondClientErr(resp, err, http.StatusBadRequest, "Please choose a file to upload", "multipart field file is required and at most 10 MB")
+			return nil, "", false
+		}
+		defer func() {
+			_ = upload.Close()
+		}()
+
+		file = upload
+		if format == "" {
+			format = strings.TrimPrefix(strings.ToLower(filepath.Ext(header.Filename)), ".")
+		}
+		mediaType, _, _ = mime.ParseMediaType(header.Header.Get("Content-Type"))
+	}
+
+	if format == "" {
+		format = importMediaTypes[mediaType]
+	}
+	if !contains(formats, format) {
+		scmerrors.RespondClientErr(resp, errors.New("unsupported format"), http.StatusBadRequest, "Please upload a "+strings.Join(formats, " or ")+" file", "format must be one of "+strings.Join(formats, ", "))
+		return nil, "", false
+	}
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		scmerrors.RespondClientErr(resp, err, http.StatusBadRequest, "The file could not be read", "file must be at most 10 MB")
+		return nil, "", false
+	}
+	return data, format, true
+}
+
+// importTable is an uploaded sheet, its columns keyed by importColumn of the header and every
+// data row next to the line it was read from.
+type importTable struct {
+	columns map[string]int
+	rows    [][]string
+	lines   []int
+}
+
+// importColumn lets headers such as "Modal Price" and "modal_price" name the same column.
+func importColumn(header string) string {
+	header = strings.ToLower(strings.TrimSpace(header))
+	return strings.NewReplacer(" ", "_", "-", "_").Replace(header)
+}
+
+func (table importTable) value(row int, column string) string {
+	i, ok := table.columns[column]
+	if !ok || i >= len(table.rows[row]) {
+		return ""
+	}
+	return strings.TrimSpace(table.rows[row][i])
+}
+
+// missingColumns reports the required columns the header lacks, as a row error on line 1.
+func (table importTable) missingColumns(required ...string) []models.ImportRowError {
+	rowErrors := make([]models.ImportRowError, 0)
+	for _, column := range required {
+		if _, ok := table.columns[column]; !ok {
+			rowErrors = append(rowErrors, models.ImportRowError{Line: 1, Field: column, Message: "column is missing"})
+		}
+	}
+	return rowErrors
+}
+
+func newImportTable(header []string) importTable {
+	table := importTable{columns: make(map[string]int, len(header))}
+	for i, name := range header {
+		table.columns[importColumn(name)] = i
+	}
+	return table
+}
+
+// readCSVTable reads a CSV with a header line, skipping blank lines. Spreadsheet exports often start
+// with a byte order mark, which is dropped. Cells quoted by csvSafeCell are read back as written.
+// Like a workbook it may have at most maxImportRows rows, header included.
+func readCSVTable(data []byte) (importTable, []models.ImportRowError) {
+	reader := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))))
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return importTable{}, []models.ImportRowError{{Line: 1, Message: "file is empty"}}
+	}
+	if err != nil {
+		return importTable{}, []models.ImportRowError{csvRowError(err)}
+	}
+
+	table := newImportTable(header)
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return importTable{}, []models.ImportRowError{csvRowError(err)}
+		}
+		if strings.TrimSpace(strings.Join(record, "")) == "" {
+			continue
+		}
+
+		line, _ := reader.FieldPos(0)
+		if len(table.rows)+1 == maxImportRows {
+			return importTable{}, []models.ImportRowError{{Line: line, Message: fmt.Sprintf("file has more than %d rows", maxImportRows)}}
+		}
+		for j := range record {
+			record[j] = csvUnsafeCell(record[j])
+		}
+		table.rows = append(table.rows, record)
+		table.lines = append(table.lines, line)
+	}
+	return table, nil
+}
+
+// readXLSXTable reads the first sheet of a workbook, its first filled row being the header. Lines
+// are the row numbers the spreadsheet shows. A zip may unzip to a few times the upload limit.
+func readXLSXTable(data []byte) (importTable, []models.ImportRowError) {
+	rows, lines, err := utils.ReadXLSX(data, maxImportUnzippedBytes, maxImportRows)
+	if err != nil {
+		return importTable{}, []models.ImportRowError{{Line: 1, Message: err.Error()}}
+	}
+	if len(rows) == 0 {
+		return importTable{}, []models.ImportRowError{{Line: 1, Message: "file is empty"}}
+	}
+
+	table := newImportTable(rows[0])
+	table.rows = rows[1:]
+	table.lines = lines[1:]
+	return table, nil
+}
+
+// readImportTable reads a CSV or XLSX upload.
+func readImportTable(data []byte, format string) (importTable, []models.ImportRowError) {
+	if format == importFormatXLSX {
+		return readXLSXTable(data)
+	}
+	return readCSVTable(data)
+}
+
+// csvFormulaPrefixes start a formula when a spreadsheet opens a CSV, a catalog description such as
+// =HYPERLINK(...) would run on the machine of whoever opens the export.
+const csvFormulaPrefixes = "=+-@\t\r"
+
+// csvSafeCell quotes a cell that would start a formula, as spreadsheets themselves do.
+func csvSafeCell(value string) string {
+	if value != "" && strings.ContainsRune(csvFormulaPrefixes, rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
+
+// csvUnsafeCell undoes csvSafeCell, so an exported sheet imports back unchanged.
+func csvUnsafeCell(value string) string {
+	if len(value) > 1 && value[0] == '\'' && strings.ContainsRune(csvFormulaPrefixes, rune(value[1])) {
+		return value[1:]
+	}
+	return value
+}
+
+func csvRowError(err error) models.ImportRowError {
+	var parseErr *csv.ParseError
+	if errors.As(err, &parseErr) {
+		return models.ImportRowError{Line: parseErr.Line, Message: parseErr.Err.Error()}
+	}
+	return models.ImportRowError{Line: 1, Message: err.Error()}
+}
@@ -0,0 +1,79 @@
+package server
+
+import (
+	"context"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/vijaygniit/ApnaSabji/utils"
+)
+
+func accountDeletionGracePeriod() time.Duration {
+	return time.Duration(utils.GetEnvInt("ACCOUNT_DELETION_GRACE_DAYS", defaultAccountDeletionGraceDays)) * 24 * time.Hour
+}
+
+// startBackgroundJobs runs the periodic jobs until ctx is cancelled by Stop.
+func (srv *Server) startBackgroundJobs(ctx context.Context) {
+	go srv.runEvery(ctx, time.Duration(utils.GetEnvInt("ACCOUNT_PURGE_INTERVAL_MINUTES", defaultAccountPurgeIntervalMinutes))*time.Minute, func() {
+		srv.purgeDeletedAccounts()
+		srv.purgeAuthEvents()
+	})
+	go srv.runEvery(ctx, time.Duration(utils.GetEnvInt("EXPORT_WORKER_INTERVAL_SECONDS", defaultExportWorkerIntervalSecs))*time.Second, func() {
+		srv.processDataExports()
+		srv.expireDataExports()
+	})
+	go srv.runEvery(ctx, rateLimitCleanupInterval, srv.cleanupRateLimits)
+	go srv.runEvery(ctx, productViewCleanupInterval, srv.purgeProductViews)
+}
+
+func (srv *Server) runEvery(ctx context.Context, interval time.Duration, job func()) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	job()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			job()
+		}
+	}
+}
+
+func (srv *Server) purgeDeletedAccounts() {
+	purged, err := srv.DBHelper.PurgeDeletedAccounts(accountDeletionGracePeriod())
+	if err != nil {
+		logrus.Error("purgeDeletedAccounts: error purging accounts ", err)
+		return
+	}
+	if purged > 0 {
+		logrus.Infof("purgeDeletedAccounts: anonymized %d deleted accounts", purged)
+	}
+}
+
+// purgeAuthEvents keeps the audit log for AUTH_EVENT_RETENTION_DAYS.
+func (srv *Server) purgeAuthEvents() {
+	retention := time.Duration(utils.GetEnvInt("AUTH_EVENT_RETENTION_DAYS", defaultAuthEventRetentionDays)) * 24 * time.Hour
+	purged, err := srv.DBHelper.PurgeAuthEvents(retention)
+	if err != nil {
+		logrus.Error("purgeAuthEvents: error purging auth events ", err)
+		return
+	}
+	if purged > 0 {
+		logrus.Infof("purgeAuthEvents: deleted %d auth events", purged)
+	}
+}
+
+func (srv *Server) cleanupRateLimits() {
+	if err := srv.RateLimits.Cleanup(rateLimitRetention); err != nil {
+		logrus.Error("cleanupRateLimits: error cleaning up rate limits ", err)
+	}
+}
+
+// purgeProductViews keeps yesterday's views, so a view just after midnight UTC is still told apart.
+func (srv *Server) purgeProductViews() {
+	if _, err := srv.DBHelper.PurgeProductViews(time.Now().UTC().AddDate(0, 0, -1)); err != nil {
+		logrus.Error("purgeProductViews: error purging product views ", err)
+	}
+}
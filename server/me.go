@@ -0,0 +1,241 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"github.com/vijaygniit/ApnaSabji/models"
+	"github.com/vijaygniit/ApnaSabji/scmerrors"
+	"github.com/vijaygniit/ApnaSabji/utils"
+	"github.com/volatiletech/null"
+)
+
+var supportedLanguages = map[string]bool{
+	"en": true, "hi": true, "bn": true, "gu": true, "kn": true,
+	"ml": true, "mr": true, "pa": true, "ta": true, "te": true,
+}
+
+func (srv *Server) getProfile(resp http.ResponseWriter, req *http.Request) {
+	uc := srv.MiddlewareProvider.UserFromContext(req.Context())
+
+	profile, err := srv.DBHelper.GetUserProfile(uc.UserID)
+	if err != nil {
+		logrus.Error("getProfile: error getting profile ", err)
+		scmerrors.RespondGenericServerErr(resp, err, "error getting profile")
+		return
+	}
+
+	utils.EncodeJSONBody(resp, http.StatusOK, map[string]interface{}{
+		"profile": profile,
+		"roles":   uc.Roles,
+	})
+}
+
+// updateProfile applies profile fields right away. A new email or phone number only
+// takes effect once the OTP sent to it is confirmed through verifyContactChange.
+func (srv *Server) updateProfile(resp http.ResponseWriter, req *http.Request) {
+	uc := srv.MiddlewareProvider.UserFromContext(req.Context())
+
+	var updateReq models.UpdateProfileRequest
+	if err := json.NewDecoder(req.Body).Decode(&updateReq); err != nil {
+		scmerrors.RespondClientErr(resp, err, http.StatusBadRequest, "Error updating profile", "Error parsing request")
+		return
+	}
+
+	if updateReq.Fullname.Valid && strings.TrimSpace(updateReq.Fullname.String) == "" {
+		scmerrors.RespondClientErr(resp, errors.New("name cannot be empty"), http.StatusBadRequest, "Name cannot be empty", "Name cannot be empty")
+		return
+	}
+
+	// an empty avatarUrl clears the picture like null does
+	updateReq.AvatarURL.Value.String = strings.TrimSpace(updateReq.AvatarURL.Value.String)
+	if updateReq.AvatarURL.Value.String == "" {
+		updateReq.AvatarURL.Value = null.String{}
+	}
+
+	if updateReq.PreferredLanguage.Valid && !supportedLanguages[updateReq.PreferredLanguage.String] {
+		scmerrors.RespondClientErr(resp, errors.New("unsupported language"), http.StatusBadRequest, "This language is not supported yet", "preferredLanguage must be one of the supported language codes")
+		return
+	}
+
+	if updateReq.DietaryPreferences != nil {
+		for _, preference := range *updateReq.DietaryPreferences {
+			if !models.DietaryPreference(preference).IsValid() {
+				scmerrors.RespondClientErr(resp, errors.New("invalid dietary preference"), http.StatusBadRequest, "Invalid dietary preference", "unknown dietary preference "+preference)
+				return
+			}
+		}
+	}
+
+	// email and phone changes are validated up front so a rejected change does not leave a half applied update
+	contactChanges := make(map[models.OTPPurpose]string)
+
+	if updateReq.Email.Valid {
+		email, err := utils.NormalizeEmail(updateReq.Email.String)
+		if err != nil {
+			scmerrors.RespondClientErr(resp, err, http.StatusBadRequest, "Please enter a valid email address", "email must be an address such as name@example.com")
+			return
+		}
+		if email != strings.ToLower(uc.Email) {
+			contactChanges[models.OTPPurposeEmailChange] = email
+		}
+	}
+
+	if updateReq.Mobilenumber.Valid {
+		phoneNumber, err := utils.NormalizePhoneNumber(updateReq.Mobilenumber.String)
+		if err != nil {
+			scmerrors.RespondClientErr(resp, err, http.StatusBadRequest, "Invalid phone number", "Invalid phone number")
+			return
+		}
+		if phoneNumber != uc.Mobilenumber {
+			contactChanges[models.OTPPurposePhoneChange] = phoneNumber
+		}
+	}
+
+	for purpose, target := range contactChanges {
+		isTaken, err := srv.isContactTaken(purpose, target)
+		if err != nil {
+			scmerrors.RespondGenericServerErr(resp, err, "error checking contact")
+			return
+		}
+		if isTaken {
+			scmerrors.RespondClientErr(resp, errors.New("contact already in use"), http.StatusBadRequest, "This is already linked with one of our accounts", "email or phone already exists")
+			return
+		}
+	}
+
+	if err := srv.DBHelper.UpdateUserProfile(uc.UserID, updateReq); err != nil {
+		logrus.Error("updateProfile: error updating profile ", err)
+		scmerrors.RespondGenericServerErr(resp, err, "error updating profile")
+		return
+	}
+
+	pendingVerification := make([]models.OTPPurpose, 0)
+	for purpose, target := range contactChanges {
+		if err := srv.sendContactChangeOTP(uc.UserID, purpose, target); err != nil {
+			logrus.Error("updateProfile: error sending contact change otp ", err)
+			scmerrors.RespondGenericServerErr(resp, err, "error sending otp")
+			return
+		}
+		pendingVerification = append(pendingVerification, purpose)
+	}
+
+	profile, err := srv.DBHelper.GetUserProfile(uc.UserID)
+	if err != nil {
+		logrus.Error("updateProfile: error getting profile ", err)
+		scmerrors.RespondGenericServerErr(resp, err, "error getting profile")
+		return
+	}
+
+	utils.EncodeJSONBody(resp, http.StatusOK, map[string]interface{}{
+		"profile":             profile,
+		"pendingVerification": pendingVerification,
+	})
+}
+
+// sendContactChangeOTP sends an OTP to a new email or phone number to prove the user owns it.
+func (srv *Server) sendContactChangeOTP(userID int, purpose models.OTPPurpose, target string) error {
+	otpReq := newOTPRequest(purpose)
+	otpReq.UserID = userID
+	otpReq.Channel = contactChangeChannel(purpose)
+	otpReq.Target = target
+
+	otp, err := srv.DBHelper.GenerateAndStoreOTP(otpReq)
+	if err != nil {
+		return err
+	}
+
+	return srv.deliverOTP(otpReq, otp)
+}
+
+// contactChangeChannel is the channel a contact change OTP goes out on, the new contact itself.
+func contactChangeChannel(purpose models.OTPPurpose) models.OTPChannel {
+	if purpose == models.OTPPurposeEmailChange {
+		return models.OTPChannelEmail
+	}
+	return models.OTPChannelPhone
+}
+
+func (srv *Server) verifyContactChange(resp http.ResponseWriter, req *http.Request) {
+	uc := srv.MiddlewareProvider.UserFromContext(req.Context())
+
+	var verifyReq models.VerifyContactChangeRequest
+	if err := json.NewDecoder(req.Body).Decode(&verifyReq); err != nil {
+		scmerrors.RespondClientErr(resp, err, http.StatusBadRequest, "Error verifying otp", "Error parsing request")
+		return
+	}
+
+	if verifyReq.Purpose != models.OTPPurposeEmailChange && verifyReq.Purpose != models.OTPPurposePhoneChange {
+		scmerrors.RespondClientErr(resp, errors.New("invalid purpose"), http.StatusBadRequest, "Invalid request", "purpose must be email_change or phone_change")
+		return
+	}
+
+	if verifyReq.OTP == "" {
+		scmerrors.RespondClientErr(resp, errors.New("otp can not be empty"), http.StatusBadRequest, "Empty otp!", "otp field can not be empty")
+		return
+	}
+
+	target, err := srv.DBHelper.VerifyOTP(uc.UserID, verifyReq.Purpose, contactChangeChannel(verifyReq.Purpose), verifyReq.OTP)
+	if err != nil {
+		scmerrors.RespondOTPErr(resp, err)
+		return
+	}
+
+	// someone may have claimed the address while the OTP was in flight
+	isTaken, err := srv.isContactTaken(verifyReq.Purpose, target)
+	if err != nil {
+		scmerrors.RespondGenericServerErr(resp, err, "error checking contact")
+		return
+	}
+	if isTaken {
+		scmerrors.RespondClientErr(resp, errors.New("contact already in use"), http.StatusBadRequest, "This is already linked with one of our accounts", "email or phone already exists")
+		return
+	}
+
+	if err := srv.DBHelper.UpdateUserContact(uc.UserID, verifyReq.Purpose, target); err != nil {
+		scmerrors.RespondGenericServerErr(resp, err, "error updating contact")
+		return
+	}
+
+	profile, err := srv.DBHelper.GetUserProfile(uc.UserID)
+	if err != nil {
+		logrus.Error("verifyContactChange: error getting profile ", err)
+		scmerrors.RespondGenericServerErr(resp, err, "error getting profile")
+		return
+	}
+
+	utils.EncodeJSONBody(resp, http.StatusOK, map[string]interface{}{
+		"profile": profile,
+	})
+}
+
+func (srv *Server) isContactTaken(purpose models.OTPPurpose, target string) (bool, error) {
+	if purpose == models.OTPPurposeEmailChange {
+		isUserExist, _, err := srv.DBHelper.IsUserAlreadyExists(target)
+		return isUserExist, err
+	}
+	return srv.DBHelper.IsPhoneNumberAlreadyExist(target)
+}
+
+// deleteAccount soft deletes the caller's account. It can be restored by support until the grace period runs out.
+func (srv *Server) deleteAccount(resp http.ResponseWriter, req *http.Request) {
+	uc := srv.MiddlewareProvider.UserFromContext(req.Context())
+
+	err := srv.DBHelper.DeleteUserAccount(models.AccountAction{
+		UserID:  uc.UserID,
+		ActorID: uc.UserID,
+	})
+	if err != nil {
+		logrus.Error("deleteAccount: error deleting account ", err)
+		scmerrors.RespondGenericServerErr(resp, err, "error deleting account")
+		return
+	}
+
+	utils.EncodeJSONBody(resp, http.StatusOK, map[string]interface{}{
+		"message":         "success",
+		"gracePeriodDays": utils.GetEnvInt("ACCOUNT_DELETION_GRACE_DAYS", defaultAccountDeletionGraceDays),
+	})
+}
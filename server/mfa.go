@@ -0,0 +1,162 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/sirupsen/logrus"
+	"github.com/vijaygniit/ApnaSabji/models"
+	"github.com/vijaygniit/ApnaSabji/providers/authProvider"
+	"github.com/vijaygniit/ApnaSabji/scmerrors"
+	"github.com/vijaygniit/ApnaSabji/utils"
+	"github.com/volatiletech/null"
+)
+
+// mfaRoles can change prices or issue refunds, their sessions must pass TOTP before reaching their routes.
+var mfaRoles = []models.Role{models.RoleAdmin, models.RoleVendor}
+
+// isMFARequired reports whether the user holds a role that needs a second factor.
+func (srv *Server) isMFARequired(userID int) (bool, error) {
+	roles, _, err := srv.DBHelper.GetUserRolesAndPermissions(userID)
+	if err != nil {
+		return false, err
+	}
+
+	for _, role := range mfaRoles {
+		if contains(roles, string(role)) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (srv *Server) getMFAStatus(resp http.ResponseWriter, req *http.Request) {
+	uc := srv.MiddlewareProvider.UserFromContext(req.Context())
+
+	status, err := srv.DBHelper.GetMFAStatus(uc.UserID)
+	if err != nil {
+		logrus.Error("getMFAStatus: error getting mfa status ", err)
+		scmerrors.RespondGenericServerErr(resp, err, "error getting mfa status")
+		return
+	}
+
+	utils.EncodeJSONBody(resp, http.StatusOK, map[string]interface{}{
+		"mfa":          status,
+		"mfaSatisfied": uc.MFASatisfied,
+	})
+}
+
+// enrollTOTP hands out a new secret. MFA stays off until confirmTOTP receives a code generated from it.
+func (srv *Server) enrollTOTP(resp http.ResponseWriter, req *http.Request) {
+	uc := srv.MiddlewareProvider.UserFromContext(req.Context())
+
+	secret, err := authProvider.GenerateTOTPSecret()
+	if err != nil {
+		logrus.Error("enrollTOTP: error generating secret ", err)
+		scmerrors.RespondGenericServerErr(resp, err, "error generating secret")
+		return
+	}
+
+	if err := srv.DBHelper.StartTOTPEnrollment(uc.UserID, secret); err != nil {
+		scmerrors.RespondMFAErr(resp, err)
+		return
+	}
+
+	accountName := uc.Email
+	if accountName == "" {
+		accountName = uc.Mobilenumber
+	}
+
+	utils.EncodeJSONBody(resp, http.StatusOK, map[string]interface{}{
+		"enrollment": models.TOTPEnrollment{
+			Secret:     secret,
+			OTPAuthURI: authProvider.TOTPAuthURI(accountName, secret),
+		},
+	})
+}
+
+func (srv *Server) confirmTOTP(resp http.ResponseWriter, req *http.Request) {
+	uc := srv.MiddlewareProvider.UserFromContext(req.Context())
+
+	var verifyReq models.MFAVerifyRequest
+	if err := json.NewDecoder(req.Body).Decode(&verifyReq); err != nil {
+		scmerrors.RespondClientErr(resp, err, http.StatusBadRequest, "Error confirming two-factor authentication", "Error parsing request")
+		return
+	}
+
+	if verifyReq.Code == "" {
+		scmerrors.RespondClientErr(resp, errors.New("code can not be empty"), http.StatusBadRequest, "Please enter the code from your authenticator app", "code can not be empty")
+		return
+	}
+
+	recoveryCodes, err := srv.DBHelper.ConfirmTOTPEnrollment(uc.UserID, uc.SessionID, verifyReq.Code)
+	if err != nil {
+		srv.recordAuthEvent(req, models.AuthEvent{UserID: null.IntFrom(uc.UserID), EventType: models.AuthEventMFAEnroll, Outcome: models.AuthEventFailure, Reason: err.Error()})
+		scmerrors.RespondMFAErr(resp, err)
+		return
+	}
+
+	srv.recordAuthEvent(req, models.AuthEvent{UserID: null.IntFrom(uc.UserID), EventType: models.AuthEventMFAEnroll, Outcome: models.AuthEventSuccess})
+
+	utils.EncodeJSONBody(resp, http.StatusOK, map[string]interface{}{
+		"recoveryCodes": recoveryCodes,
+	})
+}
+
+// verifyMFA is the second login step for users in mfaRoles, it upgrades the current session.
+func (srv *Server) verifyMFA(resp http.ResponseWriter, req *http.Request) {
+	uc := srv.MiddlewareProvider.UserFromContext(req.Context())
+
+	var verifyReq models.MFAVerifyRequest
+	if err := json.NewDecoder(req.Body).Decode(&verifyReq); err != nil {
+		scmerrors.RespondClientErr(resp, err, http.StatusBadRequest, "Error verifying code", "Error parsing request")
+		return
+	}
+
+	if err := srv.DBHelper.VerifyMFA(uc.UserID, uc.SessionID, verifyReq); err != nil {
+		srv.recordAuthEvent(req, models.AuthEvent{UserID: null.IntFrom(uc.UserID), EventType: models.AuthEventMFAVerify, Outcome: models.AuthEventFailure, Reason: err.Error()})
+		scmerrors.RespondMFAErr(resp, err)
+		return
+	}
+
+	reason := "totp"
+	if verifyReq.Code == "" {
+		reason = "recovery code"
+	}
+	srv.recordAuthEvent(req, models.AuthEvent{UserID: null.IntFrom(uc.UserID), EventType: models.AuthEventMFAVerify, Outcome: models.AuthEventSuccess, Reason: reason})
+
+	utils.EncodeJSONBody(resp, http.StatusOK, map[string]interface{}{
+		"message": "success",
+	})
+}
+
+func (srv *Server) regenerateRecoveryCodes(resp http.ResponseWriter, req *http.Request) {
+	uc := srv.MiddlewareProvider.UserFromContext(req.Context())
+
+	recoveryCodes, err := srv.DBHelper.RegenerateRecoveryCodes(uc.UserID)
+	if err != nil {
+		logrus.Error("regenerateRecoveryCodes: error generating recovery codes ", err)
+		scmerrors.RespondGenericServerErr(resp, err, "error generating recovery codes")
+		return
+	}
+
+	utils.EncodeJSONBody(resp, http.StatusOK, map[string]interface{}{
+		"recoveryCodes": recoveryCodes,
+	})
+}
+
+func (srv *Server) disableMFA(resp http.ResponseWriter, req *http.Request) {
+	uc := srv.MiddlewareProvider.UserFromContext(req.Context())
+
+	if err := srv.DBHelper.DisableMFA(uc.UserID); err != nil {
+		scmerrors.RespondMFAErr(resp, err)
+		return
+	}
+
+	srv.recordAuthEvent(req, models.AuthEvent{UserID: null.IntFrom(uc.UserID), EventType: models.AuthEventMFADisable, Outcome: models.AuthEventSuccess})
+
+	utils.EncodeJSONBody(resp, http.StatusOK, map[string]interface{}{
+		"message": "success",
+	})
+}
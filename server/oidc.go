@@ -0,0 +1,169 @@
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/sirupsen/logrus"
+	"github.com/vijaygniit/ApnaSabji/models"
+	"github.com/vijaygniit/ApnaSabji/providers"
+	"github.com/vijaygniit/ApnaSabji/providers/oidcprovider"
+	"github.com/vijaygniit/ApnaSabji/scmerrors"
+	"github.com/vijaygniit/ApnaSabji/utils"
+	"github.com/volatiletech/null"
+)
+
+// newIdentityProviders builds the providers listed in OIDC_PROVIDERS, e.g. "google", each configured
+// through OIDC_<NAME>_ISSUER, _CLIENT_ID, _CLIENT_SECRET, _REDIRECT_URL and optionally _SCOPES.
+func newIdentityProviders() map[string]providers.IdentityProvider {
+	identityProviders := make(map[string]providers.IdentityProvider)
+
+	for _, name := range strings.Split(os.Getenv("OIDC_PROVIDERS"), ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+
+		prefix := "OIDC_" + strings.ToUpper(name) + "_"
+		cfg := oidcprovider.Config{
+			Name:         name,
+			Issuer:       os.Getenv(prefix + "ISSUER"),
+			ClientID:     os.Getenv(prefix + "CLIENT_ID"),
+			ClientSecret: os.Getenv(prefix + "CLIENT_SECRET"),
+			RedirectURL:  os.Getenv(prefix + "REDIRECT_URL"),
+			Scopes:       os.Getenv(prefix + "SCOPES"),
+		}
+		if cfg.Issuer == "" || cfg.ClientID == "" || cfg.RedirectURL == "" {
+			logrus.Errorf("newIdentityProviders: %s is missing its issuer, client id or redirect url, skipping", name)
+			continue
+		}
+		identityProviders[name] = oidcprovider.NewOIDCProvider(cfg)
+	}
+
+	return identityProviders
+}
+
+func (srv *Server) identityProviderFromURL(resp http.ResponseWriter, req *http.Request) (providers.IdentityProvider, bool) {
+	identityProvider, ok := srv.IdentityProviders[chi.URLParam(req, "provider")]
+	if !ok {
+		scmerrors.RespondOIDCErr(resp, scmerrors.ErrOIDCProviderUnknown)
+		return nil, false
+	}
+	return identityProvider, true
+}
+
+// startOIDCLogin returns the provider's sign in URL. The device fields are passed as query params
+// and kept with the state so the session can be opened for the right device on callback.
+func (srv *Server) startOIDCLogin(resp http.ResponseWriter, req *http.Request) {
+	identityProvider, ok := srv.identityProviderFromURL(resp, req)
+	if !ok {
+		return
+	}
+
+	query := req.URL.Query()
+	loginState := models.OIDCLoginState{
+		Provider: identityProvider.Name(),
+		CreateSessionRequest: models.CreateSessionRequest{
+			Platform:  query.Get("platform"),
+			ModelName: query.Get("modelName"),
+			OSVersion: query.Get("osVersion"),
+			DeviceID:  query.Get("deviceId"),
+		},
+		ExpiresAt: time.Now().UTC().Add(time.Duration(utils.GetEnvInt("OIDC_STATE_TTL_MINUTES", defaultOIDCStateTTLMinutes)) * time.Minute),
+	}
+	state, err := srv.DBHelper.CreateOIDCLoginState(&loginState)
+	if err != nil {
+		scmerrors.RespondGenericServerErr(resp, err, "error storing state")
+		return
+	}
+
+	challenge := sha256.Sum256([]byte(loginState.CodeVerifier))
+	authorizationURL, err := identityProvider.AuthCodeURL(state, loginState.Nonce, base64.RawURLEncoding.EncodeToString(challenge[:]))
+	if err != nil {
+		logrus.Error("startOIDCLogin: error building authorization url ", err)
+		scmerrors.RespondGenericServerErr(resp, err, "error contacting identity provider")
+		return
+	}
+
+	utils.EncodeJSONBody(resp, http.StatusOK, map[string]interface{}{
+		"authorizationUrl": authorizationURL,
+		"state":            state,
+	})
+}
+
+// oidcCallback finishes the login. Browsers arrive with a GET carrying code and state in the query,
+// apps that catch the redirect themselves POST them as JSON.
+func (srv *Server) oidcCallback(resp http.ResponseWriter, req *http.Request) {
+	identityProvider, ok := srv.identityProviderFromURL(resp, req)
+	if !ok {
+		return
+	}
+
+	callbackReq := models.OIDCCallbackRequest{
+		State: req.URL.Query().Get("state"),
+		Code:  req.URL.Query().Get("code"),
+	}
+	if req.Method == http.MethodPost {
+		if err := json.NewDecoder(req.Body).Decode(&callbackReq); err != nil {
+			scmerrors.RespondClientErr(resp, err, http.StatusBadRequest, "Error signing in", "Error parsing request")
+			return
+		}
+	}
+
+	loginEvent := models.AuthEvent{
+		EventType: models.AuthEventLogin,
+		Outcome:   models.AuthEventFailure,
+		Reason:    identityProvider.Name(),
+	}
+
+	if providerErr := req.URL.Query().Get("error"); providerErr != "" {
+		loginEvent.Reason = identityProvider.Name() + ": " + providerErr
+		srv.recordAuthEvent(req, loginEvent)
+		scmerrors.RespondClientErr(resp, errors.New(providerErr), http.StatusBadRequest, "Sign in was cancelled", "identity provider returned an error")
+		return
+	}
+
+	if callbackReq.State == "" || callbackReq.Code == "" {
+		scmerrors.RespondClientErr(resp, errors.New("state and code are required"), http.StatusBadRequest, "Error signing in", "state and code can not be empty")
+		return
+	}
+
+	loginState, err := srv.DBHelper.ConsumeOIDCLoginState(callbackReq.State, identityProvider.Name())
+	if err != nil {
+		scmerrors.RespondOIDCErr(resp, err)
+		return
+	}
+	loginEvent.CreateSessionRequest = loginState.CreateSessionRequest
+
+	identity, err := identityProvider.Exchange(req.Context(), callbackReq.Code, loginState.CodeVerifier, loginState.Nonce)
+	if err != nil {
+		logrus.Error("oidcCallback: error exchanging code ", err)
+		loginEvent.Reason = identityProvider.Name() + ": " + err.Error()
+		srv.recordAuthEvent(req, loginEvent)
+		scmerrors.RespondOIDCErr(resp, err)
+		return
+	}
+	loginEvent.Email = identity.Email
+
+	if identity.Name == "" {
+		identity.Name = strings.Split(identity.Email, "@")[0]
+	}
+
+	userID, err := srv.DBHelper.LinkOIDCIdentity(identity)
+	if err != nil {
+		loginEvent.Reason = identityProvider.Name() + ": " + err.Error()
+		srv.recordAuthEvent(req, loginEvent)
+		scmerrors.RespondOIDCErr(resp, err)
+		return
+	}
+
+	loginEvent.UserID = null.IntFrom(userID)
+	srv.startSessionAndRespond(resp, req, userID, loginEvent)
+}
@@ -0,0 +1,136 @@
+package server
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/vijaygniit/ApnaSabji/models"
+	"github.com/vijaygniit/ApnaSabji/providers"
+)
+
+// otpTestDB holds one account and records the challenges requestOTP stores, every other helper is left unimplemented.
+type otpTestDB struct {
+	providers.DBHelperProvider
+	user       models.FetchUserData
+	challenges []models.GenerateAndStoreOTP
+}
+
+func (db *otpTestDB) GetOTPLoginUser(email, mobilenumber string) (models.FetchUserData, error) {
+	if (email != "" && email == db.user.Email) || (mobilenumber != "" && mobilenumber == db.user.Mobilenumber) {
+		return db.user, nil
+	}
+	return models.FetchUserData{}, sql.ErrNoRows
+}
+
+func (db *otpTestDB) GenerateAndStoreOTP(otpReq models.GenerateAndStoreOTP) (string, error) {
+	db.challenges = append(db.challenges, otpReq)
+	return "123456", nil
+}
+
+func (db *otpTestDB) RecordAuthEvent(event models.AuthEvent) error {
+	return nil
+}
+
+type otpTestNotifier struct {
+	emails []string
+	sms    []string
+	err    error
+}
+
+func (n *otpTestNotifier) SendEmail(to, subject, body string) error {
+	n.emails = append(n.emails, to)
+	return n.err
+}
+
+func (n *otpTestNotifier) SendSMS(to, message string) error {
+	n.sms = append(n.sms, to)
+	return n.err
+}
+
+func requestTestOTP(t *testing.T, body string, notifier *otpTestNotifier) (*httptest.ResponseRecorder, *otpTestDB) {
+	t.Helper()
+	db := &otpTestDB{user: models.FetchUserData{UserID: 7, Fullname: "Ravi", Email: "ravi@example.com", Mobilenumber: "+919812345678"}}
+	srv := &Server{DBHelper: db, Notifier: notifier}
+
+	resp := httptest.NewRecorder()
+	srv.requestOTP(resp, httptest.NewRequest(http.MethodPost, "/api/otp", strings.NewReader(body)))
+	return resp, db
+}
+
+func TestRequestOTPRejectsEmailAndPhoneTogether(t *testing.T) {
+	notifier := &otpTestNotifier{}
+	resp, db := requestTestOTP(t, `{"email": "attacker@example.com", "mobilenumber": "+919812345678"}`, notifier)
+
+	if resp.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want %d", resp.Code, http.StatusBadRequest)
+	}
+	if len(db.challenges) != 0 || len(notifier.emails) != 0 || len(notifier.sms) != 0 {
+		t.Fatalf("stored %v, emailed %v, texted %v, want nothing sent", db.challenges, notifier.emails, notifier.sms)
+	}
+}
+
+func TestRequestOTPSendsToStoredContact(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		channel models.OTPChannel
+		target  string
+	}{
+		{name: "email", body: `{"email": " Ravi@Example.com "}`, channel: models.OTPChannelEmail, target: "ravi@example.com"},
+		{name: "phone", body: `{"mobilenumber": "09812345678"}`, channel: models.OTPChannelPhone, target: "+919812345678"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			notifier := &otpTestNotifier{}
+			resp, db := requestTestOTP(t, tt.body, notifier)
+
+			if resp.Code != http.StatusOK {
+				t.Fatalf("status = %d, want %d", resp.Code, http.StatusOK)
+			}
+			if len(db.challenges) != 1 {
+				t.Fatalf("stored %d challenges, want 1", len(db.challenges))
+			}
+			challenge := db.challenges[0]
+			if challenge.Channel != tt.channel || challenge.Target != tt.target {
+				t.Errorf("challenge sent over %q to %q, want %q to %q", challenge.Channel, challenge.Target, tt.channel, tt.target)
+			}
+
+			sent := notifier.emails
+			if tt.channel == models.OTPChannelPhone {
+				sent = notifier.sms
+			}
+			if len(sent) != 1 || sent[0] != tt.target || len(notifier.emails)+len(notifier.sms) != 1 {
+				t.Errorf("emailed %v, texted %v, want only %q", notifier.emails, notifier.sms, tt.target)
+			}
+		})
+	}
+}
+
+// unknown accounts and failed deliveries get the same answer as a sent code, so neither tells who has an account
+func TestRequestOTPAnswersAlike(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     string
+		notifier *otpTestNotifier
+	}{
+		{name: "unknown account", body: `{"email": "nobody@example.com"}`, notifier: &otpTestNotifier{}},
+		{name: "delivery failed", body: `{"email": "ravi@example.com"}`, notifier: &otpTestNotifier{err: errors.New("smtp down")}},
+	}
+
+	sent := httptest.NewRecorder()
+	respondOTPRequested(sent)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, _ := requestTestOTP(t, tt.body, tt.notifier)
+			if resp.Code != sent.Code || resp.Body.String() != sent.Body.String() {
+				t.Errorf("got %d %s, want %d %s", resp.Code, resp.Body.String(), sent.Code, sent.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,547 @@
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/vijaygniit/ApnaSabji/models"
+	"github.com/vijaygniit/ApnaSabji/scmerrors"
+	"github.com/vijaygniit/ApnaSabji/utils"
+	"github.com/volatiletech/null"
+)
+
+// mandiDateLayouts are the date styles seen in mandi files, ISO first.
+var mandiDateLayouts = []string{"2006-01-02", "02/01/2006", "02-01-2006", "02-Jan-2006"}
+
+// mandiUnits are wholesale units that are not sold in the shop and so are not in the units table.
+var mandiUnits = map[string]models.Unit{
+	"quintal": {Code: "quintal", BaseUnit: "g", BaseQuantity: 100000},
+	"qtl":     {Code: "quintal", BaseUnit: "g", BaseQuantity: 100000},
+	"tonne":   {Code: "tonne", BaseUnit: "g", BaseQuantity: 1000000},
+}
+
+// mandiName makes commodity and market names from files and rules compare equal regardless of case
+// and spacing.
+func mandiName(name string) string {
+	return strings.Join(strings.Fields(strings.ToLower(name)), " ")
+}
+
+// parseRupees reads an amount such as "2,150.50" into paise without going through floats.
+func parseRupees(amount string) (int64, error) {
+	amount = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(amount), "₹"))
+	amount = strings.ReplaceAll(amount, ",", "")
+
+	rupeesPart, paisePart, _ := strings.Cut(amount, ".")
+	if rupeesPart == "" || len(paisePart) > 2 {
+		return 0, errors.New("must be an amount in rupees with at most 2 decimals")
+	}
+
+	rupees, err := strconv.ParseUint(rupeesPart, 10, 32)
+	if err != nil {
+		return 0, errors.New("must be an amount in rupees with at most 2 decimals")
+	}
+
+	var paise uint64
+	if paisePart != "" {
+		if paise, err = strconv.ParseUint(paisePart, 10, 8); err != nil {
+			return 0, errors.New("must be an amount in rupees with at most 2 decimals")
+		}
+		if len(paisePart) == 1 {
+			paise *= 10
+		}
+	}
+	return int64(rupees*100 + paise), nil
+}
+
+func parseMandiDate(date string) (time.Time, error) {
+	for _, layout := range mandiDateLayouts {
+		if parsed, err := time.Parse(layout, date); err == nil {
+			return parsed, nil
+		}
+	}
+	return time.Time{}, errors.New("must be a date such as 2024-01-31 or 31/01/2024")
+}
+
+// mandiPriceFromRow validates one entry of a mandi file, reporting every problem of the row at once.
+func mandiPriceFromRow(line int, row models.MandiPriceRow, units map[string]models.Unit) (models.MandiPrice, []models.ImportRowError) {
+	rowErrors := make([]models.ImportRowError, 0)
+	fail := func(field, message string) {
+		rowErrors = append(rowErrors, models.ImportRowError{Line: line, Field: field, Message: message})
+	}
+
+	price := models.MandiPrice{
+		Commodity: mandiName(row.Commodity),
+		Market:    mandiName(row.Market),
+	}
+
+	date, err := parseMandiDate(strings.TrimSpace(row.Date))
+	if err != nil {
+		fail("date", err.Error())
+	}
+	price.PriceDate = date
+
+	if price.Commodity == "" || len(price.Commodity) > maxMandiCommodityLength {
+		fail("commodity", "is required and at most 100 characters")
+	}
+
+	unit, ok := units[strings.ToLower(strings.TrimSpace(row.Unit))]
+	if !ok {
+		fail("unit", "must be quintal or a unit code such as kg")
+	}
+	price.UnitCode = unit.Code
+	price.BaseUnit = unit.BaseUnit
+	price.BaseQuantity = unit.BaseQuantity
+
+	modalPrice, err := parseRupees(row.ModalPrice)
+	if err == nil && modalPrice == 0 {
+		err = errors.New("must be more than zero")
+	}
+	if err != nil {
+		fail("modal_price", err.Error())
+	}
+	price.ModalPricePaise = modalPrice
+
+	return price, rowErrors
+}
+
+// mandiRows reads the uploaded file. CSV lines are counted from the header, JSON entries from 1.
+func mandiRows(data []byte, format string) (rows []models.MandiPriceRow, lines []int, rowErrors []models.ImportRowError) {
+	if format == importFormatJSON {
+		if err := json.Unmarshal(data, &rows); err != nil {
+			return nil, nil, []models.ImportRowError{{Line: 1, Message: "file must be a JSON array of prices: " + err.Error()}}
+		}
+		for i := range rows {
+			lines = append(lines, i+1)
+		}
+		return rows, lines, nil
+	}
+
+	table, rowErrors := readCSVTable(data)
+	if rowErrors != nil {
+		return nil, nil, rowErrors
+	}
+	if rowErrors = table.missingColumns("date", "commodity", "unit", "modal_price"); len(rowErrors) > 0 {
+		return nil, nil, rowErrors
+	}
+
+	for i := range table.rows {
+		rows = append(rows, models.MandiPriceRow{
+			Date:       table.value(i, "date"),
+			Commodity:  table.value(i, "commodity"),
+			Market:     table.value(i, "market"),
+			Unit:       table.value(i, "unit"),
+			ModalPrice: table.value(i, "modal_price"),
+		})
+	}
+	return rows, table.lines, nil
+}
+
+// importMandiPrices reads the day's wholesale rates from a CSV with the columns date, commodity, market,
+// unit and modal_price, or from a JSON array. A file with any bad row is rejected as a whole so a
+// half imported day never drives prices.
+func (srv *Server) importMandiPrices(resp http.ResponseWriter, req *http.Request) {
+	uc := srv.MiddlewareProvider.UserFromContext(req.Context())
+
+	data, format, ok := importFile(resp, req, importFormatCSV, importFormatJSON)
+	if !ok {
+		return
+	}
+
+	rows, lines, rowErrors := mandiRows(data, format)
+	if len(rowErrors) > 0 {
+		scmerrors.RespondImportErr(resp, rowErrors)
+		return
+	}
+	if len(rows) == 0 {
+		scmerrors.RespondImportErr(resp, []models.ImportRowError{{Line: 1, Message: "file has no prices"}})
+		return
+	}
+
+	shopUnits, err := srv.DBHelper.ListUnits()
+	if err != nil {
+		scmerrors.RespondGenericServerErr(resp, err, "error getting units")
+		return
+	}
+
+	units := make(map[string]models.Unit, len(shopUnits)+len(mandiUnits))
+	for _, unit := range shopUnits {
+		units[strings.ToLower(unit.Code)] = unit
+	}
+	for code, unit := range mandiUnits {
+		units[code] = unit
+	}
+
+	prices := make([]models.MandiPrice, 0, len(rows))
+	seen := make(map[string]int, len(rows))
+	for i, row := range rows {
+		price, priceErrors := mandiPriceFromRow(lines[i], row, units)
+		rowErrors = append(rowErrors, priceErrors...)
+		if len(priceErrors) > 0 {
+			continue
+		}
+
+		key := price.PriceDate.Format("2006-01-02") + "|" + price.Commodity + "|" + price.Market
+		if first, ok := seen[key]; ok {
+			rowErrors = append(rowErrors, models.ImportRowError{Line: lines[i], Message: fmt.Sprintf("repeats the price on line %d", first)})
+			continue
+		}
+		seen[key] = lines[i]
+		prices = append(prices, price)
+	}
+
+	if len(rowErrors) > 0 {
+		scmerrors.RespondImportErr(resp, rowErrors)
+		return
+	}
+
+	if err := srv.DBHelper.ImportMandiPrices(prices, uc.UserID); err != nil {
+		scmerrors.RespondGenericServerErr(resp, err, "error importing mandi prices")
+		return
+	}
+
+	utils.EncodeJSONBody(resp, http.StatusCreated, map[string]interface{}{
+		"imported": len(prices),
+	})
+}
+
+// pricingDate reads ?date, defaulting to today.
+func pricingDate(resp http.ResponseWriter, req *http.Request) (time.Time, bool) {
+	date := req.URL.Query().Get("date")
+	if date == "" {
+		return time.Now().UTC(), true
+	}
+
+	parsed, err := time.Parse("2006-01-02", date)
+	if err != nil {
+		scmerrors.RespondClientErr(resp, err, http.StatusBadRequest, "Please choose a valid date", "date must be YYYY-MM-DD")
+		return time.Time{}, false
+	}
+	return parsed, true
+}
+
+// listMandiPrices returns the rates of ?date, or of the latest imported day.
+func (srv *Server) listMandiPrices(resp http.ResponseWriter, req *http.Request) {
+	var date null.Time
+	if req.URL.Query().Get("date") != "" {
+		parsed, ok := pricingDate(resp, req)
+		if !ok {
+			return
+		}
+		date = null.TimeFrom(parsed)
+	}
+
+	prices, err := srv.DBHelper.ListMandiPrices(date)
+	if err != nil {
+		scmerrors.RespondGenericServerErr(resp, err, "error getting mandi prices")
+		return
+	}
+
+	utils.EncodeJSONBody(resp, http.StatusOK, map[string]interface{}{
+		"prices": prices,
+	})
+}
+
+func (srv *Server) listPricingRules(resp http.ResponseWriter, req *http.Request) {
+	rules, err := srv.DBHelper.ListPricingRules()
+	if err != nil {
+		scmerrors.RespondGenericServerErr(resp, err, "error getting pricing rules")
+		return
+	}
+
+	utils.EncodeJSONBody(resp, http.StatusOK, map[string]interface{}{
+		"rules": rules,
+	})
+}
+
+// upsertPricingRule sets how a product is priced from the mandi. Floor and ceiling are per one unitCode,
+// kg when not given, and rounding defaults to whole rupees.
+func (srv *Server) upsertPricingRule(resp http.ResponseWriter, req *http.Request) {
+	uc := srv.MiddlewareProvider.UserFromContext(req.Context())
+
+	productID, ok := catalogIDFromURL(resp, req, "product")
+	if !ok {
+		return
+	}
+
+	var ruleReq models.PricingRuleRequest
+	if err := json.NewDecoder(req.Body).Decode(&ruleReq); err != nil {
+		scmerrors.RespondClientErr(resp, err, http.StatusBadRequest, "Error saving pricing rule", "Error parsing request")
+		return
+	}
+
+	ruleReq.Commodity = mandiName(ruleReq.Commodity)
+	ruleReq.Market = mandiName(ruleReq.Market)
+	ruleReq.UnitCode = strings.TrimSpace(ruleReq.UnitCode)
+	if ruleReq.UnitCode == "" {
+		ruleReq.UnitCode = "kg"
+	}
+	if ruleReq.RoundingPaise == 0 {
+		ruleReq.RoundingPaise = defaultRoundingPaise
+	}
+
+	if ruleReq.Commodity == "" || len(ruleReq.Commodity) > maxMandiCommodityLength {
+		scmerrors.RespondClientErr(resp, errors.New("invalid commodity"), http.StatusBadRequest, "Please enter the mandi commodity", "commodity is required and at most 100 characters")
+		return
+	}
+
+	if ruleReq.MarkupPercent < 0 || ruleReq.MarkupPercent > maxMarkupPercent {
+		scmerrors.RespondClientErr(resp, errors.New("invalid markup"), http.StatusBadRequest, "Markup must be between 0 and 1000 percent", "markupPercent must be between 0 and 1000")
+		return
+	}
+
+	if ruleReq.RoundingPaise < 0 {
+		scmerrors.RespondClientErr(resp, errors.New("invalid rounding"), http.StatusBadRequest, "Rounding must be more than zero", "roundingPaise must be positive")
+		return
+	}
+
+	if (ruleReq.FloorPaise.Valid && ruleReq.FloorPaise.Int64 < 0) || (ruleReq.CeilingPaise.Valid && ruleReq.CeilingPaise.Int64 < 0) {
+		scmerrors.RespondClientErr(resp, errors.New("invalid limits"), http.StatusBadRequest, "Floor and ceiling can not be negative", "floorPaise and ceilingPaise must be zero or more")
+		return
+	}
+
+	if ruleReq.FloorPaise.Valid && ruleReq.CeilingPaise.Valid && ruleReq.FloorPaise.Int64 > ruleReq.CeilingPaise.Int64 {
+		scmerrors.RespondClientErr(resp, errors.New("invalid limits"), http.StatusBadRequest, "Floor can not be more than ceiling", "floorPaise must not exceed ceilingPaise")
+		return
+	}
+
+	rule, err := srv.DBHelper.UpsertPricingRule(productID, ruleReq, uc.UserID)
+	if err != nil {
+		scmerrors.RespondCatalogErr(resp, err)
+		return
+	}
+
+	utils.EncodeJSONBody(resp, http.StatusOK, map[string]interface{}{
+		"rule": rule,
+	})
+}
+
+func (srv *Server) deletePricingRule(resp http.ResponseWriter, req *http.Request) {
+	productID, ok := catalogIDFromURL(resp, req, "product")
+	if !ok {
+		return
+	}
+
+	if err := srv.DBHelper.DeletePricingRule(productID); err != nil {
+		scmerrors.RespondCatalogErr(resp, err)
+		return
+	}
+
+	utils.EncodeJSONBody(resp, http.StatusOK, map[string]interface{}{
+		"message": "success",
+	})
+}
+
+// computePrice prices one pack from the mandi rate: the wholesale cost of the pack plus markup, kept
+// within the rule's floor and ceiling scaled to the pack, rounded to the rule's step and never above MRP.
+// A pack whose MRP is below its floor is skipped rather than sold under the floor.
+func computePrice(input models.PricingInput) models.PriceChange {
+	change := models.PriceChange{
+		VariantID:      input.VariantID,
+		SKU:            input.SKU,
+		ProductName:    input.ProductName,
+		OldPricePaise:  input.PricePaise,
+		NewPricePaise:  input.PricePaise,
+		MRPPaise:       input.MRPPaise,
+		MandiPriceDate: input.MandiPriceDate,
+	}
+
+	if !input.MandiPerBasePaise.Valid {
+		change.Skipped = true
+		change.Reason = "no mandi price imported for the commodity"
+		return change
+	}
+	if input.MandiBaseUnit.String != input.VariantBaseUnit {
+		change.Skipped = true
+		change.Reason = fmt.Sprintf("mandi price is by %s but the pack is sold by %s", input.MandiBaseUnit.String, input.VariantBaseUnit)
+		return change
+	}
+
+	quantity := input.PackSize * float64(input.VariantBaseQuantity)
+	price := input.MandiPerBasePaise.Float64 * quantity * (1 + input.MarkupPercent/100)
+
+	// floor and ceiling are whole paise inside the scaled limits, a band narrower than a paisa is its floor
+	floor, ceiling := math.Inf(-1), math.Inf(1)
+	reasons := make([]string, 0)
+	if input.FloorPaise.Valid || input.CeilingPaise.Valid {
+		if input.RuleBaseUnit == input.VariantBaseUnit {
+			scale := quantity / float64(input.RuleBaseQuantity)
+			if input.FloorPaise.Valid {
+				floor = math.Ceil(float64(input.FloorPaise.Int64) * scale)
+			}
+			if input.CeilingPaise.Valid {
+				ceiling = math.Max(math.Floor(float64(input.CeilingPaise.Int64)*scale), floor)
+			}
+		} else {
+			reasons = append(reasons, "floor and ceiling ignored as the rule unit does not match the pack")
+		}
+	}
+
+	step := float64(input.RoundingPaise)
+	rounded := math.Round(price/step) * step
+	switch {
+	case rounded < floor:
+		reasons = append(reasons, "raised to floor")
+		rounded = math.Ceil(floor/step) * step
+		if rounded > ceiling {
+			rounded = floor
+		}
+	case rounded > ceiling:
+		reasons = append(reasons, "lowered to ceiling")
+		rounded = math.Floor(ceiling/step) * step
+		if rounded < floor {
+			rounded = ceiling
+		}
+	}
+
+	change.NewPricePaise = int64(rounded)
+	if change.NewPricePaise > input.MRPPaise {
+		if float64(input.MRPPaise) < floor {
+			change.NewPricePaise = input.PricePaise
+			change.Skipped = true
+			change.Reason = fmt.Sprintf("MRP %d paise is below the floor of %d paise", input.MRPPaise, int64(floor))
+			return change
+		}
+		change.NewPricePaise = input.MRPPaise
+		reasons = append(reasons, "capped at MRP")
+	}
+	if change.NewPricePaise <= 0 {
+		change.NewPricePaise = input.PricePaise
+		change.Skipped = true
+		reasons = append(reasons, "computed price is zero")
+	}
+
+	change.Reason = strings.Join(reasons, ", ")
+	return change
+}
+
+// repriceVariants computes the price of every variant under an active rule from the mandi rates
+// known on asOf.
+func (srv *Server) repriceVariants(asOf time.Time) ([]models.PriceChange, error) {
+	inputs, err := srv.DBHelper.GetPricingInputs(asOf)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := make([]models.PriceChange, 0, len(inputs))
+	for _, input := range inputs {
+		changes = append(changes, computePrice(input))
+	}
+	return changes, nil
+}
+
+// pricingFingerprint hashes every computed price, so a new mandi import, rule or price edit between the
+// preview and the apply gives another fingerprint.
+func pricingFingerprint(changes []models.PriceChange) string {
+	hash := sha256.New()
+	for _, change := range changes {
+		_, _ = fmt.Fprintf(hash, "%d:%d:%d:%d:%t\n", change.VariantID, change.OldPricePaise, change.NewPricePaise, change.MRPPaise, change.Skipped)
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// previewPricing is the dry run of applyPricing, nothing is written. Its fingerprint is passed to
+// applyPricing to apply exactly these prices.
+func (srv *Server) previewPricing(resp http.ResponseWriter, req *http.Request) {
+	asOf, ok := pricingDate(resp, req)
+	if !ok {
+		return
+	}
+
+	changes, err := srv.repriceVariants(asOf)
+	if err != nil {
+		scmerrors.RespondGenericServerErr(resp, err, "error computing prices")
+		return
+	}
+
+	changed, skipped := 0, 0
+	for _, change := range changes {
+		switch {
+		case change.Skipped:
+			skipped++
+		case change.NewPricePaise != change.OldPricePaise:
+			changed++
+		}
+	}
+
+	utils.EncodeJSONBody(resp, http.StatusOK, map[string]interface{}{
+		"changes":     changes,
+		"changed":     changed,
+		"skipped":     skipped,
+		"fingerprint": pricingFingerprint(changes),
+	})
+}
+
+// applyPricing recomputes prices exactly as the preview does and writes the ones that moved. It is
+// refused when ?fingerprint is not the preview's for the same inputs, so an admin never applies prices
+// they have not seen. Variants edited while applying are left alone, so applied may be lower than the
+// preview's changed.
+func (srv *Server) applyPricing(resp http.ResponseWriter, req *http.Request) {
+	uc := srv.MiddlewareProvider.UserFromContext(req.Context())
+
+	asOf, ok := pricingDate(resp, req)
+	if !ok {
+		return
+	}
+
+	fingerprint := req.URL.Query().Get("fingerprint")
+	if fingerprint == "" {
+		scmerrors.RespondClientErr(resp, errors.New("fingerprint missing"), http.StatusBadRequest, "Please preview the prices first", "fingerprint from POST /pricing/preview is required")
+		return
+	}
+
+	changes, err := srv.repriceVariants(asOf)
+	if err != nil {
+		scmerrors.RespondGenericServerErr(resp, err, "error computing prices")
+		return
+	}
+
+	if pricingFingerprint(changes) != fingerprint {
+		scmerrors.RespondClientErr(resp, errors.New("stale preview"), http.StatusConflict, "Prices have changed since the preview. Please preview again", "fingerprint does not match the current inputs")
+		return
+	}
+
+	applied, err := srv.DBHelper.ApplyPriceChanges(changes, models.PriceChangeSourceMandiRule, uc.UserID)
+	if err != nil {
+		scmerrors.RespondCatalogErr(resp, err)
+		return
+	}
+
+	utils.EncodeJSONBody(resp, http.StatusOK, map[string]interface{}{
+		"changes": changes,
+		"applied": applied,
+	})
+}
+
+func (srv *Server) listPriceHistory(resp http.ResponseWriter, req *http.Request) {
+	variantID, ok := catalogIDFromURL(resp, req, "variant")
+	if !ok {
+		return
+	}
+
+	if _, err := srv.DBHelper.GetProductVariant(variantID); err != nil {
+		scmerrors.RespondCatalogErr(resp, err)
+		return
+	}
+
+	page, limit, offset := pagination(req)
+	history, total, err := srv.DBHelper.ListPriceHistory(variantID, limit, offset)
+	if err != nil {
+		scmerrors.RespondGenericServerErr(resp, err, "error getting price history")
+		return
+	}
+
+	utils.EncodeJSONBody(resp, http.StatusOK, map[string]interface{}{
+		"history": history,
+		"total":   total,
+		"page":    page,
+		"limit":   limit,
+	})
+}
@@ -0,0 +1,103 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/vijaygniit/ApnaSabji/models"
+	"github.com/volatiletech/null"
+)
+
+// pricingInput is a 500 g pack bought at 2 paise a gram and sold at 20% markup, 1200 paise before any
+// rule limit, under a rule priced per kg.
+func pricingInput(edit func(input *models.PricingInput)) models.PricingInput {
+	input := models.PricingInput{
+		VariantID:           1,
+		SKU:                 "TOM-500G",
+		PackSize:            500,
+		VariantBaseUnit:     "g",
+		VariantBaseQuantity: 1,
+		MRPPaise:            3000,
+		PricePaise:          1000,
+		MarkupPercent:       20,
+		RoundingPaise:       100,
+		RuleBaseUnit:        "g",
+		RuleBaseQuantity:    1000,
+		MandiBaseUnit:       null.StringFrom("g"),
+		MandiPerBasePaise:   null.Float64From(2),
+	}
+	if edit != nil {
+		edit(&input)
+	}
+	return input
+}
+
+func TestComputePrice(t *testing.T) {
+	tests := []struct {
+		name        string
+		input       models.PricingInput
+		wantPrice   int64
+		wantSkipped bool
+		wantReason  string
+	}{
+		{"markup", pricingInput(nil), 1200, false, ""},
+		{"rounded to the step", pricingInput(func(input *models.PricingInput) {
+			input.MarkupPercent = 23
+		}), 1200, false, ""},
+		{"no mandi price", pricingInput(func(input *models.PricingInput) {
+			input.MandiPerBasePaise = null.Float64{}
+		}), 1000, true, "no mandi price imported for the commodity"},
+		{"mandi unit does not match the pack", pricingInput(func(input *models.PricingInput) {
+			input.MandiBaseUnit = null.StringFrom("pc")
+		}), 1000, true, "mandi price is by pc but the pack is sold by g"},
+		{"rule unit does not match the pack", pricingInput(func(input *models.PricingInput) {
+			input.RuleBaseUnit = "pc"
+			input.FloorPaise = null.Int64From(5000)
+		}), 1200, false, "floor and ceiling ignored as the rule unit does not match the pack"},
+		{"raised to floor", pricingInput(func(input *models.PricingInput) {
+			input.FloorPaise = null.Int64From(3000)
+		}), 1500, false, "raised to floor"},
+		{"raised to a floor off the step", pricingInput(func(input *models.PricingInput) {
+			input.FloorPaise = null.Int64From(2650)
+		}), 1400, false, "raised to floor"},
+		{"raised to floor, no step between floor and ceiling", pricingInput(func(input *models.PricingInput) {
+			input.FloorPaise = null.Int64From(2650)
+			input.CeilingPaise = null.Int64From(2750)
+		}), 1325, false, "raised to floor"},
+		{"lowered to ceiling", pricingInput(func(input *models.PricingInput) {
+			input.CeilingPaise = null.Int64From(2000)
+		}), 1000, false, "lowered to ceiling"},
+		{"lowered to a ceiling off the step", pricingInput(func(input *models.PricingInput) {
+			input.CeilingPaise = null.Int64From(2350)
+		}), 1100, false, "lowered to ceiling"},
+		{"lowered to ceiling, no step between floor and ceiling", pricingInput(func(input *models.PricingInput) {
+			input.FloorPaise = null.Int64From(2050)
+			input.CeilingPaise = null.Int64From(2150)
+		}), 1075, false, "lowered to ceiling"},
+		{"floor and ceiling within a paisa", pricingInput(func(input *models.PricingInput) {
+			input.PackSize = 333
+			input.RoundingPaise = 1
+			input.FloorPaise = null.Int64From(1001)
+			input.CeilingPaise = null.Int64From(1002)
+		}), 334, false, "lowered to ceiling"},
+		{"capped at MRP", pricingInput(func(input *models.PricingInput) {
+			input.MRPPaise = 1150
+		}), 1150, false, "capped at MRP"},
+		{"MRP below floor", pricingInput(func(input *models.PricingInput) {
+			input.MRPPaise = 1300
+			input.FloorPaise = null.Int64From(2650)
+		}), 1000, true, "MRP 1300 paise is below the floor of 1325 paise"},
+		{"computed price is zero", pricingInput(func(input *models.PricingInput) {
+			input.MandiPerBasePaise = null.Float64From(0.0001)
+		}), 1000, true, "computed price is zero"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			change := computePrice(tt.input)
+			if change.NewPricePaise != tt.wantPrice || change.Skipped != tt.wantSkipped || change.Reason != tt.wantReason {
+				t.Errorf("computePrice() = %d, skipped %v, %q, want %d, skipped %v, %q",
+					change.NewPricePaise, change.Skipped, change.Reason, tt.wantPrice, tt.wantSkipped, tt.wantReason)
+			}
+		})
+	}
+}
@@ -1,16 +1,16 @@
 package server
 
 import (
-	"crypto/rand"
-	"encoding/base64"
+	"database/sql"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"log"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/sirupsen/logrus"
-	"github.com/ttacon/libphonenumber"
 	"github.com/vijaygniit/ApnaSabji/models"
 	"github.com/vijaygniit/ApnaSabji/providers/authProvider"
 	"github.com/vijaygniit/ApnaSabji/scmerrors"
@@ -35,10 +35,10 @@ func (srv *Server) register(resp http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	// Check if the email is empty
-	if newUserReq.Email.String == "" {
-		log.Println("Email is empty")
-		scmerrors.RespondClientErr(resp, errors.New("email cannot be empty"), http.StatusBadRequest, "Email cannot be empty", "Email cannot be empty")
+	// An account needs at least one of email or phone number to log in with
+	if newUserReq.Email.String == "" && newUserReq.Mobilenumber.String == "" {
+		log.Println("Email and phone number are empty")
+		scmerrors.RespondClientErr(resp, errors.New("email or phone number is required"), http.StatusBadRequest, "Please enter an email or phone number", "email and mobilenumber can not both be empty")
 		return
 	}
 
@@ -50,25 +50,35 @@ func (srv *Server) register(resp http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	// Check if the user already exists
-	isUserExist, _, err := srv.DBHelper.IsUserAlreadyExists(newUserReq.Email.String)
-	if err != nil {
-		// Log the error when checking user existence
-		log.Printf("Error checking user existence: %v\n", err)
-		scmerrors.RespondGenericServerErr(resp, err, "Error in processing request")
-		return
-	}
+	if newUserReq.Email.String != "" {
+		email, err := utils.NormalizeEmail(newUserReq.Email.String)
+		if err != nil {
+			log.Printf("Error parsing email: %v\n", err)
+			scmerrors.RespondClientErr(resp, err, http.StatusBadRequest, "Please enter a valid email address", "email must be an address such as name@example.com")
+			return
+		}
+		newUserReq.Email = null.StringFrom(email)
+
+		// Check if the user already exists
+		isUserExist, _, err := srv.DBHelper.IsUserAlreadyExists(newUserReq.Email.String)
+		if err != nil {
+			// Log the error when checking user existence
+			log.Printf("Error checking user existence: %v\n", err)
+			scmerrors.RespondGenericServerErr(resp, err, "Error in processing request")
+			return
+		}
 
-	if isUserExist {
-		// Log the error when a user already exists
-		log.Println("User already exists with the provided email")
-		scmerrors.RespondClientErr(resp, errors.New("error creating user"), http.StatusBadRequest, "This email is already linked with one of our accounts. Please use a different email address", "Unable to create a user with a duplicate email address")
-		return
+		if isUserExist {
+			// Log the error when a user already exists
+			log.Println("User already exists with the provided email")
+			srv.recordAuthEvent(req, models.AuthEvent{EventType: models.AuthEventRegister, Outcome: models.AuthEventFailure, Reason: "email already registered", Email: newUserReq.Email.String})
+			scmerrors.RespondClientErr(resp, errors.New("error creating user"), http.StatusBadRequest, "This email is already linked with one of our accounts. Please use a different email address", "Unable to create a user with a duplicate email address")
+			return
+		}
+	} else {
+		newUserReq.Email = null.String{}
 	}
 
-	// Lowercase the email
-	newUserReq.Email.String = strings.ToLower(newUserReq.Email.String)
-
 	// Check if the name is empty again
 	if newUserReq.Fullname == "" {
 		log.Println("Name is empty")
@@ -76,66 +86,48 @@ func (srv *Server) register(resp http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	// Check if the mobile number is empty
-	if newUserReq.Mobilenumber.IsZero() {
-		log.Println("Phone number is empty")
-		scmerrors.RespondClientErr(resp, errors.New("phone number cannot be empty"), http.StatusBadRequest, "Phone number cannot be empty", "Phone number cannot be empty")
-		return
-	}
-
-	// Validate and format mobile number
-	num, err := libphonenumber.Parse(newUserReq.Mobilenumber.String, "IN")
-	if err != nil || !libphonenumber.IsValidNumber(num) {
-		log.Println("Invalid phone number")
-		scmerrors.RespondClientErr(resp, errors.New("invalid phone number"), http.StatusBadRequest, "Invalid phone number", "Invalid phone number")
-		return
-	}
-
-	uncleanPhoneNumber := newUserReq.Mobilenumber.String
-
-	// Extract the phone number from the format "+91 XXXXX"
-	if strings.Count(uncleanPhoneNumber, "+") == 2 {
-		uncleanPhoneNumber = uncleanPhoneNumber[strings.LastIndex(uncleanPhoneNumber, "+")+1:]
-	}
-
-	phone := strings.ReplaceAll(uncleanPhoneNumber, " ", "")
-
-	num, err = libphonenumber.Parse(phone, "IN")
-	if err != nil {
-		log.Printf("Error parsing phone number: %v\n", err)
-		scmerrors.RespondClientErr(resp, err, http.StatusBadRequest, "Phone Number not in a correct format", "Invalid format for phone number")
-		return
-	}
-
-	isValidNumber := libphonenumber.IsValidNumber(num)
+	if newUserReq.Mobilenumber.String != "" {
+		// Validate and format mobile number
+		phoneNumber, err := utils.NormalizePhoneNumber(newUserReq.Mobilenumber.String)
+		if err != nil {
+			log.Printf("Error parsing phone number: %v\n", err)
+			scmerrors.RespondClientErr(resp, err, http.StatusBadRequest, "Invalid phone number", "Invalid phone number")
+			return
+		}
+		newUserReq.Mobilenumber = null.StringFrom(phoneNumber)
+
+		// Check if the mobile number already exists
+		isMobileAlreadyExist, err := srv.DBHelper.IsPhoneNumberAlreadyExist(phoneNumber)
+		if err != nil {
+			// Log the error when checking mobile number existence
+			log.Printf("Error checking mobile number existence: %v\n", err)
+			scmerrors.RespondGenericServerErr(resp, err, "Unable to create user")
+			return
+		}
 
-	if !isValidNumber {
-		log.Println("Invalid phone number")
-		scmerrors.RespondClientErr(resp, errors.New("invalid phone number"), http.StatusBadRequest, "Invalid phone number", "Invalid phone number")
-		return
+		if isMobileAlreadyExist {
+			// Log the error when a mobile number already exists
+			log.Println("Mobile number already exists")
+			srv.recordAuthEvent(req, models.AuthEvent{EventType: models.AuthEventRegister, Outcome: models.AuthEventFailure, Reason: "phone number already registered", Phone: phoneNumber})
+			scmerrors.RespondClientErr(resp, errors.New("mobile number already exists"), http.StatusBadRequest, "This phone number is already linked with one of our accounts. Please use a different phone number", "Unable to create a user")
+			return
+		}
+	} else {
+		newUserReq.Mobilenumber = null.String{}
 	}
 
-	phoneNumber := libphonenumber.Format(num, libphonenumber.E164)
-	newUserReq.Mobilenumber = null.StringFrom(phoneNumber)
-
-	// Check if the mobile number already exists
-	isMobileAlreadyExist, err := srv.DBHelper.IsPhoneNumberAlreadyExist(phoneNumber)
+	// The latest mandatory terms and privacy policy have to be accepted to sign up
+	policyIDs, err := srv.registrationPolicyIDs(newUserReq.AcceptedPolicyIDs)
 	if err != nil {
-		// Log the error when checking mobile number existence
-		log.Printf("Error checking mobile number existence: %v\n", err)
-		scmerrors.RespondGenericServerErr(resp, err, "Unable to create user")
-		return
-	}
-
-	if isMobileAlreadyExist {
-		// Log the error when a mobile number already exists
-		log.Println("Mobile number already exists")
-		scmerrors.RespondClientErr(resp, errors.New("mobile number already exists"), http.StatusBadRequest, "This phone number is already linked with one of our accounts. Please use a different phone number", "Unable to create a user")
+		log.Printf("Error checking accepted policies: %v\n", err)
+		scmerrors.RespondConsentErr(resp, err)
 		return
 	}
 
-	// Creating user in the database
-	userID, err := srv.DBHelper.CreateNewUser(&newUserReq, uc.UserID)
+	// Creating the user with their role and consents in one transaction
+	newUserReq.AcceptedPolicyIDs = policyIDs
+	consent := consentRecord(req, 0)
+	userID, err := srv.DBHelper.CreateNewUser(&newUserReq, uc.UserID, &consent)
 	if err != nil {
 		// Log the error when creating a new user in the database
 		log.Printf("Error creating new user in the database: %v\n", err)
@@ -145,6 +137,13 @@ func (srv *Server) register(resp http.ResponseWriter, req *http.Request) {
 
 	// Log the successful registration
 	log.Printf("User registered successfully with ID: %v\n", userID)
+	srv.recordAuthEvent(req, models.AuthEvent{
+		UserID:    null.IntFromPtr(userID),
+		EventType: models.AuthEventRegister,
+		Outcome:   models.AuthEventSuccess,
+		Email:     newUserReq.Email.String,
+		Phone:     newUserReq.Mobilenumber.String,
+	})
 
 	utils.EncodeJSONBody(resp, http.StatusCreated, map[string]interface{}{
 		"message": "success",
@@ -152,68 +151,74 @@ func (srv *Server) register(resp http.ResponseWriter, req *http.Request) {
 	})
 }
 
-// Generate a Random OTP
+// LoginWithEmailOtp
 
-func (srv *Server) generateAndStoreOTP(email string) (string, error) {
-	otp, err := generateRandomOTP(6)
+func (srv *Server) loginWithEmailOTP(resp http.ResponseWriter, req *http.Request) {
+	var authLoginRequest models.AuthLoginRequest
+	err := json.NewDecoder(req.Body).Decode(&authLoginRequest)
 	if err != nil {
-		logrus.Error("Error generating OTP: ", err)
-		return "", err
+		logrus.Error("loginWithEmail/Mobilenumber and otp: unable to decode request body ", err)
+		return
 	}
 
-	// Store the OTP in the database
-	err = srv.DBHelper.StoreOTP(email, otp)
-	if err != nil {
-		logrus.Error("Error storing OTP in the database: ", err)
-		return "", err
+	if authLoginRequest.OTP == "" {
+		scmerrors.RespondClientErr(resp, errors.New("otp can not be empty"), http.StatusBadRequest, "Empty otp!", "otp field can not be empty")
+		return
 	}
 
-	return otp, nil
-}
+	if authLoginRequest.Email == "" {
+		scmerrors.RespondClientErr(resp, errors.New("email can not be empty"), http.StatusBadRequest, "Please enter email to login", "email can not be empty")
+		return
+	}
+
+	// Log the received authentication request
+	logrus.Infof("Received login request with Email: %s", authLoginRequest.Email)
 
-func generateRandomOTP(length int) (string, error) {
-	if length <= 0 {
-		return "", errors.New("length should be a positive integer")
+	createUserSession := models.CreateSessionRequest{
+		Platform:  authLoginRequest.Platform,
+		ModelName: authLoginRequest.ModelName.String,
+		OSVersion: authLoginRequest.OSVersion.String,
+		DeviceID:  authLoginRequest.DeviceID.String,
 	}
 
-	// Generate random bytes
-	randomBytes := make([]byte, length)
-	_, err := rand.Read(randomBytes)
-	if err != nil {
-		return "", err
+	loginEvent := models.AuthEvent{
+		EventType:            models.AuthEventLogin,
+		Outcome:              models.AuthEventFailure,
+		Email:                strings.ToLower(strings.TrimSpace(authLoginRequest.Email)),
+		CreateSessionRequest: createUserSession,
 	}
 
-	// Encode random bytes to base64
-	otp := base64.URLEncoding.EncodeToString(randomBytes)
+	loginReq := models.EmailAndOTP{
+		Email: authLoginRequest.Email,
+		OTP:   authLoginRequest.OTP,
+	}
+	loginReq.Email = strings.ToLower(loginReq.Email)
 
-	// Remove non-alphanumeric characters
-	otp = strings.Map(func(r rune) rune {
-		if r >= 'a' && r <= 'z' {
-			return r
-		}
-		if r >= 'A' && r <= 'Z' {
-			return r
-		}
-		if r >= '0' && r <= '9' {
-			return r
+	userID, errorMessage, err := srv.DBHelper.LogInUserUsingEmail(loginReq)
+	if err != nil {
+		logrus.Error("Error logging in user with email: ", err, errorMessage)
+		loginEvent.Reason = err.Error()
+		if userID != 0 {
+			loginEvent.UserID = null.IntFrom(userID)
+		} else if errors.Is(err, scmerrors.ErrOTPInvalid) {
+			loginEvent.Reason = "unknown account"
 		}
-		return -1
-	}, otp)
-
-	// Truncate or pad to the desired length
-	otp = otp[:length]
+		srv.recordAuthEvent(req, loginEvent)
+		scmerrors.RespondOTPErr(resp, err)
+		return
+	}
 
-	return otp, nil
+	srv.startSessionAndRespond(resp, req, userID, loginEvent)
 }
 
-// LoginWithEmailOtp
+// LoginWithPhoneOtp
 
-func (srv *Server) loginWithEmailOTP(resp http.ResponseWriter, req *http.Request) {
-	var token string
-	var authLoginRequest models.AuthLoginRequest
+func (srv *Server) loginWithPhoneOTP(resp http.ResponseWriter, req *http.Request) {
+	var authLoginRequest models.AuthPhoneLoginRequest
 	err := json.NewDecoder(req.Body).Decode(&authLoginRequest)
 	if err != nil {
-		logrus.Error("loginWithEmail/Mobilenumber and otp: unable to decode request body ", err)
+		logrus.Error("loginWithPhoneOTP: unable to decode request body ", err)
+		scmerrors.RespondClientErr(resp, err, http.StatusBadRequest, "Error logging in", "Error parsing request")
 		return
 	}
 
@@ -222,26 +227,18 @@ func (srv *Server) loginWithEmailOTP(resp http.ResponseWriter, req *http.Request
 		return
 	}
 
-	if authLoginRequest.Email == "" {
-		scmerrors.RespondClientErr(resp, errors.New("email can not be empty"), http.StatusBadRequest, "Please enter email to login", "email can not be empty")
+	if authLoginRequest.Mobilenumber == "" {
+		scmerrors.RespondClientErr(resp, errors.New("phone number can not be empty"), http.StatusBadRequest, "Please enter phone number to login", "mobilenumber can not be empty")
 		return
 	}
 
-	// Log the received authentication request
-	logrus.Infof("Received login request with Email: %s, OTP: %s", authLoginRequest.Email, authLoginRequest.OTP)
-
-	UserDataByEmail, err := srv.DBHelper.GetUserInfoByEmail(authLoginRequest.Email)
+	phoneNumber, err := utils.NormalizePhoneNumber(authLoginRequest.Mobilenumber)
 	if err != nil {
-		logrus.Error("Error getting user info by email: ", err)
-		scmerrors.RespondClientErr(resp, err, http.StatusBadRequest, "error getting user info", "error getting user info")
+		scmerrors.RespondClientErr(resp, err, http.StatusBadRequest, "Invalid phone number", "Invalid phone number")
 		return
 	}
 
-	loginReq := models.EmailAndOTP{
-		Email: authLoginRequest.Email,
-		OTP:   authLoginRequest.OTP,
-	}
-	loginReq.Email = strings.ToLower(loginReq.Email)
+	logrus.Infof("Received login request with phone: %s", phoneNumber)
 
 	createUserSession := models.CreateSessionRequest{
 		Platform:  authLoginRequest.Platform,
@@ -250,14 +247,42 @@ func (srv *Server) loginWithEmailOTP(resp http.ResponseWriter, req *http.Request
 		DeviceID:  authLoginRequest.DeviceID.String,
 	}
 
-	userID, errorMessage, err := srv.DBHelper.LogInUserUsingEmail(loginReq)
+	loginEvent := models.AuthEvent{
+		EventType:            models.AuthEventLogin,
+		Outcome:              models.AuthEventFailure,
+		Phone:                phoneNumber,
+		CreateSessionRequest: createUserSession,
+	}
+
+	loginReq := models.PhoneAndOTP{
+		Mobilenumber: phoneNumber,
+		OTP:          authLoginRequest.OTP,
+	}
+
+	userID, errorMessage, err := srv.DBHelper.LogInUserUsingPhone(loginReq)
 	if err != nil {
-		logrus.Error("Error logging in user with email: ", err)
-		scmerrors.RespondClientErr(resp, err, http.StatusInternalServerError, errorMessage, errorMessage)
+		logrus.Error("Error logging in user with phone: ", err, errorMessage)
+		loginEvent.Reason = err.Error()
+		if userID != 0 {
+			loginEvent.UserID = null.IntFrom(userID)
+		} else if errors.Is(err, scmerrors.ErrOTPInvalid) {
+			loginEvent.Reason = "unknown account"
+		}
+		srv.recordAuthEvent(req, loginEvent)
+		scmerrors.RespondOTPErr(resp, err)
 		return
 	}
 
-	UUIDToken, err := srv.DBHelper.StartNewSession(userID, &createUserSession)
+	srv.startSessionAndRespond(resp, req, userID, loginEvent)
+}
+
+// startSessionAndRespond opens a session for a user who has just proven their identity and responds with the user info and JWT.
+// loginEvent describes the attempt and is recorded as a success once the session is open.
+// Users in mfaRoles get mfaRequired and must call /api/me/mfa/verify before their role routes open up.
+func (srv *Server) startSessionAndRespond(resp http.ResponseWriter, req *http.Request, userID int, loginEvent models.AuthEvent) {
+	createUserSession := loginEvent.CreateSessionRequest
+
+	UUIDToken, err := srv.DBHelper.StartNewSession(userID, &createUserSession, authProvider.AccessTokenTTL())
 	if err != nil {
 		logrus.Error("Error creating session: ", err)
 		scmerrors.RespondGenericServerErr(resp, err, "error in creating session")
@@ -271,20 +296,172 @@ func (srv *Server) loginWithEmailOTP(resp http.ResponseWriter, req *http.Request
 		return
 	}
 
-	devClaims := make(map[string]interface{})
-	devClaims["UUIDToken"] = UUIDToken
-	devClaims["userInfo"] = UserDataByEmail
-	devClaims["UserSession"] = createUserSession
-
-	token, err = authProvider.GenerateJWT(devClaims)
+	token, err := srv.generateAccessToken(userInfo, UUIDToken, createUserSession)
 	if err != nil {
 		logrus.Error("Error generating JWT: ", err)
 		scmerrors.RespondClientErr(resp, err, http.StatusInternalServerError, "error while login", "error while login")
 		return
 	}
 
+	refreshToken, err := srv.DBHelper.CreateRefreshToken(UUIDToken, authProvider.RefreshTokenTTL())
+	if err != nil {
+		logrus.Error("Error creating refresh token: ", err)
+		scmerrors.RespondGenericServerErr(resp, err, "error in creating refresh token")
+		return
+	}
+
+	mfaRequired, err := srv.isMFARequired(userID)
+	if err != nil {
+		logrus.Error("Error checking mfa requirement: ", err)
+		scmerrors.RespondGenericServerErr(resp, err, "error in checking mfa")
+		return
+	}
+
+	mfaStatus, err := srv.DBHelper.GetMFAStatus(userID)
+	if err != nil {
+		logrus.Error("Error getting mfa status: ", err)
+		scmerrors.RespondGenericServerErr(resp, err, "error in checking mfa")
+		return
+	}
+
+	loginEvent.UserID = null.IntFrom(userID)
+	loginEvent.Outcome = models.AuthEventSuccess
+	loginEvent.Reason = ""
+	srv.recordAuthEvent(req, loginEvent)
+
+	newDevice := srv.checkLoginDevice(req, userInfo, UUIDToken, createUserSession)
+
+	utils.EncodeJSONBody(resp, http.StatusOK, map[string]interface{}{
+		"userInfo":     userInfo,
+		"token":        token,
+		"refreshToken": refreshToken,
+		"mfaRequired":  mfaRequired,
+		"mfaEnrolled":  mfaStatus.Enabled,
+		"newDevice":    newDevice,
+	})
+}
+
+// generateAccessToken issues a JWT for the given user and session.
+func (srv *Server) generateAccessToken(userInfo models.FetchUserData, sessionToken string, device models.CreateSessionRequest) (string, error) {
+	roles, _, err := srv.DBHelper.GetUserRolesAndPermissions(userInfo.UserID)
+	if err != nil {
+		return "", err
+	}
+
+	return authProvider.GenerateJWT(srv.SigningKeys, authProvider.TokenRequest{
+		UserID:    userInfo.UserID,
+		SessionID: sessionToken,
+		Name:      userInfo.Fullname,
+		Email:     userInfo.Email,
+		Roles:     roles,
+		Device:    device,
+	})
+}
+
+// requestOTP sends a login code to the email or phone number stored on the account. It answers the same
+// whether or not an account exists for the email or phone number, so it can not be used to find out who has one.
+func (srv *Server) requestOTP(resp http.ResponseWriter, req *http.Request) {
+	var otpReq models.RequestOTPRequest
+	if err := json.NewDecoder(req.Body).Decode(&otpReq); err != nil {
+		logrus.Error("requestOTP: unable to decode request body ", err)
+		scmerrors.RespondClientErr(resp, err, http.StatusBadRequest, "Error requesting otp", "Error parsing request")
+		return
+	}
+
+	if otpReq.Email.String == "" && otpReq.Mobilenumber.String == "" {
+		scmerrors.RespondClientErr(resp, errors.New("email or phone number is required"), http.StatusBadRequest, "Please enter email or phone number", "email and mobilenumber can not both be empty")
+		return
+	}
+
+	// the code goes to one channel and can only log in through that channel
+	if otpReq.Email.String != "" && otpReq.Mobilenumber.String != "" {
+		scmerrors.RespondClientErr(resp, errors.New("only one of email or phone number is allowed"), http.StatusBadRequest, "Please enter either email or phone number", "email and mobilenumber can not both be set")
+		return
+	}
+
+	storeReq := newOTPRequest(models.OTPPurposeLogin)
+
+	otpEvent := models.AuthEvent{
+		EventType: models.AuthEventOTPRequest,
+		Outcome:   models.AuthEventFailure,
+	}
+
+	if otpReq.Email.String != "" {
+		storeReq.Channel = models.OTPChannelEmail
+		otpEvent.Email = strings.ToLower(strings.TrimSpace(otpReq.Email.String))
+	} else {
+		phoneNumber, err := utils.NormalizePhoneNumber(otpReq.Mobilenumber.String)
+		if err != nil {
+			scmerrors.RespondClientErr(resp, err, http.StatusBadRequest, "Invalid phone number", "Invalid phone number")
+			return
+		}
+		storeReq.Channel = models.OTPChannelPhone
+		otpEvent.Phone = phoneNumber
+	}
+
+	user, err := srv.DBHelper.GetOTPLoginUser(otpEvent.Email, otpEvent.Phone)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			otpEvent.Reason = "unknown account"
+			srv.recordAuthEvent(req, otpEvent)
+			respondOTPRequested(resp)
+			return
+		}
+		scmerrors.RespondGenericServerErr(resp, err, "error getting user")
+		return
+	}
+	storeReq.UserID = user.UserID
+	otpEvent.UserID = null.IntFrom(user.UserID)
+
+	storeReq.Target = user.Email
+	if storeReq.Channel == models.OTPChannelPhone {
+		storeReq.Target = user.Mobilenumber
+	}
+
+	otp, err := srv.DBHelper.GenerateAndStoreOTP(storeReq)
+	if err != nil {
+		logrus.Error("requestOTP: error generating otp ", err)
+		scmerrors.RespondGenericServerErr(resp, err, "error generating otp")
+		return
+	}
+
+	if err := srv.deliverOTP(storeReq, otp); err != nil {
+		logrus.Error("requestOTP: error delivering otp ", err)
+		otpEvent.Reason = "delivery failed"
+		srv.recordAuthEvent(req, otpEvent)
+		// only accounts that exist get this far, an error would tell them apart from unknown ones
+		respondOTPRequested(resp)
+		return
+	}
+
+	otpEvent.Outcome = models.AuthEventSuccess
+	srv.recordAuthEvent(req, otpEvent)
+
+	respondOTPRequested(resp)
+}
+
+func respondOTPRequested(resp http.ResponseWriter) {
 	utils.EncodeJSONBody(resp, http.StatusOK, map[string]interface{}{
-		"userInfo": userInfo,
-		"token":    token,
+		"message": "success",
 	})
 }
+
+// newOTPRequest fills in the configured OTP length, expiry and attempt limit for a new challenge.
+func newOTPRequest(purpose models.OTPPurpose) models.GenerateAndStoreOTP {
+	return models.GenerateAndStoreOTP{
+		Purpose:     purpose,
+		OTPLength:   utils.GetEnvInt("OTP_LENGTH", defaultOTPLength),
+		ExpiresIn:   time.Duration(utils.GetEnvInt("OTP_EXPIRY_MINUTES", defaultOTPExpiryMinutes)) * time.Minute,
+		MaxAttempts: utils.GetEnvInt("OTP_MAX_ATTEMPTS", defaultOTPMaxAttempts),
+	}
+}
+
+// deliverOTP sends a freshly generated OTP to its target over the challenge's channel.
+func (srv *Server) deliverOTP(otpReq models.GenerateAndStoreOTP, otp string) error {
+	message := fmt.Sprintf("Your ApnaSabji OTP is %s. It is valid for %d minutes. Do not share it with anyone.", otp, int(otpReq.ExpiresIn.Minutes()))
+
+	if otpReq.Channel == models.OTPChannelEmail {
+		return srv.Notifier.SendEmail(otpReq.Target, "Your ApnaSabji OTP", message)
+	}
+	return srv.Notifier.SendSMS(otpReq.Target, message)
+}
@@ -0,0 +1,92 @@
+package server
+
+import (
+	"time"
+
+	"github.com/vijaygniit/ApnaSabji/models"
+)
+
+const (
+	rateLimitCleanupInterval = 10 * time.Minute
+	// rateLimitRetention must outlast the longest window and max lockout below
+	rateLimitRetention = 48 * time.Hour
+
+	productViewCleanupInterval = time.Hour
+)
+
+// registerRateLimit stops account creation floods, one address may only sign up a handful of times.
+var registerRateLimit = models.RateLimitPolicy{
+	Name: "register",
+	Rules: map[models.RateLimitKey]models.RateLimitRule{
+		models.RateLimitKeyIP:     {Limit: 10, Window: time.Hour, Lockout: 15 * time.Minute, MaxLockout: 24 * time.Hour},
+		models.RateLimitKeyEmail:  {Limit: 3, Window: time.Hour, Lockout: 15 * time.Minute, MaxLockout: 24 * time.Hour},
+		models.RateLimitKeyPhone:  {Limit: 3, Window: time.Hour, Lockout: 15 * time.Minute, MaxLockout: 24 * time.Hour},
+		models.RateLimitKeyDevice: {Limit: 5, Window: time.Hour, Lockout: 15 * time.Minute, MaxLockout: 24 * time.Hour},
+	},
+}
+
+// loginRateLimit keeps OTP guessing far below what brute forcing a 6 digit code needs,
+// on top of the per challenge attempt limit.
+var loginRateLimit = models.RateLimitPolicy{
+	Name: "login",
+	Rules: map[models.RateLimitKey]models.RateLimitRule{
+		models.RateLimitKeyIP:     {Limit: 30, Window: 15 * time.Minute, Lockout: 15 * time.Minute, MaxLockout: 24 * time.Hour},
+		models.RateLimitKeyEmail:  {Limit: 10, Window: 15 * time.Minute, Lockout: 15 * time.Minute, MaxLockout: 24 * time.Hour},
+		models.RateLimitKeyPhone:  {Limit: 10, Window: 15 * time.Minute, Lockout: 15 * time.Minute, MaxLockout: 24 * time.Hour},
+		models.RateLimitKeyDevice: {Limit: 20, Window: 15 * time.Minute, Lockout: 15 * time.Minute, MaxLockout: 24 * time.Hour},
+	},
+	FailClosed: true,
+}
+
+// mfaRateLimit keeps guessing TOTP and recovery codes impractical for a stolen session.
+var mfaRateLimit = models.RateLimitPolicy{
+	Name: "mfa",
+	Rules: map[models.RateLimitKey]models.RateLimitRule{
+		models.RateLimitKeyIP:   {Limit: 20, Window: 15 * time.Minute, Lockout: 15 * time.Minute, MaxLockout: 24 * time.Hour},
+		models.RateLimitKeyUser: {Limit: 5, Window: 5 * time.Minute, Lockout: 15 * time.Minute, MaxLockout: 24 * time.Hour},
+	},
+	FailClosed: true,
+}
+
+// oidcRateLimit only has the IP to go on, the account is not known until the provider answers.
+var oidcRateLimit = models.RateLimitPolicy{
+	Name: "oidc",
+	Rules: map[models.RateLimitKey]models.RateLimitRule{
+		models.RateLimitKeyIP: {Limit: 30, Window: 15 * time.Minute, Lockout: 15 * time.Minute, MaxLockout: 24 * time.Hour},
+	},
+}
+
+// otpRequestRateLimit limits how many codes we send, every request costs an email or sms.
+var otpRequestRateLimit = models.RateLimitPolicy{
+	Name: "otp_request",
+	Rules: map[models.RateLimitKey]models.RateLimitRule{
+		models.RateLimitKeyIP:     {Limit: 20, Window: 15 * time.Minute, Lockout: 15 * time.Minute, MaxLockout: 24 * time.Hour},
+		models.RateLimitKeyEmail:  {Limit: 5, Window: 15 * time.Minute, Lockout: 15 * time.Minute, MaxLockout: 24 * time.Hour},
+		models.RateLimitKeyPhone:  {Limit: 5, Window: 15 * time.Minute, Lockout: 15 * time.Minute, MaxLockout: 24 * time.Hour},
+		models.RateLimitKeyDevice: {Limit: 10, Window: 15 * time.Minute, Lockout: 15 * time.Minute, MaxLockout: 24 * time.Hour},
+	},
+	FailClosed: true,
+}
+
+// refreshRateLimit bounds refresh token guessing and refresh storms from a broken client.
+var refreshRateLimit = models.RateLimitPolicy{
+	Name: "refresh",
+	Rules: map[models.RateLimitKey]models.RateLimitRule{
+		models.RateLimitKeyIP: {Limit: 60, Window: 15 * time.Minute, Lockout: 15 * time.Minute, MaxLockout: 24 * time.Hour},
+	},
+	FailClosed: true,
+}
+
+// partnerRateLimit runs before the key is looked up, so requests with made up keys can not load the
+// database. It allows the default maximum per key limit, a partner's own limit is kept by its key.
+var partnerRateLimit = models.RateLimitPolicy{
+	Name: "partner",
+	Rules: map[models.RateLimitKey]models.RateLimitRule{
+		models.RateLimitKeyIP: {Limit: defaultAPIKeyMaxRateLimitPerMinute, Window: time.Minute, Lockout: time.Minute, MaxLockout: 15 * time.Minute},
+	},
+	FailClosed: true,
+}
+
+// productViewRateLimit caps how many products one customer adds to popularity, views past it are
+// served but not counted.
+var productViewRateLimit = models.RateLimitRule{Limit: 120, Window: time.Hour, Lockout: time.Hour, MaxLockout: 24 * time.Hour}
@@ -2,6 +2,7 @@ package server
 
 import (
 	"github.com/go-chi/chi"
+	"github.com/vijaygniit/ApnaSabji/models"
 )
 
 // Update InjectRoutes to use the modified srv.register
@@ -9,9 +10,170 @@ func (srv *Server) InjectRoutes() *chi.Mux {
 	r := chi.NewRouter()
 
 	// r.Get("/health", srv.HealthCheck)
+	r.Get("/.well-known/jwks.json", srv.jwks)
 	r.Route("/api", func(api chi.Router) {
-		api.Post("/register", srv.register) // Use Post method for POST requests\
-		api.Post("/login", srv.loginWithEmailOTP)
+		api.With(srv.MiddlewareProvider.RateLimit(registerRateLimit)).Post("/register", srv.register) // Use Post method for POST requests\
+		api.With(srv.MiddlewareProvider.RateLimit(loginRateLimit)).Post("/login", srv.loginWithEmailOTP)
+		api.With(srv.MiddlewareProvider.RateLimit(loginRateLimit)).Post("/login/phone", srv.loginWithPhoneOTP)
+		api.With(srv.MiddlewareProvider.RateLimit(otpRequestRateLimit)).Post("/otp/request", srv.requestOTP)
+		api.With(srv.MiddlewareProvider.RateLimit(refreshRateLimit)).Post("/token/refresh", srv.refreshToken)
+		api.Route("/oidc/{provider}", func(oidc chi.Router) {
+			oidc.Use(srv.MiddlewareProvider.RateLimit(oidcRateLimit))
+			oidc.Get("/start", srv.startOIDCLogin)
+			oidc.Get("/callback", srv.oidcCallback)
+			oidc.Post("/callback", srv.oidcCallback)
+		})
+		api.Get("/policies", srv.listPolicies)
+		api.Get("/units", srv.listUnits)
+		api.Route("/categories", func(categories chi.Router) {
+			categories.Get("/", srv.listCategories)
+			categories.Get("/{id}", srv.getCategory)
+			categories.Get("/{id}/products", srv.listCategoryProducts)
+		})
+		api.Route("/search", func(search chi.Router) {
+			search.Get("/", srv.searchProducts)
+			search.Get("/suggestions", srv.searchSuggestions)
+		})
+		api.Route("/products", func(products chi.Router) {
+			products.Get("/", srv.listProducts)
+			products.With(srv.MiddlewareProvider.OptionalAuth()).Get("/{id}", srv.getProduct)
+		})
+		api.Get("/exports/{token}", srv.downloadDataExport)
+		api.Get("/device-alerts/{token}/revoke", srv.confirmDeviceAlertRevoke)
+		api.Post("/device-alerts/{token}/revoke", srv.revokeFromDeviceAlert)
+
+		api.Group(func(authenticated chi.Router) {
+			authenticated.Use(srv.MiddlewareProvider.Middleware())
+			authenticated.Post("/logout", srv.logout)
+			authenticated.Route("/me", func(me chi.Router) {
+				// reachable before accepting updated terms, so users can review them, leave or take their data
+				me.Route("/consents", func(consents chi.Router) {
+					consents.Get("/", srv.getConsents)
+					consents.With(srv.MiddlewareProvider.BlockImpersonation()).Post("/", srv.acceptPolicies)
+					consents.With(srv.MiddlewareProvider.BlockImpersonation()).Put("/marketing", srv.setMarketingConsent)
+				})
+				me.With(srv.MiddlewareProvider.BlockImpersonation()).Delete("/", srv.deleteAccount)
+				me.With(srv.MiddlewareProvider.BlockImpersonation()).Post("/export", srv.requestDataExport)
+				me.Get("/export/{id}", srv.getDataExport)
+				me.Route("/sessions", func(sessions chi.Router) {
+					sessions.Get("/", srv.listSessions)
+					sessions.With(srv.MiddlewareProvider.BlockImpersonation()).Delete("/{id}", srv.revokeSession)
+					sessions.With(srv.MiddlewareProvider.BlockImpersonation()).Post("/revoke-all", srv.revokeAllSessions)
+				})
+
+				me.Group(func(consented chi.Router) {
+					consented.Use(srv.MiddlewareProvider.RequireConsent())
+					consented.Get("/", srv.getProfile)
+					consented.With(srv.MiddlewareProvider.BlockImpersonation()).Patch("/", srv.updateProfile)
+					consented.With(srv.MiddlewareProvider.BlockImpersonation()).Post("/contact/verify", srv.verifyContactChange)
+					consented.Route("/mfa", func(mfa chi.Router) {
+						mfa.Get("/", srv.getMFAStatus)
+						mfa.Group(func(owner chi.Router) {
+							owner.Use(srv.MiddlewareProvider.BlockImpersonation())
+							owner.Post("/totp", srv.enrollTOTP)
+							owner.With(srv.MiddlewareProvider.RateLimit(mfaRateLimit)).Post("/totp/confirm", srv.confirmTOTP)
+							owner.With(srv.MiddlewareProvider.RateLimit(mfaRateLimit)).Post("/verify", srv.verifyMFA)
+							owner.With(srv.MiddlewareProvider.RequireMFA()).Post("/recovery-codes", srv.regenerateRecoveryCodes)
+							owner.With(srv.MiddlewareProvider.RequireMFA()).Delete("/", srv.disableMFA)
+						})
+					})
+					consented.Route("/api-keys", func(apiKeys chi.Router) {
+						apiKeys.Use(srv.MiddlewareProvider.RequireRole(models.RolePartner), srv.MiddlewareProvider.BlockImpersonation())
+						apiKeys.Get("/", srv.listAPIKeys)
+						apiKeys.Post("/", srv.createAPIKey)
+						apiKeys.Post("/{id}/rotate", srv.rotateAPIKey)
+						apiKeys.Delete("/{id}", srv.revokeAPIKey)
+					})
+					consented.Route("/devices", func(devices chi.Router) {
+						devices.Get("/", srv.listDevices)
+						devices.Patch("/{id}", srv.renameDevice)
+						devices.With(srv.MiddlewareProvider.BlockImpersonation()).Delete("/{id}", srv.forgetDevice)
+					})
+				})
+			})
+
+			authenticated.Route("/admin", func(admin chi.Router) {
+				admin.Use(srv.MiddlewareProvider.RequireRole(models.RoleAdmin), srv.MiddlewareProvider.RequireConsent(), srv.MiddlewareProvider.RequireMFA())
+				admin.Get("/roles", srv.listRoles)
+				admin.With(srv.MiddlewareProvider.RequirePermission(models.PermissionUsersRead)).Get("/auth-events", srv.listAuthEvents)
+				admin.Group(func(roles chi.Router) {
+					roles.Use(srv.MiddlewareProvider.RequirePermission(models.PermissionRolesAssign))
+					roles.Post("/users/{id}/roles", srv.assignRole)
+					roles.Delete("/users/{id}/roles/{role}", srv.removeRole)
+				})
+				admin.Group(func(users chi.Router) {
+					users.Use(srv.MiddlewareProvider.RequirePermission(models.PermissionUsersWrite))
+					users.Post("/users/{id}/deactivate", srv.deactivateUser)
+					users.Post("/users/{id}/reactivate", srv.reactivateUser)
+					users.Post("/users/{id}/restore", srv.restoreUser)
+					users.Delete("/users/{id}", srv.deleteUser)
+				})
+				admin.Group(func(catalog chi.Router) {
+					catalog.Use(srv.MiddlewareProvider.RequirePermission(models.PermissionCatalogWrite))
+					catalog.Post("/units", srv.createUnit)
+					catalog.Route("/categories", func(categories chi.Router) {
+						categories.Get("/", srv.adminListCategories)
+						categories.Post("/", srv.createCategory)
+						categories.Patch("/{id}", srv.updateCategory)
+						categories.Delete("/{id}", srv.deleteCategory)
+					})
+					catalog.Route("/products", func(products chi.Router) {
+						products.Get("/", srv.adminListProducts)
+						products.Post("/", srv.createProduct)
+						products.Get("/{id}", srv.adminGetProduct)
+						products.Patch("/{id}", srv.updateProduct)
+						products.Delete("/{id}", srv.deleteProduct)
+						products.Post("/{id}/variants", srv.createVariant)
+						products.Get("/{id}/aliases", srv.listProductAliases)
+						products.Post("/{id}/aliases", srv.createProductAlias)
+						products.Put("/{id}/pricing-rule", srv.upsertPricingRule)
+						products.Delete("/{id}/pricing-rule", srv.deletePricingRule)
+					})
+					catalog.Delete("/aliases/{id}", srv.deleteProductAlias)
+					catalog.Route("/mandi-prices", func(mandiPrices chi.Router) {
+						mandiPrices.Get("/", srv.listMandiPrices)
+						mandiPrices.Post("/import", srv.importMandiPrices)
+					})
+					catalog.Get("/pricing-rules", srv.listPricingRules)
+					catalog.Route("/pricing", func(pricing chi.Router) {
+						pricing.Post("/preview", srv.previewPricing)
+						pricing.Post("/apply", srv.applyPricing)
+					})
+					catalog.Route("/catalog", func(sheets chi.Router) {
+						sheets.Get("/export", srv.exportCatalog)
+						sheets.With(srv.MiddlewareProvider.RequirePermission(models.PermissionInventoryWrite)).Post("/import", srv.importCatalog)
+					})
+				})
+				admin.Route("/variants", func(variants chi.Router) {
+					variants.With(srv.MiddlewareProvider.RequirePermission(models.PermissionCatalogWrite)).Patch("/{id}", srv.updateVariant)
+					variants.With(srv.MiddlewareProvider.RequirePermission(models.PermissionCatalogWrite)).Delete("/{id}", srv.deleteVariant)
+					variants.With(srv.MiddlewareProvider.RequirePermission(models.PermissionInventoryWrite)).Post("/{id}/stock", srv.adjustVariantStock)
+					variants.With(srv.MiddlewareProvider.RequirePermission(models.PermissionCatalogWrite)).Get("/{id}/price-history", srv.listPriceHistory)
+				})
+				admin.Route("/policies", func(policies chi.Router) {
+					policies.Use(srv.MiddlewareProvider.RequirePermission(models.PermissionPoliciesWrite))
+					policies.Get("/", srv.listPolicyVersions)
+					policies.Post("/", srv.publishPolicy)
+				})
+				admin.With(
+					srv.MiddlewareProvider.RequirePermission(models.PermissionUsersImpersonate),
+					srv.MiddlewareProvider.BlockImpersonation(),
+				).Post("/users/{id}/impersonate", srv.impersonateUser)
+			})
+
+			authenticated.Route("/vendor", func(vendor chi.Router) {
+				vendor.Use(srv.MiddlewareProvider.RequireRole(models.RoleVendor, models.RoleAdmin), srv.MiddlewareProvider.RequireConsent(), srv.MiddlewareProvider.RequireMFA())
+			})
+
+			authenticated.Route("/rider", func(rider chi.Router) {
+				rider.Use(srv.MiddlewareProvider.RequireRole(models.RoleRider, models.RoleAdmin), srv.MiddlewareProvider.RequireConsent())
+			})
+		})
+
+		api.Route("/partner", func(partner chi.Router) {
+			partner.Use(srv.MiddlewareProvider.RateLimit(partnerRateLimit), srv.MiddlewareProvider.APIKeyMiddleware())
+			partner.With(srv.MiddlewareProvider.RequireScope(models.APIKeyScopeAccountRead)).Get("/account", srv.partnerAccount)
+		})
 
 		// Other API routes can be added here if needed
 	})
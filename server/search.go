@@ -0,0 +1,186 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/go-chi/chi"
+	"github.com/sirupsen/logrus"
+	"github.com/vijaygniit/ApnaSabji/models"
+	"github.com/vijaygniit/ApnaSabji/scmerrors"
+	"github.com/vijaygniit/ApnaSabji/utils"
+)
+
+// searchQuery reads q, which may be in any script, so its length is counted in characters.
+func searchQuery(resp http.ResponseWriter, req *http.Request) (string, bool) {
+	query := strings.TrimSpace(req.URL.Query().Get("q"))
+	if query == "" || utf8.RuneCountInString(query) > maxSearchQueryLength {
+		scmerrors.RespondClientErr(resp, errors.New("invalid query"), http.StatusBadRequest, "Please enter what you are looking for", "q is required and at most 100 characters")
+		return "", false
+	}
+	return query, true
+}
+
+// countProductView feeds search ranking. Only signed in customers count, once a day per product and
+// at most productViewRateLimit products, so popularity can not be pumped by reloading or scripting.
+// A failed update is not worth failing the request for.
+func (srv *Server) countProductView(req *http.Request) {
+	uc := srv.MiddlewareProvider.UserFromContext(req.Context())
+	if uc.UserID == 0 || uc.IsImpersonated() {
+		return
+	}
+
+	productID, err := strconv.Atoi(chi.URLParam(req, "id"))
+	if err != nil {
+		return
+	}
+
+	allowed, _, err := srv.RateLimits.Hit(fmt.Sprintf("product_view:%s:%d", models.RateLimitKeyUser, uc.UserID), productViewRateLimit)
+	if err != nil || !allowed {
+		return
+	}
+
+	if err := srv.DBHelper.RecordProductView(productID, uc.UserID); err != nil {
+		logrus.Error("countProductView: error counting product view ", err)
+	}
+}
+
+// searchProducts matches names and aliases in English, Hindi and regional languages, with typos
+// forgiven. Out of stock products are hidden unless includeOutOfStock=true.
+func (srv *Server) searchProducts(resp http.ResponseWriter, req *http.Request) {
+	query, ok := searchQuery(resp, req)
+	if !ok {
+		return
+	}
+
+	filter, ok := productFilterFromQuery(resp, req)
+	if !ok {
+		return
+	}
+
+	page, limit, offset := pagination(req)
+	includeOutOfStock, _ := strconv.ParseBool(req.URL.Query().Get("includeOutOfStock"))
+
+	products, total, err := srv.DBHelper.SearchProducts(models.ProductSearch{
+		Query:             query,
+		CategoryID:        filter.CategoryID,
+		IncludeOutOfStock: includeOutOfStock,
+		Limit:             limit,
+		Offset:            offset,
+	})
+	if err != nil {
+		scmerrors.RespondGenericServerErr(resp, err, "error searching products")
+		return
+	}
+
+	utils.EncodeJSONBody(resp, http.StatusOK, map[string]interface{}{
+		"products": products,
+		"total":    total,
+		"page":     page,
+		"limit":    limit,
+	})
+}
+
+// searchSuggestions autocompletes the products searchProducts would find, out of stock ones only with
+// includeOutOfStock=true.
+func (srv *Server) searchSuggestions(resp http.ResponseWriter, req *http.Request) {
+	query, ok := searchQuery(resp, req)
+	if !ok {
+		return
+	}
+
+	limit, err := strconv.Atoi(req.URL.Query().Get("limit"))
+	if err != nil || limit < 1 {
+		limit = defaultSuggestionLimit
+	}
+	if limit > maxSuggestionLimit {
+		limit = maxSuggestionLimit
+	}
+
+	includeOutOfStock, _ := strconv.ParseBool(req.URL.Query().Get("includeOutOfStock"))
+
+	suggestions, err := srv.DBHelper.SearchSuggestions(query, includeOutOfStock, limit)
+	if err != nil {
+		scmerrors.RespondGenericServerErr(resp, err, "error getting suggestions")
+		return
+	}
+
+	utils.EncodeJSONBody(resp, http.StatusOK, map[string]interface{}{
+		"suggestions": suggestions,
+	})
+}
+
+func (srv *Server) listProductAliases(resp http.ResponseWriter, req *http.Request) {
+	productID, ok := catalogIDFromURL(resp, req, "product")
+	if !ok {
+		return
+	}
+
+	aliases, err := srv.DBHelper.ListProductAliases(productID)
+	if err != nil {
+		scmerrors.RespondGenericServerErr(resp, err, "error getting aliases")
+		return
+	}
+
+	utils.EncodeJSONBody(resp, http.StatusOK, map[string]interface{}{
+		"aliases": aliases,
+	})
+}
+
+func (srv *Server) createProductAlias(resp http.ResponseWriter, req *http.Request) {
+	productID, ok := catalogIDFromURL(resp, req, "product")
+	if !ok {
+		return
+	}
+
+	var aliasReq models.CreateAliasRequest
+	if err := json.NewDecoder(req.Body).Decode(&aliasReq); err != nil {
+		scmerrors.RespondClientErr(resp, err, http.StatusBadRequest, "Error adding name", "Error parsing request")
+		return
+	}
+
+	aliasReq.Name = strings.TrimSpace(aliasReq.Name)
+	if aliasReq.Name == "" || utf8.RuneCountInString(aliasReq.Name) > maxSearchQueryLength {
+		scmerrors.RespondClientErr(resp, errors.New("invalid alias"), http.StatusBadRequest, "Please enter a name", "name is required and at most 100 characters")
+		return
+	}
+
+	if aliasReq.Language == "" {
+		aliasReq.Language = "en"
+	}
+	if !supportedLanguages[aliasReq.Language] {
+		scmerrors.RespondClientErr(resp, errors.New("unsupported language"), http.StatusBadRequest, "This language is not supported yet", "language must be one of the supported language codes")
+		return
+	}
+
+	alias, err := srv.DBHelper.CreateProductAlias(productID, aliasReq)
+	if err != nil {
+		scmerrors.RespondCatalogErr(resp, err)
+		return
+	}
+
+	utils.EncodeJSONBody(resp, http.StatusCreated, map[string]interface{}{
+		"alias": alias,
+	})
+}
+
+func (srv *Server) deleteProductAlias(resp http.ResponseWriter, req *http.Request) {
+	aliasID, ok := catalogIDFromURL(resp, req, "alias")
+	if !ok {
+		return
+	}
+
+	if err := srv.DBHelper.DeleteProductAlias(aliasID); err != nil {
+		scmerrors.RespondCatalogErr(resp, err)
+		return
+	}
+
+	utils.EncodeJSONBody(resp, http.StatusOK, map[string]interface{}{
+		"message": "success",
+	})
+}
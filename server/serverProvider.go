@@ -11,14 +11,23 @@ import (
 	"github.com/vijaygniit/ApnaSabji/providers"
 	dbprovider "github.com/vijaygniit/ApnaSabji/providers/dbProvider"
 	"github.com/vijaygniit/ApnaSabji/providers/dbhelperprovider"
+	"github.com/vijaygniit/ApnaSabji/providers/keyprovider"
 	"github.com/vijaygniit/ApnaSabji/providers/middlewareprovider"
+	"github.com/vijaygniit/ApnaSabji/providers/notificationprovider"
+	"github.com/vijaygniit/ApnaSabji/providers/ratelimitprovider"
+	"github.com/vijaygniit/ApnaSabji/utils"
 )
 
 type Server struct {
 	MiddlewareProvider providers.MiddlewareProvider
 	DBHelper           providers.DBHelperProvider
+	Notifier           providers.NotificationProvider
+	SigningKeys        providers.SigningKeyProvider
+	RateLimits         providers.RateLimitStore
+	IdentityProviders  map[string]providers.IdentityProvider
 	PSQL               providers.PSQLProvider
 	httpServer         *http.Server
+	stopJobs           context.CancelFunc
 }
 
 func SrvInit() *Server {
@@ -28,12 +37,48 @@ func SrvInit() *Server {
 	// database helper functions
 	dbHelper := dbhelperprovider.NewDBHepler(db.DB())
 
-	middleware := middlewareprovider.NewMiddleware(dbHelper)
+	// jwt signing keys, every configured kid stays valid for verification after a rotation
+	signingKeys, err := keyprovider.NewSigningKeyProvider(keyprovider.Config{
+		ActiveKID:       os.Getenv("JWT_ACTIVE_KID"),
+		HMACKeys:        os.Getenv("JWT_HMAC_KEYS"),
+		PrivateKeyFiles: os.Getenv("JWT_PRIVATE_KEY_FILES"),
+		PublicKeyFiles:  os.Getenv("JWT_PUBLIC_KEY_FILES"),
+	})
+	if err != nil {
+		logrus.Fatalf("Failed to load JWT signing keys: %v", err)
+	}
+
+	// TOTP secrets are encrypted with this key, a guessable one would expose every user's second factor
+	if err := dbhelperprovider.ValidateMFAEncryptionKey(os.Getenv("MFA_ENCRYPTION_KEY")); err != nil {
+		logrus.Fatalf("Invalid MFA encryption key: %v", err)
+	}
+
+	// OTPs and recovery codes are hashed with this key, it has to be set before any is stored
+	if err := dbhelperprovider.ValidateOTPHashSecret(os.Getenv("OTP_HASH_SECRET")); err != nil {
+		logrus.Fatalf("Invalid OTP hash secret: %v", err)
+	}
+
+	// client addresses are only read from forwarding headers set by these load balancers
+	if err := utils.SetTrustedProxies(os.Getenv("TRUSTED_PROXY_CIDRS")); err != nil {
+		logrus.Fatalf("Failed to load trusted proxies: %v", err)
+	}
+
+	// "memory" only limits a single replica, use "postgres" when running more than one
+	rateLimits := ratelimitprovider.NewRateLimitStore(os.Getenv("RATE_LIMIT_STORE"), db.DB())
+
+	middleware := middlewareprovider.NewMiddleware(dbHelper, signingKeys, rateLimits)
+
+	// email and sms delivery channels, "sink" writes to NOTIFICATION_SINK_FILE or stdout
+	notifier := notificationprovider.NewNotificationProvider(os.Getenv("NOTIFICATION_EMAIL_DRIVER"), os.Getenv("NOTIFICATION_SMS_DRIVER"))
 
 	return &Server{
 		PSQL:               db,
 		DBHelper:           dbHelper,
 		MiddlewareProvider: middleware,
+		Notifier:           notifier,
+		SigningKeys:        signingKeys,
+		RateLimits:         rateLimits,
+		IdentityProviders:  newIdentityProviders(),
 	}
 }
 
@@ -46,6 +91,10 @@ func (srv *Server) Start() {
 	}
 	srv.httpServer = httpSrv
 
+	jobsCtx, stopJobs := context.WithCancel(context.Background())
+	srv.stopJobs = stopJobs
+	srv.startBackgroundJobs(jobsCtx)
+
 	logrus.Info("Server running at PORT ", addr)
 	if err := httpSrv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 		logrus.Fatalf("Start %v", err)
@@ -54,6 +103,9 @@ func (srv *Server) Start() {
 }
 
 func (srv *Server) Stop() {
+	if srv.stopJobs != nil {
+		srv.stopJobs()
+	}
 	logrus.Info("closing Postgres...")
 	_ = srv.PSQL.DB().Close()
 	//_ = srv.PSQLC.DB().Close()
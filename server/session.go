@@ -0,0 +1,164 @@
+package server
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi"
+	"github.com/sirupsen/logrus"
+	"github.com/vijaygniit/ApnaSabji/models"
+	"github.com/vijaygniit/ApnaSabji/providers/authProvider"
+	"github.com/vijaygniit/ApnaSabji/scmerrors"
+	"github.com/vijaygniit/ApnaSabji/utils"
+	"github.com/volatiletech/null"
+)
+
+// RefreshToken
+
+func (srv *Server) refreshToken(resp http.ResponseWriter, req *http.Request) {
+	var refreshReq models.RefreshTokenRequest
+	if err := json.NewDecoder(req.Body).Decode(&refreshReq); err != nil {
+		logrus.Error("refreshToken: unable to decode request body ", err)
+		scmerrors.RespondClientErr(resp, err, http.StatusBadRequest, "Error refreshing token", "Error parsing request")
+		return
+	}
+
+	if refreshReq.RefreshToken == "" {
+		scmerrors.RespondClientErr(resp, errors.New("refresh token can not be empty"), http.StatusBadRequest, "Please login again", "refreshToken can not be empty")
+		return
+	}
+
+	session, newRefreshToken, err := srv.DBHelper.RotateRefreshToken(refreshReq.RefreshToken, authProvider.RefreshTokenTTL(), authProvider.AccessTokenTTL())
+	if err != nil {
+		logrus.Error("refreshToken: error rotating refresh token ", err)
+		srv.recordAuthEvent(req, models.AuthEvent{
+			UserID:    null.NewInt(session.UserID, session.UserID != 0),
+			EventType: models.AuthEventTokenRefresh,
+			Outcome:   models.AuthEventFailure,
+			Reason:    err.Error(),
+		})
+		scmerrors.RespondRefreshTokenErr(resp, err)
+		return
+	}
+
+	userInfo, err := srv.DBHelper.FetchUserData(session.UserID)
+	if err != nil {
+		logrus.Error("refreshToken: error getting user info ", err)
+		scmerrors.RespondGenericServerErr(resp, err, "error in getting user info")
+		return
+	}
+
+	device := models.CreateSessionRequest{
+		Platform:  session.Platform,
+		ModelName: session.ModelName,
+		OSVersion: session.OSVersion,
+		DeviceID:  session.DeviceID,
+	}
+
+	token, err := srv.generateAccessToken(userInfo, session.UUIDToken, device)
+	if err != nil {
+		logrus.Error("refreshToken: error generating JWT ", err)
+		scmerrors.RespondGenericServerErr(resp, err, "error generating token")
+		return
+	}
+
+	srv.recordAuthEvent(req, models.AuthEvent{
+		UserID:               null.IntFrom(session.UserID),
+		EventType:            models.AuthEventTokenRefresh,
+		Outcome:              models.AuthEventSuccess,
+		CreateSessionRequest: device,
+	})
+
+	utils.EncodeJSONBody(resp, http.StatusOK, map[string]interface{}{
+		"token":        token,
+		"refreshToken": newRefreshToken,
+	})
+}
+
+// Logout
+
+func (srv *Server) logout(resp http.ResponseWriter, req *http.Request) {
+	uc := srv.MiddlewareProvider.UserFromContext(req.Context())
+
+	if err := srv.DBHelper.EndSession(uc.SessionID); err != nil {
+		logrus.Error("logout: error ending session ", err)
+		scmerrors.RespondGenericServerErr(resp, err, "error ending session")
+		return
+	}
+
+	srv.recordAuthEvent(req, models.AuthEvent{UserID: null.IntFrom(uc.UserID), EventType: models.AuthEventLogout, Outcome: models.AuthEventSuccess})
+
+	utils.EncodeJSONBody(resp, http.StatusOK, map[string]interface{}{
+		"message": "success",
+	})
+}
+
+func (srv *Server) listSessions(resp http.ResponseWriter, req *http.Request) {
+	uc := srv.MiddlewareProvider.UserFromContext(req.Context())
+
+	activeSessions, err := srv.DBHelper.ListActiveSessions(uc.UserID, uc.SessionID)
+	if err != nil {
+		logrus.Error("listSessions: error getting active sessions ", err)
+		scmerrors.RespondGenericServerErr(resp, err, "error getting sessions")
+		return
+	}
+
+	utils.EncodeJSONBody(resp, http.StatusOK, map[string]interface{}{
+		"sessions": activeSessions,
+	})
+}
+
+func (srv *Server) revokeSession(resp http.ResponseWriter, req *http.Request) {
+	uc := srv.MiddlewareProvider.UserFromContext(req.Context())
+
+	sessionID, err := strconv.Atoi(chi.URLParam(req, "id"))
+	if err != nil {
+		scmerrors.RespondClientErr(resp, err, http.StatusBadRequest, "Invalid session", "session id must be an integer")
+		return
+	}
+
+	if err := srv.DBHelper.RevokeUserSession(uc.UserID, sessionID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			scmerrors.RespondClientErr(resp, err, http.StatusNotFound, "Session not found", "no active session with this id for the user")
+			return
+		}
+		logrus.Error("revokeSession: error revoking session ", err)
+		scmerrors.RespondGenericServerErr(resp, err, "error revoking session")
+		return
+	}
+
+	srv.recordAuthEvent(req, models.AuthEvent{
+		UserID:    null.IntFrom(uc.UserID),
+		EventType: models.AuthEventSessionRevoke,
+		Outcome:   models.AuthEventSuccess,
+		Reason:    "session " + strconv.Itoa(sessionID),
+	})
+
+	utils.EncodeJSONBody(resp, http.StatusOK, map[string]interface{}{
+		"message": "success",
+	})
+}
+
+func (srv *Server) revokeAllSessions(resp http.ResponseWriter, req *http.Request) {
+	uc := srv.MiddlewareProvider.UserFromContext(req.Context())
+
+	if err := srv.DBHelper.RevokeOtherSessions(uc.UserID, uc.SessionID); err != nil {
+		logrus.Error("revokeAllSessions: error revoking sessions ", err)
+		scmerrors.RespondGenericServerErr(resp, err, "error revoking sessions")
+		return
+	}
+
+	srv.recordAuthEvent(req, models.AuthEvent{UserID: null.IntFrom(uc.UserID), EventType: models.AuthEventSessionsRevokeAll, Outcome: models.AuthEventSuccess})
+
+	utils.EncodeJSONBody(resp, http.StatusOK, map[string]interface{}{
+		"message": "success",
+	})
+}
+
+// JWKS publishes the public signing keys so other services can verify our tokens.
+func (srv *Server) jwks(resp http.ResponseWriter, req *http.Request) {
+	utils.EncodeJSONBody(resp, http.StatusOK, srv.SigningKeys.JWKS())
+}
@@ -0,0 +1,166 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/vijaygniit/ApnaSabji/models"
+	"github.com/vijaygniit/ApnaSabji/scmerrors"
+	"github.com/vijaygniit/ApnaSabji/utils"
+)
+
+const maxSKULength = 64
+
+// validateVariant normalizes a new pack and responds with the first problem found.
+func validateVariant(resp http.ResponseWriter, variantReq *models.CreateVariantRequest) bool {
+	variantReq.SKU = strings.ToUpper(strings.TrimSpace(variantReq.SKU))
+	variantReq.Barcode = strings.TrimSpace(variantReq.Barcode)
+	variantReq.UnitCode = strings.TrimSpace(variantReq.UnitCode)
+
+	if variantReq.SKU == "" || len(variantReq.SKU) > maxSKULength {
+		scmerrors.RespondClientErr(resp, errors.New("invalid sku"), http.StatusBadRequest, "Please enter a SKU", "sku is required and at most 64 characters")
+		return false
+	}
+
+	if variantReq.PackSize == 0 {
+		variantReq.PackSize = 1
+	}
+	if variantReq.PackSize < 0 {
+		scmerrors.RespondCatalogErr(resp, scmerrors.ErrInvalidPackSize)
+		return false
+	}
+
+	if variantReq.UnitCode == "" {
+		scmerrors.RespondCatalogErr(resp, scmerrors.ErrUnknownUnit)
+		return false
+	}
+
+	if variantReq.PricePaise < 0 || variantReq.PricePaise > variantReq.MRPPaise {
+		scmerrors.RespondCatalogErr(resp, scmerrors.ErrInvalidCatalogPrice)
+		return false
+	}
+
+	if variantReq.StockQuantity < 0 {
+		scmerrors.RespondClientErr(resp, errors.New("invalid stock"), http.StatusBadRequest, "Stock can not be negative", "stockQuantity must be zero or more")
+		return false
+	}
+	return true
+}
+
+func (srv *Server) createVariant(resp http.ResponseWriter, req *http.Request) {
+	productID, ok := catalogIDFromURL(resp, req, "product")
+	if !ok {
+		return
+	}
+
+	var variantReq models.CreateVariantRequest
+	if err := json.NewDecoder(req.Body).Decode(&variantReq); err != nil {
+		scmerrors.RespondClientErr(resp, err, http.StatusBadRequest, "Error creating variant", "Error parsing request")
+		return
+	}
+
+	if !validateVariant(resp, &variantReq) {
+		return
+	}
+
+	variant, err := srv.DBHelper.CreateProductVariant(productID, variantReq)
+	if err != nil {
+		scmerrors.RespondCatalogErr(resp, err)
+		return
+	}
+
+	utils.EncodeJSONBody(resp, http.StatusCreated, map[string]interface{}{
+		"variant": variant,
+	})
+}
+
+func (srv *Server) updateVariant(resp http.ResponseWriter, req *http.Request) {
+	uc := srv.MiddlewareProvider.UserFromContext(req.Context())
+
+	variantID, ok := catalogIDFromURL(resp, req, "variant")
+	if !ok {
+		return
+	}
+
+	var variantReq models.UpdateVariantRequest
+	if err := json.NewDecoder(req.Body).Decode(&variantReq); err != nil {
+		scmerrors.RespondClientErr(resp, err, http.StatusBadRequest, "Error updating variant", "Error parsing request")
+		return
+	}
+
+	if variantReq.SKU.Valid {
+		variantReq.SKU.String = strings.ToUpper(strings.TrimSpace(variantReq.SKU.String))
+		if variantReq.SKU.String == "" || len(variantReq.SKU.String) > maxSKULength {
+			scmerrors.RespondClientErr(resp, errors.New("invalid sku"), http.StatusBadRequest, "Please enter a SKU", "sku is required and at most 64 characters")
+			return
+		}
+	}
+
+	if variantReq.PackSize.Valid && variantReq.PackSize.Float64 <= 0 {
+		scmerrors.RespondCatalogErr(resp, scmerrors.ErrInvalidPackSize)
+		return
+	}
+
+	if variantReq.StockQuantity.Valid && variantReq.StockQuantity.Int < 0 {
+		scmerrors.RespondClientErr(resp, errors.New("invalid stock"), http.StatusBadRequest, "Stock can not be negative", "stockQuantity must be zero or more")
+		return
+	}
+
+	variant, err := srv.DBHelper.UpdateProductVariant(variantID, variantReq, uc.UserID)
+	if err != nil {
+		scmerrors.RespondCatalogErr(resp, err)
+		return
+	}
+
+	utils.EncodeJSONBody(resp, http.StatusOK, map[string]interface{}{
+		"variant": variant,
+	})
+}
+
+func (srv *Server) deleteVariant(resp http.ResponseWriter, req *http.Request) {
+	variantID, ok := catalogIDFromURL(resp, req, "variant")
+	if !ok {
+		return
+	}
+
+	if err := srv.DBHelper.DeleteProductVariant(variantID); err != nil {
+		scmerrors.RespondCatalogErr(resp, err)
+		return
+	}
+
+	utils.EncodeJSONBody(resp, http.StatusOK, map[string]interface{}{
+		"message": "success",
+	})
+}
+
+// adjustVariantStock records packs received or written off, the stock count itself is never sent
+// so two people updating at once can not overwrite each other.
+func (srv *Server) adjustVariantStock(resp http.ResponseWriter, req *http.Request) {
+	variantID, ok := catalogIDFromURL(resp, req, "variant")
+	if !ok {
+		return
+	}
+
+	var stockReq models.AdjustStockRequest
+	if err := json.NewDecoder(req.Body).Decode(&stockReq); err != nil {
+		scmerrors.RespondClientErr(resp, err, http.StatusBadRequest, "Error updating stock", "Error parsing request")
+		return
+	}
+
+	if stockReq.Delta == 0 {
+		scmerrors.RespondClientErr(resp, errors.New("delta is required"), http.StatusBadRequest, "Error updating stock", "delta can not be zero")
+		return
+	}
+
+	stockQuantity, err := srv.DBHelper.AdjustVariantStock(variantID, stockReq.Delta)
+	if err != nil {
+		scmerrors.RespondCatalogErr(resp, err)
+		return
+	}
+
+	utils.EncodeJSONBody(resp, http.StatusOK, map[string]interface{}{
+		"stockQuantity": stockQuantity,
+	})
+}
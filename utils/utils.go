@@ -2,9 +2,17 @@ package utils
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
 	"net/http"
+	"net/mail"
+	"os"
+	"strconv"
+	"strings"
 
 	"github.com/sirupsen/logrus"
+	"github.com/ttacon/libphonenumber"
 )
 
 func EncodeJSONBody(resp http.ResponseWriter, statusCode int, data interface{}) {
@@ -15,3 +23,138 @@ func EncodeJSONBody(resp http.ResponseWriter, statusCode int, data interface{})
 		logrus.Errorf("EncodeJSONBody : Error encoding response %v", err)
 	}
 }
+
+// GetEnvInt reads an integer from the environment, falling back to defaultValue when the key is unset or invalid.
+func GetEnvInt(key string, defaultValue int) int {
+	value, ok := os.LookupEnv(key)
+	if !ok || value == "" {
+		return defaultValue
+	}
+
+	intValue, err := strconv.Atoi(value)
+	if err != nil {
+		logrus.Errorf("GetEnvInt: invalid value %q for %s, using default %d", value, key, defaultValue)
+		return defaultValue
+	}
+
+	return intValue
+}
+
+// trustedProxies are the networks of the load balancers in front of the server, set once at start up.
+var trustedProxies []*net.IPNet
+
+// SetTrustedProxies sets the comma separated CIDRs whose X-Forwarded-For and X-Real-IP headers ClientIP
+// believes. A bare IP is taken as a single address.
+func SetTrustedProxies(cidrs string) error {
+	networks := make([]*net.IPNet, 0)
+	for _, cidr := range strings.Split(cidrs, ",") {
+		cidr = strings.TrimSpace(cidr)
+		if cidr == "" {
+			continue
+		}
+		if !strings.Contains(cidr, "/") {
+			ip := net.ParseIP(cidr)
+			if ip == nil {
+				return fmt.Errorf("invalid trusted proxy %q", cidr)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return fmt.Errorf("invalid trusted proxy %q: %w", cidr, err)
+		}
+		networks = append(networks, network)
+	}
+	trustedProxies = networks
+	return nil
+}
+
+func isTrustedProxy(ip net.IP) bool {
+	for _, network := range trustedProxies {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// ClientIP returns the socket address unless it is a trusted proxy. Behind one, X-Forwarded-For is read
+// from the right and the first hop that is not a trusted proxy is the client, hops to its left are
+// whatever the client chose to send.
+func ClientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+
+	peer := net.ParseIP(host)
+	if peer == nil || !isTrustedProxy(peer) {
+		return host
+	}
+
+	hops := make([]string, 0)
+	for _, forwarded := range r.Header.Values("X-Forwarded-For") {
+		hops = append(hops, strings.Split(forwarded, ",")...)
+	}
+	if len(hops) == 0 {
+		if realIP := net.ParseIP(strings.TrimSpace(r.Header.Get("X-Real-IP"))); realIP != nil {
+			return realIP.String()
+		}
+		return host
+	}
+
+	client := peer
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := net.ParseIP(strings.TrimSpace(hops[i]))
+		if hop == nil {
+			break
+		}
+		client = hop
+		if !isTrustedProxy(hop) {
+			break
+		}
+	}
+	return client.String()
+}
+
+// ErrInvalidEmail is returned by NormalizeEmail for anything but a bare address such as ravi@example.com.
+var ErrInvalidEmail = errors.New("invalid email address")
+
+// NormalizeEmail validates an email address and returns it trimmed and lower cased, the form it is stored
+// and looked up in.
+func NormalizeEmail(rawEmail string) (string, error) {
+	email := strings.TrimSpace(rawEmail)
+	address, err := mail.ParseAddress(email)
+	if err != nil || address.Address != email || !strings.Contains(email[strings.LastIndex(email, "@"):], ".") {
+		return "", ErrInvalidEmail
+	}
+	return strings.ToLower(email), nil
+}
+
+// NormalizePhoneNumber validates a phone number (defaulting to the IN region) and returns it in E.164 format.
+func NormalizePhoneNumber(rawPhoneNumber string) (string, error) {
+	uncleanPhoneNumber := rawPhoneNumber
+
+	// Extract the phone number from the format "+91 XXXXX"
+	if strings.Count(uncleanPhoneNumber, "+") == 2 {
+		uncleanPhoneNumber = uncleanPhoneNumber[strings.LastIndex(uncleanPhoneNumber, "+")+1:]
+	}
+
+	phone := strings.ReplaceAll(uncleanPhoneNumber, " ", "")
+
+	num, err := libphonenumber.Parse(phone, "IN")
+	if err != nil {
+		return "", err
+	}
+
+	if !libphonenumber.IsValidNumber(num) {
+		return "", errors.New("invalid phone number")
+	}
+
+	return libphonenumber.Format(num, libphonenumber.E164), nil
+}
@@ -0,0 +1,84 @@
+package utils
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+	if err := SetTrustedProxies("10.0.0.0/8, 192.168.1.10"); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		trustedProxies = nil
+	}()
+
+	tests := []struct {
+		name          string
+		remoteAddr    string
+		xForwardedFor []string
+		xRealIP       string
+		want          string
+	}{
+		{"direct client", "203.0.113.7:4000", nil, "", "203.0.113.7"},
+		{"headers from an untrusted peer are ignored", "203.0.113.7:4000", []string{"198.51.100.1"}, "198.51.100.2", "203.0.113.7"},
+		{"trusted proxy", "10.1.2.3:4000", []string{"198.51.100.1"}, "", "198.51.100.1"},
+		{"spoofed leftmost hop", "10.1.2.3:4000", []string{"1.2.3.4, 198.51.100.1"}, "", "198.51.100.1"},
+		{"chain of trusted proxies", "10.1.2.3:4000", []string{"1.2.3.4, 198.51.100.1, 192.168.1.10, 10.9.9.9"}, "", "198.51.100.1"},
+		{"repeated headers", "10.1.2.3:4000", []string{"1.2.3.4", "198.51.100.1"}, "", "198.51.100.1"},
+		{"only trusted hops", "10.1.2.3:4000", []string{"10.4.4.4"}, "", "10.4.4.4"},
+		{"garbage hop", "10.1.2.3:4000", []string{"not-an-ip"}, "", "10.1.2.3"},
+		{"x-real-ip from a trusted proxy", "192.168.1.10:4000", nil, "198.51.100.2", "198.51.100.2"},
+		{"untrusted neighbour of a trusted address", "192.168.1.11:4000", []string{"198.51.100.1"}, "", "192.168.1.11"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/", nil)
+			req.RemoteAddr = tt.remoteAddr
+			for _, forwarded := range tt.xForwardedFor {
+				req.Header.Add("X-Forwarded-For", forwarded)
+			}
+			if tt.xRealIP != "" {
+				req.Header.Set("X-Real-IP", tt.xRealIP)
+			}
+			if got := ClientIP(req); got != tt.want {
+				t.Errorf("ClientIP() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSetTrustedProxiesRejectsInvalid(t *testing.T) {
+	if err := SetTrustedProxies("10.0.0.0/33"); err == nil {
+		t.Error("SetTrustedProxies() accepted an invalid CIDR")
+	}
+	if err := SetTrustedProxies("proxy.internal"); err == nil {
+		t.Error("SetTrustedProxies() accepted a hostname")
+	}
+	trustedProxies = nil
+}
+
+func TestNormalizeEmail(t *testing.T) {
+	tests := []struct {
+		email   string
+		want    string
+		wantErr bool
+	}{
+		{"Ravi@Example.com", "ravi@example.com", false},
+		{"  ravi.kumar+veg@example.co.in ", "ravi.kumar+veg@example.co.in", false},
+		{"", "", true},
+		{"ravi", "", true},
+		{"ravi@", "", true},
+		{"ravi@localhost", "", true},
+		{"Ravi <ravi@example.com>", "", true},
+		{"ravi@example.com, priya@example.com", "", true},
+	}
+
+	for _, tt := range tests {
+		got, err := NormalizeEmail(tt.email)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("NormalizeEmail(%q) = %q, %v, want %q, error %v", tt.email, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
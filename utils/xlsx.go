@@ -0,0 +1,321 @@
+package utils
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrInvalidXLSX  = errors.New("file is not a valid xlsx workbook")
+	ErrXLSXTooLarge = errors.New("workbook is too large")
+)
+
+type xlsxText struct {
+	T    string `xml:"t"`
+	Runs []struct {
+		T string `xml:"t"`
+	} `xml:"r"`
+}
+
+// text joins the runs of rich text, which spreadsheets write once a cell has mixed formatting.
+func (text xlsxText) text() string {
+	if len(text.Runs) == 0 {
+		return text.T
+	}
+
+	var builder strings.Builder
+	for _, run := range text.Runs {
+		builder.WriteString(run.T)
+	}
+	return builder.String()
+}
+
+type xlsxWorksheet struct {
+	Rows []struct {
+		R     int `xml:"r,attr"`
+		Cells []struct {
+			R      string   `xml:"r,attr"`
+			T      string   `xml:"t,attr"`
+			V      string   `xml:"v"`
+			Inline xlsxText `xml:"is"`
+		} `xml:"c"`
+	} `xml:"sheetData>row"`
+}
+
+// xlsxBudgetReader fails once the parts of a workbook unzip to more than the budget, so a small zip
+// can not expand into gigabytes.
+type xlsxBudgetReader struct {
+	reader    io.Reader
+	remaining *int64
+}
+
+func (budget xlsxBudgetReader) Read(p []byte) (int, error) {
+	if *budget.remaining <= 0 {
+		return 0, ErrXLSXTooLarge
+	}
+	if int64(len(p)) > *budget.remaining {
+		p = p[:*budget.remaining]
+	}
+	n, err := budget.reader.Read(p)
+	*budget.remaining -= int64(n)
+	return n, err
+}
+
+func readXLSXPart(files map[string]*zip.File, name string, remaining *int64, v interface{}) error {
+	file, ok := files[name]
+	if !ok {
+		return fmt.Errorf("%w: %s is missing", ErrInvalidXLSX, name)
+	}
+
+	reader, err := file.Open()
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidXLSX, err)
+	}
+	defer func() {
+		_ = reader.Close()
+	}()
+
+	if err := xml.NewDecoder(xlsxBudgetReader{reader: reader, remaining: remaining}).Decode(v); err != nil {
+		if errors.Is(err, ErrXLSXTooLarge) {
+			return err
+		}
+		return fmt.Errorf("%w: %s: %v", ErrInvalidXLSX, name, err)
+	}
+	return nil
+}
+
+// firstSheetPath follows the workbook relationships to the first sheet, which is not always sheet1.xml.
+func firstSheetPath(files map[string]*zip.File, remaining *int64) (string, error) {
+	var workbook struct {
+		Sheets []struct {
+			ID string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+		} `xml:"sheets>sheet"`
+	}
+	if err := readXLSXPart(files, "xl/workbook.xml", remaining, &workbook); err != nil {
+		return "", err
+	}
+	if len(workbook.Sheets) == 0 {
+		return "", fmt.Errorf("%w: workbook has no sheets", ErrInvalidXLSX)
+	}
+
+	var relationships struct {
+		Relationships []struct {
+			ID     string `xml:"Id,attr"`
+			Target string `xml:"Target,attr"`
+		} `xml:"Relationship"`
+	}
+	if err := readXLSXPart(files, "xl/_rels/workbook.xml.rels", remaining, &relationships); err != nil {
+		return "", err
+	}
+
+	for _, relationship := range relationships.Relationships {
+		if relationship.ID != workbook.Sheets[0].ID {
+			continue
+		}
+		if strings.HasPrefix(relationship.Target, "/") {
+			return strings.TrimPrefix(relationship.Target, "/"), nil
+		}
+		return path.Join("xl", relationship.Target), nil
+	}
+	return "", fmt.Errorf("%w: first sheet not found", ErrInvalidXLSX)
+}
+
+// xlsxColumn turns the letters of a cell reference such as "AB12" into a 0 based column.
+func xlsxColumn(reference string) int {
+	column := 0
+	for _, r := range reference {
+		if r < 'A' || r > 'Z' {
+			break
+		}
+		column = column*26 + int(r-'A') + 1
+	}
+	return column - 1
+}
+
+// xlsxNumber undoes binary floating point noise, so 12.3 saved as 12.300000000000001 reads back as 12.3.
+func xlsxNumber(value string) string {
+	number, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return value
+	}
+	number, _ = strconv.ParseFloat(strconv.FormatFloat(number, 'g', 15, 64), 64)
+	return strconv.FormatFloat(number, 'f', -1, 64)
+}
+
+// ReadXLSX returns the cells of the first sheet of a workbook as text, next to the row number the
+// spreadsheet shows for each row. Empty rows are left out. Workbooks unzipping to more than maxBytes
+// or with more than maxRows filled rows are refused with ErrXLSXTooLarge.
+func ReadXLSX(data []byte, maxBytes int64, maxRows int) (rows [][]string, lines []int, err error) {
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, nil, ErrInvalidXLSX
+	}
+
+	files := make(map[string]*zip.File, len(archive.File))
+	for _, file := range archive.File {
+		files[file.Name] = file
+	}
+
+	remaining := maxBytes
+	sheetPath, err := firstSheetPath(files, &remaining)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var sharedStrings struct {
+		Items []xlsxText `xml:"si"`
+	}
+	if _, ok := files["xl/sharedStrings.xml"]; ok {
+		if err := readXLSXPart(files, "xl/sharedStrings.xml", &remaining, &sharedStrings); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	var sheet xlsxWorksheet
+	if err := readXLSXPart(files, sheetPath, &remaining, &sheet); err != nil {
+		return nil, nil, err
+	}
+
+	line := 0
+	for _, sheetRow := range sheet.Rows {
+		line++
+		if sheetRow.R > 0 {
+			line = sheetRow.R
+		}
+
+		row := make([]string, 0, len(sheetRow.Cells))
+		for _, cell := range sheetRow.Cells {
+			column := len(row)
+			if cell.R != "" {
+				column = xlsxColumn(cell.R)
+			}
+			if column < 0 || column >= 16384 {
+				return nil, nil, fmt.Errorf("%w: invalid cell %s", ErrInvalidXLSX, cell.R)
+			}
+
+			var value string
+			switch cell.T {
+			case "s":
+				index, err := strconv.Atoi(cell.V)
+				if err != nil || index < 0 || index >= len(sharedStrings.Items) {
+					return nil, nil, fmt.Errorf("%w: invalid shared string in %s", ErrInvalidXLSX, cell.R)
+				}
+				value = sharedStrings.Items[index].text()
+			case "inlineStr":
+				value = cell.Inline.text()
+			case "", "n":
+				value = xlsxNumber(cell.V)
+			default:
+				value = cell.V
+			}
+
+			for len(row) < column {
+				row = append(row, "")
+			}
+			if column < len(row) {
+				row[column] = value
+			} else {
+				row = append(row, value)
+			}
+		}
+
+		if strings.TrimSpace(strings.Join(row, "")) == "" {
+			continue
+		}
+		if len(rows) == maxRows {
+			return nil, nil, fmt.Errorf("%w: more than %d rows", ErrXLSXTooLarge, maxRows)
+		}
+		rows = append(rows, row)
+		lines = append(lines, line)
+	}
+	return rows, lines, nil
+}
+
+// xlsxCellReference names the cell at a 0 based column and 1 based row, such as "AB12".
+func xlsxCellReference(column, row int) string {
+	letters := ""
+	for column++; column > 0; column = (column - 1) / 26 {
+		letters = string(rune('A'+(column-1)%26)) + letters
+	}
+	return letters + strconv.Itoa(row)
+}
+
+const (
+	xlsxContentTypes = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types"><Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/><Default Extension="xml" ContentType="application/xml"/><Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/><Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/></Types>`
+	xlsxRootRelationships = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/></Relationships>`
+	xlsxWorkbook = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets><sheet name="%s" sheetId="1" r:id="rId1"/></sheets></workbook>`
+	xlsxWorkbookRelationships = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/></Relationships>`
+)
+
+func xmlEscape(text string) string {
+	var buffer bytes.Buffer
+	_ = xml.EscapeText(&buffer, []byte(text))
+	return buffer.String()
+}
+
+// WriteXLSX writes rows as the only sheet of a workbook. Cells of numericColumns are stored as numbers
+// so spreadsheets can sum them, every other cell as text.
+func WriteXLSX(w io.Writer, sheetName string, rows [][]string, numericColumns map[int]bool) error {
+	archive := zip.NewWriter(w)
+
+	parts := []struct {
+		name    string
+		content string
+	}{
+		{"[Content_Types].xml", xlsxContentTypes},
+		{"_rels/.rels", xlsxRootRelationships},
+		{"xl/workbook.xml", fmt.Sprintf(xlsxWorkbook, xmlEscape(sheetName))},
+		{"xl/_rels/workbook.xml.rels", xlsxWorkbookRelationships},
+	}
+	for _, part := range parts {
+		partWriter, err := archive.Create(part.name)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(partWriter, part.content); err != nil {
+			return err
+		}
+	}
+
+	sheetWriter, err := archive.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return err
+	}
+
+	var sheet strings.Builder
+	sheet.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>`)
+	sheet.WriteString(`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`)
+	for i, row := range rows {
+		fmt.Fprintf(&sheet, `<row r="%d">`, i+1)
+		for column, value := range row {
+			if value == "" {
+				continue
+			}
+
+			reference := xlsxCellReference(column, i+1)
+			if _, err := strconv.ParseFloat(value, 64); err == nil && i > 0 && numericColumns[column] {
+				fmt.Fprintf(&sheet, `<c r="%s"><v>%s</v></c>`, reference, value)
+				continue
+			}
+			fmt.Fprintf(&sheet, `<c r="%s" t="inlineStr"><is><t xml:space="preserve">%s</t></is></c>`, reference, xmlEscape(value))
+		}
+		sheet.WriteString(`</row>`)
+	}
+	sheet.WriteString(`</sheetData></worksheet>`)
+
+	if _, err := io.WriteString(sheetWriter, sheet.String()); err != nil {
+		return err
+	}
+	return archive.Close()
+}
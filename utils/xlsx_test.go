@@ -0,0 +1,185 @@
+package utils
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const (
+	testXLSXMaxBytes = 1 << 20
+	testXLSXMaxRows  = 100
+)
+
+// buildXLSX zips parts into a workbook the way a spreadsheet would save it.
+func buildXLSX(t *testing.T, parts map[string]string) []byte {
+	var buffer bytes.Buffer
+	archive := zip.NewWriter(&buffer)
+	for name, content := range parts {
+		partWriter, err := archive.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := partWriter.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := archive.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buffer.Bytes()
+}
+
+func TestWriteXLSXRoundTrip(t *testing.T) {
+	rows := [][]string{
+		{"name", "price", "note"},
+		{"Tomato <Hybrid> & Desi", "40.5", "  leading spaces"},
+		{"टमाटर", "12.3", ""},
+		{"", "7", "only the first cell is empty"},
+		{"0012", "100", "=SUM(A1:A2)"},
+	}
+
+	var buffer bytes.Buffer
+	if err := WriteXLSX(&buffer, "Catalog & Prices", rows, map[int]bool{1: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	got, lines, err := ReadXLSX(buffer.Bytes(), testXLSXMaxBytes, testXLSXMaxRows)
+	if err != nil {
+		t.Fatalf("ReadXLSX() error = %v", err)
+	}
+
+	want := [][]string{
+		{"name", "price", "note"},
+		{"Tomato <Hybrid> & Desi", "40.5", "  leading spaces"},
+		{"टमाटर", "12.3"},
+		{"", "7", "only the first cell is empty"},
+		{"0012", "100", "=SUM(A1:A2)"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ReadXLSX() rows = %q, want %q", got, want)
+	}
+	if !reflect.DeepEqual(lines, []int{1, 2, 3, 4, 5}) {
+		t.Errorf("ReadXLSX() lines = %v", lines)
+	}
+}
+
+func TestReadXLSX(t *testing.T) {
+	// the first sheet of the workbook is sheet2.xml, sheet1.xml is a second tab
+	parts := map[string]string{
+		"xl/workbook.xml": `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">
+<sheets><sheet name="Prices" sheetId="2" r:id="rId7"/><sheet name="Notes" sheetId="1" r:id="rId1"/></sheets></workbook>`,
+		"xl/_rels/workbook.xml.rels": `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>
+<Relationship Id="rId7" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="/xl/worksheets/sheet2.xml"/>
+</Relationships>`,
+		"xl/sharedStrings.xml": `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<sst xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" count="3" uniqueCount="3">
+<si><t>commodity</t></si>
+<si><t>modal_price</t></si>
+<si><r><rPr><b/></rPr><t>Onion </t></r><r><t xml:space="preserve">Red</t></r></si>
+</sst>`,
+		"xl/worksheets/sheet1.xml": `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>
+<row r="1"><c r="A1" t="inlineStr"><is><t>not this sheet</t></is></c></row>
+</sheetData></worksheet>`,
+		"xl/worksheets/sheet2.xml": `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>
+<row r="3"><c r="A3" t="s"><v>0</v></c><c r="B3" t="s"><v>1</v></c></row>
+<row r="4"><c r="A4" t="s"><v>2</v></c><c r="B4"><v>2150.0000000000005</v></c></row>
+<row r="5"></row>
+<row r="12"><c r="AB12" t="str"><v>far right</v></c></row>
+<row><c t="inlineStr"><is><t>no references</t></is></c><c><v>1</v></c></row>
+</sheetData></worksheet>`,
+	}
+
+	rows, lines, err := ReadXLSX(buildXLSX(t, parts), testXLSXMaxBytes, testXLSXMaxRows)
+	if err != nil {
+		t.Fatalf("ReadXLSX() error = %v", err)
+	}
+
+	farRight := make([]string, 28)
+	farRight[27] = "far right"
+	want := [][]string{
+		{"commodity", "modal_price"},
+		{"Onion Red", "2150"},
+		farRight,
+		{"no references", "1"},
+	}
+	if !reflect.DeepEqual(rows, want) {
+		t.Errorf("ReadXLSX() rows = %q, want %q", rows, want)
+	}
+	if !reflect.DeepEqual(lines, []int{3, 4, 12, 13}) {
+		t.Errorf("ReadXLSX() lines = %v, want [3 4 12 13]", lines)
+	}
+}
+
+func TestReadXLSXRejects(t *testing.T) {
+	workbook := func(sheet string) []byte {
+		var buffer bytes.Buffer
+		if err := WriteXLSX(&buffer, "Sheet", nil, nil); err != nil {
+			t.Fatal(err)
+		}
+		archive, err := zip.NewReader(bytes.NewReader(buffer.Bytes()), int64(buffer.Len()))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		parts := make(map[string]string)
+		for _, file := range archive.File {
+			reader, err := file.Open()
+			if err != nil {
+				t.Fatal(err)
+			}
+			var content bytes.Buffer
+			_, _ = content.ReadFrom(reader)
+			_ = reader.Close()
+			parts[file.Name] = content.String()
+		}
+		parts["xl/worksheets/sheet1.xml"] = sheet
+		return buildXLSX(t, parts)
+	}
+
+	sheetOf := func(rows int, cell string) string {
+		var sheet strings.Builder
+		sheet.WriteString(`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`)
+		for i := 0; i < rows; i++ {
+			sheet.WriteString(`<row><c t="inlineStr"><is><t>` + cell + `</t></is></c></row>`)
+		}
+		sheet.WriteString(`</sheetData></worksheet>`)
+		return sheet.String()
+	}
+
+	tests := []struct {
+		name string
+		data []byte
+		want error
+	}{
+		{"not a zip", []byte("product_name,price\nTomato,40\n"), ErrInvalidXLSX},
+		{"missing workbook", buildXLSX(t, map[string]string{"xl/worksheets/sheet1.xml": sheetOf(1, "x")}), ErrInvalidXLSX},
+		{"broken sheet", workbook(`<worksheet><sheetData><row>`), ErrInvalidXLSX},
+		{"unknown shared string", workbook(`<worksheet><sheetData><row><c r="A1" t="s"><v>3</v></c></row></sheetData></worksheet>`), ErrInvalidXLSX},
+		{"column past XFD", workbook(`<worksheet><sheetData><row><c r="XFE1"><v>1</v></c></row></sheetData></worksheet>`), ErrInvalidXLSX},
+		// compresses to a few kilobytes, unzips to more than the budget
+		{"oversized part", workbook(sheetOf(1, strings.Repeat("a", testXLSXMaxBytes))), ErrXLSXTooLarge},
+		{"too many rows", workbook(sheetOf(testXLSXMaxRows+1, "x")), ErrXLSXTooLarge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := ReadXLSX(tt.data, testXLSXMaxBytes, testXLSXMaxRows)
+			if !errors.Is(err, tt.want) {
+				t.Errorf("ReadXLSX() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+
+	if _, _, err := ReadXLSX(workbook(sheetOf(testXLSXMaxRows, "x")), testXLSXMaxBytes, testXLSXMaxRows); err != nil {
+		t.Errorf("ReadXLSX() with exactly the maximum rows error = %v", err)
+	}
+}